@@ -0,0 +1,232 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/cmd/utils"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/contracts/checkpointoracle"
+	"github.com/ethereum/go-ethereum/contracts/checkpointoracle/contract"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var commandDeploy = cli.Command{
+	Name:  "deploy",
+	Usage: "Deploy a new checkpoint oracle contract",
+	Flags: []cli.Flag{
+		nodeURLFlag,
+		keyFileFlag,
+		passwordFileFlag,
+		signersFlag,
+		thresholdFlag,
+	},
+	Action: utils.MigrateFlags(deploy),
+}
+
+var commandSign = cli.Command{
+	Name:  "sign",
+	Usage: "Sign the checkpoint with the specified key",
+	Flags: []cli.Flag{
+		nodeURLFlag,
+		keyFileFlag,
+		passwordFileFlag,
+		indexFlag,
+	},
+	Action: utils.MigrateFlags(sign),
+}
+
+var commandPublish = cli.Command{
+	Name:  "publish",
+	Usage: "Publish a checkpoint into the oracle",
+	Flags: []cli.Flag{
+		nodeURLFlag,
+		keyFileFlag,
+		passwordFileFlag,
+		indexFlag,
+		signaturesFlag,
+	},
+	Action: utils.MigrateFlags(publish),
+}
+
+// deploy deploys the checkpoint registrar contract.
+//
+// Note the network where the contract is deployed depends on
+// the network where the connected node is located.
+func deploy(ctx *cli.Context) error {
+	// Gather all the addresses that should be permitted to sign
+	var addrs []common.Address
+	for _, account := range strings.Split(ctx.String(signersFlag.Name), ",") {
+		if trimmed := strings.TrimSpace(account); !common.IsHexAddress(trimmed) {
+			utils.Fatalf("Invalid account in --signers: '%s'", trimmed)
+		}
+		addrs = append(addrs, common.HexToAddress(account))
+	}
+	// Retrieve and validate the signing threshold
+	needed := ctx.Int(thresholdFlag.Name)
+	if needed == 0 || needed > len(addrs) {
+		utils.Fatalf("Invalid signature threshold %d", needed)
+	}
+	// Print a summary to ensure the user understands what they're signing
+	fmt.Printf("Deploying new checkpoint oracle:\n\n")
+	for i, addr := range addrs {
+		fmt.Printf("Admin %d => %s\n", i+1, addr.Hex())
+	}
+	fmt.Printf("\nSignatures needed to publish: %d\n", needed)
+
+	// Deploy the checkpoint oracle
+	key := getKey(ctx)
+	client := newClient(ctx)
+
+	fmt.Println("Sending deploy request to Ethereum node...")
+	oracle, tx, _, err := contract.DeployCheckpointOracle(bind.NewKeyedTransactor(key.PrivateKey), client, addrs,
+		big.NewInt(int64(params.CHTFrequencyClient)), big.NewInt(int64(params.HelperTrieProcessConfirmations)), big.NewInt(int64(needed)))
+	if err != nil {
+		utils.Fatalf("Failed to deploy checkpoint oracle %v", err)
+	}
+	log.Info("Deployed checkpoint oracle", "address", oracle, "tx", tx.Hash().Hex())
+
+	return nil
+}
+
+// sign creates the signature for specific checkpoint with local key. Only
+// contract admins have the permission to sign checkpoint.
+func sign(ctx *cli.Context) error {
+	var (
+		client     = newRPCClient(ctx.GlobalString(nodeURLFlag.Name))
+		addr, _    = newContract(client, ctx)
+		checkpoint = getCheckpoint(ctx, client)
+		key        = getKey(ctx)
+	)
+	if checkpoint.Empty() {
+		utils.Fatalf("Local checkpoint of section %d is not available", checkpoint.SectionIndex)
+	}
+	fmt.Printf("Oracle     => %s\n", addr.Hex())
+	fmt.Printf("Index %4d => %s\n", checkpoint.SectionIndex, checkpoint.Hash().Hex())
+
+	sig, err := crypto.Sign(checkpointoracle.SignedHash(addr, checkpoint.SectionIndex, checkpoint.Hash()).Bytes(), key.PrivateKey)
+	if err != nil {
+		utils.Fatalf("Failed to sign checkpoint, err %v", err)
+	}
+	sig[64] += 27 // Transform V from 0/1 to 27/28 according to the yellow paper
+
+	fmt.Printf("Signer     => %s\n", key.Address.Hex())
+	fmt.Printf("Signature  => %s\n", hexutil.Encode(sig))
+	return nil
+}
+
+// sighash calculates the hash of the data to sign for the checkpoint oracle.
+func sighash(index uint64, oracle common.Address, hash common.Hash) []byte {
+	return checkpointoracle.SignedHash(oracle, index, hash).Bytes()
+}
+
+// ecrecover calculates the sender address from a sighash and signature combo.
+func ecrecover(sighash []byte, sig []byte) (common.Address, error) {
+	if len(sig) != 65 {
+		return common.Address{}, errors.New("invalid signature length")
+	}
+	sig = common.CopyBytes(sig)
+	sig[64] -= 27
+	signer, err := crypto.SigToPub(sighash, sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*signer), nil
+}
+
+// publish registers the specified checkpoint which generated by connected node
+// with a authorised private key.
+func publish(ctx *cli.Context) error {
+	// Print the checkpoint oracle's current status to make sure we're interacting
+	// with the correct network and contract.
+	if err := status(ctx); err != nil {
+		return err
+	}
+
+	// Gather the signatures from the CLI
+	var sigs [][]byte
+	for _, sig := range strings.Split(ctx.String(signaturesFlag.Name), ",") {
+		trimmed := strings.TrimPrefix(strings.TrimSpace(sig), "0x")
+		if len(trimmed) != 130 {
+			utils.Fatalf("Invalid signature in --signatures: '%s'", trimmed)
+		}
+		sigs = append(sigs, common.Hex2Bytes(trimmed))
+	}
+	// Retrieve the checkpoint we want to sign to sort the signatures
+	var (
+		client       = newRPCClient(ctx.GlobalString(nodeURLFlag.Name))
+		addr, oracle = newContract(client, ctx)
+		checkpoint   = getCheckpoint(ctx, client)
+		sighash      = sighash(checkpoint.SectionIndex, addr, checkpoint.Hash())
+	)
+	// The oracle only accepts signatures in ascending signer order
+	sort.Slice(sigs, func(i, j int) bool {
+		signerA, _ := ecrecover(sighash, sigs[i])
+		signerB, _ := ecrecover(sighash, sigs[j])
+		return bytes.Compare(signerA.Bytes(), signerB.Bytes()) < 0
+	})
+	// Retrieve recent header info to protect replay attack
+	reqCtx, cancelFn := context.WithTimeout(context.Background(), 10*time.Second)
+	head, err := ethclient.NewClient(client).HeaderByNumber(reqCtx, nil)
+	cancelFn()
+	if err != nil {
+		return err
+	}
+	// Refer to a block deep enough to survive small reorgs, but still within
+	// the 256 most recent blocks accessible to the contract.
+	num := head.Number.Uint64()
+	if num > 128 {
+		num -= 128
+	}
+	recent, err := ethclient.NewClient(client).HeaderByNumber(context.Background(), new(big.Int).SetUint64(num))
+	if err != nil {
+		return err
+	}
+	// Print a summary of the operation that's going to be performed
+	fmt.Printf("Publishing %d => %s:\n\n", checkpoint.SectionIndex, checkpoint.Hash().Hex())
+	for i, sig := range sigs {
+		signer, err := ecrecover(sighash, sig)
+		if err != nil {
+			utils.Fatalf("Invalid signature %d: %v", i+1, err)
+		}
+		fmt.Printf("Signer %d => %s\n", i+1, signer.Hex())
+	}
+	fmt.Println()
+	fmt.Printf("Sentry number => %d\nSentry hash   => %s\n", recent.Number, recent.Hash().Hex())
+
+	// Publish the checkpoint into the oracle
+	tx, err := oracle.RegisterCheckpoint(bind.NewKeyedTransactor(getKey(ctx).PrivateKey), checkpoint.SectionIndex, checkpoint.Hash().Bytes(), recent.Number, recent.Hash(), sigs)
+	if err != nil {
+		utils.Fatalf("Register contract failed %v", err)
+	}
+	log.Info("Successfully registered checkpoint", "tx", tx.Hash().Hex())
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"bytes"
+	"math/big"
+	"net"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/internal/utesting"
+	"github.com/ethereum/go-ethereum/p2p"
+)
+
+// Conn is a connection to the node under test.
+type Conn struct {
+	*p2p.RLPxConn
+}
+
+// dial connects to the node under test using a fresh node key.
+func (s *Suite) dial(t *utesting.T) *Conn {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := p2p.DialRLPx(s.Dest, key, eth.ProtocolName, eth.ProtocolVersions)
+	if err != nil {
+		t.Fatal("dial error:", err)
+	}
+	c.ReadTimeout = timeout
+	return &Conn{c}
+}
+
+// send encodes and sends a message.
+func (c *Conn) send(t *utesting.T, code uint64, data interface{}) {
+	if err := p2p.Send(c, code, data); err != nil {
+		t.Fatal("write error:", err)
+	}
+}
+
+// sendRaw sends a message with the given payload.
+func (c *Conn) sendRaw(t *utesting.T, code uint64, payload []byte) {
+	msg := p2p.Msg{Code: code, Size: uint32(len(payload)), Payload: bytes.NewReader(payload)}
+	if err := c.WriteMsg(msg); err != nil {
+		t.Fatal("write error:", err)
+	}
+}
+
+// expect reads messages until one with the given code arrives. Announcements sent by
+// the node are skipped, and its header requests are answered with an empty response.
+func (c *Conn) expect(t *utesting.T, code uint64) p2p.Msg {
+	for {
+		msg, err := c.ReadMsg()
+		if err != nil {
+			t.Fatalf("read error while waiting for message %d: %v", code, err)
+		}
+		switch {
+		case msg.Code == code:
+			return msg
+		case msg.Code == eth.GetBlockHeadersMsg:
+			msg.Discard()
+			c.send(t, eth.BlockHeadersMsg, BlockHeaders{})
+		case isAnnouncement(msg.Code):
+			msg.Discard()
+		default:
+			msg.Discard()
+			t.Fatalf("unexpected message %d while waiting for message %d", msg.Code, code)
+		}
+	}
+}
+
+// expectDisconnect checks that the node drops the connection.
+func (c *Conn) expectDisconnect(t *utesting.T) {
+	for {
+		msg, err := c.ReadMsg()
+		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+			t.Fatal("node did not disconnect")
+		} else if err != nil {
+			t.Log("disconnected:", err)
+			return
+		}
+		msg.Discard()
+	}
+}
+
+// readStatus reads the status message of the node.
+func (c *Conn) readStatus(t *utesting.T) *Status {
+	msg := c.expect(t, eth.StatusMsg)
+	var status Status
+	if err := msg.Decode(&status); err != nil {
+		t.Fatal("invalid status message:", err)
+	}
+	return &status
+}
+
+// handshake performs the eth protocol handshake. The status sent to the node
+// mirrors the node's own status.
+func (c *Conn) handshake(t *utesting.T) *Status {
+	status := c.readStatus(t)
+	c.send(t, eth.StatusMsg, c.ourStatus(status))
+	return status
+}
+
+// ourStatus creates a status which is acceptable to the node, i.e. for the same
+// network and chain. The announced head is the genesis block, so the node doesn't
+// attempt to sync from the test.
+func (c *Conn) ourStatus(their *Status) *Status {
+	return &Status{
+		ProtocolVersion: uint32(c.Version()),
+		NetworkId:       their.NetworkId,
+		TD:              big.NewInt(1),
+		CurrentBlock:    their.GenesisBlock,
+		GenesisBlock:    their.GenesisBlock,
+	}
+}
+
+// requestHeaders sends a header query and waits for the response.
+func (c *Conn) requestHeaders(t *utesting.T, query interface{}) BlockHeaders {
+	c.send(t, eth.GetBlockHeadersMsg, query)
+	msg := c.expect(t, eth.BlockHeadersMsg)
+	var headers BlockHeaders
+	if err := msg.Decode(&headers); err != nil {
+		t.Fatal("invalid block headers message:", err)
+	}
+	return headers
+}
+
+// protocolLength returns the number of message codes of the negotiated protocol version.
+func (c *Conn) protocolLength() uint64 {
+	for i, v := range eth.ProtocolVersions {
+		if v == c.Version() {
+			return eth.ProtocolLengths[i]
+		}
+	}
+	return 0
+}
+
+func isAnnouncement(code uint64) bool {
+	return code == eth.NewBlockHashesMsg || code == eth.NewBlockMsg || code == eth.TxMsg
+}
@@ -0,0 +1,271 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ethtest contains conformance tests for the eth protocol.
+package ethtest
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/eth"
+	"github.com/ethereum/go-ethereum/internal/utesting"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// timeout is the read timeout for messages from the node.
+const timeout = 5 * time.Second
+
+// maxHeaderRange is the number of headers requested in range queries.
+const maxHeaderRange = 8
+
+// invalidRLP is a message payload which is not valid RLP.
+var invalidRLP = []byte{0xf8, 0x80, 0x01}
+
+// Suite runs eth protocol tests against a single node. Every test uses a new
+// connection with a fresh node key, so that nodes banning misbehaving peers
+// don't affect later tests.
+type Suite struct {
+	Dest *enode.Node
+}
+
+// NewSuite creates a test suite for the given node.
+func NewSuite(dest *enode.Node) *Suite {
+	return &Suite{Dest: dest}
+}
+
+// AllTests returns all tests of the suite.
+func (s *Suite) AllTests() []utesting.Test {
+	return []utesting.Test{
+		{Name: "Status", Fn: s.TestStatus},
+		{Name: "GetBlockHeaders", Fn: s.TestGetBlockHeaders},
+		{Name: "GetBlockBodies", Fn: s.TestGetBlockBodies},
+		{Name: "StatusWrongNetwork", Fn: s.TestStatusWrongNetwork},
+		{Name: "StatusWrongGenesis", Fn: s.TestStatusWrongGenesis},
+		{Name: "StatusWrongVersion", Fn: s.TestStatusWrongVersion},
+		{Name: "StatusMalformed", Fn: s.TestStatusMalformed},
+		{Name: "MissingStatus", Fn: s.TestMissingStatus},
+		{Name: "ExtraStatus", Fn: s.TestExtraStatus},
+		{Name: "InvalidMsgCode", Fn: s.TestInvalidMsgCode},
+		{Name: "MalformedGetBlockHeaders", Fn: s.TestMalformedGetBlockHeaders},
+		{Name: "MalformedGetBlockBodies", Fn: s.TestMalformedGetBlockBodies},
+	}
+}
+
+// TestStatus checks the status message of the node, and that the node keeps the
+// connection after a valid status.
+func (s *Suite) TestStatus(t *utesting.T) {
+	conn := s.dial(t)
+	defer conn.Close(p2p.DiscQuitting)
+
+	status := conn.readStatus(t)
+	if status.ProtocolVersion != uint32(conn.Version()) {
+		t.Errorf("wrong protocol version %d in status, negotiated %d", status.ProtocolVersion, conn.Version())
+	}
+	if status.GenesisBlock == (common.Hash{}) {
+		t.Error("zero genesis hash in status")
+	}
+	if status.CurrentBlock == (common.Hash{}) {
+		t.Error("zero head hash in status")
+	}
+	if status.TD == nil || status.TD.Sign() <= 0 {
+		t.Errorf("invalid total difficulty %v in status", status.TD)
+	}
+	conn.send(t, eth.StatusMsg, conn.ourStatus(status))
+
+	// The node must answer requests after the handshake.
+	conn.requestHeaders(t, &GetBlockHeadersByHash{Origin: status.GenesisBlock, Amount: 1})
+}
+
+// TestGetBlockHeaders checks the responses to various header queries.
+func (s *Suite) TestGetBlockHeaders(t *utesting.T) {
+	conn := s.dial(t)
+	defer conn.Close(p2p.DiscQuitting)
+	status := conn.handshake(t)
+
+	// Query the genesis header by hash and by number.
+	headers := conn.requestHeaders(t, &GetBlockHeadersByHash{Origin: status.GenesisBlock, Amount: 1})
+	if len(headers) != 1 || headers[0].Hash() != status.GenesisBlock {
+		t.Fatalf("wrong response to genesis query by hash: %d headers", len(headers))
+	}
+	headers = conn.requestHeaders(t, &GetBlockHeadersByNumber{Origin: 0, Amount: 1})
+	if len(headers) != 1 || headers[0].Hash() != status.GenesisBlock {
+		t.Fatalf("wrong response to genesis query by number: %d headers", len(headers))
+	}
+
+	// Query the head header announced in the status.
+	headers = conn.requestHeaders(t, &GetBlockHeadersByHash{Origin: status.CurrentBlock, Amount: 1})
+	if len(headers) != 1 || headers[0].Hash() != status.CurrentBlock {
+		t.Fatalf("wrong response to head query: %d headers", len(headers))
+	}
+	head := headers[0]
+
+	// Query a range of headers ending at the head. The headers must be chained.
+	amount := head.Number.Uint64() + 1
+	if amount > maxHeaderRange {
+		amount = maxHeaderRange
+	}
+	headers = conn.requestHeaders(t, &GetBlockHeadersByHash{Origin: head.Hash(), Amount: amount, Reverse: true})
+	if uint64(len(headers)) != amount {
+		t.Fatalf("wrong number of headers in range query: got %d, want %d", len(headers), amount)
+	}
+	for i := 1; i < len(headers); i++ {
+		if headers[i-1].ParentHash != headers[i].Hash() {
+			t.Errorf("header %d (number %v) is not the parent of header %d", i, headers[i].Number, i-1)
+		}
+	}
+
+	// Query an unknown block. The response must be empty.
+	headers = conn.requestHeaders(t, &GetBlockHeadersByHash{Origin: common.Hash{0xff}, Amount: 1})
+	if len(headers) != 0 {
+		t.Errorf("got %d headers for unknown block", len(headers))
+	}
+}
+
+// TestGetBlockBodies requests the bodies of the genesis and head blocks and checks
+// them against the headers.
+func (s *Suite) TestGetBlockBodies(t *utesting.T) {
+	conn := s.dial(t)
+	defer conn.Close(p2p.DiscQuitting)
+	status := conn.handshake(t)
+
+	var headers []*types.Header
+	for _, hash := range []common.Hash{status.GenesisBlock, status.CurrentBlock} {
+		resp := conn.requestHeaders(t, &GetBlockHeadersByHash{Origin: hash, Amount: 1})
+		if len(resp) != 1 {
+			t.Fatalf("no header for block %x", hash)
+		}
+		headers = append(headers, resp[0])
+	}
+	conn.send(t, eth.GetBlockBodiesMsg, GetBlockBodies{status.GenesisBlock, status.CurrentBlock})
+	msg := conn.expect(t, eth.BlockBodiesMsg)
+	var bodies BlockBodies
+	if err := msg.Decode(&bodies); err != nil {
+		t.Fatal("invalid block bodies message:", err)
+	}
+	if len(bodies) != len(headers) {
+		t.Fatalf("wrong number of bodies: got %d, want %d", len(bodies), len(headers))
+	}
+	for i, body := range bodies {
+		if hash := types.DeriveSha(types.Transactions(body.Transactions)); hash != headers[i].TxHash {
+			t.Errorf("body %d: transactions hash %x doesn't match header", i, hash)
+		}
+		if hash := types.CalcUncleHash(body.Uncles); hash != headers[i].UncleHash {
+			t.Errorf("body %d: uncle hash %x doesn't match header", i, hash)
+		}
+	}
+}
+
+// TestStatusWrongNetwork checks that the node disconnects on a network ID mismatch.
+func (s *Suite) TestStatusWrongNetwork(t *utesting.T) {
+	conn := s.dial(t)
+	defer conn.Close(p2p.DiscQuitting)
+
+	status := conn.ourStatus(conn.readStatus(t))
+	status.NetworkId++
+	conn.send(t, eth.StatusMsg, status)
+	conn.expectDisconnect(t)
+}
+
+// TestStatusWrongGenesis checks that the node disconnects on a genesis mismatch.
+func (s *Suite) TestStatusWrongGenesis(t *utesting.T) {
+	conn := s.dial(t)
+	defer conn.Close(p2p.DiscQuitting)
+
+	status := conn.ourStatus(conn.readStatus(t))
+	status.GenesisBlock[0]++
+	status.CurrentBlock = status.GenesisBlock
+	conn.send(t, eth.StatusMsg, status)
+	conn.expectDisconnect(t)
+}
+
+// TestStatusWrongVersion checks that the node disconnects if the protocol version
+// in the status doesn't match the negotiated version.
+func (s *Suite) TestStatusWrongVersion(t *utesting.T) {
+	conn := s.dial(t)
+	defer conn.Close(p2p.DiscQuitting)
+
+	status := conn.ourStatus(conn.readStatus(t))
+	status.ProtocolVersion++
+	conn.send(t, eth.StatusMsg, status)
+	conn.expectDisconnect(t)
+}
+
+// TestStatusMalformed checks that the node disconnects on an undecodable status.
+func (s *Suite) TestStatusMalformed(t *utesting.T) {
+	conn := s.dial(t)
+	defer conn.Close(p2p.DiscQuitting)
+
+	conn.readStatus(t)
+	conn.sendRaw(t, eth.StatusMsg, invalidRLP)
+	conn.expectDisconnect(t)
+}
+
+// TestMissingStatus checks that the node disconnects if the first message isn't
+// a status message.
+func (s *Suite) TestMissingStatus(t *utesting.T) {
+	conn := s.dial(t)
+	defer conn.Close(p2p.DiscQuitting)
+
+	status := conn.readStatus(t)
+	conn.send(t, eth.GetBlockHeadersMsg, &GetBlockHeadersByHash{Origin: status.GenesisBlock, Amount: 1})
+	conn.expectDisconnect(t)
+}
+
+// TestExtraStatus checks that the node disconnects on a second status message.
+func (s *Suite) TestExtraStatus(t *utesting.T) {
+	conn := s.dial(t)
+	defer conn.Close(p2p.DiscQuitting)
+
+	status := conn.handshake(t)
+	conn.send(t, eth.StatusMsg, conn.ourStatus(status))
+	conn.expectDisconnect(t)
+}
+
+// TestInvalidMsgCode checks that the node disconnects on a message code beyond the
+// negotiated protocol.
+func (s *Suite) TestInvalidMsgCode(t *utesting.T) {
+	conn := s.dial(t)
+	defer conn.Close(p2p.DiscQuitting)
+
+	conn.handshake(t)
+	conn.sendRaw(t, conn.protocolLength(), []byte{0xc0})
+	conn.expectDisconnect(t)
+}
+
+// TestMalformedGetBlockHeaders checks that the node disconnects on an undecodable
+// header query.
+func (s *Suite) TestMalformedGetBlockHeaders(t *utesting.T) {
+	conn := s.dial(t)
+	defer conn.Close(p2p.DiscQuitting)
+
+	conn.handshake(t)
+	conn.sendRaw(t, eth.GetBlockHeadersMsg, invalidRLP)
+	conn.expectDisconnect(t)
+}
+
+// TestMalformedGetBlockBodies checks that the node disconnects on an undecodable
+// body query.
+func (s *Suite) TestMalformedGetBlockBodies(t *utesting.T) {
+	conn := s.dial(t)
+	defer conn.Close(p2p.DiscQuitting)
+
+	conn.handshake(t)
+	conn.sendRaw(t, eth.GetBlockBodiesMsg, invalidRLP)
+	conn.expectDisconnect(t)
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package ethtest
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// The message types below mirror the wire format of the packets defined in
+// eth/protocol.go.
+
+// Status is the network packet for the status message.
+type Status struct {
+	ProtocolVersion uint32
+	NetworkId       uint64
+	TD              *big.Int
+	CurrentBlock    common.Hash
+	GenesisBlock    common.Hash
+}
+
+// GetBlockHeadersByHash is a block header query starting at a block hash.
+type GetBlockHeadersByHash struct {
+	Origin  common.Hash
+	Amount  uint64
+	Skip    uint64
+	Reverse bool
+}
+
+// GetBlockHeadersByNumber is a block header query starting at a block number.
+type GetBlockHeadersByNumber struct {
+	Origin  uint64
+	Amount  uint64
+	Skip    uint64
+	Reverse bool
+}
+
+// BlockHeaders is the network packet for block header responses.
+type BlockHeaders []*types.Header
+
+// GetBlockBodies is the network packet for block body queries.
+type GetBlockBodies []common.Hash
+
+// BlockBody represents the data content of a single block.
+type BlockBody struct {
+	Transactions []*types.Transaction
+	Uncles       []*types.Header
+}
+
+// BlockBodies is the network packet for block body responses.
+type BlockBodies []*BlockBody
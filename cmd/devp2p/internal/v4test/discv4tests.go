@@ -0,0 +1,277 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+// Package v4test contains conformance tests for the discovery v4 protocol.
+package v4test
+
+import (
+	"crypto/rand"
+	"net"
+
+	"github.com/ethereum/go-ethereum/internal/utesting"
+	"github.com/ethereum/go-ethereum/p2p/discover/v4wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// wrongEndpoint is an endpoint which doesn't belong to the test.
+var wrongEndpoint = v4wire.Endpoint{IP: net.ParseIP("192.0.2.1").To4(), UDP: 5000, TCP: 5000}
+
+// Suite runs discovery v4 tests against a single node.
+type Suite struct {
+	Dest   *enode.Node
+	Listen string // local UDP address of test sockets
+}
+
+// NewSuite creates a test suite for the given node.
+func NewSuite(dest *enode.Node) *Suite {
+	return &Suite{Dest: dest, Listen: "0.0.0.0:0"}
+}
+
+// AllTests returns all tests of the suite.
+func (s *Suite) AllTests() []utesting.Test {
+	return []utesting.Test{
+		{Name: "Ping", Fn: s.TestPing},
+		{Name: "PingWrongTo", Fn: s.TestPingWrongTo},
+		{Name: "PingWrongFrom", Fn: s.TestPingWrongFrom},
+		{Name: "PingExtraData", Fn: s.TestPingExtraData},
+		{Name: "PingExtraDataWrongFrom", Fn: s.TestPingExtraDataWrongFrom},
+		{Name: "PingPastExpiration", Fn: s.TestPingPastExpiration},
+		{Name: "WrongPacketType", Fn: s.TestWrongPacketType},
+		{Name: "BadHash", Fn: s.TestBadHash},
+		{Name: "PacketTooSmall", Fn: s.TestPacketTooSmall},
+		{Name: "InvalidRLP", Fn: s.TestInvalidRLP},
+		{Name: "BondThenPingWithWrongFrom", Fn: s.TestBondThenPingWithWrongFrom},
+		{Name: "FindnodeWithoutEndpointProof", Fn: s.TestFindnodeWithoutEndpointProof},
+		{Name: "BasicFindnode", Fn: s.TestBasicFindnode},
+		{Name: "UnsolicitedNeighbors", Fn: s.TestUnsolicitedNeighbors},
+		{Name: "FindnodePastExpiration", Fn: s.TestFindnodePastExpiration},
+	}
+}
+
+// TestPing sends a PING packet and expects a PONG.
+func (s *Suite) TestPing(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+
+	hash := te.send(t, te.ping())
+	te.expectPong(t, hash)
+}
+
+// TestPingWrongTo sends a PING packet with wrong 'to' field and expects a PONG.
+func (s *Suite) TestPingWrongTo(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+
+	req := te.ping()
+	req.To = wrongEndpoint
+	hash := te.send(t, req)
+	te.expectPong(t, hash)
+}
+
+// TestPingWrongFrom sends a PING packet with wrong 'from' field and expects a PONG.
+// The PONG is sent to the sender address of the packet.
+func (s *Suite) TestPingWrongFrom(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+
+	req := te.ping()
+	req.From = wrongEndpoint
+	hash := te.send(t, req)
+	te.expectPong(t, hash)
+}
+
+// TestPingExtraData sends a PING packet with additional list elements and a higher
+// version number, and expects a PONG.
+func (s *Suite) TestPingExtraData(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+
+	req := te.ping()
+	req.Version = 5
+	req.Rest = extraData()
+	hash := te.send(t, req)
+	te.expectPong(t, hash)
+}
+
+// TestPingExtraDataWrongFrom sends a PING packet with additional data and wrong
+// 'from' field, and expects a PONG.
+func (s *Suite) TestPingExtraDataWrongFrom(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+
+	req := te.ping()
+	req.From = wrongEndpoint
+	req.Rest = extraData()
+	hash := te.send(t, req)
+	te.expectPong(t, hash)
+}
+
+// TestPingPastExpiration sends a PING packet with an expiration in the past. The node
+// must not reply.
+func (s *Suite) TestPingPastExpiration(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+
+	req := te.ping()
+	req.Expiration = pastExpiration()
+	te.send(t, req)
+	te.expectNoReply(t)
+}
+
+// TestWrongPacketType sends a packet of unknown type. The node must not reply.
+func (s *Suite) TestWrongPacketType(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+
+	body, _ := rlp.EncodeToBytes(te.ping())
+	te.sendSigned(t, 0xff, body)
+	te.expectNoReply(t)
+}
+
+// TestBadHash sends a PING packet with an invalid hash. The node must not reply.
+func (s *Suite) TestBadHash(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+
+	packet, _, err := v4wire.Encode(te.key, te.ping())
+	if err != nil {
+		t.Fatal("can't encode packet:", err)
+	}
+	packet[0]++
+	te.sendRaw(t, packet)
+	te.expectNoReply(t)
+}
+
+// TestPacketTooSmall sends a packet which is shorter than the packet header. The
+// node must not reply.
+func (s *Suite) TestPacketTooSmall(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+
+	packet, _, err := v4wire.Encode(te.key, te.ping())
+	if err != nil {
+		t.Fatal("can't encode packet:", err)
+	}
+	te.sendRaw(t, packet[:v4wire.HeadSize])
+	te.expectNoReply(t)
+}
+
+// TestInvalidRLP sends a correctly signed PING packet with invalid RLP content. The
+// node must not reply.
+func (s *Suite) TestInvalidRLP(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+
+	te.sendSigned(t, v4wire.PingPacket, []byte{0xf8, 0x80, 0x01})
+	te.expectNoReply(t)
+}
+
+// TestBondThenPingWithWrongFrom performs the endpoint proof, then sends a PING
+// with wrong 'from' field and expects a PONG.
+func (s *Suite) TestBondThenPingWithWrongFrom(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+	te.bond(t)
+
+	req := te.ping()
+	req.From = wrongEndpoint
+	hash := te.send(t, req)
+	te.expectPong(t, hash)
+}
+
+// TestFindnodeWithoutEndpointProof sends FINDNODE without a prior endpoint proof.
+// The node must not reply.
+func (s *Suite) TestFindnodeWithoutEndpointProof(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+
+	te.send(t, &v4wire.Findnode{Target: randomKey(), Expiration: futureExpiration()})
+	te.expectNoReply(t)
+}
+
+// TestBasicFindnode performs the endpoint proof, then sends FINDNODE and expects
+// a NEIGHBORS response.
+func (s *Suite) TestBasicFindnode(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+	te.bond(t)
+
+	te.send(t, &v4wire.Findnode{Target: randomKey(), Expiration: futureExpiration()})
+	reply, _, err := te.read()
+	if err != nil {
+		t.Fatal("read error:", err)
+	}
+	if _, ok := reply.(*v4wire.Neighbors); !ok {
+		t.Fatalf("expected NEIGHBORS, got %s", reply.Name())
+	}
+}
+
+// TestUnsolicitedNeighbors performs the endpoint proof, then sends an unsolicited
+// NEIGHBORS packet containing a fake node. The fake node must not be returned by
+// a subsequent FINDNODE for its key.
+func (s *Suite) TestUnsolicitedNeighbors(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+	te.bond(t)
+
+	fakeKey := randomKey()
+	te.send(t, &v4wire.Neighbors{
+		Expiration: futureExpiration(),
+		Nodes: []v4wire.Node{{
+			ID:  fakeKey,
+			IP:  net.IP{1, 2, 3, 4},
+			UDP: 30303,
+			TCP: 30303,
+		}},
+	})
+	te.send(t, &v4wire.Findnode{Target: fakeKey, Expiration: futureExpiration()})
+	for {
+		reply, _, err := te.read()
+		if err == errTimeout {
+			return
+		} else if err != nil {
+			t.Fatal("read error:", err)
+		}
+		neighbors, ok := reply.(*v4wire.Neighbors)
+		if !ok {
+			t.Fatalf("expected NEIGHBORS, got %s", reply.Name())
+		}
+		if containsKey(neighbors.Nodes, fakeKey) {
+			t.Fatal("unsolicited node was added to the table")
+		}
+	}
+}
+
+// TestFindnodePastExpiration performs the endpoint proof, then sends FINDNODE with
+// an expiration in the past. The node must not reply.
+func (s *Suite) TestFindnodePastExpiration(t *utesting.T) {
+	te := newTestEnv(t, s.Dest, s.Listen)
+	defer te.close()
+	te.bond(t)
+
+	te.send(t, &v4wire.Findnode{Target: randomKey(), Expiration: pastExpiration()})
+	te.expectNoReply(t)
+}
+
+func extraData() []rlp.RawValue {
+	return []rlp.RawValue{{0xC5, 0x01, 0x02, 0x03, 0x04, 0x05}, {0x06}}
+}
+
+func randomKey() (key v4wire.Pubkey) {
+	rand.Read(key[:])
+	return key
+}
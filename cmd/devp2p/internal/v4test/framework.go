@@ -0,0 +1,215 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package v4test
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/internal/utesting"
+	"github.com/ethereum/go-ethereum/p2p/discover/v4wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+const (
+	expiration = 20 * time.Second
+	waitTime   = 300 * time.Millisecond
+)
+
+var errTimeout = errors.New("timeout")
+
+// testenv is the connection to the remote node used by a single test.
+type testenv struct {
+	conn       *net.UDPConn
+	key        *ecdsa.PrivateKey
+	remote     *enode.Node
+	remoteAddr *net.UDPAddr
+}
+
+func newTestEnv(t *utesting.T, remote *enode.Node, listen string) *testenv {
+	laddr, err := net.ResolveUDPAddr("udp", listen)
+	if err != nil {
+		t.Fatal("invalid listen address:", err)
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		t.Fatal("can't listen:", err)
+	}
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testenv{
+		conn:       conn,
+		key:        key,
+		remote:     remote,
+		remoteAddr: &net.UDPAddr{IP: remote.IP(), Port: remote.UDP()},
+	}
+}
+
+func (te *testenv) close() {
+	te.conn.Close()
+}
+
+// send encodes and sends a packet, returning its hash.
+func (te *testenv) send(t *utesting.T, req v4wire.Packet) []byte {
+	packet, hash, err := v4wire.Encode(te.key, req)
+	if err != nil {
+		t.Fatal("can't encode packet:", err)
+	}
+	te.sendRaw(t, packet)
+	return hash
+}
+
+// sendSigned signs and sends a packet with arbitrary type and content.
+func (te *testenv) sendSigned(t *utesting.T, ptype byte, body []byte) {
+	sigdata := append([]byte{ptype}, body...)
+	sig, err := crypto.Sign(crypto.Keccak256(sigdata), te.key)
+	if err != nil {
+		t.Fatal("can't sign packet:", err)
+	}
+	hash := crypto.Keccak256(sig, sigdata)
+	packet := make([]byte, 0, len(hash)+len(sig)+len(sigdata))
+	packet = append(packet, hash...)
+	packet = append(packet, sig...)
+	packet = append(packet, sigdata...)
+	te.sendRaw(t, packet)
+}
+
+func (te *testenv) sendRaw(t *utesting.T, packet []byte) {
+	if _, err := te.conn.WriteToUDP(packet, te.remoteAddr); err != nil {
+		t.Fatal("write error:", err)
+	}
+}
+
+// read waits for the next packet from the remote node.
+func (te *testenv) read() (v4wire.Packet, []byte, error) {
+	buf := make([]byte, 1280)
+	for {
+		te.conn.SetReadDeadline(time.Now().Add(waitTime))
+		n, from, err := te.conn.ReadFromUDP(buf)
+		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+			return nil, nil, errTimeout
+		} else if err != nil {
+			return nil, nil, err
+		}
+		if !from.IP.Equal(te.remoteAddr.IP) || from.Port != te.remoteAddr.Port {
+			continue // not from the node under test
+		}
+		p, _, hash, err := v4wire.Decode(buf[:n])
+		return p, hash, err
+	}
+}
+
+// expectPong waits for the reply to the ping with the given hash. Pings sent by the
+// remote node are ignored.
+func (te *testenv) expectPong(t *utesting.T, pingHash []byte) *v4wire.Pong {
+	for {
+		reply, _, err := te.read()
+		if err != nil {
+			t.Fatal("read error:", err)
+		}
+		switch reply := reply.(type) {
+		case *v4wire.Ping:
+			continue
+		case *v4wire.Pong:
+			if !bytes.Equal(reply.ReplyTok, pingHash) {
+				t.Fatalf("PONG reply token mismatch: got %x, want %x", reply.ReplyTok, pingHash)
+			}
+			return reply
+		default:
+			t.Fatalf("expected PONG, got %s", reply.Name())
+		}
+	}
+}
+
+// expectNoReply checks that the remote node doesn't send any packet.
+func (te *testenv) expectNoReply(t *utesting.T) {
+	reply, _, err := te.read()
+	switch {
+	case err == errTimeout:
+	case err != nil:
+		t.Fatal("read error:", err)
+	default:
+		t.Fatalf("expected no reply, got %s", reply.Name())
+	}
+}
+
+// bond performs the endpoint proof with the remote node. After bonding, the
+// remote node answers FINDNODE and ENRREQUEST.
+func (te *testenv) bond(t *utesting.T) {
+	pingHash := te.send(t, te.ping())
+	var gotPing, gotPong bool
+	for !gotPing || !gotPong {
+		req, hash, err := te.read()
+		if err != nil {
+			t.Fatal("read error during bond:", err)
+		}
+		switch req := req.(type) {
+		case *v4wire.Ping:
+			te.send(t, &v4wire.Pong{
+				To:         te.remoteEndpoint(),
+				ReplyTok:   hash,
+				Expiration: futureExpiration(),
+			})
+			gotPing = true
+		case *v4wire.Pong:
+			if !bytes.Equal(req.ReplyTok, pingHash) {
+				t.Fatalf("PONG reply token mismatch: got %x, want %x", req.ReplyTok, pingHash)
+			}
+			gotPong = true
+		}
+	}
+}
+
+func (te *testenv) ping() *v4wire.Ping {
+	return &v4wire.Ping{
+		Version:    4,
+		From:       te.localEndpoint(),
+		To:         te.remoteEndpoint(),
+		Expiration: futureExpiration(),
+	}
+}
+
+func (te *testenv) localEndpoint() v4wire.Endpoint {
+	return v4wire.NewEndpoint(te.conn.LocalAddr().(*net.UDPAddr), 0)
+}
+
+func (te *testenv) remoteEndpoint() v4wire.Endpoint {
+	return v4wire.NewEndpoint(te.remoteAddr, uint16(te.remote.TCP()))
+}
+
+func futureExpiration() uint64 {
+	return uint64(time.Now().Add(expiration).Unix())
+}
+
+func pastExpiration() uint64 {
+	return uint64(time.Now().Add(-expiration).Unix())
+}
+
+func containsKey(nodes []v4wire.Node, key v4wire.Pubkey) bool {
+	for _, n := range nodes {
+		if n.ID == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of go-ethereum.
+//
+// go-ethereum is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// go-ethereum is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with go-ethereum. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/cmd/devp2p/internal/ethtest"
+	"github.com/ethereum/go-ethereum/cmd/devp2p/internal/v4test"
+	"github.com/ethereum/go-ethereum/internal/utesting"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var (
+	testCommand = cli.Command{
+		Name:  "test",
+		Usage: "Protocol Conformance Tests",
+		Subcommands: []cli.Command{
+			testDiscv4Command,
+			testEthCommand,
+		},
+	}
+	testDiscv4Command = cli.Command{
+		Name:      "discv4",
+		Usage:     "Run discovery v4 tests against a node",
+		ArgsUsage: "<node>",
+		Action:    testDiscv4,
+		Flags:     []cli.Flag{testPatternFlag, testListenFlag},
+	}
+	testEthCommand = cli.Command{
+		Name:      "eth",
+		Usage:     "Run eth protocol tests against a node",
+		ArgsUsage: "<node>",
+		Action:    testEth,
+		Flags:     []cli.Flag{testPatternFlag},
+	}
+)
+
+var (
+	testPatternFlag = cli.StringFlag{
+		Name:  "run",
+		Usage: "Regular expression selecting the tests to run",
+	}
+	testListenFlag = cli.StringFlag{
+		Name:  "listen",
+		Usage: "Local UDP address of test sockets",
+		Value: "0.0.0.0:0",
+	}
+)
+
+// testDiscv4 performs testDiscv4Command.
+func testDiscv4(ctx *cli.Context) error {
+	n, err := getNodeArg(ctx)
+	if err != nil {
+		return err
+	}
+	suite := v4test.NewSuite(n)
+	suite.Listen = ctx.String(testListenFlag.Name)
+	return runTests(ctx, suite.AllTests())
+}
+
+// testEth performs testEthCommand.
+func testEth(ctx *cli.Context) error {
+	n, err := getNodeArg(ctx)
+	if err != nil {
+		return err
+	}
+	return runTests(ctx, ethtest.NewSuite(n).AllTests())
+}
+
+// runTests runs the tests selected on the command line and prints a report.
+func runTests(ctx *cli.Context, tests []utesting.Test) error {
+	if ctx.IsSet(testPatternFlag.Name) {
+		pattern := ctx.String(testPatternFlag.Name)
+		if tests = utesting.MatchTests(tests, pattern); len(tests) == 0 {
+			return fmt.Errorf("no tests match %q", pattern)
+		}
+	}
+	results := utesting.RunTests(tests, os.Stdout)
+	if fails := utesting.CountFailures(results); fails > 0 {
+		return fmt.Errorf("%d of %d tests failed", fails, len(tests))
+	}
+	fmt.Printf("%d tests passed\n", len(tests))
+	return nil
+}
+
+// getNodeArg parses the node given as the command argument.
+func getNodeArg(ctx *cli.Context) (*enode.Node, error) {
+	if ctx.NArg() != 1 {
+		return nil, fmt.Errorf("need node as argument")
+	}
+	return parseNode(ctx.Args().First())
+}
+
+// parseNode parses a node in enode URL or record text form.
+func parseNode(source string) (*enode.Node, error) {
+	if strings.HasPrefix(source, "enode://") {
+		return enode.ParseV4(source)
+	}
+	return decodeRecord(source)
+}
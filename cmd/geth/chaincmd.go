@@ -18,6 +18,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"runtime"
@@ -34,6 +35,7 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/internal/era"
 	"github.com/ethereum/go-ethereum/log"
 	"gopkg.in/urfave/cli.v1"
 )
@@ -67,6 +69,9 @@ It expects the genesis file as argument.`,
 			utils.GCModeFlag,
 			utils.CacheDatabaseFlag,
 			utils.CacheGCFlag,
+			eraFlag,
+			eraTrustedFlag,
+			eraWorkersFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -74,7 +79,12 @@ The import command imports blocks from an RLP-encoded form. The form can be one
 with several RLP-encoded blocks, or several files can be used.
 
 If only one file is used, import error will result in failure. If several files are used,
-processing will proceed even if an individual RLP-file import failure occurs.`,
+processing will proceed even if an individual RLP-file import failure occurs.
+
+With --era, the arguments are segmented chain archives, given as local directories
+or http(s) URLs of mirrors. Segments are fetched and verified in parallel and include
+the receipts of the blocks. With --era.trusted, the blocks are not executed but
+stored along with the receipts of the archive, like fast sync does.`,
 	}
 	exportCommand = cli.Command{
 		Action:    utils.MigrateFlags(exportChain),
@@ -85,6 +95,8 @@ processing will proceed even if an individual RLP-file import failure occurs.`,
 			utils.DataDirFlag,
 			utils.CacheFlag,
 			utils.SyncModeFlag,
+			eraFlag,
+			eraSegmentFlag,
 		},
 		Category: "BLOCKCHAIN COMMANDS",
 		Description: `
@@ -92,7 +104,11 @@ Requires a first argument of the file to write to.
 Optional second and third arguments control the first and
 last block to write. In this mode, the file will be appended
 if already existing. If the file ends with .gz, the output will
-be gzipped.`,
+be gzipped.
+
+With --era, the first argument is the directory of a segmented
+chain archive, which is created or extended with the exported
+blocks and their receipts.`,
 	}
 	importPreimagesCommand = cli.Command{
 		Action:    utils.MigrateFlags(importPreimages),
@@ -166,6 +182,58 @@ Remove blockchain and state databases`,
 The arguments are interpreted as block numbers or hashes.
 Use "ethereum dump 0" to dump the genesis block.`,
 	}
+	verifyCommand = cli.Command{
+		Action:    utils.MigrateFlags(verifyChain),
+		Name:      "verify",
+		Usage:     "Verify the integrity of the chain database",
+		ArgsUsage: " ",
+		Flags: []cli.Flag{
+			utils.DataDirFlag,
+			utils.CacheFlag,
+			utils.SyncModeFlag,
+			verifyStateFlag,
+			verifyRepairFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The verify command walks the canonical chain of an existing database offline,
+checking the header linkage, total difficulties, block bodies, receipts and
+transaction lookup entries. With --state, the presence of the head block's state
+is also checked.
+
+With --repair, derived data is rewritten, dangling canonical hashes are deleted
+and the chain head is rewound below any damaged block, so the next sync only has
+to retrieve the damaged part of the chain instead of starting from scratch.`,
+	}
+)
+
+var (
+	verifyStateFlag = cli.BoolFlag{
+		Name:  "state",
+		Usage: "Check the presence of the head block's state",
+	}
+	verifyRepairFlag = cli.BoolFlag{
+		Name:  "repair",
+		Usage: "Repair the inconsistencies found",
+	}
+	eraFlag = cli.BoolFlag{
+		Name:  "era",
+		Usage: "Use the segmented chain archive format",
+	}
+	eraTrustedFlag = cli.BoolFlag{
+		Name:  "era.trusted",
+		Usage: "Store the archived blocks and receipts without executing the blocks",
+	}
+	eraSegmentFlag = cli.Uint64Flag{
+		Name:  "era.segment",
+		Usage: "Number of blocks per archive segment",
+		Value: era.DefaultSegmentSize,
+	}
+	eraWorkersFlag = cli.IntFlag{
+		Name:  "era.workers",
+		Usage: "Number of archive segments fetched and verified in parallel",
+		Value: 4,
+	}
 )
 
 // initGenesis will initialise the given JSON format genesis file and writes it as
@@ -233,7 +301,13 @@ func importChain(ctx *cli.Context) error {
 	// Import the chain
 	start := time.Now()
 
-	if len(ctx.Args()) == 1 {
+	if ctx.Bool(eraFlag.Name) {
+		for _, arg := range ctx.Args() {
+			if err := utils.ImportArchive(chain, arg, ctx.Bool(eraTrustedFlag.Name), ctx.Int(eraWorkersFlag.Name)); err != nil {
+				log.Error("Import error", "archive", arg, "err", err)
+			}
+		}
+	} else if len(ctx.Args()) == 1 {
 		if err := utils.ImportChain(chain, ctx.Args().First()); err != nil {
 			log.Error("Import error", "err", err)
 		}
@@ -307,7 +381,18 @@ func exportChain(ctx *cli.Context) error {
 
 	var err error
 	fp := ctx.Args().First()
-	if len(ctx.Args()) < 3 {
+	if ctx.Bool(eraFlag.Name) {
+		first, last := uint64(0), chain.CurrentFastBlock().NumberU64()
+		if len(ctx.Args()) >= 3 {
+			var ferr, lerr error
+			first, ferr = strconv.ParseUint(ctx.Args().Get(1), 10, 64)
+			last, lerr = strconv.ParseUint(ctx.Args().Get(2), 10, 64)
+			if ferr != nil || lerr != nil {
+				utils.Fatalf("Export error in parsing parameters: block number not an integer\n")
+			}
+		}
+		err = utils.ExportArchive(chain, fp, first, last, ctx.Uint64(eraSegmentFlag.Name))
+	} else if len(ctx.Args()) < 3 {
 		err = utils.ExportChain(chain, fp)
 	} else {
 		// This can be improved to allow for numbers larger than 9223372036854775807
@@ -470,6 +555,38 @@ func dump(ctx *cli.Context) error {
 	return nil
 }
 
+// verifyChain checks the consistency of the canonical chain in the database,
+// optionally repairing the inconsistencies found.
+func verifyChain(ctx *cli.Context) error {
+	stack := makeFullNode(ctx)
+	defer stack.Close()
+
+	db := utils.MakeChainDatabase(ctx, stack)
+	defer db.Close()
+
+	config := rawdb.VerifyConfig{Repair: ctx.Bool(verifyRepairFlag.Name)}
+	if ctx.Bool(verifyStateFlag.Name) {
+		sdb := state.NewDatabase(db)
+		config.HasState = func(root common.Hash) bool {
+			_, err := sdb.OpenTrie(root)
+			return err == nil
+		}
+	}
+	start := time.Now()
+	issues := rawdb.VerifyChain(db, config)
+	for _, issue := range issues {
+		fmt.Println(issue)
+	}
+	fmt.Printf("Verification done in %v, %d issues found\n", time.Since(start), len(issues))
+
+	for _, issue := range issues {
+		if !issue.Repaired {
+			return errors.New("chain database inconsistent")
+		}
+	}
+	return nil
+}
+
 // hashish returns true for strings that look like hashes.
 func hashish(x string) bool {
 	_, err := strconv.Atoi(x)
@@ -185,6 +185,11 @@ func makeFullNode(ctx *cli.Context) *node.Node {
 		}
 	}
 
+	// Seal developer blocks instantly if requested
+	if ctx.GlobalBool(utils.DeveloperInstantFlag.Name) {
+		utils.RegisterDevSealerService(stack, &cfg.Eth)
+	}
+
 	// Add the Ethereum Stats daemon if requested.
 	if cfg.Ethstats.URL != "" {
 		utils.RegisterEthStatsService(stack, cfg.Ethstats.URL)
@@ -60,6 +60,7 @@ var (
 		utils.BootnodesFlag,
 		utils.BootnodesV4Flag,
 		utils.BootnodesV5Flag,
+		utils.BootnodesV51Flag,
 		utils.DataDirFlag,
 		utils.KeyStoreDirFlag,
 		utils.ExternalSignerFlag,
@@ -98,6 +99,7 @@ var (
 		utils.LightPeersFlag,
 		utils.LightKDFFlag,
 		utils.WhitelistFlag,
+		utils.CheckpointSyncFlag,
 		utils.CacheFlag,
 		utils.CacheDatabaseFlag,
 		utils.CacheTrieFlag,
@@ -105,6 +107,16 @@ var (
 		utils.ListenPortFlag,
 		utils.MaxPeersFlag,
 		utils.MaxPendingPeersFlag,
+		utils.MaxPeersPerSubnetFlag,
+		utils.PeerNetGroupsFlag,
+		utils.MaxPeersPerNetGroupFlag,
+		utils.MultiplexFlag,
+		utils.MaxIngressFlag,
+		utils.MaxEgressFlag,
+		utils.MaxPeerIngressFlag,
+		utils.MaxPeerEgressFlag,
+		utils.BlockAnnounceRateFlag,
+		utils.TxAnnounceRateFlag,
 		utils.MiningEnabledFlag,
 		utils.MinerThreadsFlag,
 		utils.MinerLegacyThreadsFlag,
@@ -120,14 +132,20 @@ var (
 		utils.MinerLegacyExtraDataFlag,
 		utils.MinerRecommitIntervalFlag,
 		utils.MinerNoVerfiyFlag,
+		utils.MinerStratumFlag,
+		utils.MinerShareDifficultyFlag,
 		utils.NATFlag,
 		utils.NoDiscoverFlag,
 		utils.DiscoveryV5Flag,
+		utils.DiscoveryV51Flag,
+		utils.DNSDiscoveryFlag,
+		utils.DiscoveryFileFlag,
 		utils.NetrestrictFlag,
 		utils.NodeKeyFileFlag,
 		utils.NodeKeyHexFlag,
 		utils.DeveloperFlag,
 		utils.DeveloperPeriodFlag,
+		utils.DeveloperInstantFlag,
 		utils.TestnetFlag,
 		utils.RinkebyFlag,
 		utils.GoerliFlag,
@@ -199,6 +217,7 @@ func init() {
 		copydbCommand,
 		removedbCommand,
 		dumpCommand,
+		verifyCommand,
 		// See monitorcmd.go:
 		monitorCommand,
 		// See accountcmd.go:
@@ -311,13 +330,25 @@ func startNode(ctx *cli.Context, stack *node.Node) {
 	events := make(chan accounts.WalletEvent, 16)
 	stack.AccountManager().Subscribe(events)
 
-	go func() {
-		// Create a chain state reader for self-derivation
-		rpcClient, err := stack.Attach()
-		if err != nil {
-			utils.Fatalf("Failed to attach to self: %v", err)
+	// Create a client to interact with local geth node.
+	rpcClient, err := stack.Attach()
+	if err != nil {
+		utils.Fatalf("Failed to attach to self: %v", err)
+	}
+	ethClient := ethclient.NewClient(rpcClient)
+
+	// Set contract backend for ethereum service if local node
+	// is serving LES requests.
+	if ctx.GlobalInt(utils.LightServFlag.Name) > 0 {
+		var ethService *eth.Ethereum
+		if err := stack.Service(&ethService); err != nil {
+			utils.Fatalf("Failed to retrieve ethereum service: %v", err)
 		}
-		stateReader := ethclient.NewClient(rpcClient)
+		ethService.SetContractBackend(ethClient)
+	}
+
+	go func() {
+		// Open any wallets already attached
 
 		// Open any wallets already attached
 		for _, wallet := range stack.AccountManager().Wallets() {
@@ -340,7 +371,7 @@ func startNode(ctx *cli.Context, stack *node.Node) {
 				if event.Wallet.URL().Scheme == "ledger" {
 					derivationPath = accounts.DefaultLedgerBaseDerivationPath
 				}
-				event.Wallet.SelfDerive(derivationPath, stateReader)
+				event.Wallet.SelfDerive(derivationPath, ethClient)
 
 			case accounts.WalletDropped:
 				log.Info("Old wallet dropped", "url", event.Wallet.URL())
@@ -391,6 +422,10 @@ func startNode(ctx *cli.Context, stack *node.Node) {
 		}
 		ethereum.TxPool().SetGasPrice(gasprice)
 
+		// The instant sealer takes over block production in developer mode
+		if ctx.GlobalBool(utils.DeveloperInstantFlag.Name) {
+			return
+		}
 		threads := ctx.GlobalInt(utils.MinerLegacyThreadsFlag.Name)
 		if ctx.GlobalIsSet(utils.MinerThreadsFlag.Name) {
 			threads = ctx.GlobalInt(utils.MinerThreadsFlag.Name)
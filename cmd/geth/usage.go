@@ -86,6 +86,7 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.LightPeersFlag,
 			utils.LightKDFFlag,
 			utils.WhitelistFlag,
+			utils.CheckpointSyncFlag,
 		},
 	},
 	{
@@ -93,6 +94,7 @@ var AppHelpFlagGroups = []flagGroup{
 		Flags: []cli.Flag{
 			utils.DeveloperFlag,
 			utils.DeveloperPeriodFlag,
+			utils.DeveloperInstantFlag,
 		},
 	},
 	{
@@ -176,12 +178,26 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.BootnodesFlag,
 			utils.BootnodesV4Flag,
 			utils.BootnodesV5Flag,
+			utils.BootnodesV51Flag,
 			utils.ListenPortFlag,
 			utils.MaxPeersFlag,
 			utils.MaxPendingPeersFlag,
+			utils.MaxPeersPerSubnetFlag,
+			utils.PeerNetGroupsFlag,
+			utils.MaxPeersPerNetGroupFlag,
+			utils.MultiplexFlag,
+			utils.MaxIngressFlag,
+			utils.MaxEgressFlag,
+			utils.MaxPeerIngressFlag,
+			utils.MaxPeerEgressFlag,
+			utils.BlockAnnounceRateFlag,
+			utils.TxAnnounceRateFlag,
 			utils.NATFlag,
 			utils.NoDiscoverFlag,
 			utils.DiscoveryV5Flag,
+			utils.DiscoveryV51Flag,
+			utils.DNSDiscoveryFlag,
+			utils.DiscoveryFileFlag,
 			utils.NetrestrictFlag,
 			utils.NodeKeyFileFlag,
 			utils.NodeKeyHexFlag,
@@ -200,6 +216,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.MinerExtraDataFlag,
 			utils.MinerRecommitIntervalFlag,
 			utils.MinerNoVerfiyFlag,
+			utils.MinerStratumFlag,
+			utils.MinerShareDifficultyFlag,
 		},
 	},
 	{
@@ -30,6 +30,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ibft"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/params"
@@ -57,6 +58,7 @@ func (w *wizard) makeGenesis() {
 	fmt.Println("Which consensus engine to use? (default = clique)")
 	fmt.Println(" 1. Ethash - proof-of-work")
 	fmt.Println(" 2. Clique - proof-of-authority")
+	fmt.Println(" 3. IBFT   - Byzantine fault tolerant (immediate finality)")
 
 	choice := w.read()
 	switch {
@@ -103,6 +105,41 @@ func (w *wizard) makeGenesis() {
 			copy(genesis.ExtraData[32+i*common.AddressLength:], signer[:])
 		}
 
+	case choice == "3":
+		// In the case of ibft, configure the consensus parameters
+		genesis.Difficulty = big.NewInt(1)
+		genesis.Config.IBFT = &params.IBFTConfig{
+			BlockPeriod:    5,
+			RequestTimeout: 10000,
+		}
+		fmt.Println()
+		fmt.Println("How many seconds should blocks take? (default = 5)")
+		genesis.Config.IBFT.BlockPeriod = uint64(w.readDefaultInt(5))
+
+		fmt.Println()
+		fmt.Println("How many milliseconds until a consensus round times out? (default = 10000)")
+		genesis.Config.IBFT.RequestTimeout = uint64(w.readDefaultInt(10000))
+
+		// We also need the initial list of validators
+		fmt.Println()
+		fmt.Println("Which accounts are validators? (mandatory at least one, 3F+1 tolerate F faults)")
+
+		var validators []common.Address
+		for {
+			if address := w.readAddress(); address != nil {
+				validators = append(validators, *address)
+				continue
+			}
+			if len(validators) > 0 {
+				break
+			}
+		}
+		extra, err := ibft.GenesisExtra(validators)
+		if err != nil {
+			log.Crit("Failed to create validator extra-data", "err", err)
+		}
+		genesis.ExtraData = extra
+
 	default:
 		log.Crit("Invalid consensus engine choice", "choice", choice)
 	}
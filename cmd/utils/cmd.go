@@ -34,6 +34,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/internal/debug"
+	"github.com/ethereum/go-ethereum/internal/era"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -186,6 +187,43 @@ func missingBlocks(chain *core.BlockChain, blocks []*types.Block) []*types.Block
 	return nil
 }
 
+// ImportArchive imports the segments of a chain archive from a local directory
+// or an HTTP mirror, storing the blocks without execution if trusted.
+func ImportArchive(chain *core.BlockChain, location string, trusted bool, workers int) error {
+	// Watch for Ctrl-C while the import is running.
+	// If a signal is received, the import will stop at the next segment.
+	interrupt := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+	defer close(interrupt)
+	go func() {
+		if _, ok := <-interrupt; ok {
+			log.Info("Interrupted during import, stopping at next segment")
+		}
+		close(stop)
+	}()
+	log.Info("Importing chain archive", "location", location, "trusted", trusted, "workers", workers)
+
+	if err := era.Import(chain, era.NewSource(location), trusted, workers, stop); err != nil {
+		return err
+	}
+	log.Info("Imported chain archive", "location", location)
+	return nil
+}
+
+// ExportArchive exports the blocks in the range [first, last] along with their
+// receipts into the segmented chain archive in the specified directory.
+func ExportArchive(chain *core.BlockChain, dir string, first, last, segmentSize uint64) error {
+	log.Info("Exporting chain archive", "dir", dir, "first", first, "last", last)
+
+	if err := era.Export(chain, dir, first, last, segmentSize); err != nil {
+		return err
+	}
+	log.Info("Exported chain archive", "dir", dir)
+	return nil
+}
+
 // ExportChain exports a blockchain into the specified file, truncating any data
 // already present in the file.
 func ExportChain(blockchain *core.BlockChain, fn string) error {
@@ -36,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/ibft"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/vm"
 	"github.com/ethereum/go-ethereum/crypto"
@@ -49,9 +50,11 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/metrics/influxdb"
+	"github.com/ethereum/go-ethereum/miner/devsealer"
 	"github.com/ethereum/go-ethereum/node"
 	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/nat"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
@@ -156,6 +159,10 @@ var (
 		Name:  "dev.period",
 		Usage: "Block period to use in developer mode (0 = mine only if transaction pending)",
 	}
+	DeveloperInstantFlag = cli.BoolFlag{
+		Name:  "dev.instant",
+		Usage: "Seal developer blocks instantly and enable the evm time travel and snapshot APIs",
+	}
 	IdentityFlag = cli.StringFlag{
 		Name:  "identity",
 		Usage: "Custom node name",
@@ -224,6 +231,10 @@ var (
 		Name:  "whitelist",
 		Usage: "Comma separated block number-to-hash mappings to enforce (<number>=<hash>)",
 	}
+	CheckpointSyncFlag = cli.BoolFlag{
+		Name:  "checkpoint.sync",
+		Usage: "Fast sync from the built-in trusted checkpoint of the network, backfilling the history below it in the background",
+	}
 	// Dashboard settings
 	DashboardEnabledFlag = cli.BoolFlag{
 		Name:  "dashboard",
@@ -420,6 +431,14 @@ var (
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	MinerStratumFlag = cli.StringFlag{
+		Name:  "miner.stratum",
+		Usage: "Stratum server listening address to push work packages to remote miners (e.g. 127.0.0.1:8008)",
+	}
+	MinerShareDifficultyFlag = cli.Uint64Flag{
+		Name:  "miner.sharediff",
+		Usage: "Difficulty of the shares accepted from remote miners (0 = block difficulty)",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:  "unlock",
@@ -559,6 +578,48 @@ var (
 		Usage: "Maximum number of pending connection attempts (defaults used if set to 0)",
 		Value: 0,
 	}
+	MaxPeersPerSubnetFlag = cli.IntFlag{
+		Name:  "maxpeers.subnet",
+		Usage: "Maximum number of peers from a single IPv4 /24 or IPv6 /64 subnet (0 = unlimited)",
+	}
+	PeerNetGroupsFlag = cli.StringFlag{
+		Name:  "netgroups",
+		Usage: "Semicolon separated groups of comma separated CIDR masks, each treated as a single network operator",
+	}
+	MaxPeersPerNetGroupFlag = cli.IntFlag{
+		Name:  "maxpeers.netgroup",
+		Usage: "Maximum number of peers from each network group given by --netgroups (0 = unlimited)",
+	}
+	MultiplexFlag = cli.BoolFlag{
+		Name:  "multiplex",
+		Usage: "Multiplex the protocols of peer connections which support it, falling back to plain RLPx",
+	}
+	MaxIngressFlag = cli.IntFlag{
+		Name:  "bandwidth.ingress",
+		Usage: "Maximum total ingress bandwidth of all peers in KB/s (0 = unlimited)",
+	}
+	MaxEgressFlag = cli.IntFlag{
+		Name:  "bandwidth.egress",
+		Usage: "Maximum total egress bandwidth of all peers in KB/s (0 = unlimited)",
+	}
+	MaxPeerIngressFlag = cli.IntFlag{
+		Name:  "bandwidth.peeringress",
+		Usage: "Maximum ingress bandwidth of a single peer in KB/s (0 = unlimited)",
+	}
+	MaxPeerEgressFlag = cli.IntFlag{
+		Name:  "bandwidth.peeregress",
+		Usage: "Maximum egress bandwidth of a single peer in KB/s (0 = unlimited)",
+	}
+	BlockAnnounceRateFlag = cli.Float64Flag{
+		Name:  "announce.blockrate",
+		Usage: "Maximum rate of block announcements accepted from a single peer per second",
+		Value: eth.DefaultConfig.BlockAnnounceLimit.Rate,
+	}
+	TxAnnounceRateFlag = cli.Float64Flag{
+		Name:  "announce.txrate",
+		Usage: "Maximum rate of transaction announcements accepted from a single peer per second",
+		Value: eth.DefaultConfig.TxAnnounceLimit.Rate,
+	}
 	ListenPortFlag = cli.IntFlag{
 		Name:  "port",
 		Usage: "Network listening port",
@@ -579,6 +640,11 @@ var (
 		Usage: "Comma separated enode URLs for P2P v5 discovery bootstrap (light server, light nodes)",
 		Value: "",
 	}
+	BootnodesV51Flag = cli.StringFlag{
+		Name:  "bootnodesv51",
+		Usage: "Comma separated enode URLs for P2P v5.1 discovery bootstrap (defaults to the v4 bootnodes)",
+		Value: "",
+	}
 	NodeKeyFileFlag = cli.StringFlag{
 		Name:  "nodekey",
 		Usage: "P2P node key file",
@@ -600,6 +666,18 @@ var (
 		Name:  "v5disc",
 		Usage: "Enables the experimental RLPx V5 (Topic Discovery) mechanism",
 	}
+	DiscoveryV51Flag = cli.BoolFlag{
+		Name:  "v51disc",
+		Usage: "Enables the discovery v5.1 protocol alongside the V5 mechanism",
+	}
+	DNSDiscoveryFlag = cli.StringFlag{
+		Name:  "discovery.dns",
+		Usage: "Comma separated enrtree:// URLs of DNS node lists to find peers from",
+	}
+	DiscoveryFileFlag = cli.StringFlag{
+		Name:  "discovery.file",
+		Usage: "JSON file listing enode URLs to find peers from",
+	}
 	NetrestrictFlag = cli.StringFlag{
 		Name:  "netrestrict",
 		Usage: "Restricts network communication to the given IP networks (CIDR masks)",
@@ -816,6 +894,32 @@ func setBootstrapNodesV5(ctx *cli.Context, cfg *p2p.Config) {
 	}
 }
 
+// setBootstrapNodesV51 creates a list of discovery v5.1 bootstrap nodes from the
+// command line flags, reverting to the v4 bootstrap nodes if none have been specified.
+func setBootstrapNodesV51(ctx *cli.Context, cfg *p2p.Config) {
+	switch {
+	case ctx.GlobalIsSet(BootnodesV51Flag.Name):
+	case cfg.BootstrapNodesV51 != nil:
+		return // already set, don't apply defaults.
+	default:
+		cfg.BootstrapNodesV51 = cfg.BootstrapNodes
+		return
+	}
+
+	urls := strings.Split(ctx.GlobalString(BootnodesV51Flag.Name), ",")
+	cfg.BootstrapNodesV51 = make([]*enode.Node, 0, len(urls))
+	for _, url := range urls {
+		if url != "" {
+			node, err := enode.ParseV4(url)
+			if err != nil {
+				log.Error("Bootstrap URL invalid", "enode", url, "err", err)
+				continue
+			}
+			cfg.BootstrapNodesV51 = append(cfg.BootstrapNodesV51, node)
+		}
+	}
+}
+
 // setListenAddress creates a TCP listening address string from set command
 // line flags.
 func setListenAddress(ctx *cli.Context, cfg *p2p.Config) {
@@ -1042,6 +1146,7 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	setListenAddress(ctx, cfg)
 	setBootstrapNodes(ctx, cfg)
 	setBootstrapNodesV5(ctx, cfg)
+	setBootstrapNodesV51(ctx, cfg)
 
 	lightClient := ctx.GlobalString(SyncModeFlag.Name) == "light"
 	lightServer := ctx.GlobalInt(LightServFlag.Name) != 0
@@ -1072,6 +1177,36 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	if ctx.GlobalIsSet(MaxPendingPeersFlag.Name) {
 		cfg.MaxPendingPeers = ctx.GlobalInt(MaxPendingPeersFlag.Name)
 	}
+	if ctx.GlobalIsSet(MaxPeersPerSubnetFlag.Name) {
+		cfg.MaxPeersPerSubnet = ctx.GlobalInt(MaxPeersPerSubnetFlag.Name)
+	}
+	if groups := ctx.GlobalString(PeerNetGroupsFlag.Name); groups != "" {
+		for _, group := range strings.Split(groups, ";") {
+			list, err := netutil.ParseNetlist(group)
+			if err != nil {
+				Fatalf("Option %q: %v", PeerNetGroupsFlag.Name, err)
+			}
+			cfg.PeerNetGroups = append(cfg.PeerNetGroups, list)
+		}
+	}
+	if ctx.GlobalIsSet(MaxPeersPerNetGroupFlag.Name) {
+		cfg.MaxPeersPerNetGroup = ctx.GlobalInt(MaxPeersPerNetGroupFlag.Name)
+	}
+	if ctx.GlobalIsSet(MultiplexFlag.Name) {
+		cfg.Multiplex = true
+	}
+	if ctx.GlobalIsSet(MaxIngressFlag.Name) {
+		cfg.MaxIngress = ctx.GlobalInt(MaxIngressFlag.Name) * 1024
+	}
+	if ctx.GlobalIsSet(MaxEgressFlag.Name) {
+		cfg.MaxEgress = ctx.GlobalInt(MaxEgressFlag.Name) * 1024
+	}
+	if ctx.GlobalIsSet(MaxPeerIngressFlag.Name) {
+		cfg.MaxPeerIngress = ctx.GlobalInt(MaxPeerIngressFlag.Name) * 1024
+	}
+	if ctx.GlobalIsSet(MaxPeerEgressFlag.Name) {
+		cfg.MaxPeerEgress = ctx.GlobalInt(MaxPeerEgressFlag.Name) * 1024
+	}
 	if ctx.GlobalIsSet(NoDiscoverFlag.Name) || lightClient {
 		cfg.NoDiscovery = true
 	}
@@ -1085,6 +1220,23 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 	} else if forceV5Discovery {
 		cfg.DiscoveryV5 = true
 	}
+	if ctx.GlobalIsSet(DiscoveryV51Flag.Name) {
+		cfg.DiscoveryV51 = ctx.GlobalBool(DiscoveryV51Flag.Name)
+	} else if forceV5Discovery {
+		cfg.DiscoveryV51 = true
+	}
+
+	if urls := ctx.GlobalString(DNSDiscoveryFlag.Name); urls != "" {
+		for _, url := range strings.Split(urls, ",") {
+			if _, _, err := dnsdisc.ParseURL(url); err != nil {
+				Fatalf("Option %q: %v", DNSDiscoveryFlag.Name, err)
+			}
+			cfg.DiscoveryDNS = append(cfg.DiscoveryDNS, url)
+		}
+	}
+	if ctx.GlobalIsSet(DiscoveryFileFlag.Name) {
+		cfg.DiscoveryFile = ctx.GlobalString(DiscoveryFileFlag.Name)
+	}
 
 	if netrestrict := ctx.GlobalString(NetrestrictFlag.Name); netrestrict != "" {
 		list, err := netutil.ParseNetlist(netrestrict)
@@ -1100,6 +1252,7 @@ func SetP2PConfig(ctx *cli.Context, cfg *p2p.Config) {
 		cfg.ListenAddr = ":0"
 		cfg.NoDiscovery = true
 		cfg.DiscoveryV5 = false
+		cfg.DiscoveryV51 = false
 	}
 }
 
@@ -1214,6 +1367,12 @@ func setEthash(ctx *cli.Context, cfg *eth.Config) {
 	if ctx.GlobalIsSet(EthashDatasetsOnDiskFlag.Name) {
 		cfg.Ethash.DatasetsOnDisk = ctx.GlobalInt(EthashDatasetsOnDiskFlag.Name)
 	}
+	if ctx.GlobalIsSet(MinerStratumFlag.Name) {
+		cfg.Ethash.StratumAddr = ctx.GlobalString(MinerStratumFlag.Name)
+	}
+	if ctx.GlobalIsSet(MinerShareDifficultyFlag.Name) {
+		cfg.Ethash.ShareDifficulty = ctx.GlobalUint64(MinerShareDifficultyFlag.Name)
+	}
 }
 
 func setWhitelist(ctx *cli.Context, cfg *eth.Config) {
@@ -1239,6 +1398,31 @@ func setWhitelist(ctx *cli.Context, cfg *eth.Config) {
 	}
 }
 
+// setCheckpoint configures the built-in trusted checkpoint of the selected
+// network as the starting point of the chain synchronisation. Checkpoint sync
+// is disabled on networks without a usable checkpoint.
+func setCheckpoint(ctx *cli.Context, cfg *eth.Config) {
+	if !ctx.GlobalBool(CheckpointSyncFlag.Name) {
+		return
+	}
+	if cfg.SyncMode != downloader.FastSync {
+		log.Warn("Checkpoint sync requires fast sync, disabling", "syncmode", cfg.SyncMode)
+		return
+	}
+	switch {
+	case ctx.GlobalBool(TestnetFlag.Name):
+		cfg.Checkpoint = params.TestnetTrustedCheckpoint
+	case ctx.GlobalBool(RinkebyFlag.Name), ctx.GlobalBool(GoerliFlag.Name), ctx.GlobalBool(DeveloperFlag.Name):
+		// Clique needs the signer snapshots below the checkpoint to verify the
+		// headers above it, so these networks can't sync from a checkpoint
+		log.Warn("No trusted checkpoint usable on clique networks, disabling checkpoint sync")
+	case cfg.NetworkId == 1:
+		cfg.Checkpoint = params.MainnetTrustedCheckpoint
+	default:
+		log.Warn("No trusted checkpoint known for network, disabling checkpoint sync", "network", cfg.NetworkId)
+	}
+}
+
 // checkExclusive verifies that only a single instance of the provided flags was
 // set by the user. Each flag might optionally be followed by a string type to
 // specialize it further.
@@ -1300,6 +1484,9 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	checkExclusive(ctx, LightServFlag, SyncModeFlag, "light")
 	// Can't use both ephemeral unlocked and external signer
 	checkExclusive(ctx, DeveloperFlag, ExternalSignerFlag)
+	if ctx.GlobalBool(DeveloperInstantFlag.Name) && !ctx.GlobalBool(DeveloperFlag.Name) {
+		Fatalf("Flag --%s requires --%s", DeveloperInstantFlag.Name, DeveloperFlag.Name)
+	}
 	var ks *keystore.KeyStore
 	if keystores := stack.AccountManager().Backends(keystore.KeyStoreType); len(keystores) > 0 {
 		ks = keystores[0].(*keystore.KeyStore)
@@ -1313,6 +1500,12 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 	if ctx.GlobalIsSet(SyncModeFlag.Name) {
 		cfg.SyncMode = *GlobalTextMarshaler(ctx, SyncModeFlag.Name).(*downloader.SyncMode)
 	}
+	if ctx.GlobalIsSet(BlockAnnounceRateFlag.Name) {
+		cfg.BlockAnnounceLimit.Rate = ctx.GlobalFloat64(BlockAnnounceRateFlag.Name)
+	}
+	if ctx.GlobalIsSet(TxAnnounceRateFlag.Name) {
+		cfg.TxAnnounceLimit.Rate = ctx.GlobalFloat64(TxAnnounceRateFlag.Name)
+	}
 	if ctx.GlobalIsSet(LightServFlag.Name) {
 		cfg.LightServ = ctx.GlobalInt(LightServFlag.Name)
 	}
@@ -1429,10 +1622,14 @@ func SetEthConfig(ctx *cli.Context, stack *node.Node, cfg *eth.Config) {
 		log.Info("Using developer account", "address", developer.Address)
 
 		cfg.Genesis = core.DeveloperGenesisBlock(uint64(ctx.GlobalInt(DeveloperPeriodFlag.Name)), developer.Address)
+		if ctx.GlobalBool(DeveloperInstantFlag.Name) {
+			cfg.Genesis.Config.Impersonation = true
+		}
 		if !ctx.GlobalIsSet(MinerGasPriceFlag.Name) && !ctx.GlobalIsSet(MinerLegacyGasPriceFlag.Name) {
 			cfg.MinerGasPrice = big.NewInt(1)
 		}
 	}
+	setCheckpoint(ctx, cfg)
 }
 
 // SetDashboardConfig applies dashboard related command line flags to the config.
@@ -1497,6 +1694,24 @@ func RegisterEthStatsService(stack *node.Node, url string) {
 	}
 }
 
+// RegisterDevSealerService configures the instant developer block sealer and
+// adds it to the given node.
+func RegisterDevSealerService(stack *node.Node, cfg *eth.Config) {
+	if err := stack.Register(func(ctx *node.ServiceContext) (node.Service, error) {
+		var ethServ *eth.Ethereum
+		if err := ctx.Service(&ethServ); err != nil {
+			return nil, err
+		}
+		return devsealer.New(ethServ, &devsealer.Config{
+			GasFloor: cfg.MinerGasFloor,
+			GasCeil:  cfg.MinerGasCeil,
+			Extra:    cfg.MinerExtraData,
+		})
+	}); err != nil {
+		Fatalf("Failed to register the developer sealer service: %v", err)
+	}
+}
+
 func SetupMetrics(ctx *cli.Context) {
 	if metrics.Enabled {
 		log.Info("Enabling metrics collection")
@@ -1578,6 +1793,8 @@ func MakeChain(ctx *cli.Context, stack *node.Node) (chain *core.BlockChain, chai
 	var engine consensus.Engine
 	if config.Clique != nil {
 		engine = clique.New(config.Clique, chainDb)
+	} else if config.IBFT != nil {
+		engine = ibft.New(config.IBFT)
 	} else {
 		engine = ethash.NewFaker()
 		if !ctx.GlobalBool(FakePoWFlag.Name) {
@@ -17,12 +17,22 @@
 package clique
 
 import (
+	"fmt"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+const (
+	defaultStatusBlocks = 64   // Number of recent blocks to gather signer activity from by default
+	maxStatusBlocks     = 8192 // Maximum number of recent blocks to gather signer activity from
+
+	defaultTallyEpochs = 1  // Number of epochs to retrieve the vote tallies of by default
+	maxTallyEpochs     = 64 // Maximum number of epochs to retrieve the vote tallies of
+)
+
 // API is a user facing RPC API to allow controlling the signer and voting
 // mechanisms of the proof-of-authority scheme.
 type API struct {
@@ -117,3 +127,135 @@ func (api *API) Discard(address common.Address) {
 
 	delete(api.clique.proposals, address)
 }
+
+// SignerStatus is the sealing activity of a single signer over a range of blocks.
+type SignerStatus struct {
+	Sealed    uint64 `json:"sealed"`    // Number of blocks sealed by the signer
+	InTurn    uint64 `json:"inturn"`    // Number of blocks sealed in-turn
+	OutOfTurn uint64 `json:"outofturn"` // Number of blocks sealed out-of-turn
+}
+
+// Status is the sealing activity of the signers over a range of recent blocks.
+type Status struct {
+	NumBlocks     uint64                           `json:"numBlocks"`      // Number of blocks inspected
+	InturnPercent float64                          `json:"inturnPercent"`  // Percentage of blocks sealed in-turn
+	Signers       map[common.Address]*SignerStatus `json:"sealerActivity"` // Sealing activity of individual signers
+}
+
+// Status retrieves the sealing activity of the signers over the last given number
+// of blocks (64 by default). Currently authorized signers are always reported,
+// even if they did not seal any of the inspected blocks.
+func (api *API) Status(blocks *uint64) (*Status, error) {
+	count := uint64(defaultStatusBlocks)
+	if blocks != nil {
+		count = *blocks
+	}
+	if count > maxStatusBlocks {
+		return nil, fmt.Errorf("too many blocks requested: %d > %d", count, maxStatusBlocks)
+	}
+	header := api.chain.CurrentHeader()
+	snap, err := api.clique.snapshot(api.chain, header.Number.Uint64(), header.Hash(), nil)
+	if err != nil {
+		return nil, err
+	}
+	// The genesis block carries no seal, don't count it
+	if number := header.Number.Uint64(); count > number {
+		count = number
+	}
+	status := &Status{
+		NumBlocks: count,
+		Signers:   make(map[common.Address]*SignerStatus),
+	}
+	for _, signer := range snap.signers() {
+		status.Signers[signer] = new(SignerStatus)
+	}
+	var inturn uint64
+	for i := uint64(0); i < count; i++ {
+		if i > 0 {
+			if header = api.chain.GetHeader(header.ParentHash, header.Number.Uint64()-1); header == nil {
+				return nil, errUnknownBlock
+			}
+		}
+		signer, err := api.clique.Author(header)
+		if err != nil {
+			return nil, err
+		}
+		if status.Signers[signer] == nil {
+			status.Signers[signer] = new(SignerStatus)
+		}
+		activity := status.Signers[signer]
+		activity.Sealed++
+
+		if header.Difficulty.Cmp(diffInTurn) == 0 {
+			activity.InTurn++
+			inturn++
+		} else {
+			activity.OutOfTurn++
+		}
+	}
+	if count > 0 {
+		status.InturnPercent = float64(100*inturn) / float64(count)
+	}
+	return status, nil
+}
+
+// GetSigner recovers the account that sealed the specified block.
+func (api *API) GetSigner(hash common.Hash) (common.Address, error) {
+	header := api.chain.GetHeaderByHash(hash)
+	if header == nil {
+		return common.Address{}, errUnknownBlock
+	}
+	return api.clique.Author(header)
+}
+
+// EpochTally is the state of the authorization voting at the end of an epoch,
+// or at the current head for the epoch still in progress.
+type EpochTally struct {
+	Epoch  uint64                   `json:"epoch"`  // Index of the epoch
+	Number uint64                   `json:"number"` // Last block of the epoch the tally was taken at
+	Hash   common.Hash              `json:"hash"`   // Hash of the block the tally was taken at
+	Votes  []*Vote                  `json:"votes"`  // List of votes cast in the epoch
+	Tally  map[common.Address]Tally `json:"tally"`  // Vote tally at the end of the epoch
+}
+
+// GetTallyHistory retrieves the vote tallies of the last given number of epochs
+// (only the current one by default), newest first. Tallies are reset on every
+// epoch checkpoint, so the final tally of each epoch reports the proposals that
+// were still pending when the epoch ended.
+func (api *API) GetTallyHistory(epochs *uint64) ([]*EpochTally, error) {
+	count := uint64(defaultTallyEpochs)
+	if epochs != nil {
+		count = *epochs
+	}
+	if count > maxTallyEpochs {
+		return nil, fmt.Errorf("too many epochs requested: %d > %d", count, maxTallyEpochs)
+	}
+	var (
+		head    = api.chain.CurrentHeader().Number.Uint64()
+		epoch   = api.clique.config.Epoch
+		current = head / epoch
+	)
+	history := make([]*EpochTally, 0, count)
+	for i := uint64(0); i < count && i <= current; i++ {
+		number := head
+		if i > 0 {
+			number = (current-i+1)*epoch - 1
+		}
+		header := api.chain.GetHeaderByNumber(number)
+		if header == nil {
+			return nil, errUnknownBlock
+		}
+		snap, err := api.clique.snapshot(api.chain, number, header.Hash(), nil)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, &EpochTally{
+			Epoch:  current - i,
+			Number: number,
+			Hash:   header.Hash(),
+			Votes:  snap.Votes,
+			Tally:  snap.Tally,
+		})
+	}
+	return history, nil
+}
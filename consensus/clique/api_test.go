@@ -0,0 +1,162 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// newTestAPI creates a two signer chain with a single pending vote cast in the
+// first epoch, returning the API wrapping it along with the signer accounts.
+func newTestAPI(t *testing.T) (*API, *testerAccountPool, []*types.Block) {
+	accounts := newTesterAccountPool()
+	signers := []common.Address{accounts.address("A"), accounts.address("B")}
+	sort.Sort(signersAscending(signers))
+
+	genesis := &core.Genesis{ExtraData: make([]byte, extraVanity+2*common.AddressLength+extraSeal)}
+	for i, signer := range signers {
+		copy(genesis.ExtraData[extraVanity+i*common.AddressLength:], signer[:])
+	}
+	db := rawdb.NewMemoryDatabase()
+	genesis.Commit(db)
+
+	config := *params.TestChainConfig
+	config.Clique = &params.CliqueConfig{Period: 1, Epoch: 3}
+	engine := New(config.Clique, db)
+	engine.fakeDiff = true
+
+	blocks, _ := core.GenerateChain(&config, genesis.ToBlock(db), engine, db, 4, nil)
+	for i, block := range blocks {
+		header := block.Header()
+		if i > 0 {
+			header.ParentHash = blocks[i-1].Hash()
+		}
+		header.Extra = make([]byte, extraVanity+extraSeal)
+		if header.Number.Uint64() == 3 {
+			header.Extra = make([]byte, extraVanity+2*common.AddressLength+extraSeal)
+			accounts.checkpoint(header, []string{"A", "B"})
+		}
+		if header.Number.Uint64() == 1 {
+			header.Coinbase = accounts.address("C")
+			copy(header.Nonce[:], nonceAuthVote)
+		}
+		// Alternate between the signers, A always sealing in-turn
+		signer := []string{"A", "B"}[i%2]
+		header.Difficulty = diffNoTurn
+		if signers[header.Number.Uint64()%2] == accounts.address("A") && signer == "A" {
+			header.Difficulty = diffInTurn
+		}
+		accounts.sign(header, signer)
+		blocks[i] = block.WithSeal(header)
+	}
+	chain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create test chain: %v", err)
+	}
+	if _, err := chain.InsertChain(blocks); err != nil {
+		t.Fatalf("failed to import test chain: %v", err)
+	}
+	return &API{chain: chain, clique: engine}, accounts, blocks
+}
+
+// Tests that the sealing activity of the signers is reported correctly.
+func TestAPIStatus(t *testing.T) {
+	api, accounts, blocks := newTestAPI(t)
+
+	var inturn uint64
+	for _, block := range blocks {
+		if block.Difficulty().Cmp(diffInTurn) == 0 {
+			inturn++
+		}
+	}
+	status, err := api.Status(nil)
+	if err != nil {
+		t.Fatalf("failed to retrieve status: %v", err)
+	}
+	if status.NumBlocks != 4 {
+		t.Errorf("block count mismatch: have %d, want %d", status.NumBlocks, 4)
+	}
+	if want := float64(100*inturn) / 4; status.InturnPercent != want {
+		t.Errorf("in-turn percentage mismatch: have %v, want %v", status.InturnPercent, want)
+	}
+	a, b := status.Signers[accounts.address("A")], status.Signers[accounts.address("B")]
+	if a == nil || b == nil {
+		t.Fatalf("signer activity missing: %v", status.Signers)
+	}
+	if a.Sealed != 2 || a.InTurn != inturn || a.OutOfTurn != 2-inturn {
+		t.Errorf("signer A activity mismatch: have %+v", a)
+	}
+	if b.Sealed != 2 || b.InTurn != 0 || b.OutOfTurn != 2 {
+		t.Errorf("signer B activity mismatch: have %+v", b)
+	}
+	// Limiting the range must only inspect the most recent blocks
+	count := uint64(1)
+	if status, err = api.Status(&count); err != nil {
+		t.Fatalf("failed to retrieve limited status: %v", err)
+	}
+	if status.NumBlocks != 1 || status.Signers[accounts.address("A")].Sealed != 0 || status.Signers[accounts.address("B")].Sealed != 1 {
+		t.Errorf("limited status mismatch: have %d blocks, A %+v, B %+v", status.NumBlocks, status.Signers[accounts.address("A")], status.Signers[accounts.address("B")])
+	}
+}
+
+// Tests that block sealers are recovered correctly.
+func TestAPIGetSigner(t *testing.T) {
+	api, accounts, blocks := newTestAPI(t)
+
+	for i, block := range blocks {
+		want := accounts.address([]string{"A", "B"}[i%2])
+		if have, err := api.GetSigner(block.Hash()); err != nil || have != want {
+			t.Errorf("block %d: signer mismatch: have %x, %v, want %x", block.NumberU64(), have, err, want)
+		}
+	}
+	if _, err := api.GetSigner(common.Hash{}); err != errUnknownBlock {
+		t.Errorf("unknown block error mismatch: have %v, want %v", err, errUnknownBlock)
+	}
+}
+
+// Tests that the vote tallies of past epochs are retrieved correctly.
+func TestAPIGetTallyHistory(t *testing.T) {
+	api, accounts, blocks := newTestAPI(t)
+
+	epochs := uint64(3)
+	history, err := api.GetTallyHistory(&epochs)
+	if err != nil {
+		t.Fatalf("failed to retrieve tally history: %v", err)
+	}
+	if len(history) != 2 {
+		t.Fatalf("history length mismatch: have %d, want %d", len(history), 2)
+	}
+	// The current epoch started at the checkpoint, so no votes are pending
+	if history[0].Epoch != 1 || history[0].Hash != blocks[3].Hash() || len(history[0].Tally) != 0 {
+		t.Errorf("current epoch mismatch: have %+v", history[0])
+	}
+	// The first epoch ended with the vote of A still pending
+	if history[1].Epoch != 0 || history[1].Hash != blocks[1].Hash() {
+		t.Errorf("first epoch mismatch: have %+v", history[1])
+	}
+	if tally := history[1].Tally[accounts.address("C")]; !tally.Authorize || tally.Votes != 1 {
+		t.Errorf("first epoch tally mismatch: have %+v", tally)
+	}
+}
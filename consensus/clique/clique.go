@@ -135,6 +135,10 @@ var (
 	// errRecentlySigned is returned if a header is signed by an authorized entity
 	// that already signed a header recently, thus is temporarily not allowed to.
 	errRecentlySigned = errors.New("recently signed")
+
+	// errMissingGovernanceState is returned if a checkpoint block is attempted to
+	// be created without the state needed to read the governance contract.
+	errMissingGovernanceState = errors.New("governance contract state unavailable")
 )
 
 // SignerFn is a signer callback function to request a header to be signed by a
@@ -331,19 +335,25 @@ func (c *Clique) verifyCascadingFields(chain consensus.ChainReader, header *type
 	}
 	// If the block is a checkpoint block, verify the signer list
 	if number%c.config.Epoch == 0 {
-		signers := make([]byte, len(snap.Signers)*common.AddressLength)
-		for i, signer := range snap.signers() {
-			copy(signers[i*common.AddressLength:], signer[:])
-		}
 		extraSuffix := len(header.Extra) - extraSeal
-		if !bytes.Equal(header.Extra[extraVanity:extraSuffix], signers) {
-			return errMismatchingCheckpointSigners
+		if signers, ok := c.checkpointSigners(chain, parent, snap); ok {
+			if !bytes.Equal(header.Extra[extraVanity:extraSuffix], encodeSigners(signers)) {
+				return errMismatchingCheckpointSigners
+			}
+		} else if extraSuffix == extraVanity {
+			// Governance state unavailable, the best we can do is reject empty lists
+			return errInvalidCheckpointSigners
 		}
 	}
 	// All basic checks passed, verify the seal and return
 	return c.verifySeal(chain, header, parents)
 }
 
+// SnapshotAt retrieves the authorization snapshot right after the given header.
+func (c *Clique) SnapshotAt(chain consensus.ChainReader, header *types.Header) (*Snapshot, error) {
+	return c.snapshot(chain, header.Number.Uint64(), header.Hash(), nil)
+}
+
 // snapshot retrieves the authorization snapshot at a given point in time.
 func (c *Clique) snapshot(chain consensus.ChainReader, number uint64, hash common.Hash, parents []*types.Header) (*Snapshot, error) {
 	// Search for a snapshot in memory or on disk for checkpoints
@@ -495,7 +505,7 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 	if err != nil {
 		return err
 	}
-	if number%c.config.Epoch != 0 {
+	if number%c.config.Epoch != 0 && c.config.Governance == nil {
 		c.lock.RLock()
 
 		// Gather all the proposals that make sense voting on
@@ -525,10 +535,16 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 	}
 	header.Extra = header.Extra[:extraVanity]
 
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
 	if number%c.config.Epoch == 0 {
-		for _, signer := range snap.signers() {
-			header.Extra = append(header.Extra, signer[:]...)
+		signers, ok := c.checkpointSigners(chain, parent, snap)
+		if !ok {
+			return errMissingGovernanceState
 		}
+		header.Extra = append(header.Extra, encodeSigners(signers)...)
 	}
 	header.Extra = append(header.Extra, make([]byte, extraSeal)...)
 
@@ -536,10 +552,6 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 	header.MixDigest = common.Hash{}
 
 	// Ensure the timestamp has the correct delay
-	parent := chain.GetHeader(header.ParentHash, number-1)
-	if parent == nil {
-		return consensus.ErrUnknownAncestor
-	}
 	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(c.config.Period))
 	if header.Time.Int64() < time.Now().Unix() {
 		header.Time = big.NewInt(time.Now().Unix())
@@ -548,8 +560,15 @@ func (c *Clique) Prepare(chain consensus.ChainReader, header *types.Header) erro
 }
 
 // Finalize implements consensus.Engine, ensuring no uncles are set, nor block
-// rewards given, and returns the final block.
+// rewards given, and returns the final block. If a governance contract is used,
+// checkpoint blocks are also checked against the contract's signer list, since
+// the parent state is guaranteed to be available at this point.
 func (c *Clique) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	if number := header.Number.Uint64(); c.config.Governance != nil && number > 0 && number%c.config.Epoch == 0 {
+		if err := c.verifyGovernance(chain, header); err != nil {
+			return nil, err
+		}
+	}
 	// No block rewards in PoA, so the state remains as is and uncles are dropped
 	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
 	header.UncleHash = types.CalcUncleHash(nil)
@@ -682,6 +701,16 @@ func (c *Clique) APIs(chain consensus.ChainReader) []rpc.API {
 	}}
 }
 
+// encodeSigners flattens a list of signers into the format embedded into the
+// extra-data section of checkpoint headers.
+func encodeSigners(signers []common.Address) []byte {
+	blob := make([]byte, len(signers)*common.AddressLength)
+	for i, signer := range signers {
+		copy(blob[i*common.AddressLength:], signer[:])
+	}
+	return blob
+}
+
 // SealHash returns the hash of a block prior to it being sealed.
 func SealHash(header *types.Header) (hash common.Hash) {
 	hasher := sha3.NewLegacyKeccak256()
@@ -0,0 +1,133 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"bytes"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	// governanceSlot is the storage slot of the `address[] signers` array in the
+	// governance contract. The array length is stored at the slot itself, while
+	// the items are laid out from keccak256(slot) onwards, as per Solidity rules.
+	governanceSlot = 0
+
+	// maxGovernanceSigners is the maximum number of signers accepted from the
+	// governance contract. Longer lists are ignored to avoid unbounded reads.
+	maxGovernanceSigners = 1024
+)
+
+// stateReader is implemented by chains that can provide access to historical
+// state (e.g. core.BlockChain). Light and header-only chains cannot, in which
+// case the governance contract cannot be consulted during header verification.
+type stateReader interface {
+	StateAt(root common.Hash) (*state.StateDB, error)
+}
+
+// governanceSigners reads the authorized signer list from the governance contract
+// in the given state, returning it in ascending order with duplicates removed.
+func governanceSigners(statedb *state.StateDB, contract common.Address) []common.Address {
+	slot := common.BigToHash(big.NewInt(governanceSlot))
+
+	length := statedb.GetState(contract, slot).Big()
+	if !length.IsUint64() || length.Uint64() > maxGovernanceSigners {
+		log.Warn("Oversized governance signer list", "contract", contract, "length", length)
+		return nil
+	}
+	var (
+		start   = new(big.Int).SetBytes(crypto.Keccak256(slot[:]))
+		seen    = make(map[common.Address]struct{})
+		signers = make([]common.Address, 0, length.Uint64())
+	)
+	for i := uint64(0); i < length.Uint64(); i++ {
+		key := common.BigToHash(new(big.Int).Add(start, new(big.Int).SetUint64(i)))
+		signer := common.BytesToAddress(statedb.GetState(contract, key).Bytes())
+		if signer == (common.Address{}) {
+			continue
+		}
+		if _, ok := seen[signer]; ok {
+			continue
+		}
+		seen[signer] = struct{}{}
+		signers = append(signers, signer)
+	}
+	sort.Sort(signersAscending(signers))
+	return signers
+}
+
+// checkpointSigners returns the signer list that must be embedded into the
+// checkpoint header following parent. Without a governance contract this is the
+// list of signers in the snapshot. Otherwise the list is read from the contract's
+// storage in the state of the parent block, falling back to the snapshot if the
+// contract holds no signers. The returned flag reports whether the list could be
+// determined: chains that don't have the parent state available can't resolve it.
+func (c *Clique) checkpointSigners(chain consensus.ChainReader, parent *types.Header, snap *Snapshot) ([]common.Address, bool) {
+	if c.config.Governance == nil {
+		return snap.signers(), true
+	}
+	reader, ok := chain.(stateReader)
+	if !ok {
+		return nil, false
+	}
+	statedb, err := reader.StateAt(parent.Root)
+	if err != nil {
+		return nil, false
+	}
+	if signers := governanceSigners(statedb, *c.config.Governance); len(signers) > 0 {
+		return signers, true
+	}
+	return snap.signers(), true
+}
+
+// verifyGovernance checks that the signer list embedded into a checkpoint header
+// matches the one held by the governance contract in the parent state. Chains not
+// having the parent state available skip the check.
+func (c *Clique) verifyGovernance(chain consensus.ChainReader, header *types.Header) error {
+	if _, ok := chain.(stateReader); !ok {
+		return nil
+	}
+	number := header.Number.Uint64()
+
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	snap, err := c.snapshot(chain, number-1, header.ParentHash, nil)
+	if err != nil {
+		return err
+	}
+	signers, ok := c.checkpointSigners(chain, parent, snap)
+	if !ok {
+		return nil
+	}
+	if len(header.Extra) < extraVanity+extraSeal {
+		return errMissingSignature
+	}
+	if !bytes.Equal(header.Extra[extraVanity:len(header.Extra)-extraSeal], encodeSigners(signers)) {
+		return errMismatchingCheckpointSigners
+	}
+	return nil
+}
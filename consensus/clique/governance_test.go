@@ -0,0 +1,172 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package clique
+
+import (
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// governanceStorage assembles the storage of a governance contract holding the
+// given signers in a Solidity `address[]` at the governance slot.
+func governanceStorage(signers []common.Address) map[common.Hash]common.Hash {
+	slot := common.BigToHash(big.NewInt(governanceSlot))
+	start := new(big.Int).SetBytes(crypto.Keccak256(slot[:]))
+
+	storage := map[common.Hash]common.Hash{
+		slot: common.BigToHash(big.NewInt(int64(len(signers)))),
+	}
+	for i, signer := range signers {
+		key := common.BigToHash(new(big.Int).Add(start, big.NewInt(int64(i))))
+		storage[key] = common.BytesToHash(signer[:])
+	}
+	return storage
+}
+
+// Tests that checkpoint blocks on a governance contract managed chain must carry
+// the signer list held by the contract, and that the list takes effect afterwards.
+func TestGovernance(t *testing.T) {
+	tests := []struct {
+		contract   []string // Signers stored in the governance contract
+		checkpoint []string // Signers embedded into the checkpoint block
+		results    []string // Expected signers after the checkpoint
+		failure    error
+	}{
+		// Contract adds a new signer, which can sign straight after the checkpoint
+		{contract: []string{"A", "B"}, checkpoint: []string{"A", "B"}, results: []string{"A", "B"}},
+		// Contract swaps out the only signer
+		{contract: []string{"B", "B"}, checkpoint: []string{"B"}, results: []string{"B"}},
+		// Empty contract retains the current signer set
+		{contract: nil, checkpoint: []string{"A"}, results: []string{"A"}},
+		// Checkpoint not matching the contract is rejected
+		{contract: []string{"A", "B"}, checkpoint: []string{"A"}, failure: errMismatchingCheckpointSigners},
+	}
+	for i, tt := range tests {
+		accounts := newTesterAccountPool()
+
+		contract := make([]common.Address, len(tt.contract))
+		for j, signer := range tt.contract {
+			contract[j] = accounts.address(signer)
+		}
+		governance := common.HexToAddress("0x0000000000000000000000000000000000000c11")
+
+		// Create the genesis block with a single signer and the governance contract
+		genesis := &core.Genesis{
+			ExtraData: make([]byte, extraVanity+common.AddressLength+extraSeal),
+			Alloc: core.GenesisAlloc{
+				governance: {Balance: new(big.Int), Storage: governanceStorage(contract)},
+			},
+		}
+		copy(genesis.ExtraData[extraVanity:], accounts.address("A").Bytes())
+
+		db := rawdb.NewMemoryDatabase()
+		genesis.Commit(db)
+
+		config := *params.TestChainConfig
+		config.Clique = &params.CliqueConfig{Period: 1, Epoch: 3, Governance: &governance}
+		engine := New(config.Clique, db)
+		engine.fakeDiff = true
+
+		blocks, _ := core.GenerateChain(&config, genesis.ToBlock(db), engine, db, 4, nil)
+		signers := []string{"A", "A", "A", tt.checkpoint[0]}
+		if len(tt.checkpoint) > 1 {
+			signers[3] = tt.checkpoint[1]
+		}
+		for j, block := range blocks {
+			header := block.Header()
+			if j > 0 {
+				header.ParentHash = blocks[j-1].Hash()
+			}
+			header.Extra = make([]byte, extraVanity+extraSeal)
+			if header.Number.Uint64() == 3 {
+				header.Extra = make([]byte, extraVanity+len(tt.checkpoint)*common.AddressLength+extraSeal)
+				accounts.checkpoint(header, tt.checkpoint)
+			}
+			header.Difficulty = diffInTurn
+
+			accounts.sign(header, signers[j])
+			blocks[j] = block.WithSeal(header)
+		}
+		chain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{}, nil)
+		if err != nil {
+			t.Fatalf("test %d: failed to create test chain: %v", i, err)
+		}
+		if _, err := chain.InsertChain(blocks[:2]); err != nil {
+			t.Fatalf("test %d: failed to import pre-checkpoint blocks: %v", i, err)
+		}
+		if _, err := chain.InsertChain(blocks[2:]); err != tt.failure {
+			t.Errorf("test %d: failure mismatch: have %v, want %v", i, err, tt.failure)
+		}
+		if tt.failure != nil {
+			continue
+		}
+		snap, err := engine.snapshot(chain, 4, blocks[3].Hash(), nil)
+		if err != nil {
+			t.Errorf("test %d: failed to retrieve snapshot: %v", i, err)
+			continue
+		}
+		want := make([]common.Address, len(tt.results))
+		for j, signer := range tt.results {
+			want[j] = accounts.address(signer)
+		}
+		sort.Sort(signersAscending(want))
+
+		if have := snap.signers(); !reflect.DeepEqual(have, want) {
+			t.Errorf("test %d: signers mismatch: have %x, want %x", i, have, want)
+		}
+	}
+}
+
+// Tests that the signer list is correctly decoded from the contract storage.
+func TestGovernanceSigners(t *testing.T) {
+	var (
+		db       = rawdb.NewMemoryDatabase()
+		contract = common.HexToAddress("0x0000000000000000000000000000000000000c11")
+		signers  = []common.Address{
+			common.HexToAddress("0x0000000000000000000000000000000000000003"),
+			common.HexToAddress("0x0000000000000000000000000000000000000001"),
+			{},
+			common.HexToAddress("0x0000000000000000000000000000000000000003"),
+		}
+	)
+	genesis := &core.Genesis{Alloc: core.GenesisAlloc{
+		contract: {Balance: new(big.Int), Storage: governanceStorage(signers)},
+	}}
+	block := genesis.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, params.TestChainConfig, New(&params.CliqueConfig{}, db), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create test chain: %v", err)
+	}
+	statedb, err := chain.StateAt(block.Root())
+	if err != nil {
+		t.Fatalf("failed to open genesis state: %v", err)
+	}
+	want := []common.Address{signers[1], signers[0]}
+	if have := governanceSigners(statedb, contract); !reflect.DeepEqual(have, want) {
+		t.Errorf("signers mismatch: have %x, want %x", have, want)
+	}
+}
@@ -223,6 +223,14 @@ func (s *Snapshot) apply(headers []*types.Header) (*Snapshot, error) {
 		}
 		snap.Recents[number] = signer
 
+		// If signers are managed by a governance contract, votes are ignored and
+		// the authorized set is replaced wholesale on checkpoint blocks
+		if s.config.Governance != nil {
+			if number%s.config.Epoch == 0 {
+				snap.resetSigners(number, header)
+			}
+			continue
+		}
 		// Header authorized, discard any previous votes from the signer
 		for i, vote := range snap.Votes {
 			if vote.Signer == signer && vote.Address == header.Coinbase {
@@ -292,6 +300,24 @@ func (s *Snapshot) apply(headers []*types.Header) (*Snapshot, error) {
 	return snap, nil
 }
 
+// resetSigners replaces the set of authorized signers with the list embedded
+// into the given checkpoint header, dropping any recent signers that would
+// otherwise linger forever due to the signer set shrinking.
+func (s *Snapshot) resetSigners(number uint64, checkpoint *types.Header) {
+	signers := checkpoint.Extra[extraVanity : len(checkpoint.Extra)-extraSeal]
+
+	s.Signers = make(map[common.Address]struct{})
+	for i := 0; i+common.AddressLength <= len(signers); i += common.AddressLength {
+		s.Signers[common.BytesToAddress(signers[i:i+common.AddressLength])] = struct{}{}
+	}
+	limit := uint64(len(s.Signers)/2 + 1)
+	for block := range s.Recents {
+		if block+limit <= number+1 {
+			delete(s.Recents, block)
+		}
+	}
+}
+
 // signers retrieves the list of authorized signers in ascending order.
 func (s *Snapshot) signers() []common.Address {
 	sigs := make([]common.Address, 0, len(s.Signers))
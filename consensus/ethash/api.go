@@ -17,11 +17,14 @@
 package ethash
 
 import (
+	"context"
 	"errors"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
 )
 
 var errEthashStopped = errors.New("ethash stopped")
@@ -43,23 +46,7 @@ func (api *API) GetWork() ([4]string, error) {
 		return [4]string{}, errors.New("not supported")
 	}
 
-	var (
-		workCh = make(chan [4]string, 1)
-		errc   = make(chan error, 1)
-	)
-
-	select {
-	case api.ethash.fetchWorkCh <- &sealWork{errc: errc, res: workCh}:
-	case <-api.ethash.exitCh:
-		return [4]string{}, errEthashStopped
-	}
-
-	select {
-	case work := <-workCh:
-		return work, nil
-	case err := <-errc:
-		return [4]string{}, err
-	}
+	return api.ethash.remoteWork()
 }
 
 // SubmitWork can be used by external miner to submit their POW solution.
@@ -70,21 +57,7 @@ func (api *API) SubmitWork(nonce types.BlockNonce, hash, digest common.Hash) boo
 		return false
 	}
 
-	var errc = make(chan error, 1)
-
-	select {
-	case api.ethash.submitWorkCh <- &mineResult{
-		nonce:     nonce,
-		mixDigest: digest,
-		hash:      hash,
-		errc:      errc,
-	}:
-	case <-api.ethash.exitCh:
-		return false
-	}
-
-	err := <-errc
-	return err == nil
+	return api.ethash.submitRemoteWork(nonce, hash, digest, "")
 }
 
 // SubmitHashrate can be used for remote miners to submit their hash rate.
@@ -98,21 +71,68 @@ func (api *API) SubmitHashRate(rate hexutil.Uint64, id common.Hash) bool {
 		return false
 	}
 
-	var done = make(chan struct{}, 1)
+	return api.ethash.submitRemoteHashrate(uint64(rate), id, id.Hex())
+}
+
+// GetHashrate returns the current hashrate for local CPU miner and remote miner.
+func (api *API) GetHashrate() uint64 {
+	return uint64(api.ethash.Hashrate())
+}
+
+// WorkerStats is the mining activity of a single remote worker.
+type WorkerStats struct {
+	ReportedHashrate  uint64    `json:"reportedHashrate"`  // Hash rate last reported by the worker
+	EffectiveHashrate uint64    `json:"effectiveHashrate"` // Hash rate derived from the accepted shares
+	Shares            uint64    `json:"shares"`            // Number of valid shares submitted
+	StaleShares       uint64    `json:"staleShares"`       // Number of shares submitted for unknown or outdated work
+	InvalidShares     uint64    `json:"invalidShares"`     // Number of shares failing proof-of-work verification or submitted repeatedly
+	LastSeen          time.Time `json:"lastSeen"`          // Last time the worker submitted anything
+}
+
+// GetWorkers returns the mining activity of the named remote workers, keyed by
+// worker name. Workers are named by their stratum login, or by the identifier
+// of their submitted hash rate.
+func (api *API) GetWorkers() (map[string]*WorkerStats, error) {
+	if api.ethash.config.PowMode != ModeNormal && api.ethash.config.PowMode != ModeTest {
+		return nil, errors.New("not supported")
+	}
+	req := make(chan map[string]*WorkerStats, 1)
 
 	select {
-	case api.ethash.submitRateCh <- &hashrate{done: done, rate: uint64(rate), id: id}:
+	case api.ethash.fetchWorkersCh <- req:
 	case <-api.ethash.exitCh:
-		return false
+		return nil, errEthashStopped
 	}
-
-	// Block until hash rate submitted successfully.
-	<-done
-
-	return true
+	return <-req, nil
 }
 
-// GetHashrate returns the current hashrate for local CPU miner and remote miner.
-func (api *API) GetHashrate() uint64 {
-	return uint64(api.ethash.Hashrate())
+// NewWork creates a subscription that is triggered each time a new work package
+// is available for remote miners, pushing the same package GetWork would return.
+func (api *API) NewWork(ctx context.Context) (*rpc.Subscription, error) {
+	if api.ethash.config.PowMode != ModeNormal && api.ethash.config.PowMode != ModeTest {
+		return &rpc.Subscription{}, errors.New("not supported")
+	}
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	go func() {
+		works := make(chan [4]string, 16)
+		sub := api.ethash.workFeed.Subscribe(works)
+		defer sub.Unsubscribe()
+
+		for {
+			select {
+			case work := <-works:
+				notifier.Notify(rpcSub.ID, work)
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return rpcSub, nil
 }
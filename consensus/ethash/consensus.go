@@ -506,12 +506,25 @@ func (ethash *Ethash) verifySeal(chain consensus.ChainReader, header *types.Head
 		return errInvalidDifficulty
 	}
 	// Recompute the digest and PoW values
+	digest, result := ethash.hashimoto(header, fulldag)
+
+	// Verify the calculated values against the ones provided in the header
+	if !bytes.Equal(header.MixDigest[:], digest) {
+		return errInvalidMixDigest
+	}
+	target := new(big.Int).Div(two256, header.Difficulty)
+	if new(big.Int).SetBytes(result).Cmp(target) > 0 {
+		return errInvalidPoW
+	}
+	return nil
+}
+
+// hashimoto recomputes the mix digest and the PoW value of a sealed header, using
+// either the full ethash dataset if requested and available, or the verification
+// cache otherwise.
+func (ethash *Ethash) hashimoto(header *types.Header, fulldag bool) (digest []byte, result []byte) {
 	number := header.Number.Uint64()
 
-	var (
-		digest []byte
-		result []byte
-	)
 	// If fast-but-heavy PoW verification was requested, use an ethash dataset
 	if fulldag {
 		dataset := ethash.dataset(number, true)
@@ -540,15 +553,7 @@ func (ethash *Ethash) verifySeal(chain consensus.ChainReader, header *types.Head
 		// until after the call to hashimotoLight so it's not unmapped while being used.
 		runtime.KeepAlive(cache)
 	}
-	// Verify the calculated values against the ones provided in the header
-	if !bytes.Equal(header.MixDigest[:], digest) {
-		return errInvalidMixDigest
-	}
-	target := new(big.Int).Div(two256, header.Difficulty)
-	if new(big.Int).SetBytes(result).Cmp(target) > 0 {
-		return errInvalidPoW
-	}
-	return nil
+	return digest, result
 }
 
 // Prepare implements consensus.Engine, initializing the difficulty field of a
@@ -37,6 +37,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/metrics"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -50,7 +51,7 @@ var (
 	two256 = new(big.Int).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0))
 
 	// sharedEthash is a full instance that can be shared between multiple users.
-	sharedEthash = New(Config{"", 3, 0, "", 1, 0, ModeNormal}, nil, false)
+	sharedEthash = New(Config{"", 3, 0, "", 1, 0, ModeNormal, "", 0}, nil, false)
 
 	// algorithmRevision is the data structure version used for file naming.
 	algorithmRevision = 23
@@ -403,6 +404,10 @@ type Config struct {
 	DatasetsInMem  int
 	DatasetsOnDisk int
 	PowMode        Mode
+
+	// Remote sealer settings
+	StratumAddr     string `toml:",omitempty"` // Listening address of the stratum server (disabled if empty)
+	ShareDifficulty uint64 `toml:",omitempty"` // Difficulty of the shares accepted from remote miners (block difficulty if zero)
 }
 
 // sealTask wraps a seal block with relative result channel for remote sealer thread.
@@ -416,15 +421,17 @@ type mineResult struct {
 	nonce     types.BlockNonce
 	mixDigest common.Hash
 	hash      common.Hash
+	worker    string // Name of the remote worker submitting the result, if known
 
 	errc chan error
 }
 
 // hashrate wraps the hash rate submitted by the remote sealer.
 type hashrate struct {
-	id   common.Hash
-	ping time.Time
-	rate uint64
+	id     common.Hash
+	ping   time.Time
+	rate   uint64
+	worker string // Name of the remote worker submitting the rate, if known
 
 	done chan struct{}
 }
@@ -456,6 +463,10 @@ type Ethash struct {
 	fetchRateCh  chan chan uint64 // Channel used to gather submitted hash rate for local or remote sealer.
 	submitRateCh chan *hashrate   // Channel used for remote sealer to submit their mining hashrate
 
+	fetchWorkersCh chan chan map[string]*WorkerStats // Channel used to gather the activity of remote workers
+	workFeed       event.Feed                        // Feed announcing new work packages to push based remote miners
+	stratum        *stratumServer                    // Stratum server pushing work to remote miners, if started
+
 	// The fields below are hooks for testing
 	shared    *Ethash       // Shared PoW verifier to avoid cache regeneration
 	fakeFail  uint64        // Block number which fails PoW check even in fake mode
@@ -492,6 +503,8 @@ func New(config Config, notify []string, noverify bool) *Ethash {
 		fetchRateCh:  make(chan chan uint64),
 		submitRateCh: make(chan *hashrate),
 		exitCh:       make(chan chan error),
+
+		fetchWorkersCh: make(chan chan map[string]*WorkerStats),
 	}
 	go ethash.remote(notify, noverify)
 	return ethash
@@ -512,6 +525,8 @@ func NewTester(notify []string, noverify bool) *Ethash {
 		fetchRateCh:  make(chan chan uint64),
 		submitRateCh: make(chan *hashrate),
 		exitCh:       make(chan chan error),
+
+		fetchWorkersCh: make(chan chan map[string]*WorkerStats),
 	}
 	go ethash.remote(notify, noverify)
 	return ethash
@@ -576,6 +591,14 @@ func (ethash *Ethash) Close() error {
 		if ethash.exitCh == nil {
 			return
 		}
+		ethash.lock.Lock()
+		stratum := ethash.stratum
+		ethash.stratum = nil
+		ethash.lock.Unlock()
+
+		if stratum != nil {
+			stratum.close()
+		}
 		errc := make(chan error)
 		ethash.exitCh <- errc
 		err = <-errc
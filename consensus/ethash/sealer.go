@@ -34,11 +34,15 @@ import (
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/metrics"
 )
 
 const (
 	// staleThreshold is the maximum depth of the acceptable stale but valid ethash solution.
 	staleThreshold = 7
+
+	// workerTimeout is the time after which an inactive remote worker is forgotten.
+	workerTimeout = 10 * time.Minute
 )
 
 var (
@@ -189,8 +193,11 @@ search:
 // remote is a standalone goroutine to handle remote mining related stuff.
 func (ethash *Ethash) remote(notify []string, noverify bool) {
 	var (
-		works = make(map[common.Hash]*types.Block)
-		rates = make(map[common.Hash]hashrate)
+		works   = make(map[common.Hash]*types.Block)
+		shares  = make(map[common.Hash]map[types.BlockNonce]struct{}) // Nonces accepted per work package
+		rates   = make(map[common.Hash]hashrate)
+		workers = make(map[string]*remoteWorker)
+		pushes  = make(chan [4]string, 1)
 
 		results      chan<- *types.Block
 		currentBlock *types.Block
@@ -229,6 +236,38 @@ func (ethash *Ethash) remote(notify []string, noverify bool) {
 			}(notifyReqs[i], url)
 		}
 	}
+	// Feed the work packages to push based miners from a separate goroutine, so
+	// slow subscribers can't hold up the sealer.
+	go func() {
+		for work := range pushes {
+			ethash.workFeed.Send(work)
+		}
+	}()
+	defer close(pushes)
+
+	// pushWork announces the current work package to push based miners, replacing
+	// any stale package not yet picked up by the feeding goroutine.
+	pushWork := func() {
+		select {
+		case <-pushes:
+		default:
+		}
+		pushes <- currentWork
+	}
+	// track retrieves the activity tracker of a named remote worker, creating a
+	// new one if the worker wasn't seen yet. Anonymous workers aren't tracked.
+	track := func(name string) *remoteWorker {
+		if name == "" {
+			return nil
+		}
+		worker := workers[name]
+		if worker == nil {
+			worker = &remoteWorker{effective: metrics.NewMeterForced()}
+			workers[name] = worker
+		}
+		worker.seen = time.Now()
+		return worker
+	}
 	// makeWork creates a work package for external miner.
 	//
 	// The work package consists of 3 strings:
@@ -236,12 +275,15 @@ func (ethash *Ethash) remote(notify []string, noverify bool) {
 	//   result[1], 32 bytes hex encoded seed hash used for DAG
 	//   result[2], 32 bytes hex encoded boundary condition ("target"), 2^256/difficulty
 	//   result[3], hex encoded block number
+	//
+	// If a share difficulty lower than the block's is configured, the boundary
+	// condition is derived from the share difficulty instead.
 	makeWork := func(block *types.Block) {
 		hash := ethash.SealHash(block.Header())
 
 		currentWork[0] = hash.Hex()
 		currentWork[1] = common.BytesToHash(SeedHash(block.NumberU64())).Hex()
-		currentWork[2] = common.BytesToHash(new(big.Int).Div(two256, block.Difficulty()).Bytes()).Hex()
+		currentWork[2] = common.BytesToHash(new(big.Int).Div(two256, ethash.shareDifficulty(block.Header())).Bytes()).Hex()
 		currentWork[3] = hexutil.EncodeBig(block.Number())
 
 		// Trace the seal work fetched by remote sealer.
@@ -250,16 +292,26 @@ func (ethash *Ethash) remote(notify []string, noverify bool) {
 	}
 	// submitWork verifies the submitted pow solution, returning
 	// whether the solution was accepted or not (not can be both a bad pow as well as
-	// any other error, like no pending work or stale mining result).
-	submitWork := func(nonce types.BlockNonce, mixDigest common.Hash, sealhash common.Hash) bool {
+	// any other error, like no pending work or stale mining result). Solutions only
+	// satisfying the share difficulty are accepted, but not sealed into blocks.
+	submitWork := func(nonce types.BlockNonce, mixDigest common.Hash, sealhash common.Hash, name string) bool {
 		if currentBlock == nil {
 			log.Error("Pending work without block", "sealhash", sealhash)
 			return false
 		}
+		worker := track(name)
+
 		// Make sure the work submitted is present
 		block := works[sealhash]
 		if block == nil {
-			log.Warn("Work submitted but none pending", "sealhash", sealhash, "curnumber", currentBlock.NumberU64())
+			log.Warn("Work submitted but none pending", "sealhash", sealhash, "curnumber", currentBlock.NumberU64(), "worker", name)
+			worker.markStale()
+			return false
+		}
+		// Reject shares submitted repeatedly for the same work package.
+		if _, ok := shares[sealhash][nonce]; ok {
+			log.Warn("Duplicate proof-of-work submitted", "sealhash", sealhash, "nonce", nonce, "worker", name)
+			worker.markInvalid()
 			return false
 		}
 		// Verify the correctness of submitted result.
@@ -267,13 +319,30 @@ func (ethash *Ethash) remote(notify []string, noverify bool) {
 		header.Nonce = nonce
 		header.MixDigest = mixDigest
 
-		start := time.Now()
+		start, solved := time.Now(), true
 		if !noverify {
-			if err := ethash.verifySeal(nil, header, true); err != nil {
-				log.Warn("Invalid proof-of-work submitted", "sealhash", sealhash, "elapsed", time.Since(start), "err", err)
+			var err error
+			if solved, err = ethash.verifyShare(header); err != nil {
+				log.Warn("Invalid proof-of-work submitted", "sealhash", sealhash, "elapsed", time.Since(start), "worker", name, "err", err)
+				worker.markInvalid()
 				return false
 			}
 		}
+		// The submitted block is too old to accept, drop it.
+		if block.NumberU64()+staleThreshold <= currentBlock.NumberU64() {
+			log.Warn("Work submitted is too old", "number", block.NumberU64(), "sealhash", sealhash, "worker", name)
+			worker.markStale()
+			return false
+		}
+		if shares[sealhash] == nil {
+			shares[sealhash] = make(map[types.BlockNonce]struct{})
+		}
+		shares[sealhash][nonce] = struct{}{}
+		worker.markShare(ethash.shareDifficulty(header))
+		if !solved {
+			log.Trace("Verified correct proof-of-work share", "sealhash", sealhash, "elapsed", time.Since(start), "worker", name)
+			return true
+		}
 		// Make sure the result channel is assigned.
 		if results == nil {
 			log.Warn("Ethash result channel is empty, submitted mining result is rejected")
@@ -284,20 +353,14 @@ func (ethash *Ethash) remote(notify []string, noverify bool) {
 		// Solutions seems to be valid, return to the miner and notify acceptance.
 		solution := block.WithSeal(header)
 
-		// The submitted solution is within the scope of acceptance.
-		if solution.NumberU64()+staleThreshold > currentBlock.NumberU64() {
-			select {
-			case results <- solution:
-				log.Debug("Work submitted is acceptable", "number", solution.NumberU64(), "sealhash", sealhash, "hash", solution.Hash())
-				return true
-			default:
-				log.Warn("Sealing result is not read by miner", "mode", "remote", "sealhash", sealhash)
-				return false
-			}
+		select {
+		case results <- solution:
+			log.Debug("Work submitted is acceptable", "number", solution.NumberU64(), "sealhash", sealhash, "hash", solution.Hash())
+			return true
+		default:
+			log.Warn("Sealing result is not read by miner", "mode", "remote", "sealhash", sealhash)
+			return false
 		}
-		// The submitted block is too old to accept, drop it.
-		log.Warn("Work submitted is too old", "number", solution.NumberU64(), "sealhash", sealhash, "hash", solution.Hash())
-		return false
 	}
 
 	ticker := time.NewTicker(5 * time.Second)
@@ -312,8 +375,9 @@ func (ethash *Ethash) remote(notify []string, noverify bool) {
 
 			makeWork(work.block)
 
-			// Notify and requested URLs of the new work availability
+			// Notify and requested URLs and push based miners of the new work availability
 			notifyWork()
+			pushWork()
 
 		case work := <-ethash.fetchWorkCh:
 			// Return current mining work to remote miner.
@@ -325,7 +389,7 @@ func (ethash *Ethash) remote(notify []string, noverify bool) {
 
 		case result := <-ethash.submitWorkCh:
 			// Verify submitted PoW solution based on maintained mining blocks.
-			if submitWork(result.nonce, result.mixDigest, result.hash) {
+			if submitWork(result.nonce, result.mixDigest, result.hash, result.worker) {
 				result.errc <- nil
 			} else {
 				result.errc <- errInvalidSealResult
@@ -334,8 +398,19 @@ func (ethash *Ethash) remote(notify []string, noverify bool) {
 		case result := <-ethash.submitRateCh:
 			// Trace remote sealer's hash rate by submitted value.
 			rates[result.id] = hashrate{rate: result.rate, ping: time.Now()}
+			if worker := track(result.worker); worker != nil {
+				worker.reported = result.rate
+			}
 			close(result.done)
 
+		case req := <-ethash.fetchWorkersCh:
+			// Gather the activity of all tracked remote workers.
+			stats := make(map[string]*WorkerStats, len(workers))
+			for name, worker := range workers {
+				stats[name] = worker.stats()
+			}
+			req <- stats
+
 		case req := <-ethash.fetchRateCh:
 			// Gather all hash rate submitted by remote sealer.
 			var total uint64
@@ -352,20 +427,163 @@ func (ethash *Ethash) remote(notify []string, noverify bool) {
 					delete(rates, id)
 				}
 			}
+			// Forget about workers not submitting anything for a while
+			for name, worker := range workers {
+				if time.Since(worker.seen) > workerTimeout {
+					worker.effective.Stop()
+					delete(workers, name)
+				}
+			}
 			// Clear stale pending blocks
 			if currentBlock != nil {
 				for hash, block := range works {
 					if block.NumberU64()+staleThreshold <= currentBlock.NumberU64() {
 						delete(works, hash)
+						delete(shares, hash)
 					}
 				}
 			}
 
 		case errc := <-ethash.exitCh:
 			// Exit remote loop if ethash is closed and return relevant error.
+			for _, worker := range workers {
+				worker.effective.Stop()
+			}
 			errc <- nil
 			log.Trace("Ethash remote sealer is exiting")
 			return
 		}
 	}
 }
+
+// remoteWork retrieves the current work package from the remote sealer.
+func (ethash *Ethash) remoteWork() ([4]string, error) {
+	var (
+		workCh = make(chan [4]string, 1)
+		errc   = make(chan error, 1)
+	)
+	select {
+	case ethash.fetchWorkCh <- &sealWork{errc: errc, res: workCh}:
+	case <-ethash.exitCh:
+		return [4]string{}, errEthashStopped
+	}
+	select {
+	case work := <-workCh:
+		return work, nil
+	case err := <-errc:
+		return [4]string{}, err
+	}
+}
+
+// submitRemoteWork passes a proof-of-work solution of a remote worker to the
+// remote sealer, returning whether it was accepted.
+func (ethash *Ethash) submitRemoteWork(nonce types.BlockNonce, hash, digest common.Hash, worker string) bool {
+	var errc = make(chan error, 1)
+
+	select {
+	case ethash.submitWorkCh <- &mineResult{
+		nonce:     nonce,
+		mixDigest: digest,
+		hash:      hash,
+		worker:    worker,
+		errc:      errc,
+	}:
+	case <-ethash.exitCh:
+		return false
+	}
+	err := <-errc
+	return err == nil
+}
+
+// submitRemoteHashrate passes the hash rate reported by a remote worker to the
+// remote sealer.
+func (ethash *Ethash) submitRemoteHashrate(rate uint64, id common.Hash, worker string) bool {
+	var done = make(chan struct{}, 1)
+
+	select {
+	case ethash.submitRateCh <- &hashrate{done: done, rate: rate, id: id, worker: worker}:
+	case <-ethash.exitCh:
+		return false
+	}
+	// Block until hash rate submitted successfully.
+	<-done
+
+	return true
+}
+
+// shareDifficulty returns the difficulty of the proof-of-work shares accepted from
+// remote miners working on the given header.
+func (ethash *Ethash) shareDifficulty(header *types.Header) *big.Int {
+	if share := ethash.config.ShareDifficulty; share > 0 && new(big.Int).SetUint64(share).Cmp(header.Difficulty) < 0 {
+		return new(big.Int).SetUint64(share)
+	}
+	return header.Difficulty
+}
+
+// verifyShare checks whether the proof-of-work of a remotely sealed header satisfies
+// the share difficulty, also reporting whether it satisfies the block difficulty.
+func (ethash *Ethash) verifyShare(header *types.Header) (bool, error) {
+	if header.Difficulty.Sign() <= 0 {
+		return false, errInvalidDifficulty
+	}
+	digest, result := ethash.hashimoto(header, true)
+	if !bytes.Equal(header.MixDigest[:], digest) {
+		return false, errInvalidMixDigest
+	}
+	value := new(big.Int).SetBytes(result)
+	if value.Cmp(new(big.Int).Div(two256, ethash.shareDifficulty(header))) > 0 {
+		return false, errInvalidPoW
+	}
+	return value.Cmp(new(big.Int).Div(two256, header.Difficulty)) <= 0, nil
+}
+
+// remoteWorker tracks the activity of a single named remote miner. All methods
+// are safe to call on a nil worker, which represents an anonymous miner.
+type remoteWorker struct {
+	reported  uint64        // Hash rate last reported by the worker
+	effective metrics.Meter // Difficulty of the accepted shares, metering the effective hash rate
+	shares    uint64        // Number of valid shares submitted
+	stale     uint64        // Number of shares submitted for unknown or outdated work
+	invalid   uint64        // Number of shares failing proof-of-work verification or submitted repeatedly
+	seen      time.Time     // Last time the worker submitted anything
+}
+
+// markShare records a valid share of the given difficulty.
+func (w *remoteWorker) markShare(difficulty *big.Int) {
+	if w == nil {
+		return
+	}
+	w.shares++
+	if difficulty.IsInt64() {
+		w.effective.Mark(difficulty.Int64())
+	} else {
+		w.effective.Mark(math.MaxInt64)
+	}
+}
+
+// markStale records a share submitted for unknown or outdated work.
+func (w *remoteWorker) markStale() {
+	if w != nil {
+		w.stale++
+	}
+}
+
+// markInvalid records a share failing proof-of-work verification or submitted
+// repeatedly.
+func (w *remoteWorker) markInvalid() {
+	if w != nil {
+		w.invalid++
+	}
+}
+
+// stats returns a snapshot of the worker's activity.
+func (w *remoteWorker) stats() *WorkerStats {
+	return &WorkerStats{
+		ReportedHashrate:  w.reported,
+		EffectiveHashrate: uint64(w.effective.Rate1()),
+		Shares:            w.shares,
+		StaleShares:       w.stale,
+		InvalidShares:     w.invalid,
+		LastSeen:          w.seen,
+	}
+}
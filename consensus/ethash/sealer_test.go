@@ -1,6 +1,7 @@
 package ethash
 
 import (
+	"bufio"
 	"encoding/json"
 	"io/ioutil"
 	"math/big"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
 )
 
@@ -209,3 +211,161 @@ func TestStaleSubmission(t *testing.T) {
 		}
 	}
 }
+
+// Tests that stratum miners get new work pushed and their requests served.
+func TestStratumNotify(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	if err := ethash.StartStratum("127.0.0.1:0"); err != nil {
+		t.Fatalf("failed to start stratum server: %v", err)
+	}
+	conn, err := net.Dial("tcp", ethash.stratum.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to connect to stratum server: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(3 * time.Second))
+
+	reader := bufio.NewReader(conn)
+	call := func(request string) map[string]interface{} {
+		if _, err := conn.Write([]byte(request + "\n")); err != nil {
+			t.Fatalf("failed to send request: %v", err)
+		}
+		return readStratum(t, reader)
+	}
+	// Log in and ensure new work is pushed without polling
+	if res := call(`{"id":1,"jsonrpc":"2.0","method":"eth_submitLogin","params":["0x0000000000000000000000000000000000000001"],"worker":"rig"}`); res["result"] != true {
+		t.Fatalf("login rejected: %v", res)
+	}
+	header := &types.Header{Number: big.NewInt(1), Difficulty: big.NewInt(100000000)}
+	ethash.Seal(nil, types.NewBlockWithHeader(header), nil, nil)
+
+	work := readStratum(t, reader)
+	if id := work["id"]; id != float64(0) {
+		t.Errorf("pushed work id mismatch: have %v, want 0", id)
+	}
+	if result, ok := work["result"].([]interface{}); !ok || len(result) != 4 || result[0] != ethash.SealHash(header).Hex() {
+		t.Errorf("pushed work mismatch: have %v, want hash %s", work["result"], ethash.SealHash(header).Hex())
+	}
+	// Submit an invalid solution and a hash rate, and ensure the worker is tracked
+	if res := call(`{"id":2,"jsonrpc":"2.0","method":"eth_submitWork","params":["0x0000000000000001","` + ethash.SealHash(header).Hex() + `","0x0000000000000000000000000000000000000000000000000000000000000000"]}`); res["result"] != false {
+		t.Errorf("invalid solution accepted: %v", res)
+	}
+	if res := call(`{"id":3,"jsonrpc":"2.0","method":"eth_submitHashrate","params":["0x100","0x0000000000000000000000000000000000000000000000000000000000000001"]}`); res["result"] != true {
+		t.Errorf("hash rate rejected: %v", res)
+	}
+	if res := call(`{"id":4,"jsonrpc":"2.0","method":"eth_unknown","params":[]}`); res["error"] == nil {
+		t.Errorf("unknown method accepted: %v", res)
+	}
+	workers, err := (&API{ethash}).GetWorkers()
+	if err != nil {
+		t.Fatalf("failed to retrieve workers: %v", err)
+	}
+	stats := workers["0x0000000000000000000000000000000000000001.rig"]
+	if stats == nil {
+		t.Fatalf("stratum worker not tracked: %v", workers)
+	}
+	if stats.InvalidShares != 1 || stats.ReportedHashrate != 0x100 {
+		t.Errorf("worker stats mismatch: have %+v", stats)
+	}
+}
+
+// readStratum reads a single message sent by the stratum server.
+func readStratum(t *testing.T, reader *bufio.Reader) map[string]interface{} {
+	line, err := reader.ReadBytes('\n')
+	if err != nil {
+		t.Fatalf("failed to read stratum message: %v", err)
+	}
+	msg := make(map[string]interface{})
+	if err := json.Unmarshal(line, &msg); err != nil {
+		t.Fatalf("failed to decode stratum message: %v", err)
+	}
+	return msg
+}
+
+// Tests that solutions meeting only the share difficulty are accepted from remote
+// miners, but not sealed into blocks.
+func TestRemoteShares(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+	ethash.config.ShareDifficulty = 2
+
+	header := &types.Header{Number: big.NewInt(1), Difficulty: new(big.Int).Lsh(big.NewInt(1), 64)}
+	results := make(chan *types.Block, 1)
+	ethash.Seal(nil, types.NewBlockWithHeader(header), results, nil)
+
+	work, err := (&API{ethash}).GetWork()
+	if err != nil {
+		t.Fatalf("failed to retrieve work: %v", err)
+	}
+	if want := common.BytesToHash(new(big.Int).Rsh(two256, 1).Bytes()).Hex(); work[2] != want {
+		t.Errorf("share target mismatch: have %s, want %s", work[2], want)
+	}
+	// Find a nonce meeting the share difficulty and submit it
+	share := new(big.Int).Rsh(two256, 1)
+	for nonce := uint64(0); ; nonce++ {
+		header.Nonce = types.EncodeNonce(nonce)
+		digest, result := ethash.hashimoto(header, false)
+		if new(big.Int).SetBytes(result).Cmp(share) <= 0 {
+			header.MixDigest = common.BytesToHash(digest)
+			break
+		}
+	}
+	if !ethash.submitRemoteWork(header.Nonce, ethash.SealHash(header), header.MixDigest, "rig") {
+		t.Fatalf("valid share rejected")
+	}
+	select {
+	case block := <-results:
+		t.Fatalf("share sealed into block %d", block.NumberU64())
+	case <-time.After(100 * time.Millisecond):
+	}
+	// Submitting the same share again must not be counted twice
+	if ethash.submitRemoteWork(header.Nonce, ethash.SealHash(header), header.MixDigest, "rig") {
+		t.Fatalf("duplicate share accepted")
+	}
+	workers, err := (&API{ethash}).GetWorkers()
+	if err != nil {
+		t.Fatalf("failed to retrieve workers: %v", err)
+	}
+	if stats := workers["rig"]; stats == nil || stats.Shares != 1 || stats.InvalidShares != 1 {
+		t.Errorf("worker stats mismatch: have %+v", stats)
+	}
+	// Hash rates submitted over RPC are tracked by their identifier
+	(&API{ethash}).SubmitHashRate(hexutil.Uint64(100), common.Hash{0x01})
+	if workers, _ = (&API{ethash}).GetWorkers(); workers[common.Hash{0x01}.Hex()] == nil {
+		t.Errorf("hash rate submitter not tracked: %v", workers)
+	}
+}
+
+// Tests that push based subscribers not reading new work packages don't hold up
+// the remote sealer.
+func TestRemoteSlowSubscriber(t *testing.T) {
+	ethash := NewTester(nil, false)
+	defer ethash.Close()
+	ethash.SetThreads(-1)
+
+	sub := ethash.workFeed.Subscribe(make(chan [4]string))
+	defer sub.Unsubscribe()
+
+	for i := int64(1); i <= 3; i++ {
+		header := &types.Header{Number: big.NewInt(i), Difficulty: big.NewInt(100)}
+		ethash.Seal(nil, types.NewBlockWithHeader(header), nil, nil)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			work, err := (&API{ethash}).GetWork()
+			if err != nil || work[0] != ethash.SealHash(header).Hex() {
+				t.Errorf("work %d mismatch: have %v (%v), want hash %s", i, work[0], err, ethash.SealHash(header).Hex())
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("remote sealer blocked by subscriber")
+		}
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ethash
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+const (
+	stratumMaxRequestSize = 4096             // Maximum size of a single request line
+	stratumIdleTimeout    = 10 * time.Minute // Time after which silent miners are disconnected
+	stratumWriteTimeout   = 10 * time.Second // Time allowance for sending a single message
+	stratumWorkQueue      = 16               // Number of work packages to queue up for a slow miner
+)
+
+var (
+	errStratumRunning     = errors.New("stratum server already running")
+	errStratumUnsupported = errors.New("stratum server not supported in this mode")
+)
+
+// stratumRequest is a single request sent by a remote miner.
+type stratumRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Worker string          `json:"worker"`
+}
+
+// stratumResponse is a reply to a remote miner's request, or a work package
+// pushed to it unsolicited (with an id of zero).
+type stratumResponse struct {
+	ID      json.RawMessage `json:"id"`
+	Version string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *stratumError   `json:"error,omitempty"`
+}
+
+// stratumError is the error returned to a remote miner for a failed request.
+type stratumError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// StartStratum starts a stratum server on the given TCP address, pushing work
+// packages to the connected miners as soon as they become available instead of
+// waiting for them to be polled.
+//
+// The dialect spoken is the one commonly known as eth-proxy: newline terminated
+// JSON-RPC requests for eth_submitLogin, eth_getWork, eth_submitWork and
+// eth_submitHashrate, with new work packages pushed as responses with id zero.
+func (ethash *Ethash) StartStratum(addr string) error {
+	if ethash.config.PowMode != ModeNormal && ethash.config.PowMode != ModeTest {
+		return errStratumUnsupported
+	}
+	ethash.lock.Lock()
+	defer ethash.lock.Unlock()
+
+	if ethash.stratum != nil {
+		return errStratumRunning
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	ethash.stratum = newStratumServer(ethash, listener)
+
+	log.Info("Stratum server started", "addr", listener.Addr())
+	return nil
+}
+
+// stratumServer accepts connections from remote miners and serves them work.
+type stratumServer struct {
+	ethash   *Ethash
+	listener net.Listener
+
+	conns map[net.Conn]struct{} // Currently connected miners, nil after closing
+	lock  sync.Mutex
+	wg    sync.WaitGroup
+}
+
+// newStratumServer creates a stratum server serving the connections accepted by
+// the given listener.
+func newStratumServer(ethash *Ethash, listener net.Listener) *stratumServer {
+	srv := &stratumServer{
+		ethash:   ethash,
+		listener: listener,
+		conns:    make(map[net.Conn]struct{}),
+	}
+	srv.wg.Add(1)
+	go srv.loop()
+	return srv
+}
+
+// close stops accepting new miners, disconnects all existing ones and waits for
+// their handlers to terminate.
+func (srv *stratumServer) close() {
+	srv.listener.Close()
+
+	srv.lock.Lock()
+	for conn := range srv.conns {
+		conn.Close()
+	}
+	srv.conns = nil
+	srv.lock.Unlock()
+
+	srv.wg.Wait()
+}
+
+// loop accepts incoming connections until the listener is closed.
+func (srv *stratumServer) loop() {
+	defer srv.wg.Done()
+
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			if err, ok := err.(net.Error); ok && err.Temporary() {
+				log.Debug("Temporary stratum accept error", "err", err)
+				time.Sleep(50 * time.Millisecond)
+				continue
+			}
+			return
+		}
+		srv.lock.Lock()
+		if srv.conns == nil {
+			srv.lock.Unlock()
+			conn.Close()
+			return
+		}
+		srv.conns[conn] = struct{}{}
+		srv.wg.Add(1)
+		srv.lock.Unlock()
+
+		go srv.serve(conn)
+	}
+}
+
+// stratumSession is a single connected remote miner.
+type stratumSession struct {
+	conn  net.Conn
+	login string     // Account and worker name the miner logged in with
+	lock  sync.Mutex // Serializes replies with pushed work packages
+}
+
+// send writes a single message to the remote miner.
+func (s *stratumSession) send(msg *stratumResponse) error {
+	blob, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.conn.SetWriteDeadline(time.Now().Add(stratumWriteTimeout))
+	_, err = s.conn.Write(append(blob, '\n'))
+	return err
+}
+
+// serve handles the requests of a single remote miner, pushing it new work
+// packages until it disconnects.
+func (srv *stratumServer) serve(conn net.Conn) {
+	defer srv.wg.Done()
+	defer func() {
+		srv.lock.Lock()
+		delete(srv.conns, conn)
+		srv.lock.Unlock()
+		conn.Close()
+	}()
+	var (
+		session = &stratumSession{conn: conn}
+		logger  = log.New("remote", conn.RemoteAddr())
+	)
+	logger.Debug("Stratum miner connected")
+	defer logger.Debug("Stratum miner disconnected")
+
+	// Push new work packages to the miner as soon as they are available
+	works := make(chan [4]string, stratumWorkQueue)
+	sub := srv.ethash.workFeed.Subscribe(works)
+	defer sub.Unsubscribe()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for {
+			select {
+			case work := <-works:
+				if err := session.send(&stratumResponse{ID: json.RawMessage("0"), Version: "2.0", Result: work}); err != nil {
+					logger.Debug("Failed to push stratum work", "err", err)
+					conn.Close()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	// Serve the miner's requests until it disconnects
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 512), stratumMaxRequestSize)
+	for {
+		conn.SetReadDeadline(time.Now().Add(stratumIdleTimeout))
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				logger.Debug("Failed to read stratum request", "err", err)
+			}
+			return
+		}
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		req := new(stratumRequest)
+		if err := json.Unmarshal(scanner.Bytes(), req); err != nil {
+			session.send(&stratumResponse{Version: "2.0", Error: &stratumError{Code: -32700, Message: "parse error"}})
+			return
+		}
+		if err := session.send(srv.handle(session, req)); err != nil {
+			logger.Debug("Failed to send stratum response", "err", err)
+			return
+		}
+	}
+}
+
+// handle processes a single request of a remote miner.
+func (srv *stratumServer) handle(session *stratumSession, req *stratumRequest) *stratumResponse {
+	res := &stratumResponse{ID: req.ID, Version: "2.0"}
+
+	switch req.Method {
+	case "eth_submitLogin":
+		var login string
+		if err := decodeStratumParams(req.Params, &login); err != nil {
+			res.Error = &stratumError{Code: -32602, Message: err.Error()}
+			return res
+		}
+		if req.Worker != "" {
+			login += "." + req.Worker
+		}
+		session.login = login
+		res.Result = true
+
+	case "eth_getWork":
+		work, err := srv.ethash.remoteWork()
+		if err != nil {
+			res.Error = &stratumError{Code: -32000, Message: err.Error()}
+			return res
+		}
+		res.Result = work
+
+	case "eth_submitWork":
+		var (
+			nonce        types.BlockNonce
+			hash, digest common.Hash
+		)
+		if err := decodeStratumParams(req.Params, &nonce, &hash, &digest); err != nil {
+			res.Error = &stratumError{Code: -32602, Message: err.Error()}
+			return res
+		}
+		res.Result = srv.ethash.submitRemoteWork(nonce, hash, digest, session.login)
+
+	case "eth_submitHashrate":
+		var (
+			rate hexutil.Uint64
+			id   common.Hash
+		)
+		if err := decodeStratumParams(req.Params, &rate, &id); err != nil {
+			res.Error = &stratumError{Code: -32602, Message: err.Error()}
+			return res
+		}
+		worker := session.login
+		if worker == "" {
+			worker = id.Hex()
+		}
+		res.Result = srv.ethash.submitRemoteHashrate(uint64(rate), id, worker)
+
+	default:
+		res.Error = &stratumError{Code: -32601, Message: fmt.Sprintf("the method %s does not exist/is not available", req.Method)}
+	}
+	return res
+}
+
+// decodeStratumParams decodes the leading positional parameters of a request
+// into the given values, ignoring any surplus ones.
+func decodeStratumParams(raw json.RawMessage, args ...interface{}) error {
+	var params []json.RawMessage
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return fmt.Errorf("invalid parameters: %v", err)
+	}
+	if len(params) < len(args) {
+		return fmt.Errorf("missing parameters: have %d, want %d", len(params), len(args))
+	}
+	for i, arg := range args {
+		if err := json.Unmarshal(params[i], arg); err != nil {
+			return fmt.Errorf("invalid parameter %d: %v", i, err)
+		}
+	}
+	return nil
+}
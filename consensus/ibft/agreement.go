@@ -0,0 +1,640 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ibft
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	messageChanSize   = 256  // Number of consensus messages to queue up before blocking the network
+	chainHeadChanSize = 10   // Size of channel listening to ChainHeadEvent
+	maxBacklog        = 1024 // Maximum number of future messages to hold on to
+	maxTimeoutShift   = 8    // Maximum number of times the round timeout is doubled
+)
+
+// errUnendorsedVote is returned if a proposal casts a vote the local validator
+// did not propose itself, and which isn't justified by a lock either.
+var errUnendorsedVote = errors.New("unendorsed validator vote")
+
+// Chain is the subset of core.BlockChain needed to take part in consensus.
+type Chain interface {
+	consensus.ChainReader
+
+	// CurrentBlock retrieves the current head block of the canonical chain.
+	CurrentBlock() *types.Block
+
+	// StateAt returns a new mutable state based on a particular point in time.
+	StateAt(root common.Hash) (*state.StateDB, error)
+
+	// Processor returns the current processor, used to execute proposals.
+	Processor() core.Processor
+
+	// Validator returns the current validator, used to check proposals.
+	Validator() core.Validator
+
+	// InsertChain imports committed blocks into the chain.
+	InsertChain(chain types.Blocks) (int, error)
+
+	// SubscribeChainHeadEvent registers a subscription of ChainHeadEvent.
+	SubscribeChainHeadEvent(ch chan<- core.ChainHeadEvent) event.Subscription
+}
+
+// sealRequest is a block the local miner would like to propose.
+type sealRequest struct {
+	block   *types.Block        // Block signed by the local validator as proposer
+	results chan<- *types.Block // Channel to deliver the block on once committed
+}
+
+// roundState is the progress of agreement within a single round.
+type roundState struct {
+	number   uint64       // Round number within the current height
+	proposal *types.Block // Proposal accepted from the round's proposer
+	digest   common.Hash  // Hash of the accepted proposal
+
+	prepares map[common.Address]*message // Prepare messages received in this round
+	commits  map[common.Address]*message // Commit messages received in this round
+
+	prepared  bool // Whether a quorum prepared (and we sent our commit)
+	committed bool // Whether a quorum committed
+}
+
+// agreement runs the consensus protocol on top of the local chain, one height at
+// a time. All of its state is owned by the loop goroutine.
+type agreement struct {
+	engine    *IBFT
+	chain     Chain
+	broadcast func(*message) // Gossips a locally created message to the network
+
+	parent       *types.Header                               // Head block the next block is built on
+	height       uint64                                      // Number of the block being agreed upon
+	validators   validatorSet                                // Validators agreeing on the current height
+	round        *roundState                                 // Progress within the current round
+	locked       *types.Block                                // Proposal locked on after a prepare quorum
+	roundChanges map[uint64]map[common.Address]struct{}      // Round change requests for future rounds
+	lockReports  map[common.Hash]map[common.Address]struct{} // Validators reporting a lock on a proposal
+	desired      uint64                                      // Highest round we requested a change to
+	backlog      []*message                                  // Messages for future rounds or heights
+	pending      *sealRequest                                // Local block waiting to be proposed
+	timer        *time.Timer                                 // Round timeout timer
+
+	msgCh   chan *message
+	reqCh   chan *sealRequest
+	headCh  chan core.ChainHeadEvent
+	headSub event.Subscription
+	quit    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// newAgreement creates the consensus state machine for the given chain.
+func newAgreement(engine *IBFT, chain Chain, broadcast func(*message)) *agreement {
+	return &agreement{
+		engine:    engine,
+		chain:     chain,
+		broadcast: broadcast,
+		msgCh:     make(chan *message, messageChanSize),
+		reqCh:     make(chan *sealRequest),
+		headCh:    make(chan core.ChainHeadEvent, chainHeadChanSize),
+		quit:      make(chan struct{}),
+	}
+}
+
+// start launches the consensus loop.
+func (a *agreement) start() {
+	a.headSub = a.chain.SubscribeChainHeadEvent(a.headCh)
+	a.timer = time.NewTimer(0)
+	<-a.timer.C
+
+	a.wg.Add(1)
+	go a.loop()
+}
+
+// stop terminates the consensus loop and waits for it to exit.
+func (a *agreement) stop() {
+	close(a.quit)
+	a.wg.Wait()
+}
+
+// request schedules a local block for proposal.
+func (a *agreement) request(req *sealRequest) {
+	select {
+	case a.reqCh <- req:
+	case <-a.quit:
+	}
+}
+
+// deliver schedules a consensus message received from the network.
+func (a *agreement) deliver(msg *message) {
+	select {
+	case a.msgCh <- msg:
+	case <-a.quit:
+	}
+}
+
+// loop is the main event loop of the consensus state machine.
+func (a *agreement) loop() {
+	defer a.wg.Done()
+	defer a.headSub.Unsubscribe()
+	defer a.timer.Stop()
+
+	a.startHeight(a.chain.CurrentBlock().Header())
+	for {
+		select {
+		case head := <-a.headCh:
+			if head.Block.NumberU64() >= a.height {
+				a.startHeight(head.Block.Header())
+			}
+		case req := <-a.reqCh:
+			a.handleRequest(req)
+
+		case msg := <-a.msgCh:
+			a.handleMessage(msg)
+
+		case <-a.timer.C:
+			a.handleTimeout()
+
+		case <-a.headSub.Err():
+			return
+		case <-a.quit:
+			return
+		}
+	}
+}
+
+// startHeight resets the state machine to agree on the child of the given head.
+func (a *agreement) startHeight(head *types.Header) {
+	validators, err := parentValidators(head)
+	if err != nil {
+		log.Error("Failed to retrieve validators", "number", head.Number, "hash", head.Hash(), "err", err)
+	}
+	a.parent, a.height, a.validators = head, head.Number.Uint64()+1, validators
+	a.locked, a.desired = nil, 0
+	a.roundChanges = make(map[uint64]map[common.Address]struct{})
+	a.lockReports = make(map[common.Hash]map[common.Address]struct{})
+
+	if a.pending != nil && a.pending.block.ParentHash() != head.Hash() {
+		a.pending = nil
+	}
+	log.Debug("Starting consensus height", "number", a.height, "validators", len(validators))
+	a.startRound(0)
+}
+
+// startRound resets the state machine to the given round of the current height,
+// proposing a block if it's the local validator's turn.
+func (a *agreement) startRound(round uint64) {
+	a.round = &roundState{
+		number:   round,
+		prepares: make(map[common.Address]*message),
+		commits:  make(map[common.Address]*message),
+	}
+	if round > a.desired {
+		a.desired = round
+	}
+	for r := range a.roundChanges {
+		if r <= round {
+			delete(a.roundChanges, r)
+		}
+	}
+	a.resetTimer(round)
+
+	if round > 0 {
+		log.Debug("Starting consensus round", "number", a.height, "round", round, "proposer", a.proposer())
+	}
+	if a.isProposer() {
+		a.propose()
+	}
+	a.replayBacklog()
+}
+
+// proposer returns the validator expected to propose in the current round.
+func (a *agreement) proposer() common.Address {
+	return a.validators.proposer(a.height, a.round.number)
+}
+
+// isProposer returns whether the local validator is the current proposer.
+func (a *agreement) isProposer() bool {
+	signer := a.engine.localSigner()
+	return a.validators.contains(signer) && a.proposer() == signer
+}
+
+// resetTimer schedules the timeout of the given round. Every round doubles the
+// timeout of the previous one, and the first round has to also accommodate the
+// block period, since the proposer waits for the block to become due.
+func (a *agreement) resetTimer(round uint64) {
+	if !a.timer.Stop() {
+		select {
+		case <-a.timer.C:
+		default:
+		}
+	}
+	shift := round
+	if shift > maxTimeoutShift {
+		shift = maxTimeoutShift
+	}
+	timeout := time.Duration(a.engine.config.RequestTimeout) * time.Millisecond << shift
+	if round == 0 {
+		timeout += time.Duration(a.engine.config.BlockPeriod) * time.Second
+	}
+	a.timer.Reset(timeout)
+}
+
+// handleRequest stores a local block for proposal, proposing it right away if
+// it's the local validator's turn.
+func (a *agreement) handleRequest(req *sealRequest) {
+	if req.block.NumberU64() != a.height || req.block.ParentHash() != a.parent.Hash() {
+		return
+	}
+	a.pending = req
+	if a.isProposer() {
+		a.propose()
+	}
+}
+
+// propose sends a pre-prepare for the locked block if any, or for the pending
+// local block otherwise.
+func (a *agreement) propose() {
+	if a.round.proposal != nil {
+		return
+	}
+	block := a.locked
+	if block == nil && a.pending != nil {
+		block = a.pending.block
+	}
+	if block == nil {
+		return
+	}
+	blob, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		log.Error("Failed to encode proposal", "err", err)
+		return
+	}
+	a.send(&message{
+		Code:     msgPreprepare,
+		Height:   a.height,
+		Round:    a.round.number,
+		Digest:   proposalHash(block.Header()),
+		Proposal: blob,
+		block:    block,
+	})
+}
+
+// send signs a locally created message, gossips it to the network and feeds
+// it into the state machine. Nodes that aren't validators stay silent.
+func (a *agreement) send(msg *message) {
+	if !a.validators.contains(a.engine.localSigner()) {
+		return
+	}
+	if err := msg.sign(a.engine); err != nil {
+		log.Warn("Failed to sign consensus message", "msg", msg, "err", err)
+		return
+	}
+	a.broadcast(msg)
+	a.handleMessage(msg)
+}
+
+// handleMessage processes a consensus message, buffering it if it's for a
+// future round or height.
+func (a *agreement) handleMessage(msg *message) {
+	switch {
+	case msg.Height < a.height:
+		return
+	case msg.Height > a.height:
+		a.addBacklog(msg)
+		return
+	}
+	if !a.validators.contains(msg.sender) {
+		return
+	}
+	switch msg.Code {
+	case msgRoundChange:
+		a.handleRoundChange(msg)
+		return
+	case msgCommitted:
+		a.handleCommitted(msg)
+		return
+	}
+	switch {
+	case msg.Round < a.round.number:
+		return
+	case msg.Round > a.round.number:
+		a.addBacklog(msg)
+		return
+	}
+	switch msg.Code {
+	case msgPreprepare:
+		a.handlePreprepare(msg)
+	case msgPrepare:
+		a.round.prepares[msg.sender] = msg
+		a.checkPrepared()
+	case msgCommit:
+		a.round.commits[msg.sender] = msg
+		a.checkCommitted()
+	}
+}
+
+// handlePreprepare validates the proposal of the current round, and if it's
+// acceptable, broadcasts a prepare message for it.
+func (a *agreement) handlePreprepare(msg *message) {
+	if msg.sender != a.proposer() || a.round.proposal != nil {
+		return
+	}
+	block := msg.block
+	if block.ParentHash() != a.parent.Hash() {
+		return
+	}
+	if a.locked != nil && proposalHash(a.locked.Header()) != msg.Digest {
+		log.Debug("Rejecting proposal conflicting with lock", "number", a.height, "round", a.round.number)
+		return
+	}
+	if err := a.verifyProposal(block, msg.Digest); err != nil {
+		log.Warn("Rejecting invalid proposal", "number", a.height, "round", a.round.number, "proposer", msg.sender, "err", err)
+		return
+	}
+	a.round.proposal, a.round.digest = block, msg.Digest
+
+	a.send(&message{Code: msgPrepare, Height: a.height, Round: a.round.number, Digest: msg.Digest})
+	a.checkPrepared()
+	a.checkCommitted()
+}
+
+// verifyProposal checks that a proposed block is valid: its header must be
+// correct (apart from the missing committed seals), its vote must be endorsed
+// and its transactions must execute to the promised state.
+func (a *agreement) verifyProposal(block *types.Block, digest common.Hash) error {
+	header := block.Header()
+	if err := a.engine.verifyHeader(a.chain, header, nil, false); err != nil {
+		return err
+	}
+	if !a.endorsed(header, digest) {
+		return errUnendorsedVote
+	}
+	if err := a.chain.Validator().ValidateBody(block); err != nil {
+		return err
+	}
+	statedb, err := a.chain.StateAt(a.parent.Root)
+	if err != nil {
+		return err
+	}
+	receipts, _, usedGas, err := a.chain.Processor().Process(block, statedb, vm.Config{})
+	if err != nil {
+		return err
+	}
+	return a.chain.Validator().ValidateState(block, statedb, receipts, usedGas)
+}
+
+// endorsed returns whether the vote cast by a proposal may be accepted. Votes
+// are endorsed if the local validator proposed them too. A re-proposed block is
+// also accepted if the local node or more than F validators (i.e. at least one
+// honest node) are locked on it, since a quorum endorsed it already. Otherwise
+// validators which lost their proposals (e.g. by restarting) would reject the
+// locked block forever, stalling the height.
+func (a *agreement) endorsed(header *types.Header, digest common.Hash) bool {
+	if header.Coinbase == (common.Address{}) {
+		return true
+	}
+	a.engine.lock.RLock()
+	authorize, ok := a.engine.proposals[header.Coinbase]
+	a.engine.lock.RUnlock()
+
+	if ok && authorize == (header.Nonce != types.BlockNonce{}) {
+		return true
+	}
+	if a.locked != nil && proposalHash(a.locked.Header()) == digest {
+		return true
+	}
+	return len(a.lockReports[digest]) > a.validators.faulty()
+}
+
+// checkPrepared locks on the current proposal and broadcasts a commit once a
+// quorum of validators prepared it.
+func (a *agreement) checkPrepared() {
+	if a.round.proposal == nil || a.round.prepared {
+		return
+	}
+	if count(a.round.prepares, a.round.digest) < a.validators.quorum() {
+		return
+	}
+	a.round.prepared = true
+	a.locked = a.round.proposal
+
+	_, seal, err := a.engine.sign(commitData(a.round.digest))
+	if err != nil {
+		log.Warn("Failed to sign commitment", "err", err)
+		return
+	}
+	a.send(&message{Code: msgCommit, Height: a.height, Round: a.round.number, Digest: a.round.digest, CommittedSeal: seal})
+}
+
+// checkCommitted assembles the final block once a quorum of validators committed
+// to the current proposal. The block is assembled by the round's proposer only
+// and gossiped to everyone else, to avoid different validators importing the
+// same proposal with different sets of committed seals.
+func (a *agreement) checkCommitted() {
+	if a.round.proposal == nil || a.round.committed {
+		return
+	}
+	if count(a.round.commits, a.round.digest) < a.validators.quorum() {
+		return
+	}
+	a.round.committed = true
+	a.locked = a.round.proposal
+
+	// Gather the committed seals in validator order and seal the block
+	var seals [][]byte
+	for _, validator := range a.validators {
+		if msg, ok := a.round.commits[validator]; ok && msg.Digest == a.round.digest {
+			seals = append(seals, msg.CommittedSeal)
+		}
+	}
+	header := a.round.proposal.Header()
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		log.Error("Failed to decode committed proposal", "err", err)
+		return
+	}
+	extra.CommittedSeal = seals
+	if err := writeExtra(header, extra); err != nil {
+		log.Error("Failed to seal committed proposal", "err", err)
+		return
+	}
+	block := a.round.proposal.WithSeal(header)
+	log.Debug("Consensus reached", "number", a.height, "round", a.round.number, "hash", block.Hash(), "seals", len(seals))
+
+	if !a.isProposer() {
+		return
+	}
+	a.sendCommitted(block)
+
+	// If the block is the one the local miner asked for, hand it back to it
+	if a.pending != nil && proposalHash(a.pending.block.Header()) == a.round.digest {
+		select {
+		case a.pending.results <- block:
+		default:
+			log.Warn("Committed block is not read by miner", "sealhash", SealHash(header))
+		}
+		return
+	}
+	// Otherwise we re-proposed a locked block, import it directly
+	if _, err := a.chain.InsertChain(types.Blocks{block}); err != nil {
+		log.Error("Failed to import committed block", "number", block.Number(), "hash", block.Hash(), "err", err)
+		return
+	}
+	if mux := a.engine.mux; mux != nil {
+		mux.Post(core.NewMinedBlockEvent{Block: block})
+	}
+}
+
+// sendCommitted gossips a committed block to the other nodes. The message is not
+// fed into the local state machine, since the proposer imports the block itself.
+func (a *agreement) sendCommitted(block *types.Block) {
+	blob, err := rlp.EncodeToBytes(block)
+	if err != nil {
+		log.Error("Failed to encode committed block", "err", err)
+		return
+	}
+	msg := &message{
+		Code:     msgCommitted,
+		Height:   a.height,
+		Round:    a.round.number,
+		Digest:   proposalHash(block.Header()),
+		Proposal: blob,
+	}
+	if err := msg.sign(a.engine); err != nil {
+		log.Warn("Failed to sign consensus message", "msg", msg, "err", err)
+		return
+	}
+	a.broadcast(msg)
+}
+
+// handleCommitted imports a block committed in any round of the current height.
+// The committed seals are checked during import, so the block is final whoever
+// relayed it.
+func (a *agreement) handleCommitted(msg *message) {
+	block := msg.block
+	if block.ParentHash() != a.parent.Hash() {
+		return
+	}
+	if _, err := a.chain.InsertChain(types.Blocks{block}); err != nil {
+		log.Warn("Failed to import committed block", "number", block.Number(), "hash", block.Hash(), "sender", msg.sender, "err", err)
+	}
+}
+
+// handleTimeout requests moving to the next round if the current one didn't
+// reach agreement in time.
+func (a *agreement) handleTimeout() {
+	a.desired++
+	log.Debug("Consensus round timed out", "number", a.height, "round", a.round.number, "desired", a.desired)
+
+	a.sendRoundChange(a.desired)
+	a.resetTimer(a.desired)
+}
+
+// sendRoundChange broadcasts a request to move to the given round.
+func (a *agreement) sendRoundChange(round uint64) {
+	var digest common.Hash
+	if a.locked != nil {
+		digest = proposalHash(a.locked.Header())
+	}
+	a.send(&message{Code: msgRoundChange, Height: a.height, Round: round, Digest: digest})
+}
+
+// handleRoundChange tallies requests to move to a future round. A quorum moves
+// the local node to that round, while F+1 requests (i.e. at least one honest
+// node) make the local node join in to catch up with the rest. The proposal the
+// sender is locked on is tallied too, justifying re-proposals of it.
+func (a *agreement) handleRoundChange(msg *message) {
+	if msg.Digest != (common.Hash{}) {
+		set, ok := a.lockReports[msg.Digest]
+		if !ok {
+			set = make(map[common.Address]struct{})
+			a.lockReports[msg.Digest] = set
+		}
+		set[msg.sender] = struct{}{}
+	}
+	if msg.Round <= a.round.number {
+		return
+	}
+	set, ok := a.roundChanges[msg.Round]
+	if !ok {
+		set = make(map[common.Address]struct{})
+		a.roundChanges[msg.Round] = set
+	}
+	set[msg.sender] = struct{}{}
+
+	switch {
+	case len(set) >= a.validators.quorum():
+		a.startRound(msg.Round)
+
+	case len(set) > a.validators.faulty() && msg.Round > a.desired:
+		a.desired = msg.Round
+		a.sendRoundChange(msg.Round)
+		a.resetTimer(msg.Round)
+	}
+}
+
+// addBacklog buffers a message for a future round or height.
+func (a *agreement) addBacklog(msg *message) {
+	if len(a.backlog) >= maxBacklog {
+		a.backlog = a.backlog[1:]
+	}
+	a.backlog = append(a.backlog, msg)
+}
+
+// replayBacklog processes any buffered messages which became current.
+func (a *agreement) replayBacklog() {
+	var ready []*message
+
+	backlog := a.backlog[:0]
+	for _, msg := range a.backlog {
+		switch {
+		case msg.Height < a.height:
+			// Stale, drop
+		case msg.Height == a.height && (msg.Round <= a.round.number || msg.Code == msgRoundChange || msg.Code == msgCommitted):
+			ready = append(ready, msg)
+		default:
+			backlog = append(backlog, msg)
+		}
+	}
+	a.backlog = backlog
+
+	for _, msg := range ready {
+		a.handleMessage(msg)
+	}
+}
+
+// count returns the number of messages voting for the given digest.
+func count(msgs map[common.Address]*message, digest common.Hash) int {
+	n := 0
+	for _, msg := range msgs {
+		if msg.Digest == digest {
+			n++
+		}
+	}
+	return n
+}
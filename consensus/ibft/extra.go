@@ -0,0 +1,137 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ibft
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// extraVanity is the fixed number of extra-data prefix bytes reserved for
+// proposer vanity, the consensus fields are RLP encoded after it.
+const extraVanity = 32
+
+// Extra is the consensus specific data embedded into the extra-data field of
+// every header, following the vanity prefix.
+type Extra struct {
+	Validators    []common.Address // Validators authorized to agree on the next block
+	Seal          []byte           // Proposer signature over the seal hash
+	CommittedSeal [][]byte         // Validator signatures over the proposal hash
+}
+
+// ExtractExtra decodes the consensus fields from the extra-data of a header.
+func ExtractExtra(header *types.Header) (*Extra, error) {
+	if len(header.Extra) < extraVanity {
+		return nil, errMissingVanity
+	}
+	extra := new(Extra)
+	if err := rlp.DecodeBytes(header.Extra[extraVanity:], extra); err != nil {
+		return nil, errInvalidExtra
+	}
+	return extra, nil
+}
+
+// writeExtra encodes the consensus fields into the extra-data of a header,
+// retaining (or padding) the vanity prefix.
+func writeExtra(header *types.Header, extra *Extra) error {
+	blob, err := rlp.EncodeToBytes(extra)
+	if err != nil {
+		return err
+	}
+	vanity := make([]byte, extraVanity)
+	copy(vanity, header.Extra)
+
+	header.Extra = append(vanity, blob...)
+	return nil
+}
+
+// GenesisExtra assembles the extra-data of a genesis block authorizing the given
+// set of validators to agree on the first block.
+func GenesisExtra(validators []common.Address) ([]byte, error) {
+	header := new(types.Header)
+	if err := writeExtra(header, &Extra{Validators: sortValidators(validators)}); err != nil {
+		return nil, err
+	}
+	return header.Extra, nil
+}
+
+// filteredHash returns the hash of a header after stripping the proposer seal
+// (optionally) and the committed seals from its extra-data.
+func filteredHash(header *types.Header, keepSeal bool) common.Hash {
+	cpy := types.CopyHeader(header)
+
+	extra, err := ExtractExtra(cpy)
+	if err != nil {
+		return common.Hash{}
+	}
+	if !keepSeal {
+		extra.Seal = []byte{}
+	}
+	extra.CommittedSeal = [][]byte{}
+	if err := writeExtra(cpy, extra); err != nil {
+		return common.Hash{}
+	}
+	return cpy.Hash()
+}
+
+// SealHash returns the hash of a block prior to it being sealed, which is the
+// digest signed by the proposer.
+func SealHash(header *types.Header) common.Hash {
+	return filteredHash(header, false)
+}
+
+// proposalHash returns the hash of a proposed block, including the proposer's
+// seal but not the committed seals. This is the value validators agree on.
+func proposalHash(header *types.Header) common.Hash {
+	return filteredHash(header, true)
+}
+
+// commitData returns the data a validator signs to commit to a proposal.
+func commitData(proposal common.Hash) []byte {
+	return append(proposal.Bytes(), byte(msgCommit))
+}
+
+// recoverSigner returns the address which signed keccak256(data).
+func recoverSigner(data []byte, sig []byte) (common.Address, error) {
+	pubkey, err := crypto.SigToPub(crypto.Keccak256(data), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
+}
+
+// sortValidators returns a sorted copy of the given validator list, with any
+// duplicates removed.
+func sortValidators(validators []common.Address) []common.Address {
+	sorted := make([]common.Address, 0, len(validators))
+	seen := make(map[common.Address]struct{})
+	for _, validator := range validators {
+		if _, ok := seen[validator]; !ok {
+			seen[validator] = struct{}{}
+			sorted = append(sorted, validator)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+	return sorted
+}
@@ -0,0 +1,600 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package ibft implements a Byzantine fault tolerant consensus engine in the
+// spirit of Istanbul BFT.
+//
+// Every block is agreed upon by a fixed set of validators running a three phase
+// (pre-prepare, prepare, commit) protocol over a dedicated devp2p sub-protocol.
+// A block is only ever imported once a quorum of validators signed a commitment
+// to it, so blocks are final the moment they appear on chain.
+package ibft
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/rpc"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	inmemorySignatures = 4096 // Number of recent block authors to keep in memory
+
+	defaultRequestTimeout = 10000 // Default milliseconds after which the first round times out
+
+	mimetypeIBFT = "application/x-ibft" // Mimetype of data signed by validators
+)
+
+// IBFT protocol constants.
+var (
+	nonceAuthVote = hexutil.MustDecode("0xffffffffffffffff") // Magic nonce number to vote on adding a new validator
+	nonceDropVote = hexutil.MustDecode("0x0000000000000000") // Magic nonce number to vote on removing a validator
+
+	uncleHash = types.CalcUncleHash(nil) // Always Keccak256(RLP([])) as uncles are meaningless outside of PoW.
+
+	defaultDifficulty = big.NewInt(1) // Block difficulty, every block is final so there's no heaviest chain
+)
+
+// Various error messages to mark blocks invalid. These should be private to
+// prevent engine specific errors from being referenced in the remainder of the
+// codebase, inherently breaking if the engine is swapped out. Please put common
+// error types into the consensus package.
+var (
+	// errUnknownBlock is returned when the list of validators is requested for a
+	// block that is not part of the local blockchain.
+	errUnknownBlock = errors.New("unknown block")
+
+	// errMissingVanity is returned if a block's extra-data section is shorter than
+	// 32 bytes, which is required to store the proposer vanity.
+	errMissingVanity = errors.New("extra-data 32 byte vanity prefix missing")
+
+	// errInvalidExtra is returned if a block's extra-data doesn't contain the RLP
+	// encoded consensus fields.
+	errInvalidExtra = errors.New("invalid extra-data consensus fields")
+
+	// errInvalidVote is returned if a nonce value is something else that the two
+	// allowed constants of 0x00..0 or 0xff..f.
+	errInvalidVote = errors.New("vote nonce not 0x00..0 or 0xff..f")
+
+	// errMismatchingValidators is returned if a block contains a list of validators
+	// different than the one the local node calculated.
+	errMismatchingValidators = errors.New("mismatching validator list")
+
+	// errInvalidMixDigest is returned if a block's mix digest is non-zero.
+	errInvalidMixDigest = errors.New("non-zero mix digest")
+
+	// errInvalidUncleHash is returned if a block contains an non-empty uncle list.
+	errInvalidUncleHash = errors.New("non empty uncle hash")
+
+	// errInvalidDifficulty is returned if the difficulty of a block is not 1.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// errInvalidTimestamp is returned if the timestamp of a block is lower than
+	// the previous block's timestamp + the minimum block period.
+	errInvalidTimestamp = errors.New("invalid timestamp")
+
+	// errUnauthorizedProposer is returned if a header is proposed by a non-validator.
+	errUnauthorizedProposer = errors.New("unauthorized proposer")
+
+	// errInvalidCommittedSeals is returned if a committed seal is not signed by a
+	// validator or if a validator committed multiple times.
+	errInvalidCommittedSeals = errors.New("invalid committed seals")
+
+	// errInsufficientCommittedSeals is returned if a block wasn't committed to by
+	// a quorum of validators.
+	errInsufficientCommittedSeals = errors.New("insufficient committed seals")
+
+	// errNotStarted is returned if a block is attempted to be sealed before the
+	// consensus protocol was started.
+	errNotStarted = errors.New("consensus not started")
+)
+
+// SignerFn is a signer callback function to request a hash to be signed by a
+// backing account.
+type SignerFn func(accounts.Account, string, []byte) ([]byte, error)
+
+// IBFT is the Byzantine fault tolerant consensus engine.
+type IBFT struct {
+	config *params.IBFTConfig // Consensus engine configuration parameters
+
+	signatures *lru.ARCCache // Authors of recent blocks to speed up verification
+
+	proposals map[common.Address]bool // Current list of proposals we are pushing
+
+	signer common.Address // Ethereum address of the signing key
+	signFn SignerFn       // Signer function to authorize hashes with
+	lock   sync.RWMutex   // Protects the signer and proposal fields
+
+	agreement *agreement     // Consensus state machine, nil until started
+	network   *network       // Consensus message gossip sub-protocol
+	mux       *event.TypeMux // Event mux to announce committed blocks on
+	runLock   sync.Mutex     // Protects the consensus lifecycle
+}
+
+// New creates a Byzantine fault tolerant consensus engine. The engine verifies
+// blocks right away, but only participates in reaching consensus once started.
+func New(config *params.IBFTConfig) *IBFT {
+	conf := *config
+	if conf.RequestTimeout == 0 {
+		conf.RequestTimeout = defaultRequestTimeout
+	}
+	signatures, _ := lru.NewARC(inmemorySignatures)
+
+	engine := &IBFT{
+		config:     &conf,
+		signatures: signatures,
+		proposals:  make(map[common.Address]bool),
+	}
+	engine.network = newNetwork(engine)
+	return engine
+}
+
+// Author implements consensus.Engine, returning the address of the validator
+// which proposed the block.
+func (e *IBFT) Author(header *types.Header) (common.Address, error) {
+	// If the proposer's already cached, return that
+	hash := proposalHash(header)
+	if address, known := e.signatures.Get(hash); known {
+		return address.(common.Address), nil
+	}
+	// Recover the proposer from the seal in the extra-data
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	payload, err := sealData(header)
+	if err != nil {
+		return common.Address{}, err
+	}
+	signer, err := recoverSigner(payload, extra.Seal)
+	if err != nil {
+		return common.Address{}, err
+	}
+	e.signatures.Add(hash, signer)
+	return signer, nil
+}
+
+// VerifyHeader checks whether a header conforms to the consensus rules. The
+// committed seals are only checked if seal is set.
+func (e *IBFT) VerifyHeader(chain consensus.ChainReader, header *types.Header, seal bool) error {
+	return e.verifyHeader(chain, header, nil, seal)
+}
+
+// VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers. The
+// method returns a quit channel to abort the operations and a results channel to
+// retrieve the async verifications (the order is that of the input slice).
+func (e *IBFT) VerifyHeaders(chain consensus.ChainReader, headers []*types.Header, seals []bool) (chan<- struct{}, <-chan error) {
+	abort := make(chan struct{})
+	results := make(chan error, len(headers))
+
+	go func() {
+		for i, header := range headers {
+			err := e.verifyHeader(chain, header, headers[:i], seals[i])
+
+			select {
+			case <-abort:
+				return
+			case results <- err:
+			}
+		}
+	}()
+	return abort, results
+}
+
+// verifyHeader checks whether a header conforms to the consensus rules. The
+// caller may optionally pass in a batch of parents (ascending order) to avoid
+// looking those up from the database. Committed seals are only checked if
+// requested, as proposals under agreement don't have them yet.
+func (e *IBFT) verifyHeader(chain consensus.ChainReader, header *types.Header, parents []*types.Header, committed bool) error {
+	if header.Number == nil {
+		return errUnknownBlock
+	}
+	number := header.Number.Uint64()
+
+	// Don't waste time checking blocks from the future
+	if header.Time.Cmp(big.NewInt(time.Now().Unix())) > 0 {
+		return consensus.ErrFutureBlock
+	}
+	// Ensure that the consensus fields are present and decodable
+	if _, err := ExtractExtra(header); err != nil {
+		return err
+	}
+	// Nonces must be 0x00..0 or 0xff..f
+	if header.Coinbase == (common.Address{}) && header.Nonce != (types.BlockNonce{}) {
+		return errInvalidVote
+	}
+	// Ensure that the mix digest is zero as we don't have fork protection currently
+	if header.MixDigest != (common.Hash{}) {
+		return errInvalidMixDigest
+	}
+	// Ensure that the block doesn't contain any uncles which are meaningless in BFT
+	if header.UncleHash != uncleHash {
+		return errInvalidUncleHash
+	}
+	// Ensure that the block's difficulty is constant (genesis is free to choose)
+	if number > 0 && (header.Difficulty == nil || header.Difficulty.Cmp(defaultDifficulty) != 0) {
+		return errInvalidDifficulty
+	}
+	// The genesis block is the always valid dead-end
+	if number == 0 {
+		return nil
+	}
+	// Ensure that the block's timestamp isn't too close to it's parent
+	var parent *types.Header
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeader(header.ParentHash, number-1)
+	}
+	if parent == nil || parent.Number.Uint64() != number-1 || parent.Hash() != header.ParentHash {
+		return consensus.ErrUnknownAncestor
+	}
+	if parent.Time.Uint64()+e.config.BlockPeriod > header.Time.Uint64() {
+		return errInvalidTimestamp
+	}
+	// Ensure the validator set for the next block is correctly derived
+	validators, err := parentValidators(parent)
+	if err != nil {
+		return err
+	}
+	next, err := validators.applyVote(header)
+	if err != nil {
+		return err
+	}
+	extra, _ := ExtractExtra(header)
+	if !next.equal(extra.Validators) {
+		return errMismatchingValidators
+	}
+	// All basic checks passed, verify the seals
+	return e.verifySeals(header, validators, committed)
+}
+
+// parentValidators returns the validator set that must agree on the child of
+// the given header.
+func parentValidators(parent *types.Header) (validatorSet, error) {
+	extra, err := ExtractExtra(parent)
+	if err != nil {
+		return nil, err
+	}
+	return validatorSet(extra.Validators), nil
+}
+
+// validators returns the validator set that must agree on the block following
+// the one with the given hash and number.
+func (e *IBFT) validators(chain consensus.ChainReader, number uint64, hash common.Hash) (validatorSet, error) {
+	header := chain.GetHeader(hash, number)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return parentValidators(header)
+}
+
+// VerifyUncles implements consensus.Engine, always returning an error for any
+// uncles as this consensus mechanism doesn't permit uncles.
+func (e *IBFT) VerifyUncles(chain consensus.ChainReader, block *types.Block) error {
+	if len(block.Uncles()) > 0 {
+		return errors.New("uncles not allowed")
+	}
+	return nil
+}
+
+// VerifySeal implements consensus.Engine, checking whether the proposer seal
+// and the committed seals contained in the header satisfy the consensus protocol
+// requirements.
+func (e *IBFT) VerifySeal(chain consensus.ChainReader, header *types.Header) error {
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	validators, err := e.validators(chain, number-1, header.ParentHash)
+	if err != nil {
+		return err
+	}
+	return e.verifySeals(header, validators, true)
+}
+
+// verifySeals checks that the block was proposed by a validator and, if requested,
+// that a quorum of distinct validators committed to it.
+func (e *IBFT) verifySeals(header *types.Header, validators validatorSet, committed bool) error {
+	proposer, err := e.Author(header)
+	if err != nil {
+		return err
+	}
+	if !validators.contains(proposer) {
+		return errUnauthorizedProposer
+	}
+	if !committed {
+		return nil
+	}
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return err
+	}
+	var (
+		data = commitData(proposalHash(header))
+		seen = make(map[common.Address]struct{})
+	)
+	for _, seal := range extra.CommittedSeal {
+		signer, err := recoverSigner(data, seal)
+		if err != nil {
+			return errInvalidCommittedSeals
+		}
+		if _, ok := seen[signer]; ok || !validators.contains(signer) {
+			return errInvalidCommittedSeals
+		}
+		seen[signer] = struct{}{}
+	}
+	if len(seen) < validators.quorum() {
+		return errInsufficientCommittedSeals
+	}
+	return nil
+}
+
+// Prepare implements consensus.Engine, preparing all the consensus fields of the
+// header for running the transactions on top.
+func (e *IBFT) Prepare(chain consensus.ChainReader, header *types.Header) error {
+	header.Coinbase = common.Address{}
+	header.Nonce = types.BlockNonce{}
+
+	number := header.Number.Uint64()
+	parent := chain.GetHeader(header.ParentHash, number-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	validators, err := parentValidators(parent)
+	if err != nil {
+		return err
+	}
+	// Cast a random vote from the pending proposals that make sense
+	e.lock.RLock()
+	addresses := make([]common.Address, 0, len(e.proposals))
+	for address, authorize := range e.proposals {
+		if validators.contains(address) != authorize {
+			addresses = append(addresses, address)
+		}
+	}
+	if len(addresses) > 0 {
+		header.Coinbase = addresses[rand.Intn(len(addresses))]
+		if e.proposals[header.Coinbase] {
+			copy(header.Nonce[:], nonceAuthVote)
+		}
+	}
+	e.lock.RUnlock()
+
+	next, err := validators.applyVote(header)
+	if err != nil {
+		return err
+	}
+	header.Difficulty = new(big.Int).Set(defaultDifficulty)
+	header.MixDigest = common.Hash{}
+
+	if err := writeExtra(header, &Extra{Validators: next}); err != nil {
+		return err
+	}
+	// Ensure the timestamp has the correct delay
+	header.Time = new(big.Int).Add(parent.Time, new(big.Int).SetUint64(e.config.BlockPeriod))
+	if header.Time.Int64() < time.Now().Unix() {
+		header.Time = big.NewInt(time.Now().Unix())
+	}
+	return nil
+}
+
+// Finalize implements consensus.Engine, ensuring no uncles are set, nor block
+// rewards given, and returns the final block.
+func (e *IBFT) Finalize(chain consensus.ChainReader, header *types.Header, state *state.StateDB, txs []*types.Transaction, uncles []*types.Header, receipts []*types.Receipt) (*types.Block, error) {
+	header.Root = state.IntermediateRoot(chain.Config().IsEIP158(header.Number))
+	header.UncleHash = types.CalcUncleHash(nil)
+
+	return types.NewBlock(header, txs, nil, receipts), nil
+}
+
+// Authorize injects a private key into the consensus engine to propose blocks
+// and take part in reaching agreement with.
+func (e *IBFT) Authorize(signer common.Address, signFn SignerFn) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.signer = signer
+	e.signFn = signFn
+}
+
+// sign signs the given data with the local validator key.
+func (e *IBFT) sign(data []byte) (common.Address, []byte, error) {
+	e.lock.RLock()
+	signer, signFn := e.signer, e.signFn
+	e.lock.RUnlock()
+
+	if signFn == nil {
+		return common.Address{}, nil, errUnauthorizedProposer
+	}
+	sig, err := signFn(accounts.Account{Address: signer}, mimetypeIBFT, data)
+	return signer, sig, err
+}
+
+// localSigner returns the address of the local validator key, if any.
+func (e *IBFT) localSigner() common.Address {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+
+	return e.signer
+}
+
+// Seal implements consensus.Engine, signing the block as its proposer and handing
+// it over to the consensus protocol. The committed block is delivered on the
+// results channel if agreement is reached while the local node is the proposer.
+func (e *IBFT) Seal(chain consensus.ChainReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) error {
+	header := block.Header()
+
+	// Sealing the genesis block is not supported
+	number := header.Number.Uint64()
+	if number == 0 {
+		return errUnknownBlock
+	}
+	// For 0-period chains, refuse to seal empty blocks (no reward but would spin sealing)
+	if e.config.BlockPeriod == 0 && len(block.Transactions()) == 0 {
+		log.Info("Sealing paused, waiting for transactions")
+		return nil
+	}
+	e.runLock.Lock()
+	agreement := e.agreement
+	e.runLock.Unlock()
+	if agreement == nil {
+		return errNotStarted
+	}
+	// Bail out if we're not a validator for this block
+	validators, err := e.validators(chain, number-1, header.ParentHash)
+	if err != nil {
+		return err
+	}
+	if !validators.contains(e.localSigner()) {
+		return errUnauthorizedProposer
+	}
+	// Sign the block as its proposer
+	payload, err := sealData(header)
+	if err != nil {
+		return err
+	}
+	_, sig, err := e.sign(payload)
+	if err != nil {
+		return err
+	}
+	extra, err := ExtractExtra(header)
+	if err != nil {
+		return err
+	}
+	extra.Seal = sig
+	if err := writeExtra(header, extra); err != nil {
+		return err
+	}
+	// Wait until the block is due and pass it to the consensus protocol
+	delay := time.Unix(header.Time.Int64(), 0).Sub(time.Now()) // nolint: gosimple
+	log.Trace("Waiting for slot to propose", "delay", common.PrettyDuration(delay))
+
+	go func() {
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		agreement.request(&sealRequest{block: block.WithSeal(header), results: results})
+	}()
+	return nil
+}
+
+// sealData returns the RLP encoding of the header to be signed by the proposer.
+func sealData(header *types.Header) ([]byte, error) {
+	cpy := types.CopyHeader(header)
+
+	extra, err := ExtractExtra(cpy)
+	if err != nil {
+		return nil, err
+	}
+	extra.Seal, extra.CommittedSeal = []byte{}, [][]byte{}
+	if err := writeExtra(cpy, extra); err != nil {
+		return nil, err
+	}
+	return rlp.EncodeToBytes(cpy)
+}
+
+// SealHash returns the hash of a block prior to it being sealed.
+func (e *IBFT) SealHash(header *types.Header) common.Hash {
+	return SealHash(header)
+}
+
+// CalcDifficulty is the difficulty adjustment algorithm. Every block is final,
+// so the difficulty is constant.
+func (e *IBFT) CalcDifficulty(chain consensus.ChainReader, time uint64, parent *types.Header) *big.Int {
+	return new(big.Int).Set(defaultDifficulty)
+}
+
+// APIs implements consensus.Engine, returning the user facing RPC API to allow
+// controlling the validator voting.
+func (e *IBFT) APIs(chain consensus.ChainReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "ibft",
+		Version:   "1.0",
+		Service:   &API{chain: chain, ibft: e},
+		Public:    false,
+	}}
+}
+
+// Start launches the consensus state machine, taking part in reaching agreement
+// on new blocks on top of the given chain. Committed blocks proposed by others
+// are imported directly and announced as mined blocks on the event mux.
+func (e *IBFT) Start(chain Chain, mux *event.TypeMux) error {
+	e.runLock.Lock()
+	defer e.runLock.Unlock()
+
+	if e.agreement != nil {
+		return nil
+	}
+	e.mux = mux
+	e.agreement = newAgreement(e, chain, e.network.broadcast)
+	e.agreement.start()
+	return nil
+}
+
+// Close implements consensus.Engine, terminating the consensus state machine.
+func (e *IBFT) Close() error {
+	e.runLock.Lock()
+	defer e.runLock.Unlock()
+
+	if e.agreement != nil {
+		e.agreement.stop()
+		e.agreement = nil
+	}
+	return nil
+}
+
+// isValidator returns whether the given address takes part in agreeing on the
+// block following the local chain head.
+func (e *IBFT) isValidator(address common.Address) bool {
+	e.runLock.Lock()
+	agreement := e.agreement
+	e.runLock.Unlock()
+
+	if agreement == nil {
+		return false
+	}
+	validators, err := parentValidators(agreement.chain.CurrentBlock().Header())
+	return err == nil && validators.contains(address)
+}
+
+// handleMessage injects a consensus message received from the network into the
+// state machine.
+func (e *IBFT) handleMessage(msg *message) {
+	e.runLock.Lock()
+	agreement := e.agreement
+	e.runLock.Unlock()
+
+	if agreement != nil {
+		agreement.deliver(msg)
+	}
+}
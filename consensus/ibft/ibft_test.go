@@ -0,0 +1,399 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ibft
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// testValidator is a single in-process validator node.
+type testValidator struct {
+	key    *ecdsa.PrivateKey
+	engine *IBFT
+	chain  *core.BlockChain
+}
+
+// newTestNetwork creates a set of validators sharing the same genesis block,
+// exchanging consensus messages directly instead of over devp2p. The last
+// offline validators are created, but never take part in reaching agreement.
+func newTestNetwork(t *testing.T, validators int, offline int, timeout uint64) []*testValidator {
+	keys := make([]*ecdsa.PrivateKey, validators)
+	addrs := make([]common.Address, validators)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		addrs[i] = crypto.PubkeyToAddress(keys[i].PublicKey)
+	}
+	// Sort the keys by address so validator indexes match the rotation order
+	addrs = sortValidators(addrs)
+	sorted := make([]*ecdsa.PrivateKey, validators)
+	for _, key := range keys {
+		sorted[validatorSet(addrs).index(crypto.PubkeyToAddress(key.PublicKey))] = key
+	}
+	extra, err := GenesisExtra(addrs)
+	if err != nil {
+		t.Fatalf("failed to create genesis extra-data: %v", err)
+	}
+	config := *params.TestChainConfig
+	config.Ethash = nil
+	config.IBFT = &params.IBFTConfig{BlockPeriod: 1, RequestTimeout: timeout}
+
+	nodes := make([]*testValidator, validators)
+	for i, key := range sorted {
+		db := rawdb.NewMemoryDatabase()
+		genesis := &core.Genesis{Config: &config, ExtraData: extra, Difficulty: big.NewInt(1), GasLimit: params.GenesisGasLimit}
+		genesis.MustCommit(db)
+
+		engine := New(config.IBFT)
+		chain, err := core.NewBlockChain(db, nil, &config, engine, vm.Config{}, nil)
+		if err != nil {
+			t.Fatalf("failed to create validator chain: %v", err)
+		}
+		key := key
+		engine.Authorize(crypto.PubkeyToAddress(key.PublicKey), func(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+			return crypto.Sign(crypto.Keccak256(data), key)
+		})
+		nodes[i] = &testValidator{key: key, engine: engine, chain: chain}
+	}
+	// Wire up the online validators to deliver messages to each other
+	online := nodes[:validators-offline]
+	for i, node := range online {
+		i := i
+		broadcast := func(msg *message) {
+			blob, _ := rlp.EncodeToBytes(msg)
+			for j, peer := range online {
+				if i == j {
+					continue
+				}
+				decoded, err := decodeMessage(blob)
+				if err != nil {
+					panic(err)
+				}
+				go peer.engine.handleMessage(decoded)
+			}
+		}
+		node.engine.agreement = newAgreement(node.engine, node.chain, broadcast)
+		node.engine.agreement.start()
+	}
+	return nodes
+}
+
+// propose assembles an empty block on top of the validator's chain head and
+// passes it to the consensus engine for sealing.
+func (v *testValidator) propose(t *testing.T) <-chan *types.Block {
+	parent := v.chain.CurrentBlock()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   parent.GasLimit(),
+		Time:       big.NewInt(time.Now().Unix()),
+	}
+	if err := v.engine.Prepare(v.chain, header); err != nil {
+		t.Fatalf("failed to prepare header: %v", err)
+	}
+	statedb, err := v.chain.StateAt(parent.Root())
+	if err != nil {
+		t.Fatalf("failed to retrieve parent state: %v", err)
+	}
+	block, err := v.engine.Finalize(v.chain, header, statedb, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to finalize block: %v", err)
+	}
+	results := make(chan *types.Block, 1)
+	if err := v.engine.Seal(v.chain, block, results, make(chan struct{})); err != nil {
+		t.Fatalf("failed to seal block: %v", err)
+	}
+	return results
+}
+
+// Tests that a quorum of validators reaches agreement on a proposed block, and
+// that the committed block verifies on every node.
+func TestAgreement(t *testing.T) {
+	nodes := newTestNetwork(t, 4, 1, 0)
+	defer func() {
+		for _, node := range nodes {
+			node.engine.Close()
+			node.chain.Stop()
+		}
+	}()
+	// Validator #1 is the proposer of block #1 in round zero
+	var block *types.Block
+	select {
+	case block = <-nodes[1].propose(t):
+	case <-time.After(5 * time.Second):
+		t.Fatalf("consensus not reached")
+	}
+	extra, err := ExtractExtra(block.Header())
+	if err != nil {
+		t.Fatalf("failed to decode committed block: %v", err)
+	}
+	if len(extra.CommittedSeal) != 3 {
+		t.Errorf("committed seal count mismatch: have %d, want %d", len(extra.CommittedSeal), 3)
+	}
+	if author, _ := nodes[1].engine.Author(block.Header()); author != crypto.PubkeyToAddress(nodes[1].key.PublicKey) {
+		t.Errorf("author mismatch: have %x, want %x", author, crypto.PubkeyToAddress(nodes[1].key.PublicKey))
+	}
+	// Every node, including the offline one, must accept the block
+	for i, node := range nodes {
+		if _, err := node.chain.InsertChain(types.Blocks{block}); err != nil {
+			t.Errorf("node %d: failed to import committed block: %v", i, err)
+		}
+	}
+	// Dropping a seal must render the block invalid
+	extra.CommittedSeal = extra.CommittedSeal[:2]
+	header := block.Header()
+	if err := writeExtra(header, extra); err != nil {
+		t.Fatalf("failed to encode extra-data: %v", err)
+	}
+	if err := nodes[0].engine.VerifyHeader(nodes[0].chain, header, true); err != errInsufficientCommittedSeals {
+		t.Errorf("stripped seal verification mismatch: have %v, want %v", err, errInsufficientCommittedSeals)
+	}
+}
+
+// Tests that validators move on to the next round if the proposer of the
+// current one has nothing to propose or is offline.
+func TestRoundChange(t *testing.T) {
+	nodes := newTestNetwork(t, 4, 1, 100)
+	defer func() {
+		for _, node := range nodes {
+			node.engine.Close()
+			node.chain.Stop()
+		}
+	}()
+	// Validators #1 and #2 have nothing to propose in rounds zero and one, #3 is
+	// offline in round two, so validator #0 gets to propose in round three.
+	var block *types.Block
+	select {
+	case block = <-nodes[0].propose(t):
+	case <-time.After(5 * time.Second):
+		t.Fatalf("consensus not reached after round changes")
+	}
+	if author, _ := nodes[0].engine.Author(block.Header()); author != crypto.PubkeyToAddress(nodes[0].key.PublicKey) {
+		t.Errorf("author mismatch: have %x, want %x", author, crypto.PubkeyToAddress(nodes[0].key.PublicKey))
+	}
+	if err := nodes[3].engine.VerifyHeader(nodes[3].chain, block.Header(), true); err != nil {
+		t.Errorf("failed to verify committed block: %v", err)
+	}
+}
+
+// Tests that the validators which didn't propose a block import it too once it's
+// committed.
+func TestCommittedImport(t *testing.T) {
+	nodes := newTestNetwork(t, 4, 0, 0)
+	defer func() {
+		for _, node := range nodes {
+			node.engine.Close()
+			node.chain.Stop()
+		}
+	}()
+	var block *types.Block
+	select {
+	case block = <-nodes[1].propose(t):
+	case <-time.After(5 * time.Second):
+		t.Fatalf("consensus not reached")
+	}
+	for _, i := range []int{0, 2, 3} {
+		deadline := time.Now().Add(5 * time.Second)
+		for nodes[i].chain.CurrentBlock().Hash() != block.Hash() {
+			if time.Now().After(deadline) {
+				t.Fatalf("node %d: committed block not imported, head #%d", i, nodes[i].chain.CurrentBlock().NumberU64())
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+// Tests that votes are only accepted if the local validator proposed them too,
+// or if the proposal was locked on by at least one honest validator.
+func TestEndorsement(t *testing.T) {
+	var (
+		engine = New(&params.IBFTConfig{})
+		a      = newAgreement(engine, nil, nil)
+		vote   = common.HexToAddress("0x01")
+		header = &types.Header{Coinbase: vote, Nonce: types.BlockNonce{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}}
+		digest = common.HexToHash("0x02")
+	)
+	a.validators = validatorSet{common.HexToAddress("0x10"), common.HexToAddress("0x11"), common.HexToAddress("0x12"), common.HexToAddress("0x13")}
+	a.lockReports = make(map[common.Hash]map[common.Address]struct{})
+	a.round = &roundState{number: 1}
+
+	if !a.endorsed(&types.Header{}, digest) {
+		t.Errorf("block without vote not endorsed")
+	}
+	if a.endorsed(header, digest) {
+		t.Errorf("unknown vote endorsed")
+	}
+	engine.proposals[vote] = false
+	if a.endorsed(header, digest) {
+		t.Errorf("opposite vote endorsed")
+	}
+	engine.proposals[vote] = true
+	if !a.endorsed(header, digest) {
+		t.Errorf("proposed vote not endorsed")
+	}
+	delete(engine.proposals, vote)
+
+	// A single lock report could come from the faulty validator
+	a.handleRoundChange(&message{Code: msgRoundChange, Digest: digest, sender: a.validators[0]})
+	if a.endorsed(header, digest) {
+		t.Errorf("vote endorsed by a single lock")
+	}
+	a.handleRoundChange(&message{Code: msgRoundChange, Digest: digest, sender: a.validators[1]})
+	if !a.endorsed(header, digest) {
+		t.Errorf("vote locked by F+1 validators not endorsed")
+	}
+}
+
+// Tests that consensus messages are only relayed if they're sent by a validator.
+func TestRelayValidatorsOnly(t *testing.T) {
+	nodes := newTestNetwork(t, 1, 0, 0)
+	defer func() {
+		nodes[0].engine.Close()
+		nodes[0].chain.Stop()
+	}()
+	var (
+		network   = nodes[0].engine.network
+		inA, outA = p2p.MsgPipe()
+		inB, outB = p2p.MsgPipe()
+		outsider  = newTestMessage(t, mustGenerateKey(t), 5)
+		validator = newTestMessage(t, nodes[0].key, 6)
+		relayed   = make(chan []byte, 2)
+	)
+	defer inA.Close()
+	defer inB.Close()
+	go network.run(p2p.NewPeer(enode.ID{1}, "a", nil), outA)
+	go network.run(p2p.NewPeer(enode.ID{2}, "b", nil), outB)
+	for {
+		network.lock.RLock()
+		peers := len(network.peers)
+		network.lock.RUnlock()
+		if peers == 2 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	go func() {
+		for {
+			msg, err := inB.ReadMsg()
+			if err != nil {
+				return
+			}
+			var blob []byte
+			msg.Decode(&blob)
+			relayed <- blob
+		}
+	}()
+	for _, blob := range [][]byte{outsider, validator} {
+		if err := p2p.Send(inA, consensusMsg, blob); err != nil {
+			t.Fatalf("failed to send message: %v", err)
+		}
+	}
+	select {
+	case blob := <-relayed:
+		if !bytes.Equal(blob, validator) {
+			t.Fatalf("relayed wrong message: %x", blob)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("validator message not relayed")
+	}
+}
+
+// newTestMessage creates an encoded round change message signed by the given key.
+func newTestMessage(t *testing.T, key *ecdsa.PrivateKey, round uint64) []byte {
+	msg := &message{Code: msgRoundChange, Height: 1, Round: round}
+	data, err := msg.signingData()
+	if err != nil {
+		t.Fatalf("failed to encode message: %v", err)
+	}
+	if msg.Signature, err = crypto.Sign(crypto.Keccak256(data), key); err != nil {
+		t.Fatalf("failed to sign message: %v", err)
+	}
+	blob, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		t.Fatalf("failed to encode message: %v", err)
+	}
+	return blob
+}
+
+func mustGenerateKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+// Tests that validator votes are applied to the validator set correctly.
+func TestApplyVote(t *testing.T) {
+	var (
+		a = common.HexToAddress("0x01")
+		b = common.HexToAddress("0x02")
+		c = common.HexToAddress("0x03")
+	)
+	auth := types.BlockNonce{}
+	copy(auth[:], nonceAuthVote)
+
+	tests := []struct {
+		set      validatorSet
+		coinbase common.Address
+		nonce    types.BlockNonce
+		want     validatorSet
+	}{
+		{validatorSet{a, b}, common.Address{}, types.BlockNonce{}, validatorSet{a, b}},
+		{validatorSet{a, c}, b, auth, validatorSet{a, b, c}},
+		{validatorSet{a, b}, b, auth, validatorSet{a, b}},
+		{validatorSet{a, b, c}, b, types.BlockNonce{}, validatorSet{a, c}},
+		{validatorSet{a}, a, types.BlockNonce{}, validatorSet{a}},
+	}
+	for i, tt := range tests {
+		have, err := tt.set.applyVote(&types.Header{Coinbase: tt.coinbase, Nonce: tt.nonce})
+		if err != nil {
+			t.Errorf("test %d: failed to apply vote: %v", i, err)
+			continue
+		}
+		if !have.equal(tt.want) {
+			t.Errorf("test %d: validator set mismatch: have %x, want %x", i, have, tt.want)
+		}
+	}
+	if _, err := (validatorSet{a}).applyVote(&types.Header{Coinbase: b, Nonce: types.BlockNonce{1}}); err != errInvalidVote {
+		t.Errorf("invalid vote error mismatch: have %v, want %v", err, errInvalidVote)
+	}
+}
+
+// Tests the quorum sizes of various validator set sizes.
+func TestQuorum(t *testing.T) {
+	for n, want := range map[int]int{1: 1, 2: 2, 3: 2, 4: 3, 5: 4, 6: 4, 7: 5, 10: 7} {
+		if have := make(validatorSet, n).quorum(); have != want {
+			t.Errorf("quorum mismatch for %d validators: have %d, want %d", n, have, want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ibft
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Consensus message codes.
+const (
+	msgPreprepare uint64 = iota
+	msgPrepare
+	msgCommit
+	msgRoundChange
+	msgCommitted
+)
+
+var (
+	errInvalidMessage   = errors.New("invalid consensus message")
+	errInvalidSignature = errors.New("invalid message signature")
+)
+
+// message is a single signed consensus protocol message.
+type message struct {
+	Code          uint64      // Type of the message
+	Height        uint64      // Block number being agreed upon
+	Round         uint64      // Round the message was created in
+	Digest        common.Hash // Proposal hash (prepare, commit, round change)
+	Proposal      []byte      // RLP encoded proposed or committed block (pre-prepare, committed)
+	CommittedSeal []byte      // Validator signature over the proposal (commit)
+	Signature     []byte      // Signature of the sender over all other fields
+
+	sender common.Address // Validator that created the message (recovered)
+	block  *types.Block   // Decoded block (pre-prepare, committed)
+}
+
+// String implements fmt.Stringer.
+func (m *message) String() string {
+	names := map[uint64]string{
+		msgPreprepare:  "PRE-PREPARE",
+		msgPrepare:     "PREPARE",
+		msgCommit:      "COMMIT",
+		msgRoundChange: "ROUND-CHANGE",
+		msgCommitted:   "COMMITTED",
+	}
+	return fmt.Sprintf("%s{height: %d, round: %d, digest: %x}", names[m.Code], m.Height, m.Round, m.Digest[:4])
+}
+
+// signingData returns the RLP encoding of all the fields covered by the sender's
+// signature.
+func (m *message) signingData() ([]byte, error) {
+	return rlp.EncodeToBytes([]interface{}{m.Code, m.Height, m.Round, m.Digest, m.Proposal, m.CommittedSeal})
+}
+
+// sign signs the message with the local validator key.
+func (m *message) sign(engine *IBFT) error {
+	data, err := m.signingData()
+	if err != nil {
+		return err
+	}
+	signer, sig, err := engine.sign(data)
+	if err != nil {
+		return err
+	}
+	m.sender, m.Signature = signer, sig
+	return nil
+}
+
+// decodeMessage decodes a network message, recovers its sender and decodes the
+// block if it carries one.
+func decodeMessage(blob []byte) (*message, error) {
+	msg := new(message)
+	if err := rlp.DecodeBytes(blob, msg); err != nil {
+		return nil, err
+	}
+	if msg.Code > msgCommitted {
+		return nil, errInvalidMessage
+	}
+	data, err := msg.signingData()
+	if err != nil {
+		return nil, err
+	}
+	if msg.sender, err = recoverSigner(data, msg.Signature); err != nil {
+		return nil, errInvalidSignature
+	}
+	if msg.Code == msgPreprepare || msg.Code == msgCommitted {
+		block := new(types.Block)
+		if err := rlp.DecodeBytes(msg.Proposal, block); err != nil {
+			return nil, errInvalidMessage
+		}
+		if block.NumberU64() != msg.Height || proposalHash(block.Header()) != msg.Digest {
+			return nil, errInvalidMessage
+		}
+		msg.block = block
+	}
+	if msg.Code == msgCommit {
+		signer, err := recoverSigner(commitData(msg.Digest), msg.CommittedSeal)
+		if err != nil || signer != msg.sender {
+			return nil, errInvalidSignature
+		}
+	}
+	return msg, nil
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ibft
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Consensus sub-protocol constants.
+const (
+	protocolName    = "ibft"
+	protocolVersion = 1
+	protocolLength  = 1
+
+	consensusMsg = 0x00 // Carries a single RLP encoded consensus message
+
+	protocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
+
+	maxKnownMessages = 1024 // Maximum message hashes to keep in the known list per peer
+	maxSeenMessages  = 4096 // Maximum message hashes to remember as already processed
+	maxQueuedSends   = 256  // Maximum number of messages to queue up for a single peer
+)
+
+// network gossips consensus messages between validators over a dedicated devp2p
+// sub-protocol. Every new message is relayed to all peers not yet knowing it, so
+// validators don't need to be directly connected to each other.
+type network struct {
+	engine *IBFT
+
+	peers map[enode.ID]*peer
+	seen  *lru.ARCCache // Hashes of messages already processed
+	lock  sync.RWMutex
+}
+
+// peer is a remote node speaking the consensus sub-protocol.
+type peer struct {
+	*p2p.Peer
+	rw    p2p.MsgReadWriter
+	known *lru.ARCCache // Hashes of messages known by the peer
+	queue chan []byte   // Messages queued for sending
+	term  chan struct{} // Termination channel to stop the sender
+}
+
+// newNetwork creates the gossip layer of the given engine.
+func newNetwork(engine *IBFT) *network {
+	seen, _ := lru.NewARC(maxSeenMessages)
+	return &network{
+		engine: engine,
+		peers:  make(map[enode.ID]*peer),
+		seen:   seen,
+	}
+}
+
+// Protocols returns the devp2p sub-protocol used to exchange consensus messages.
+func (e *IBFT) Protocols() []p2p.Protocol {
+	return []p2p.Protocol{{
+		Name:    protocolName,
+		Version: protocolVersion,
+		Length:  protocolLength,
+		Run:     e.network.run,
+	}}
+}
+
+// run is invoked for every peer speaking the consensus sub-protocol, relaying
+// consensus messages until the connection is torn down.
+func (n *network) run(p *p2p.Peer, rw p2p.MsgReadWriter) error {
+	known, _ := lru.NewARC(maxKnownMessages)
+	peer := &peer{
+		Peer:  p,
+		rw:    rw,
+		known: known,
+		queue: make(chan []byte, maxQueuedSends),
+		term:  make(chan struct{}),
+	}
+	n.lock.Lock()
+	n.peers[p.ID()] = peer
+	n.lock.Unlock()
+
+	go peer.sendLoop()
+	defer func() {
+		n.lock.Lock()
+		delete(n.peers, p.ID())
+		n.lock.Unlock()
+		close(peer.term)
+	}()
+
+	for {
+		msg, err := rw.ReadMsg()
+		if err != nil {
+			return err
+		}
+		if msg.Size > protocolMaxMsgSize {
+			msg.Discard()
+			return fmt.Errorf("message too large: %v > %v", msg.Size, protocolMaxMsgSize)
+		}
+		if msg.Code != consensusMsg {
+			msg.Discard()
+			return fmt.Errorf("invalid message code: %v", msg.Code)
+		}
+		var blob []byte
+		if err := msg.Decode(&blob); err != nil {
+			return fmt.Errorf("invalid message: %v", err)
+		}
+		hash := crypto.Keccak256Hash(blob)
+		peer.known.Add(hash, nil)
+
+		if n.seen.Contains(hash) {
+			continue
+		}
+		cmsg, err := decodeMessage(blob)
+		if err != nil {
+			return fmt.Errorf("invalid consensus message: %v", err)
+		}
+		// Only messages of current validators are processed and relayed, anyone
+		// else could flood the network otherwise. They're not marked as seen, as
+		// the local node might be lagging behind a validator set change.
+		if !n.engine.isValidator(cmsg.sender) {
+			continue
+		}
+		n.seen.Add(hash, nil)
+		n.engine.handleMessage(cmsg)
+		n.gossip(hash, blob)
+	}
+}
+
+// broadcast gossips a locally created consensus message to all peers.
+func (n *network) broadcast(msg *message) {
+	blob, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		log.Error("Failed to encode consensus message", "err", err)
+		return
+	}
+	hash := crypto.Keccak256Hash(blob)
+	n.seen.Add(hash, nil)
+	n.gossip(hash, blob)
+}
+
+// gossip queues a consensus message for sending to all peers not knowing it yet.
+func (n *network) gossip(hash common.Hash, blob []byte) {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+
+	for _, peer := range n.peers {
+		if peer.known.Contains(hash) {
+			continue
+		}
+		peer.known.Add(hash, nil)
+		select {
+		case peer.queue <- blob:
+		default:
+			peer.Log().Debug("Dropping consensus message, queue full")
+		}
+	}
+}
+
+// sendLoop writes queued messages to the peer until it's disconnected.
+func (p *peer) sendLoop() {
+	for {
+		select {
+		case blob := <-p.queue:
+			if err := p2p.Send(p.rw, consensusMsg, blob); err != nil {
+				p.Log().Debug("Failed to send consensus message", "err", err)
+				return
+			}
+		case <-p.term:
+			return
+		}
+	}
+}
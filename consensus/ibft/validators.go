@@ -0,0 +1,106 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package ibft
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// validatorSet is the sorted list of validators agreeing on a block.
+type validatorSet []common.Address
+
+// contains returns whether the given address is a member of the set.
+func (vs validatorSet) contains(address common.Address) bool {
+	return vs.index(address) >= 0
+}
+
+// index returns the position of the given address in the set, or -1 if it's
+// not a validator.
+func (vs validatorSet) index(address common.Address) int {
+	for i, validator := range vs {
+		if validator == address {
+			return i
+		}
+	}
+	return -1
+}
+
+// faulty returns the maximum number of faulty validators the set can tolerate.
+func (vs validatorSet) faulty() int {
+	return (len(vs) - 1) / 3
+}
+
+// quorum returns the number of matching messages needed to reach agreement,
+// which is ceil(2N/3). Any two quorums intersect in at least one honest node.
+func (vs validatorSet) quorum() int {
+	return (2*len(vs) + 2) / 3
+}
+
+// proposer returns the validator expected to propose the block at the given
+// height and round. Proposers are rotated round robin both across heights and
+// across failed rounds at the same height.
+func (vs validatorSet) proposer(number uint64, round uint64) common.Address {
+	if len(vs) == 0 {
+		return common.Address{}
+	}
+	return vs[(number+round)%uint64(len(vs))]
+}
+
+// applyVote returns the validator set resulting from the vote (if any) cast in
+// the given header. Votes are encoded as in clique: the coinbase is the account
+// voted on, and the nonce signals whether it should be added or removed. Since
+// every block is agreed upon by a quorum, votes take effect immediately.
+func (vs validatorSet) applyVote(header *types.Header) (validatorSet, error) {
+	if header.Coinbase == (common.Address{}) {
+		return vs, nil
+	}
+	switch {
+	case bytes.Equal(header.Nonce[:], nonceAuthVote):
+		if vs.contains(header.Coinbase) {
+			return vs, nil
+		}
+		return validatorSet(sortValidators(append(append([]common.Address{}, vs...), header.Coinbase))), nil
+
+	case bytes.Equal(header.Nonce[:], nonceDropVote):
+		idx := vs.index(header.Coinbase)
+		if idx < 0 || len(vs) == 1 {
+			return vs, nil
+		}
+		next := make(validatorSet, 0, len(vs)-1)
+		next = append(next, vs[:idx]...)
+		return append(next, vs[idx+1:]...), nil
+
+	default:
+		return nil, errInvalidVote
+	}
+}
+
+// equal returns whether two validator sets are identical.
+func (vs validatorSet) equal(other []common.Address) bool {
+	if len(vs) != len(other) {
+		return false
+	}
+	for i := range vs {
+		if vs[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
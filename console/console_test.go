@@ -193,6 +193,26 @@ func TestEvaluate(t *testing.T) {
 	}
 }
 
+// Tests that peers can be banned from the console without specifying the optional
+// duration and reason.
+func TestBanPeer(t *testing.T) {
+	tester := newTester(t, nil)
+	defer tester.Close(t)
+
+	tester.console.Evaluate(`admin.banPeer("10.0.0.1")`)
+	tester.console.Evaluate(`admin.banPeer("10.0.1.0/24", 60)`)
+	tester.console.Evaluate(`admin.banPeer("10.0.2.0/24", 60, "spam")`)
+	tester.console.Evaluate(`admin.listBans().length`)
+
+	output := tester.output.String()
+	if strings.Contains(output, "Error") {
+		t.Fatalf("failed to ban peers: %s", output)
+	}
+	if want := "true\ntrue\ntrue\n3\n"; output != want {
+		t.Fatalf("console output mismatch: have %q, want %q", output, want)
+	}
+}
+
 // Tests that the console can be used in interactive mode.
 func TestInteractive(t *testing.T) {
 	// Create a tester and run an interactive console in the background
@@ -0,0 +1,170 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package checkpointoracle
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/contracts/checkpointoracle/contract"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+const (
+	sectionSize     = 4 // Section size used by the test contract
+	processConfirms = 2 // Confirmations needed by the test contract
+)
+
+// testAccount is a checkpoint signer, also acting as the transaction sender.
+type testAccount struct {
+	key  *ecdsa.PrivateKey
+	addr common.Address
+}
+
+// newTestAccounts creates a batch of signer accounts, sorted by address.
+func newTestAccounts(n int) []testAccount {
+	accounts := make([]testAccount, n)
+	for i := range accounts {
+		key, _ := crypto.GenerateKey()
+		accounts[i] = testAccount{key: key, addr: crypto.PubkeyToAddress(key.PublicKey)}
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		return bytes.Compare(accounts[i].addr[:], accounts[j].addr[:]) < 0
+	})
+	return accounts
+}
+
+// sign creates the Ethereum style signatures of the given accounts over a
+// checkpoint to be registered into the given oracle.
+func sign(t *testing.T, oracle common.Address, index uint64, hash common.Hash, accounts []testAccount) [][]byte {
+	var sigs [][]byte
+	for _, account := range accounts {
+		sig, err := crypto.Sign(SignedHash(oracle, index, hash).Bytes(), account.key)
+		if err != nil {
+			t.Fatalf("failed to sign checkpoint: %v", err)
+		}
+		sig[64] += 27
+		sigs = append(sigs, sig)
+	}
+	return sigs
+}
+
+// Tests that checkpoints are only registered with enough valid signatures, and
+// that the votes can be looked up from the logs afterwards.
+func TestCheckpointRegister(t *testing.T) {
+	accounts := newTestAccounts(3)
+
+	alloc := make(core.GenesisAlloc)
+	for _, account := range accounts {
+		alloc[account.addr] = core.GenesisAccount{Balance: big.NewInt(params.Ether)}
+	}
+	backend := backends.NewSimulatedBackend(alloc, 10000000)
+
+	auth := bind.NewKeyedTransactor(accounts[0].key)
+	admins := []common.Address{accounts[0].addr, accounts[1].addr, accounts[2].addr}
+	addr, _, _, err := contract.DeployCheckpointOracle(auth, backend, admins, big.NewInt(sectionSize), big.NewInt(processConfirms), big.NewInt(2))
+	if err != nil {
+		t.Fatalf("failed to deploy oracle: %v", err)
+	}
+	backend.Commit()
+
+	oracle, err := NewCheckpointOracle(addr, backend)
+	if err != nil {
+		t.Fatalf("failed to bind oracle: %v", err)
+	}
+	if have, err := oracle.Contract().GetAllAdmin(nil); err != nil || !reflect.DeepEqual(have, admins) {
+		t.Fatalf("admin list mismatch: have %x, %v, want %x", have, err, admins)
+	}
+	var (
+		index = uint64(0)
+		hash  = common.HexToHash("0xdeadbeef")
+	)
+	register := func(sigs [][]byte) (*types.Receipt, error) {
+		head, _ := backend.HeaderByNumber(context.Background(), nil)
+		tx, err := oracle.RegisterCheckpoint(auth, index, hash.Bytes(), head.Number, head.Hash(), sigs)
+		if err != nil {
+			return nil, err
+		}
+		backend.Commit()
+		return backend.TransactionReceipt(context.Background(), tx.Hash())
+	}
+	latest := func() (uint64, common.Hash, uint64) {
+		index, hash, height, err := oracle.Contract().GetLatestCheckpoint(nil)
+		if err != nil {
+			t.Fatalf("failed to retrieve latest checkpoint: %v", err)
+		}
+		return index, hash, height.Uint64()
+	}
+	// Checkpoints of unfinished sections must be filtered out
+	if receipt, err := register(sign(t, addr, index, hash, accounts[:2])); err != nil || len(receipt.Logs) != 0 {
+		t.Fatalf("future checkpoint not filtered: %v", err)
+	}
+	for i := 0; i < sectionSize+processConfirms; i++ {
+		backend.Commit()
+	}
+	// Insufficient, unordered or foreign signatures must be rejected
+	if _, err := register(sign(t, addr, index, hash, accounts[:1])); err == nil {
+		t.Errorf("checkpoint with insufficient signatures accepted")
+	}
+	if _, err := register(sign(t, addr, index, hash, []testAccount{accounts[1], accounts[0]})); err == nil {
+		t.Errorf("checkpoint with unordered signatures accepted")
+	}
+	if _, err := register(sign(t, common.Address{0x01}, index, hash, accounts[:2])); err == nil {
+		t.Errorf("checkpoint signed for another oracle accepted")
+	}
+	if _, have, _ := latest(); have != (common.Hash{}) {
+		t.Fatalf("checkpoint registered by rejected transactions: %x", have)
+	}
+	// Sufficient signatures must register the checkpoint
+	receipt, err := register(sign(t, addr, index, hash, accounts[1:]))
+	if err != nil {
+		t.Fatalf("failed to register checkpoint: %v", err)
+	}
+	head, _ := backend.HeaderByNumber(context.Background(), nil)
+	if haveIndex, haveHash, height := latest(); haveIndex != index || haveHash != hash || height != head.Number.Uint64() {
+		t.Errorf("latest checkpoint mismatch: have #%d %x at %d, want #%d %x at %d", haveIndex, haveHash, height, index, hash, head.Number)
+	}
+	votes := oracle.LookupCheckpointEvents([][]*types.Log{receipt.Logs}, index, hash)
+	if len(votes) != 2 {
+		t.Fatalf("vote count mismatch: have %d, want %d", len(votes), 2)
+	}
+	for i, vote := range votes {
+		sig := append(append(vote.R[:], vote.S[:]...), vote.V-27)
+		pubkey, err := crypto.SigToPub(SignedHash(addr, index, hash).Bytes(), sig)
+		if err != nil || crypto.PubkeyToAddress(*pubkey) != accounts[i+1].addr {
+			t.Errorf("vote %d: signer mismatch: %v", i, err)
+		}
+	}
+	if votes := oracle.LookupCheckpointEvents([][]*types.Log{receipt.Logs}, index, common.Hash{}); len(votes) != 0 {
+		t.Errorf("votes found for unknown checkpoint: %d", len(votes))
+	}
+	// Re-registering the same section must be filtered out
+	if receipt, err := register(sign(t, addr, index, common.HexToHash("0xcafebabe"), accounts[1:])); err != nil || len(receipt.Logs) != 0 {
+		t.Errorf("stale checkpoint not filtered: %v", err)
+	}
+}
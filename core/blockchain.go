@@ -1665,6 +1665,28 @@ func (bc *BlockChain) InsertHeaderChain(chain []*types.Header, checkFreq int) (i
 	return bc.hc.InsertHeaderChain(chain, whFunc, start)
 }
 
+// InsertCheckpointHeader stores the header of a trusted checkpoint to sync the
+// chain above it from. See HeaderChain.InsertCheckpointHeader.
+func (bc *BlockChain) InsertCheckpointHeader(header *types.Header) error {
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	return bc.hc.InsertCheckpointHeader(header)
+}
+
+// InsertCheckpointChain stores a batch of headers retrieved backwards from a
+// trusted checkpoint, ordered from the highest to the lowest. It reports whether
+// the batch linked up with the local chain. See HeaderChain.InsertCheckpointChain.
+func (bc *BlockChain) InsertCheckpointChain(chain []*types.Header) (bool, error) {
+	bc.chainmu.Lock()
+	defer bc.chainmu.Unlock()
+
+	bc.wg.Add(1)
+	defer bc.wg.Done()
+
+	return bc.hc.InsertCheckpointChain(chain)
+}
+
 // CurrentHeader retrieves the current head header of the canonical chain. The
 // header is retrieved from the HeaderChain's internal cache.
 func (bc *BlockChain) CurrentHeader() *types.Header {
@@ -1603,3 +1603,55 @@ func TestPrunedImportSide(t *testing.T) {
 	testSideImport(t, 1, 10)
 	testSideImport(t, 1, -10)
 }
+
+// Tests that a chain anchored at a trusted checkpoint and synced above it gets
+// the correct total difficulties once the headers below the checkpoint link up.
+func TestCheckpointHeaderChain(t *testing.T) {
+	var (
+		gendb   = rawdb.NewMemoryDatabase()
+		gspec   = &Genesis{Config: params.TestChainConfig}
+		genesis = gspec.MustCommit(gendb)
+	)
+	blocks, _ := GenerateChain(gspec.Config, genesis, ethash.NewFaker(), gendb, 64, func(i int, block *BlockGen) {})
+	headers := make([]*types.Header, len(blocks))
+	for i, block := range blocks {
+		headers[i] = block.Header()
+	}
+	db := rawdb.NewMemoryDatabase()
+	gspec.MustCommit(db)
+	chain, _ := NewBlockChain(db, nil, gspec.Config, ethash.NewFaker(), vm.Config{}, nil)
+	defer chain.Stop()
+
+	// Anchor the chain at a checkpoint and sync the headers above it
+	checkpoint := 40
+	if err := chain.InsertCheckpointHeader(headers[checkpoint]); err != nil {
+		t.Fatalf("failed to insert checkpoint: %v", err)
+	}
+	if n, err := chain.InsertHeaderChain(headers[checkpoint+1:], 1); err != nil {
+		t.Fatalf("failed to insert header %d above checkpoint: %v", n, err)
+	}
+	if head := chain.CurrentHeader().Hash(); head != headers[len(headers)-1].Hash() {
+		t.Fatalf("head header mismatch: have %x, want %x", head, headers[len(headers)-1].Hash())
+	}
+	// Import the headers below the checkpoint backwards in two batches
+	var below []*types.Header
+	for i := checkpoint - 1; i >= 0; i-- {
+		below = append(below, headers[i])
+	}
+	if linked, err := chain.InsertCheckpointChain(below[:20]); err != nil || linked {
+		t.Fatalf("first batch: linked %v, err %v", linked, err)
+	}
+	if linked, err := chain.InsertCheckpointChain(below[20:]); err != nil || !linked {
+		t.Fatalf("second batch: linked %v, err %v", linked, err)
+	}
+	td := new(big.Int).Set(chain.GetTd(genesis.Hash(), 0))
+	for _, header := range headers {
+		td.Add(td, header.Difficulty)
+		if have := chain.GetTd(header.Hash(), header.Number.Uint64()); have == nil || have.Cmp(td) != 0 {
+			t.Fatalf("td #%d mismatch: have %v, want %v", header.Number, have, td)
+		}
+		if hash := rawdb.ReadCanonicalHash(db, header.Number.Uint64()); hash != header.Hash() {
+			t.Fatalf("canonical hash #%d mismatch: have %x, want %x", header.Number, hash, header.Hash())
+		}
+	}
+}
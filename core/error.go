@@ -32,4 +32,9 @@ var (
 	// ErrNonceTooHigh is returned if the nonce of a transaction is higher than the
 	// next one expected based on the local chain.
 	ErrNonceTooHigh = errors.New("nonce too high")
+
+	// ErrImpersonated is returned if a transaction carries an impersonation marker
+	// instead of a signature. Such transactions are only included by the local
+	// developer sealer, they are never accepted from the network.
+	ErrImpersonated = errors.New("impersonated transaction")
 )
@@ -301,6 +301,159 @@ func (hc *HeaderChain) InsertHeaderChain(chain []*types.Header, writeHeader WhCa
 	return 0, nil
 }
 
+// InsertCheckpointHeader stores the header of a trusted checkpoint as canonical,
+// anchoring the chain synchronisation above it without its ancestors.
+//
+// The true total difficulty of the checkpoint is unknown until the headers below
+// it are retrieved, so it's assigned a provisional one: the difficulty of the
+// local head plus its own. This is a lower bound as long as the local chain is
+// an ancestor of the checkpoint, and is corrected once the span imported via
+// InsertCheckpointChain links up with the local chain.
+func (hc *HeaderChain) InsertCheckpointHeader(header *types.Header) error {
+	hash, number := header.Hash(), header.Number.Uint64()
+	if hc.GetTd(hash, number) != nil {
+		return nil
+	}
+	head := hc.CurrentHeader()
+	td := new(big.Int).Add(hc.GetTd(head.Hash(), head.Number.Uint64()), header.Difficulty)
+
+	batch := hc.chainDb.NewBatch()
+	rawdb.WriteHeader(batch, header)
+	rawdb.WriteTd(batch, hash, number, td)
+	rawdb.WriteCanonicalHash(batch, hash, number)
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	hc.headerCache.Add(hash, header)
+	hc.numberCache.Add(hash, number)
+	return nil
+}
+
+// InsertCheckpointChain stores a batch of headers retrieved backwards from a
+// trusted checkpoint, ordered from the highest number to the lowest. The batch
+// must be linked by parent hashes, the authenticity of the headers deriving
+// solely from the checkpoint at the top of the span, so no seal verification
+// is done.
+//
+// The headers are marked canonical but their total difficulties are unknown
+// until the span links up with a locally known ancestor. Once the parent of the
+// lowest header is known together with its total difficulty, the difficulties
+// of the entire span are filled in and true is returned. The provisional total
+// difficulty of the checkpoint, and of the canonical chain synced on top of it,
+// is corrected at the same time.
+func (hc *HeaderChain) InsertCheckpointChain(chain []*types.Header) (bool, error) {
+	for i := 1; i < len(chain); i++ {
+		if chain[i].Number.Uint64() != chain[i-1].Number.Uint64()-1 || chain[i].Hash() != chain[i-1].ParentHash {
+			return false, fmt.Errorf("non contiguous checkpoint insert: item %d is #%d [%x…], item %d is #%d [%x…] (parent [%x…])", i-1, chain[i-1].Number,
+				chain[i-1].Hash().Bytes()[:4], i, chain[i].Number, chain[i].Hash().Bytes()[:4], chain[i-1].ParentHash.Bytes()[:4])
+		}
+	}
+	// Any header already known with a total difficulty links the span to the
+	// local chain, the ones below it need not be written
+	var ancestor *types.Header
+	for i, header := range chain {
+		if hc.GetTd(header.Hash(), header.Number.Uint64()) != nil {
+			ancestor, chain = header, chain[:i]
+			break
+		}
+	}
+	batch := hc.chainDb.NewBatch()
+	for _, header := range chain {
+		if hc.procInterrupt() {
+			log.Debug("Premature abort during checkpoint headers import")
+			return false, errors.New("aborted")
+		}
+		rawdb.WriteHeader(batch, header)
+		rawdb.WriteCanonicalHash(batch, header.Hash(), header.Number.Uint64())
+	}
+	if err := batch.Write(); err != nil {
+		return false, err
+	}
+	// Check whether the span reached a known ancestor, bail out if not yet
+	if ancestor == nil {
+		last := chain[len(chain)-1]
+		if ancestor = hc.GetHeader(last.ParentHash, last.Number.Uint64()-1); ancestor == nil {
+			return false, nil
+		}
+	}
+	number := ancestor.Number.Uint64()
+	td := hc.GetTd(ancestor.Hash(), number)
+	if td == nil {
+		return false, nil
+	}
+	// Ancestor found, fill in the total difficulties up to the checkpoint
+	var delta *big.Int
+
+	batch.Reset()
+	for number++; ; number++ {
+		hash := rawdb.ReadCanonicalHash(hc.chainDb, number)
+		if hash == (common.Hash{}) {
+			break
+		}
+		header := hc.GetHeader(hash, number)
+		if header == nil {
+			return false, fmt.Errorf("checkpoint header #%d [%x…] missing", number, hash.Bytes()[:4])
+		}
+		td = new(big.Int).Add(td, header.Difficulty)
+
+		// The first header with a known difficulty is the checkpoint, measure the
+		// error of its provisional one
+		if stored := rawdb.ReadTd(hc.chainDb, hash, number); stored != nil {
+			delta = new(big.Int).Sub(td, stored)
+			break
+		}
+		rawdb.WriteTd(batch, hash, number, td)
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return false, err
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return false, err
+	}
+	checkpoint := number
+	if delta != nil && delta.Sign() != 0 {
+		if err := hc.shiftTd(checkpoint, delta); err != nil {
+			return false, err
+		}
+	}
+	log.Info("Linked checkpoint headers to local chain", "ancestor", ancestor.Number, "checkpoint", checkpoint)
+	return true, nil
+}
+
+// shiftTd corrects the total difficulties of the canonical chain from the given
+// block upwards by a fixed amount. Side chains above it are left untouched, only
+// becoming less likely to be reorged to until they are extended.
+func (hc *HeaderChain) shiftTd(number uint64, delta *big.Int) error {
+	batch := hc.chainDb.NewBatch()
+	for ; ; number++ {
+		hash := rawdb.ReadCanonicalHash(hc.chainDb, number)
+		if hash == (common.Hash{}) {
+			break
+		}
+		td := rawdb.ReadTd(hc.chainDb, hash, number)
+		if td == nil {
+			break
+		}
+		rawdb.WriteTd(batch, hash, number, td.Add(td, delta))
+
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	hc.tdCache.Purge()
+	return nil
+}
+
 // GetBlockHashesFromHash retrieves a number of block hashes starting at a given
 // hash, fetching towards the genesis block.
 func (hc *HeaderChain) GetBlockHashesFromHash(hash common.Hash, max uint64) []common.Hash {
@@ -127,6 +127,70 @@ func WriteFastTrieProgress(db ethdb.Writer, count uint64) {
 	}
 }
 
+// ReadCheckpointTail retrieves the hash of the lowest header imported backwards
+// from a trusted checkpoint, allowing an interrupted import to resume.
+func ReadCheckpointTail(db ethdb.Reader) common.Hash {
+	data, _ := db.Get(checkpointTailKey)
+	if len(data) == 0 {
+		return common.Hash{}
+	}
+	return common.BytesToHash(data)
+}
+
+// WriteCheckpointTail stores the hash of the lowest header imported backwards
+// from a trusted checkpoint.
+func WriteCheckpointTail(db ethdb.Writer, hash common.Hash) {
+	if err := db.Put(checkpointTailKey, hash.Bytes()); err != nil {
+		log.Crit("Failed to store checkpoint tail", "err", err)
+	}
+}
+
+// DeleteCheckpointTail removes the checkpoint import marker once the imported
+// headers linked up with the local chain.
+func DeleteCheckpointTail(db ethdb.Deleter) {
+	if err := db.Delete(checkpointTailKey); err != nil {
+		log.Crit("Failed to delete checkpoint tail", "err", err)
+	}
+}
+
+// ReadBackfillRange retrieves the range of blocks whose bodies and receipts
+// still need to be retrieved after a checkpoint sync: the number of the lowest
+// one and the hash of the highest one, as the range is backfilled downwards.
+func ReadBackfillRange(db ethdb.Reader) (target uint64, head common.Hash, ok bool) {
+	data, _ := db.Get(backfillRangeKey)
+	if len(data) == 0 {
+		return 0, common.Hash{}, false
+	}
+	var bounds struct {
+		Target uint64
+		Head   common.Hash
+	}
+	if err := rlp.DecodeBytes(data, &bounds); err != nil {
+		log.Error("Invalid backfill range RLP", "err", err)
+		return 0, common.Hash{}, false
+	}
+	return bounds.Target, bounds.Head, true
+}
+
+// WriteBackfillRange stores the range of blocks still lacking bodies and receipts.
+func WriteBackfillRange(db ethdb.Writer, target uint64, head common.Hash) {
+	data, err := rlp.EncodeToBytes([]interface{}{target, head})
+	if err != nil {
+		log.Crit("Failed to RLP encode backfill range", "err", err)
+	}
+	if err := db.Put(backfillRangeKey, data); err != nil {
+		log.Crit("Failed to store backfill range", "err", err)
+	}
+}
+
+// DeleteBackfillRange removes the backfill marker once all the history below
+// the checkpoint has been retrieved.
+func DeleteBackfillRange(db ethdb.Deleter) {
+	if err := db.Delete(backfillRangeKey); err != nil {
+		log.Crit("Failed to delete backfill range", "err", err)
+	}
+}
+
 // ReadHeaderRLP retrieves a block header in its raw RLP database encoding.
 func ReadHeaderRLP(db ethdb.Reader, hash common.Hash, number uint64) rlp.RawValue {
 	data, _ := db.Get(headerKey(number, hash))
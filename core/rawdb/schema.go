@@ -41,6 +41,12 @@ var (
 	// fastTrieProgressKey tracks the number of trie entries imported during fast sync.
 	fastTrieProgressKey = []byte("TrieSync")
 
+	// checkpointTailKey tracks the lowest header imported backwards from a trusted checkpoint.
+	checkpointTailKey = []byte("CheckpointTail")
+
+	// backfillRangeKey tracks the blocks below a trusted checkpoint still lacking bodies and receipts.
+	backfillRangeKey = []byte("BackfillRange")
+
 	// Data item prefixes (use single byte to avoid mixing data types, avoid `i`, used for indexes).
 	headerPrefix       = []byte("h") // headerPrefix + num (uint64 big endian) + hash -> header
 	headerTDSuffix     = []byte("t") // headerPrefix + num (uint64 big endian) + hash + headerTDSuffix -> td
@@ -0,0 +1,319 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// VerifyConfig contains the settings of a chain database verification.
+type VerifyConfig struct {
+	Repair   bool                        // Whether to fix the inconsistencies found
+	HasState func(root common.Hash) bool // Checks the presence of a state trie (nil = skip state checks)
+}
+
+// ChainIssue is an inconsistency found while verifying the chain database.
+type ChainIssue struct {
+	Number   uint64      // Number of the block the issue belongs to
+	Hash     common.Hash // Canonical hash of the block (zero if missing)
+	Problem  string      // Description of the inconsistency
+	Repaired bool        // Whether the inconsistency was fixed
+}
+
+// String implements fmt.Stringer.
+func (issue ChainIssue) String() string {
+	status := "unrepaired"
+	if issue.Repaired {
+		status = "repaired"
+	}
+	return fmt.Sprintf("#%d [%x…]: %s (%s)", issue.Number, issue.Hash[:4], issue.Problem, status)
+}
+
+// VerifyChain walks the canonical chain from the genesis block to the head header,
+// checking that the headers are linked by their parent hashes and that the total
+// difficulties, hash to number mappings, block bodies, receipts and transaction
+// lookup entries are consistent with them.
+//
+// Bodies and receipts are only required up to the head fast block, excluding the
+// history still being backfilled below a trusted checkpoint. Likewise, the headers
+// between the local chain and the lowest one retrieved backwards from a checkpoint
+// are skipped, and the provisional total difficulties above it are accepted. The
+// state of the head block is checked if a state checker is configured.
+//
+// If repairing is requested, derived data (total difficulties, number mappings and
+// lookup entries) is rewritten, canonical hashes above the head header are deleted,
+// and the head markers are rewound below any corrupted header or block data, so
+// that a subsequent sync only has to retrieve the damaged part of the chain.
+func VerifyChain(db ethdb.Database, config VerifyConfig) []ChainIssue {
+	var issues []ChainIssue
+	report := func(number uint64, hash common.Hash, repaired bool, format string, args ...interface{}) {
+		issue := ChainIssue{Number: number, Hash: hash, Problem: fmt.Sprintf(format, args...), Repaired: repaired}
+		log.Warn("Chain database inconsistency", "number", number, "hash", hash, "problem", issue.Problem, "repaired", repaired)
+		issues = append(issues, issue)
+	}
+	// Resolve the head markers the chain data is required up to
+	headHeader, headerOk := headNumber(db, ReadHeadHeaderHash(db))
+	if !headerOk {
+		// Without a usable head header, consider the whole canonical chain
+		headHeader = math.MaxUint64
+	}
+	headBlock, ok := headNumber(db, ReadHeadBlockHash(db))
+	if !ok {
+		report(0, ReadHeadBlockHash(db), false, "head block not canonical")
+	}
+	headFast, ok := headNumber(db, ReadHeadFastBlockHash(db))
+	if !ok && ReadHeadFastBlockHash(db) != (common.Hash{}) {
+		report(0, ReadHeadFastBlockHash(db), false, "head fast block not canonical")
+	}
+	if headFast < headBlock {
+		headFast = headBlock
+	}
+	var backfillLow, backfillHigh uint64
+	if target, hash, ok := ReadBackfillRange(db); ok {
+		if number, ok := headNumber(db, hash); ok {
+			backfillLow, backfillHigh = target, number
+		}
+	}
+	var tailNumber uint64
+	if tail := ReadCheckpointTail(db); tail != (common.Hash{}) {
+		if number, ok := headNumber(db, tail); ok {
+			tailNumber = number
+		} else {
+			report(0, tail, false, "checkpoint tail not canonical")
+		}
+	}
+	// Walk the canonical chain, stopping at the first broken header
+	var (
+		parent    *types.Header
+		td        *big.Int
+		brokenAt  = uint64(0) // First block with an unusable header (0 = none)
+		missingAt = uint64(0) // First block with missing or corrupted data (0 = none)
+		start     = time.Now()
+		logged    = time.Now()
+		number    uint64
+	)
+	for number = 0; number <= headHeader; number++ {
+		if time.Since(logged) > 8*time.Second {
+			log.Info("Verifying chain database", "number", number, "head", headHeader, "issues", len(issues), "elapsed", common.PrettyDuration(time.Since(start)))
+			logged = time.Now()
+		}
+		hash := ReadCanonicalHash(db, number)
+		if hash == (common.Hash{}) && number < tailNumber {
+			// The headers below the checkpoint tail are still being backfilled,
+			// continue at the tail without knowing its total difficulty
+			number, parent, td = tailNumber, nil, nil
+			hash = ReadCanonicalHash(db, number)
+		}
+		if hash == (common.Hash{}) {
+			if headerOk && number <= headHeader {
+				report(number, hash, false, "canonical hash missing")
+				brokenAt = number
+			}
+			break
+		}
+		header := ReadHeader(db, hash, number)
+		if header == nil {
+			report(number, hash, false, "header missing")
+			brokenAt = number
+			break
+		}
+		if header.Hash() != hash {
+			report(number, hash, false, "header hash mismatch: have %x", header.Hash())
+			brokenAt = number
+			break
+		}
+		if parent != nil && header.ParentHash != parent.Hash() {
+			report(number, hash, false, "header not linked to parent: have %x, want %x", header.ParentHash, parent.Hash())
+			brokenAt = number
+			break
+		}
+		if mapped := ReadHeaderNumber(db, hash); mapped == nil || *mapped != number {
+			if config.Repair {
+				WriteHeader(db, header)
+			}
+			report(number, hash, config.Repair, "hash to number mapping missing")
+		}
+		// Verify the total difficulty against the parent's. Above the checkpoint
+		// tail, the difficulties are unknown up to the checkpoint, whose provisional
+		// one the difficulties of its descendants are based on.
+		switch {
+		case number == 0:
+			td = new(big.Int).Set(header.Difficulty)
+		case td != nil:
+			td = new(big.Int).Add(td, header.Difficulty)
+		}
+		have := ReadTd(db, hash, number)
+		if td == nil {
+			td = have
+		} else if have == nil || have.Cmp(td) != 0 {
+			if config.Repair {
+				WriteTd(db, hash, number, td)
+			}
+			report(number, hash, config.Repair, "total difficulty mismatch: have %v, want %v", have, td)
+		}
+		parent = header
+
+		// Verify the block body, receipts and lookup entries against the header
+		required := number <= headFast && (number < backfillLow || number > backfillHigh)
+		if !verifyBlockData(db, header, required, config.Repair, report) && required && missingAt == 0 {
+			missingAt = number
+		}
+	}
+	if config.HasState != nil && headBlock < number && (brokenAt == 0 || headBlock < brokenAt) {
+		hash := ReadCanonicalHash(db, headBlock)
+		if header := ReadHeader(db, hash, headBlock); header != nil && !config.HasState(header.Root) {
+			report(headBlock, hash, false, "state of head block missing: root %x", header.Root)
+		}
+	}
+	// Rewind the head markers below any damage if repairing was requested
+	if !headerOk && number > 0 {
+		hash := ReadCanonicalHash(db, number-1)
+		if config.Repair && brokenAt == 0 {
+			WriteHeadHeaderHash(db, hash)
+		}
+		report(number-1, hash, config.Repair, "head header not canonical")
+	}
+	if brokenAt == 0 {
+		// Canonical hashes above the head are leftovers of interrupted rewinds
+		tip := headHeader
+		if !headerOk {
+			tip = number - 1
+		}
+		for _, n := range canonicalNumbersAbove(db, tip) {
+			hash := ReadCanonicalHash(db, n)
+			if config.Repair {
+				DeleteCanonicalHash(db, n)
+			}
+			report(n, hash, config.Repair, "dangling canonical hash above head header")
+		}
+	}
+	if config.Repair && brokenAt > 0 {
+		for _, n := range canonicalNumbersAbove(db, brokenAt-1) {
+			DeleteCanonicalHash(db, n)
+		}
+		hash := ReadCanonicalHash(db, brokenAt-1)
+		WriteHeadHeaderHash(db, hash)
+		report(brokenAt, common.Hash{}, true, "canonical chain truncated to #%d", brokenAt-1)
+
+		if missingAt == 0 || missingAt > brokenAt {
+			missingAt = brokenAt
+		}
+	}
+	if config.Repair && missingAt > 0 && missingAt <= headFast {
+		hash := ReadCanonicalHash(db, missingAt-1)
+		WriteHeadFastBlockHash(db, hash)
+		if headBlock >= missingAt {
+			WriteHeadBlockHash(db, hash)
+		}
+		report(missingAt, common.Hash{}, true, "head block rewound to #%d", missingAt-1)
+	}
+	log.Info("Verified chain database", "blocks", number, "issues", len(issues), "elapsed", common.PrettyDuration(time.Since(start)))
+	return issues
+}
+
+// verifyBlockData checks the body, receipts and transaction lookup entries of a
+// canonical block against its header, returning whether they are all present
+// and valid. Missing data is only reported if required.
+func verifyBlockData(db ethdb.Database, header *types.Header, required, repair bool, report func(uint64, common.Hash, bool, string, ...interface{})) bool {
+	var (
+		hash   = header.Hash()
+		number = header.Number.Uint64()
+		valid  = true
+	)
+	body := ReadBody(db, hash, number)
+	switch {
+	case body == nil:
+		if required {
+			report(number, hash, false, "block body missing")
+		}
+		valid = false
+
+	case types.DeriveSha(types.Transactions(body.Transactions)) != header.TxHash || types.CalcUncleHash(body.Uncles) != header.UncleHash:
+		if repair {
+			DeleteBody(db, hash, number)
+		}
+		report(number, hash, repair, "block body doesn't match header")
+		valid = false
+
+	default:
+		missing := 0
+		for _, tx := range body.Transactions {
+			if ReadTxLookupEntry(db, tx.Hash()) != hash {
+				missing++
+			}
+		}
+		if missing > 0 {
+			if repair {
+				WriteTxLookupEntries(db, types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles))
+			}
+			report(number, hash, repair, "%d transaction lookup entries missing", missing)
+		}
+	}
+	switch {
+	case !HasReceipts(db, hash, number):
+		if required {
+			report(number, hash, false, "receipts missing")
+		}
+		valid = false
+
+	case types.DeriveSha(ReadReceipts(db, hash, number)) != header.ReceiptHash:
+		if repair {
+			DeleteReceipts(db, hash, number)
+		}
+		report(number, hash, repair, "receipts don't match header")
+		valid = false
+	}
+	return valid
+}
+
+// canonicalNumbersAbove iterates the database for the block numbers above the
+// given one which have a canonical hash assigned.
+func canonicalNumbersAbove(db ethdb.Database, number uint64) []uint64 {
+	it := db.NewIteratorWithPrefix(headerPrefix)
+	defer it.Release()
+
+	var numbers []uint64
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(headerPrefix)+8+len(headerHashSuffix) || !bytes.HasSuffix(key, headerHashSuffix) {
+			continue
+		}
+		if n := binary.BigEndian.Uint64(key[len(headerPrefix):]); n > number {
+			numbers = append(numbers, n)
+		}
+	}
+	return numbers
+}
+
+// headNumber resolves the number of a head marker, checking that it's canonical.
+func headNumber(db ethdb.Reader, hash common.Hash) (uint64, bool) {
+	number := ReadHeaderNumber(db, hash)
+	if number == nil || ReadCanonicalHash(db, *number) != hash {
+		return 0, false
+	}
+	return *number, true
+}
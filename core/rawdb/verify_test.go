@@ -0,0 +1,192 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package rawdb
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethdb"
+)
+
+// writeVerifyChain writes a canonical chain of the given length with a single
+// transaction in each block, returning the blocks.
+func writeVerifyChain(db ethdb.Database, length int) []*types.Block {
+	var (
+		blocks []*types.Block
+		parent common.Hash
+		td     = new(big.Int)
+	)
+	for i := 0; i < length; i++ {
+		tx := types.NewTransaction(uint64(i), common.Address{0x01}, big.NewInt(1), 21000, big.NewInt(1), nil)
+		receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 21000, TxHash: tx.Hash()}
+
+		header := &types.Header{Number: big.NewInt(int64(i)), ParentHash: parent, Difficulty: big.NewInt(int64(i + 1))}
+		block := types.NewBlock(header, []*types.Transaction{tx}, nil, []*types.Receipt{receipt})
+		td.Add(td, header.Difficulty)
+
+		WriteBlock(db, block)
+		WriteReceipts(db, block.Hash(), block.NumberU64(), types.Receipts{receipt})
+		WriteTd(db, block.Hash(), block.NumberU64(), td)
+		WriteCanonicalHash(db, block.Hash(), block.NumberU64())
+		WriteTxLookupEntries(db, block)
+
+		blocks = append(blocks, block)
+		parent = block.Hash()
+	}
+	head := blocks[len(blocks)-1].Hash()
+	WriteHeadHeaderHash(db, head)
+	WriteHeadBlockHash(db, head)
+	WriteHeadFastBlockHash(db, head)
+	return blocks
+}
+
+// Tests that a consistent chain database passes verification, and that derived
+// data is reported and repaired.
+func TestVerifyChainRepair(t *testing.T) {
+	db := NewMemoryDatabase()
+	blocks := writeVerifyChain(db, 10)
+
+	if issues := VerifyChain(db, VerifyConfig{}); len(issues) != 0 {
+		t.Fatalf("consistent chain reported issues: %v", issues)
+	}
+	// Damage the derived data and the receipts of a block
+	DeleteTxLookupEntry(db, blocks[3].Transactions()[0].Hash())
+	WriteTd(db, blocks[4].Hash(), 4, big.NewInt(1))
+	WriteCanonicalHash(db, common.Hash{0xde, 0xad}, 12)
+	DeleteReceipts(db, blocks[6].Hash(), 6)
+
+	if issues := VerifyChain(db, VerifyConfig{}); len(issues) != 4 {
+		t.Fatalf("issue count mismatch: have %d, want %d: %v", len(issues), 4, issues)
+	}
+	VerifyChain(db, VerifyConfig{Repair: true})
+
+	if ReadTxLookupEntry(db, blocks[3].Transactions()[0].Hash()) != blocks[3].Hash() {
+		t.Fatalf("transaction lookup entry not repaired")
+	}
+	if td := ReadTd(db, blocks[4].Hash(), 4); td.Cmp(big.NewInt(15)) != 0 {
+		t.Fatalf("total difficulty not repaired: have %v, want %v", td, 15)
+	}
+	if hash := ReadCanonicalHash(db, 12); hash != (common.Hash{}) {
+		t.Fatalf("dangling canonical hash not deleted")
+	}
+	if head := ReadHeadBlockHash(db); head != blocks[5].Hash() {
+		t.Fatalf("head block not rewound below missing receipts: have %x, want %x", head, blocks[5].Hash())
+	}
+	if head := ReadHeadHeaderHash(db); head != blocks[9].Hash() {
+		t.Fatalf("head header changed: have %x, want %x", head, blocks[9].Hash())
+	}
+	if issues := VerifyChain(db, VerifyConfig{}); len(issues) != 0 {
+		t.Fatalf("repaired chain reported issues: %v", issues)
+	}
+}
+
+// Tests that a broken header chain is truncated below the damage, and that the
+// state of the head block is checked.
+func TestVerifyChainBroken(t *testing.T) {
+	db := NewMemoryDatabase()
+	blocks := writeVerifyChain(db, 10)
+
+	// Replace a canonical block with an unlinked one
+	fake := types.NewBlock(&types.Header{Number: big.NewInt(7), Difficulty: big.NewInt(1)}, nil, nil, nil)
+	WriteBlock(db, fake)
+	WriteCanonicalHash(db, fake.Hash(), 7)
+
+	hasState := func(root common.Hash) bool { return false }
+	issues := VerifyChain(db, VerifyConfig{HasState: hasState})
+	if len(issues) != 1 || issues[0].Number != 7 {
+		t.Fatalf("broken linkage not reported: %v", issues)
+	}
+	VerifyChain(db, VerifyConfig{Repair: true})
+
+	for n := uint64(7); n < 10; n++ {
+		if hash := ReadCanonicalHash(db, n); hash != (common.Hash{}) {
+			t.Fatalf("canonical hash #%d not deleted", n)
+		}
+	}
+	if head := ReadHeadHeaderHash(db); head != blocks[6].Hash() {
+		t.Fatalf("head header not rewound: have %x, want %x", head, blocks[6].Hash())
+	}
+	if head := ReadHeadBlockHash(db); head != blocks[6].Hash() {
+		t.Fatalf("head block not rewound: have %x, want %x", head, blocks[6].Hash())
+	}
+	// The state of the new head block is missing, which can't be repaired
+	issues = VerifyChain(db, VerifyConfig{HasState: hasState, Repair: true})
+	if len(issues) != 1 || issues[0].Number != 6 || issues[0].Repaired {
+		t.Fatalf("missing state not reported: %v", issues)
+	}
+}
+
+// Tests that a database synced from a trusted checkpoint passes verification while
+// the headers and blocks below the checkpoint are still being backfilled.
+func TestVerifyChainCheckpoint(t *testing.T) {
+	db := NewMemoryDatabase()
+	blocks := writeVerifyChain(db, 20)
+
+	// Turn the database into one synced from a checkpoint at #12 on top of a local
+	// chain up to #2, with the headers down to #8 backfilled already
+	var (
+		tail, checkpoint = uint64(8), uint64(12)
+		delta            = new(big.Int)
+	)
+	for n := uint64(3); n <= checkpoint; n++ {
+		hash := blocks[n].Hash()
+		if n < tail {
+			DeleteCanonicalHash(db, n)
+			DeleteHeader(db, hash, n)
+			delta.Add(delta, blocks[n].Difficulty())
+		} else if n < checkpoint {
+			delta.Add(delta, blocks[n].Difficulty())
+		}
+		if n < checkpoint {
+			DeleteTd(db, hash, n)
+		}
+		DeleteBody(db, hash, n)
+		DeleteReceipts(db, hash, n)
+	}
+	for n := checkpoint; n < 20; n++ {
+		hash := blocks[n].Hash()
+		WriteTd(db, hash, n, new(big.Int).Sub(ReadTd(db, hash, n), delta))
+	}
+	WriteCheckpointTail(db, blocks[tail].Hash())
+	WriteBackfillRange(db, 3, blocks[checkpoint].Hash())
+
+	if issues := VerifyChain(db, VerifyConfig{}); len(issues) != 0 {
+		t.Fatalf("checkpoint synced chain reported issues: %v", issues)
+	}
+	// Damage a provisional total difficulty, which is repaired based on the checkpoint's
+	want := ReadTd(db, blocks[15].Hash(), 15)
+	WriteTd(db, blocks[15].Hash(), 15, big.NewInt(1))
+
+	if issues := VerifyChain(db, VerifyConfig{Repair: true}); len(issues) != 1 || issues[0].Number != 15 {
+		t.Fatalf("damaged total difficulty not reported: %v", issues)
+	}
+	if td := ReadTd(db, blocks[15].Hash(), 15); td.Cmp(want) != 0 {
+		t.Fatalf("total difficulty not repaired: have %v, want %v", td, want)
+	}
+	if head := ReadHeadHeaderHash(db); head != blocks[19].Hash() {
+		t.Fatalf("head header changed: have %x, want %x", head, blocks[19].Hash())
+	}
+	if hash := ReadCanonicalHash(db, 19); hash != blocks[19].Hash() {
+		t.Fatalf("canonical hash deleted")
+	}
+	if issues := VerifyChain(db, VerifyConfig{}); len(issues) != 0 {
+		t.Fatalf("repaired chain reported issues: %v", issues)
+	}
+}
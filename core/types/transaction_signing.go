@@ -49,9 +49,53 @@ func MakeSigner(config *params.ChainConfig, blockNumber *big.Int) Signer {
 	default:
 		signer = FrontierSigner{}
 	}
+	if config.Impersonation {
+		signer = impersonationSigner{signer}
+	}
 	return signer
 }
 
+// Impersonate returns a copy of an unsigned transaction carrying an impersonation
+// marker for the given account in place of a signature. The marker has zero V and
+// S values, which no valid signature has, and the address in R. Such transactions
+// are only accepted on chains enabling impersonation, meant for development.
+func Impersonate(tx *Transaction, from common.Address) *Transaction {
+	cpy := &Transaction{data: tx.data}
+	cpy.data.V = new(big.Int)
+	cpy.data.R = new(big.Int).SetBytes(from[:])
+	cpy.data.S = new(big.Int)
+	return cpy
+}
+
+// ImpersonatedSender returns the account named by the impersonation marker of
+// a transaction, if it carries one.
+func ImpersonatedSender(tx *Transaction) (common.Address, bool) {
+	v, r, s := tx.RawSignatureValues()
+	if v.Sign() != 0 || s.Sign() != 0 || r.Sign() == 0 || r.BitLen() > 8*common.AddressLength {
+		return common.Address{}, false
+	}
+	return common.BigToAddress(r), true
+}
+
+// impersonationSigner wraps the signer of a chain enabling impersonation,
+// attributing transactions carrying an impersonation marker to the account
+// named by it.
+type impersonationSigner struct {
+	Signer
+}
+
+func (s impersonationSigner) Equal(s2 Signer) bool {
+	other, ok := s2.(impersonationSigner)
+	return ok && s.Signer.Equal(other.Signer)
+}
+
+func (s impersonationSigner) Sender(tx *Transaction) (common.Address, error) {
+	if from, ok := ImpersonatedSender(tx); ok {
+		return from, nil
+	}
+	return s.Signer.Sender(tx)
+}
+
 // SignTx signs the transaction using the given signer and private key
 func SignTx(tx *Transaction, s Signer, prv *ecdsa.PrivateKey) (*Transaction, error) {
 	h := s.Hash(tx)
@@ -22,6 +22,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -136,3 +137,25 @@ func TestChainId(t *testing.T) {
 		t.Error("expected no error")
 	}
 }
+
+func TestImpersonation(t *testing.T) {
+	from := common.HexToAddress("0xaa")
+	tx := Impersonate(NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), nil), from)
+
+	config := *params.AllEthashProtocolChanges
+	if _, err := Sender(MakeSigner(&config, big.NewInt(1)), tx); err == nil {
+		t.Error("expected error without impersonation enabled")
+	}
+	config.Impersonation = true
+	sender, err := Sender(MakeSigner(&config, big.NewInt(1)), tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sender != from {
+		t.Errorf("expected %x got %x", from, sender)
+	}
+	// Unsigned transactions don't carry a marker
+	if _, ok := ImpersonatedSender(NewTransaction(0, common.Address{}, new(big.Int), 0, new(big.Int), nil)); ok {
+		t.Error("unsigned transaction reported as impersonated")
+	}
+}
@@ -26,11 +26,13 @@ import (
 	"sync/atomic"
 
 	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/consensus"
 	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/consensus/ibft"
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/bloombits"
 	"github.com/ethereum/go-ethereum/core/rawdb"
@@ -57,6 +59,7 @@ type LesServer interface {
 	APIs() []rpc.API
 	Protocols() []p2p.Protocol
 	SetBloomBitsIndexer(bbIndexer *core.ChainIndexer)
+	SetContractBackend(bind.ContractBackend)
 }
 
 // Ethereum implements the Ethereum full node service.
@@ -100,6 +103,15 @@ func (s *Ethereum) AddLesServer(ls LesServer) {
 	ls.SetBloomBitsIndexer(s.bloomIndexer)
 }
 
+// SetContractBackend sets the contract backend used by the LES server for
+// interacting with on-chain contracts, e.g. the checkpoint oracle.
+func (s *Ethereum) SetContractBackend(backend bind.ContractBackend) {
+	// Pass the rpc client to les server if it is enabled.
+	if s.lesServer != nil {
+		s.lesServer.SetContractBackend(backend)
+	}
+}
+
 // New creates a new Ethereum object (including the
 // initialisation of the common Ethereum object)
 func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
@@ -114,6 +126,14 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 		log.Warn("Sanitizing invalid miner gas price", "provided", config.MinerGasPrice, "updated", DefaultConfig.MinerGasPrice)
 		config.MinerGasPrice = new(big.Int).Set(DefaultConfig.MinerGasPrice)
 	}
+	if config.BlockAnnounceLimit.Rate <= 0 || config.BlockAnnounceLimit.Burst <= 0 {
+		log.Warn("Sanitizing invalid block announcement limit", "provided", config.BlockAnnounceLimit, "updated", DefaultConfig.BlockAnnounceLimit)
+		config.BlockAnnounceLimit = DefaultConfig.BlockAnnounceLimit
+	}
+	if config.TxAnnounceLimit.Rate <= 0 || config.TxAnnounceLimit.Burst <= 0 {
+		log.Warn("Sanitizing invalid transaction announcement limit", "provided", config.TxAnnounceLimit, "updated", DefaultConfig.TxAnnounceLimit)
+		config.TxAnnounceLimit = DefaultConfig.TxAnnounceLimit
+	}
 	if config.NoPruning && config.TrieDirtyCache > 0 {
 		config.TrieCleanCache += config.TrieDirtyCache
 		config.TrieDirtyCache = 0
@@ -186,9 +206,14 @@ func New(ctx *node.ServiceContext, config *Config) (*Ethereum, error) {
 	}
 	eth.txPool = core.NewTxPool(config.TxPool, eth.chainConfig, eth.blockchain)
 
-	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb, config.Whitelist); err != nil {
+	if eth.protocolManager, err = NewProtocolManager(eth.chainConfig, config.SyncMode, config.NetworkId, eth.eventMux, eth.txPool, eth.engine, eth.blockchain, chainDb, config.Whitelist, msgLimits(config.BlockAnnounceLimit, config.TxAnnounceLimit)); err != nil {
 		return nil, err
 	}
+	if config.Checkpoint != nil && chainConfig.Clique != nil {
+		log.Warn("Checkpoint sync unsupported on clique networks, disabling")
+	} else {
+		eth.protocolManager.downloader.SetCheckpoint(config.Checkpoint)
+	}
 
 	eth.miner = miner.New(eth, eth.chainConfig, eth.EventMux(), eth.engine, config.MinerRecommit, config.MinerGasFloor, config.MinerGasCeil, eth.isLocalBlock)
 	eth.miner.SetExtra(makeExtraData(config.MinerExtraData))
@@ -226,6 +251,10 @@ func CreateConsensusEngine(ctx *node.ServiceContext, chainConfig *params.ChainCo
 	if chainConfig.Clique != nil {
 		return clique.New(chainConfig.Clique, db)
 	}
+	// If Byzantine fault tolerance is requested, set it up
+	if chainConfig.IBFT != nil {
+		return ibft.New(chainConfig.IBFT)
+	}
 	// Otherwise assume proof-of-work
 	switch config.PowMode {
 	case ethash.ModeFake:
@@ -239,12 +268,14 @@ func CreateConsensusEngine(ctx *node.ServiceContext, chainConfig *params.ChainCo
 		return ethash.NewShared()
 	default:
 		engine := ethash.New(ethash.Config{
-			CacheDir:       ctx.ResolvePath(config.CacheDir),
-			CachesInMem:    config.CachesInMem,
-			CachesOnDisk:   config.CachesOnDisk,
-			DatasetDir:     config.DatasetDir,
-			DatasetsInMem:  config.DatasetsInMem,
-			DatasetsOnDisk: config.DatasetsOnDisk,
+			CacheDir:        ctx.ResolvePath(config.CacheDir),
+			CachesInMem:     config.CachesInMem,
+			CachesOnDisk:    config.CachesOnDisk,
+			DatasetDir:      config.DatasetDir,
+			DatasetsInMem:   config.DatasetsInMem,
+			DatasetsOnDisk:  config.DatasetsOnDisk,
+			StratumAddr:     config.StratumAddr,
+			ShareDifficulty: config.ShareDifficulty,
 		}, notify, noverify)
 		engine.SetThreads(-1) // Disable CPU mining
 		return engine
@@ -390,6 +421,10 @@ func (s *Ethereum) shouldPreserve(block *types.Block) bool {
 	if _, ok := s.engine.(*clique.Clique); ok {
 		return false
 	}
+	// Blocks are final with ibft, there's never a choice to make between forks
+	if _, ok := s.engine.(*ibft.IBFT); ok {
+		return false
+	}
 	return s.isLocalBlock(block)
 }
 
@@ -439,6 +474,14 @@ func (s *Ethereum) StartMining(threads int) error {
 			}
 			clique.Authorize(eb, wallet.SignData)
 		}
+		if ibft, ok := s.engine.(*ibft.IBFT); ok {
+			wallet, err := s.accountManager.Find(accounts.Account{Address: eb})
+			if wallet == nil || err != nil {
+				log.Error("Etherbase account unavailable locally", "err", err)
+				return fmt.Errorf("validator missing: %v", err)
+			}
+			ibft.Authorize(eb, wallet.SignData)
+		}
 		// If mining is started, we can disable the transaction rejection mechanism
 		// introduced to speed sync times.
 		atomic.StoreUint32(&s.protocolManager.acceptTxs, 1)
@@ -479,10 +522,15 @@ func (s *Ethereum) Downloader() *downloader.Downloader { return s.protocolManage
 // Protocols implements node.Service, returning all the currently configured
 // network protocols to start.
 func (s *Ethereum) Protocols() []p2p.Protocol {
-	if s.lesServer == nil {
-		return s.protocolManager.SubProtocols
+	protos := s.protocolManager.SubProtocols
+	if s.lesServer != nil {
+		protos = append(protos, s.lesServer.Protocols()...)
+	}
+	// Append any protocols needed explicitly by the consensus engine
+	if ibft, ok := s.engine.(*ibft.IBFT); ok {
+		protos = append(protos, ibft.Protocols()...)
 	}
-	return append(s.protocolManager.SubProtocols, s.lesServer.Protocols()...)
+	return protos
 }
 
 // Start implements node.Service, starting all internal goroutines needed by the
@@ -491,6 +539,8 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 	// Start the bloom bits servicing goroutines
 	s.startBloomHandlers(params.BloomBitsBlocks)
 
+	s.startEthEntryUpdate(srvr.LocalNode())
+
 	// Start the RPC service
 	s.netRPCService = ethapi.NewPublicNetAPI(srvr, s.NetVersion())
 
@@ -507,6 +557,18 @@ func (s *Ethereum) Start(srvr *p2p.Server) error {
 	if s.lesServer != nil {
 		s.lesServer.Start(srvr)
 	}
+	// Start taking part in reaching consensus if the engine requires it
+	if ibft, ok := s.engine.(*ibft.IBFT); ok {
+		if err := ibft.Start(s.blockchain, s.eventMux); err != nil {
+			return err
+		}
+	}
+	// Start pushing work packages to remote miners if requested
+	if ethash, ok := s.engine.(*ethash.Ethash); ok && s.config.Ethash.StratumAddr != "" {
+		if err := ethash.StartStratum(s.config.Ethash.StratumAddr); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/params"
 )
 
@@ -54,6 +55,11 @@ var DefaultConfig = Config{
 	MinerGasPrice:  big.NewInt(params.GWei),
 	MinerRecommit:  3 * time.Second,
 
+	// The announcement limits leave ample room for bursts after reorgs and busy
+	// transaction pools on chains with the block time of the main net.
+	BlockAnnounceLimit: p2p.MsgLimit{Rate: 2, Burst: 64},
+	TxAnnounceLimit:    p2p.MsgLimit{Rate: 100, Burst: 1000},
+
 	TxPool: core.DefaultTxPoolConfig,
 	GPO: gasprice.Config{
 		Blocks:     20,
@@ -97,6 +103,10 @@ type Config struct {
 	// Whitelist of required block number -> hash values to accept
 	Whitelist map[uint64]common.Hash `toml:"-"`
 
+	// Rate limits of the block and transaction announcements received from a peer
+	BlockAnnounceLimit p2p.MsgLimit
+	TxAnnounceLimit    p2p.MsgLimit
+
 	// Light client options
 	LightServ         int  `toml:",omitempty"` // Maximum percentage of time allowed for serving LES requests
 	LightBandwidthIn  int  `toml:",omitempty"` // Incoming bandwidth limit for light servers
@@ -107,6 +117,12 @@ type Config struct {
 	// Ultra Light client options
 	ULC *ULCConfig `toml:",omitempty"`
 
+	// Trusted checkpoint to start the chain synchronisation from instead of genesis
+	Checkpoint *params.TrustedCheckpoint `toml:",omitempty"`
+
+	// Checkpoint oracle contract announcing the trusted checkpoints for light clients
+	CheckpointOracle *params.CheckpointOracleConfig `toml:",omitempty"`
+
 	// Database options
 	SkipBcVersionCheck bool `toml:"-"`
 	DatabaseHandles    int  `toml:"-"`
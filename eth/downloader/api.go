@@ -19,12 +19,18 @@ package downloader
 import (
 	"context"
 	"sync"
+	"time"
 
 	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/rpc"
 )
 
+// syncStatusInterval is the interval at which the sync progress is reported to
+// the syncing subscriptions while a synchronisation is running.
+var syncStatusInterval = 8 * time.Second
+
 // PublicDownloaderAPI provides an API which gives information about the current synchronisation status.
 // It offers only methods that operates on data that can be available to anyone without security risks.
 type PublicDownloaderAPI struct {
@@ -53,39 +59,62 @@ func NewPublicDownloaderAPI(d *Downloader, m *event.TypeMux) *PublicDownloaderAP
 
 // eventLoop runs a loop until the event mux closes. It will install and uninstall new
 // sync subscriptions and broadcasts sync status updates to the installed sync subscriptions.
+// While a sync is running, its progress is also broadcast periodically.
 func (api *PublicDownloaderAPI) eventLoop() {
 	var (
 		sub               = api.mux.Subscribe(StartEvent{}, DoneEvent{}, FailedEvent{})
 		syncSubscriptions = make(map[chan interface{}]struct{})
+		ticker            *time.Ticker
+		tick              <-chan time.Time
 	)
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
 
 	for {
+		var notification interface{}
+
 		select {
 		case i := <-api.installSyncSubscription:
 			syncSubscriptions[i] = struct{}{}
+			continue
 		case u := <-api.uninstallSyncSubscription:
 			delete(syncSubscriptions, u.c)
 			close(u.uninstalled)
+			continue
+		case <-tick:
+			notification = &SyncingResult{
+				Syncing: true,
+				Status:  RPCMarshalProgress(api.d.Progress()),
+			}
 		case event := <-sub.Chan():
 			if event == nil {
 				return
 			}
-
-			var notification interface{}
 			switch event.Data.(type) {
 			case StartEvent:
 				notification = &SyncingResult{
 					Syncing: true,
-					Status:  api.d.Progress(),
+					Status:  RPCMarshalProgress(api.d.Progress()),
+				}
+				if ticker == nil {
+					ticker = time.NewTicker(syncStatusInterval)
+					tick = ticker.C
 				}
 			case DoneEvent, FailedEvent:
 				notification = false
-			}
-			// broadcast
-			for c := range syncSubscriptions {
-				c <- notification
+				if ticker != nil {
+					ticker.Stop()
+					ticker, tick = nil, nil
+				}
 			}
 		}
+		// broadcast
+		for c := range syncSubscriptions {
+			c <- notification
+		}
 	}
 }
 
@@ -121,8 +150,41 @@ func (api *PublicDownloaderAPI) Syncing(ctx context.Context) (*rpc.Subscription,
 
 // SyncingResult provides information about the current synchronisation status for this node.
 type SyncingResult struct {
-	Syncing bool                  `json:"syncing"`
-	Status  ethereum.SyncProgress `json:"status"`
+	Syncing bool                   `json:"syncing"`
+	Status  map[string]interface{} `json:"status"`
+}
+
+// RPCMarshalProgress converts the given sync progress to the RPC output, which
+// is shared between eth_syncing and the syncing subscription.
+func RPCMarshalProgress(progress ethereum.SyncProgress) map[string]interface{} {
+	status := map[string]interface{}{
+		"startingBlock": hexutil.Uint64(progress.StartingBlock),
+		"currentBlock":  hexutil.Uint64(progress.CurrentBlock),
+		"highestBlock":  hexutil.Uint64(progress.HighestBlock),
+		"pulledStates":  hexutil.Uint64(progress.PulledStates),
+		"knownStates":   hexutil.Uint64(progress.KnownStates),
+		"pendingStates": hexutil.Uint64(progress.PendingStates),
+		"headers":       rpcMarshalPhase(progress.Headers),
+		"bodies":        rpcMarshalPhase(progress.Bodies),
+		"receipts":      rpcMarshalPhase(progress.Receipts),
+		"states":        rpcMarshalPhase(progress.States),
+	}
+	if progress.BackfillTarget != 0 {
+		status["backfillBlock"] = hexutil.Uint64(progress.BackfillBlock)
+		status["backfillTarget"] = hexutil.Uint64(progress.BackfillTarget)
+	}
+	return status
+}
+
+// rpcMarshalPhase converts the progress of a sync phase to the RPC output, with
+// the rate in items per second and the ETA in seconds.
+func rpcMarshalPhase(phase ethereum.SyncPhaseProgress) map[string]interface{} {
+	return map[string]interface{}{
+		"current": hexutil.Uint64(phase.Current),
+		"highest": hexutil.Uint64(phase.Highest),
+		"rate":    hexutil.Uint64(phase.Rate + 0.5),
+		"eta":     hexutil.Uint64(phase.ETA / time.Second),
+	}
 }
 
 // uninstallSyncSubscriptionRequest uninstalles a syncing subscription in the API event loop.
@@ -0,0 +1,351 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+var (
+	backfillRetry = time.Second // Time to wait for an idle peer or a finished sync cycle before retrying
+
+	errCancelBackfill = errors.New("history backfill canceled (requested)")
+)
+
+// backfillRequest is an in-flight backfill request. Only the deliveries matching
+// it are routed to the backfill, anything else the peer sends is delivered to the
+// sync cycles as usual.
+type backfillRequest struct {
+	peer   string        // Identifier of the peer serving the request
+	ch     chan dataPack // Channel receiving the response
+	origin common.Hash   // Hash of the first requested header (header requests only)
+	count  int           // Number of requested items
+}
+
+// markBackfill records the blocks between the local chain and the trusted
+// checkpoint as lacking bodies and receipts, to be retrieved in the background
+// once the recent chain is synced.
+func (d *Downloader) markBackfill() {
+	if _, _, ok := rawdb.ReadBackfillRange(d.stateDB); ok {
+		return
+	}
+	target := d.blockchain.CurrentFastBlock().NumberU64()
+	if head := d.blockchain.CurrentBlock().NumberU64(); head > target {
+		target = head
+	}
+	rawdb.WriteBackfillRange(d.stateDB, target+1, d.checkpointHash)
+}
+
+// startBackfill launches the background retrieval of the history below the
+// trusted checkpoint, if there is any missing and no backfill is already running.
+func (d *Downloader) startBackfill() {
+	if d.blockchain == nil {
+		return
+	}
+	target, _, ok := rawdb.ReadBackfillRange(d.stateDB)
+	if !ok {
+		return
+	}
+	if !atomic.CompareAndSwapInt32(&d.backfilling, 0, 1) {
+		return
+	}
+	atomic.StoreUint64(&d.backfillTarget, target)
+	atomic.StoreUint64(&d.backfillCurrent, d.checkpointNumber)
+
+	go d.backfill()
+}
+
+// backfill retrieves the history below the trusted checkpoint: first the headers
+// linking the checkpoint to the local chain, then the block bodies and receipts.
+// The requests are only issued in between sync cycles to idle peers, so
+// backfilling never competes with keeping the recent chain in sync.
+func (d *Downloader) backfill() {
+	defer atomic.StoreInt32(&d.backfilling, 0)
+
+	if !d.backfillHeaders() {
+		return
+	}
+	target, hash, _ := rawdb.ReadBackfillRange(d.stateDB)
+	header := d.lightchain.GetHeaderByHash(hash)
+	if header == nil {
+		log.Error("Missing backfill head header", "hash", hash)
+		return
+	}
+	d.backfillBlocks(target, header)
+}
+
+// backfillHeaders retrieves the headers backwards from the lowest one imported
+// below the trusted checkpoint until they link up with the local chain. It
+// reports whether the headers are complete.
+func (d *Downloader) backfillHeaders() bool {
+	tail := rawdb.ReadCheckpointTail(d.stateDB)
+	if tail == (common.Hash{}) {
+		return true
+	}
+	header := d.lightchain.GetHeaderByHash(tail)
+	if header == nil {
+		log.Error("Missing checkpoint tail header", "hash", tail)
+		return false
+	}
+	log.Info("Backfilling checkpoint headers", "from", header.Number)
+	for {
+		atomic.StoreUint64(&d.backfillCurrent, header.Number.Uint64()-1)
+
+		// Retrieve the next batch from an idle peer, retrying later if none is available
+		p := d.backfillPeer(true)
+		if p == nil {
+			select {
+			case <-time.After(backfillRetry):
+				continue
+			case <-d.quitCh:
+				return false
+			}
+		}
+		batch, err := d.fetchCheckpointHeaders(p, header.ParentHash, header.Number.Uint64()-1)
+		if err == errCancelBackfill {
+			return false
+		}
+		if err != nil {
+			p.log.Debug("Failed to backfill checkpoint headers", "number", header.Number.Uint64()-1, "err", err)
+			select {
+			case <-time.After(backfillRetry):
+				continue
+			case <-d.quitCh:
+				return false
+			}
+		}
+		linked, err := d.blockchain.InsertCheckpointChain(batch)
+		if err != nil {
+			log.Error("Failed to import checkpoint headers", "number", batch[0].Number, "err", err)
+			return false
+		}
+		if linked {
+			rawdb.DeleteCheckpointTail(d.stateDB)
+			return true
+		}
+		header = batch[len(batch)-1]
+		rawdb.WriteCheckpointTail(d.stateDB, header.Hash())
+	}
+}
+
+// backfillBlocks retrieves the block bodies and receipts from the given header
+// down to the target block, inserting them in batches via the receipt chain
+// import.
+func (d *Downloader) backfillBlocks(target uint64, header *types.Header) {
+	atomic.StoreUint64(&d.backfillCurrent, header.Number.Uint64())
+
+	log.Info("Backfilling chain history", "from", header.Number, "to", target)
+	for header != nil && header.Number.Uint64() >= target {
+		// Gather the next batch of headers still lacking their data
+		var headers []*types.Header
+		for h := header; h != nil && h.Number.Uint64() >= target && len(headers) < MaxBlockFetch; h = d.lightchain.GetHeaderByHash(h.ParentHash) {
+			if d.blockchain.HasBlock(h.Hash(), h.Number.Uint64()) {
+				break
+			}
+			headers = append(headers, h)
+		}
+		if len(headers) == 0 {
+			break
+		}
+		// Retrieve the batch from an idle peer, retrying later if none is available
+		p := d.backfillPeer(false)
+		if p == nil {
+			select {
+			case <-time.After(backfillRetry):
+				continue
+			case <-d.quitCh:
+				return
+			}
+		}
+		blocks, receipts, err := d.fetchBackfill(p, headers)
+		if err == errCancelBackfill {
+			return
+		}
+		if err != nil {
+			p.log.Debug("Failed to backfill chain history", "number", header.Number, "err", err)
+			select {
+			case <-time.After(backfillRetry):
+				continue
+			case <-d.quitCh:
+				return
+			}
+		}
+		// Import the retrieved data in ascending order and move the marker down
+		for i, j := 0, len(blocks)-1; i < j; i, j = i+1, j-1 {
+			blocks[i], blocks[j] = blocks[j], blocks[i]
+			receipts[i], receipts[j] = receipts[j], receipts[i]
+		}
+		if index, err := d.blockchain.InsertReceiptChain(blocks, receipts); err != nil {
+			log.Error("Failed to import backfilled history", "number", blocks[index].Number(), "hash", blocks[index].Hash(), "err", err)
+			return
+		}
+		lowest := blocks[0].Header()
+		if lowest.Number.Uint64() <= target {
+			break
+		}
+		rawdb.WriteBackfillRange(d.stateDB, target, lowest.ParentHash)
+		atomic.StoreUint64(&d.backfillCurrent, lowest.Number.Uint64()-1)
+
+		header = d.lightchain.GetHeaderByHash(lowest.ParentHash)
+	}
+	rawdb.DeleteBackfillRange(d.stateDB)
+	atomic.StoreUint64(&d.backfillTarget, 0)
+	atomic.StoreUint64(&d.backfillCurrent, 0)
+
+	log.Info("Backfilled chain history", "to", target)
+}
+
+// backfillPeer selects the best peer idle for header retrieval, or for both body
+// and receipt retrieval, or nil if a sync cycle is running or all peers are busy.
+func (d *Downloader) backfillPeer(headers bool) *peerConnection {
+	if d.Synchronising() {
+		return nil
+	}
+	if headers {
+		if peers, _ := d.peers.HeaderIdlePeers(); len(peers) > 0 {
+			return peers[0]
+		}
+		return nil
+	}
+	peers, _ := d.peers.ReceiptIdlePeers()
+	for _, p := range peers {
+		if atomic.LoadInt32(&p.blockIdle) == 0 {
+			return p
+		}
+	}
+	return nil
+}
+
+// fetchBackfill retrieves the bodies and receipts of a batch of descending
+// headers from the given peer. Partial responses are accepted, in which case
+// the leading blocks for which both data items were delivered are returned.
+func (d *Downloader) fetchBackfill(p *peerConnection, headers []*types.Header) (types.Blocks, []types.Receipts, error) {
+	defer d.releaseBackfill()
+
+	// Retrieve the block bodies, validating them against the headers
+	d.claimBackfill(&backfillRequest{peer: p.id, ch: d.backfillBodyCh, count: len(headers)})
+	request := &fetchRequest{Peer: p, Headers: headers, Time: time.Now()}
+	if err := p.FetchBodies(request); err != nil {
+		return nil, nil, err
+	}
+	packet, err := d.waitBackfill(d.backfillBodyCh)
+	if err != nil {
+		p.SetBodiesIdle(0)
+		return nil, nil, err
+	}
+	bodies := packet.(*bodyPack)
+
+	var blocks types.Blocks
+	for i := 0; i < len(bodies.transactions) && i < len(headers); i++ {
+		if types.DeriveSha(types.Transactions(bodies.transactions[i])) != headers[i].TxHash || types.CalcUncleHash(bodies.uncles[i]) != headers[i].UncleHash {
+			break
+		}
+		blocks = append(blocks, types.NewBlockWithHeader(headers[i]).WithBody(bodies.transactions[i], bodies.uncles[i]))
+	}
+	p.SetBodiesIdle(len(blocks))
+	if len(blocks) == 0 {
+		return nil, nil, errInvalidBody
+	}
+	// Retrieve the receipts of the delivered blocks, validating them as well
+	d.claimBackfill(&backfillRequest{peer: p.id, ch: d.backfillReceiptCh, count: len(blocks)})
+	request = &fetchRequest{Peer: p, Headers: headers[:len(blocks)], Time: time.Now()}
+	if err := p.FetchReceipts(request); err != nil {
+		return nil, nil, err
+	}
+	if packet, err = d.waitBackfill(d.backfillReceiptCh); err != nil {
+		p.SetReceiptsIdle(0)
+		return nil, nil, err
+	}
+	delivered := packet.(*receiptPack).receipts
+
+	var receipts []types.Receipts
+	for i := 0; i < len(delivered) && i < len(blocks); i++ {
+		if types.DeriveSha(types.Receipts(delivered[i])) != headers[i].ReceiptHash {
+			break
+		}
+		receipts = append(receipts, delivered[i])
+	}
+	p.SetReceiptsIdle(len(receipts))
+	if len(receipts) == 0 {
+		return nil, nil, errInvalidReceipt
+	}
+	return blocks[:len(receipts)], receipts, nil
+}
+
+// claimBackfill routes the response to the given request to the backfill,
+// dropping any stale response of a previous request.
+func (d *Downloader) claimBackfill(req *backfillRequest) {
+	select {
+	case <-req.ch:
+	default:
+	}
+	d.backfillLock.Lock()
+	d.backfillReq = req
+	d.backfillLock.Unlock()
+}
+
+// releaseBackfill stops routing deliveries to the backfill.
+func (d *Downloader) releaseBackfill() {
+	d.backfillLock.Lock()
+	d.backfillReq = nil
+	d.backfillLock.Unlock()
+}
+
+// waitBackfill waits for the response to an in-flight backfill request.
+func (d *Downloader) waitBackfill(ch chan dataPack) (dataPack, error) {
+	timeout := time.NewTimer(d.requestTTL())
+	defer timeout.Stop()
+
+	select {
+	case packet := <-ch:
+		return packet, nil
+	case <-timeout.C:
+		return nil, errTimeout
+	case <-d.quitCh:
+		return nil, errCancelBackfill
+	}
+}
+
+// deliverBackfill routes a delivery to the backfill if it matches the in-flight
+// backfill request, reporting whether the packet was consumed. Header responses
+// must start at the requested origin, as the sync cycles may request headers from
+// the same peer without reserving it.
+func (d *Downloader) deliverBackfill(id string, ch chan dataPack, packet dataPack) bool {
+	d.backfillLock.Lock()
+	defer d.backfillLock.Unlock()
+
+	req := d.backfillReq
+	if req == nil || req.peer != id || req.ch != ch || packet.Items() > req.count {
+		return false
+	}
+	if headers, ok := packet.(*headerPack); ok {
+		if len(headers.headers) == 0 || headers.headers[0].Hash() != req.origin {
+			return false
+		}
+	}
+	// The request is answered, further deliveries belong to the sync cycles
+	d.backfillReq = nil
+	ch <- packet
+	return true
+}
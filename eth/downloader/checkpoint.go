@@ -0,0 +1,156 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// SetCheckpoint configures a trusted checkpoint the downloader may start syncing
+// from instead of the genesis block. Fast syncing nodes below it anchor the chain
+// at the head of its section and sync forward from there, while the headers below
+// the anchor, authenticated by their hash links alone, are retrieved backwards in
+// the background together with the rest of the missing history.
+//
+// The method must be called before any synchronisation is started.
+func (d *Downloader) SetCheckpoint(cp *params.TrustedCheckpoint) {
+	if cp == nil || cp.Empty() {
+		return
+	}
+	d.checkpointNumber = (cp.SectionIndex+1)*params.CHTFrequencyClient - 1
+	d.checkpointHash = cp.SectionHead
+
+	log.Info("Configured trusted sync checkpoint", "section", cp.SectionIndex, "number", d.checkpointNumber, "hash", d.checkpointHash)
+}
+
+// checkpointUsable reports whether a sync cycle towards the given remote height
+// should skip the history below the trusted checkpoint. The state of the blocks
+// below it is unavailable, so only fast sync may start from the checkpoint, and
+// only if the pivot ends up above it.
+func (d *Downloader) checkpointUsable(height uint64) bool {
+	if d.checkpointNumber == 0 || d.mode != FastSync || height <= d.checkpointNumber+uint64(fsMinFullBlocks) {
+		return false
+	}
+	return d.blockchain.CurrentFastBlock().NumberU64() < d.checkpointNumber
+}
+
+// syncCheckpoint anchors the local chain at the trusted checkpoint, verifying
+// that the given peer is on the same chain as the checkpoint, otherwise it would
+// be of no use syncing from it. The headers below the anchor are scheduled to be
+// backfilled once the sync cycle is done.
+func (d *Downloader) syncCheckpoint(p *peerConnection) error {
+	anchor, err := d.fetchHeaderByNumber(p, d.checkpointNumber)
+	if err != nil {
+		return err
+	}
+	if anchor.Hash() != d.checkpointHash {
+		p.log.Warn("Remote chain doesn't contain the checkpoint", "number", d.checkpointNumber, "have", anchor.Hash(), "want", d.checkpointHash)
+		return errInvalidCheckpoint
+	}
+	// Nothing else to do if a previous cycle already anchored the chain
+	if d.lightchain.GetTd(d.checkpointHash, d.checkpointNumber) != nil {
+		return nil
+	}
+	d.markBackfill()
+	if err := d.blockchain.InsertCheckpointHeader(anchor); err != nil {
+		return err
+	}
+	rawdb.WriteCheckpointTail(d.stateDB, d.checkpointHash)
+	return nil
+}
+
+// fetchHeaderByNumber retrieves a single canonical header from a remote peer.
+func (d *Downloader) fetchHeaderByNumber(p *peerConnection, number uint64) (*types.Header, error) {
+	go p.peer.RequestHeadersByNumber(number, 1, 0, false)
+
+	headers, err := d.waitHeaders(p)
+	if err != nil {
+		return nil, err
+	}
+	if len(headers) != 1 || headers[0].Number.Uint64() != number {
+		p.log.Debug("Invalid headers for single request", "headers", len(headers))
+		return nil, errBadPeer
+	}
+	return headers[0], nil
+}
+
+// fetchCheckpointHeaders retrieves a batch of headers backwards starting at the
+// given hash from a backfill peer, ensuring they form a chain linked by parent
+// hashes.
+func (d *Downloader) fetchCheckpointHeaders(p *peerConnection, hash common.Hash, number uint64) ([]*types.Header, error) {
+	d.claimBackfill(&backfillRequest{peer: p.id, ch: d.backfillHeaderCh, origin: hash, count: MaxHeaderFetch})
+	defer d.releaseBackfill()
+
+	if err := p.FetchHeadersByHash(hash, MaxHeaderFetch); err != nil {
+		return nil, err
+	}
+	packet, err := d.waitBackfill(d.backfillHeaderCh)
+	if err != nil {
+		p.SetHeadersIdle(0)
+		return nil, err
+	}
+	headers := packet.(*headerPack).headers
+	if headers[0].Number.Uint64() != number {
+		p.log.Debug("Unrequested checkpoint header delivered", "number", headers[0].Number, "hash", headers[0].Hash())
+		p.SetHeadersIdle(0)
+		return nil, errInvalidChain
+	}
+	for i := 1; i < len(headers); i++ {
+		if headers[i].Number.Uint64() != number-uint64(i) || headers[i].Hash() != headers[i-1].ParentHash {
+			p.log.Debug("Unlinked checkpoint header delivered", "number", headers[i].Number, "hash", headers[i].Hash())
+			headers = headers[:i]
+			break
+		}
+	}
+	p.SetHeadersIdle(len(headers))
+	return headers, nil
+}
+
+// waitHeaders waits for a header response from the given peer, discarding any
+// other deliveries in the meantime.
+func (d *Downloader) waitHeaders(p *peerConnection) ([]*types.Header, error) {
+	ttl := d.requestTTL()
+	timeout := time.After(ttl)
+	for {
+		select {
+		case <-d.cancelCh:
+			return nil, errCancelHeaderFetch
+
+		case packet := <-d.headerCh:
+			// Discard anything not from the origin peer
+			if packet.PeerId() != p.id {
+				log.Debug("Received headers from incorrect peer", "peer", packet.PeerId())
+				break
+			}
+			return packet.(*headerPack).headers, nil
+
+		case <-timeout:
+			p.log.Debug("Waiting for checkpoint headers timed out", "elapsed", ttl)
+			return nil, errTimeout
+
+		case <-d.bodyCh:
+		case <-d.receiptCh:
+			// Out of bounds delivery, ignore
+		}
+	}
+}
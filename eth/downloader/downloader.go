@@ -80,6 +80,7 @@ var (
 	errEmptyHeaderSet          = errors.New("empty header set by peer")
 	errPeersUnavailable        = errors.New("no peers available or all tried for download")
 	errInvalidAncestor         = errors.New("retrieved ancestor is invalid")
+	errInvalidCheckpoint       = errors.New("remote chain doesn't contain the trusted checkpoint")
 	errInvalidChain            = errors.New("retrieved hash chain is invalid")
 	errInvalidBlock            = errors.New("retrieved block is invalid")
 	errInvalidBody             = errors.New("retrieved block body is invalid")
@@ -99,6 +100,9 @@ type Downloader struct {
 	mode SyncMode       // Synchronisation mode defining the strategy used (per sync cycle)
 	mux  *event.TypeMux // Event multiplexer to announce sync operation events
 
+	checkpointNumber uint64      // Block number of the trusted checkpoint to sync from (0 = disabled)
+	checkpointHash   common.Hash // Block hash of the trusted checkpoint to sync from
+
 	genesis uint64   // Genesis block number to limit sync to (e.g. light client CHT)
 	queue   *queue   // Scheduler for selecting the hashes to download
 	peers   *peerSet // Set of active peers from which download can proceed
@@ -111,7 +115,9 @@ type Downloader struct {
 	syncStatsChainOrigin uint64 // Origin block number where syncing started at
 	syncStatsChainHeight uint64 // Highest block number known when syncing started
 	syncStatsState       stateSyncStats
-	syncStatsLock        sync.RWMutex // Lock protecting the sync stats fields
+	syncStatsStateReqs   uint64         // Number of state entries currently requested from peers (atomic access)
+	syncStatsRates       progressMeters // Rate meters of the individual sync phases
+	syncStatsLock        sync.RWMutex   // Lock protecting the sync stats fields
 
 	lightchain LightChain
 	blockchain BlockChain
@@ -138,6 +144,16 @@ type Downloader struct {
 	trackStateReq  chan *stateReq
 	stateCh        chan dataPack // [eth/63] Channel receiving inbound node state data
 
+	// Background history backfill below the trusted checkpoint
+	backfillReq       *backfillRequest // In-flight backfill request, if any
+	backfillLock      sync.Mutex       // Lock protecting the in-flight backfill request
+	backfillHeaderCh  chan dataPack    // Channel receiving the headers of backfill requests
+	backfillBodyCh    chan dataPack    // Channel receiving the block bodies of backfill requests
+	backfillReceiptCh chan dataPack    // Channel receiving the receipts of backfill requests
+	backfillTarget    uint64           // Lowest block the backfill retrieves (atomic access)
+	backfillCurrent   uint64           // Highest block still lacking bodies and receipts (atomic access)
+	backfilling       int32            // Flag whether a backfill is running (atomic access)
+
 	// Cancellation and termination
 	cancelPeer string         // Identifier of the peer currently being used as the master (cancel on drop)
 	cancelCh   chan struct{}  // Channel to cancel mid-flight syncs
@@ -202,6 +218,14 @@ type BlockChain interface {
 
 	// InsertReceiptChain inserts a batch of receipts into the local chain.
 	InsertReceiptChain(types.Blocks, []types.Receipts) (int, error)
+
+	// InsertCheckpointHeader inserts the header of a trusted checkpoint to sync
+	// the chain above it from.
+	InsertCheckpointHeader(*types.Header) error
+
+	// InsertCheckpointChain inserts a batch of headers retrieved backwards from
+	// a trusted checkpoint, reporting whether they linked up with the local chain.
+	InsertCheckpointChain([]*types.Header) (bool, error)
 }
 
 // New creates a new downloader to fetch hashes and blocks from remote peers.
@@ -233,10 +257,16 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 		syncStatsState: stateSyncStats{
 			processed: rawdb.ReadFastTrieProgress(stateDb),
 		},
-		trackStateReq: make(chan *stateReq),
+		trackStateReq:     make(chan *stateReq),
+		backfillHeaderCh:  make(chan dataPack, 1),
+		backfillBodyCh:    make(chan dataPack, 1),
+		backfillReceiptCh: make(chan dataPack, 1),
 	}
 	go dl.qosTuner()
 	go dl.stateFetcher()
+
+	// Resume any history backfill interrupted by a restart
+	dl.startBackfill()
 	return dl
 }
 
@@ -246,7 +276,11 @@ func New(mode SyncMode, stateDb ethdb.Database, mux *event.TypeMux, chain BlockC
 //
 // In addition, during the state download phase of fast synchronisation the number
 // of processed and the total number of known states are also returned. Otherwise
-// these are zero.
+// these are zero. The same holds for the history backfill below a trusted checkpoint,
+// reported as the oldest block retrieved so far and the one the backfill targets.
+//
+// The progress of the individual sync phases is also broken down, along with
+// the rates they advance at and the estimated time to complete them.
 func (d *Downloader) Progress() ethereum.SyncProgress {
 	// Lock the current stats and return the progress
 	d.syncStatsLock.RLock()
@@ -261,13 +295,31 @@ func (d *Downloader) Progress() ethereum.SyncProgress {
 	case LightSync:
 		current = d.lightchain.CurrentHeader().Number.Uint64()
 	}
-	return ethereum.SyncProgress{
+	progress := ethereum.SyncProgress{
 		StartingBlock: d.syncStatsChainOrigin,
 		CurrentBlock:  current,
 		HighestBlock:  d.syncStatsChainHeight,
 		PulledStates:  d.syncStatsState.processed,
 		KnownStates:   d.syncStatsState.processed + d.syncStatsState.pending,
+		PendingStates: atomic.LoadUint64(&d.syncStatsStateReqs),
+	}
+	progress.Headers.Current = d.lightchain.CurrentHeader().Number.Uint64()
+	progress.Headers.Highest = d.syncStatsChainHeight
+	if d.mode != LightSync {
+		done, pending := d.queue.BlockProgress()
+		progress.Bodies.Current, progress.Bodies.Highest = done, done+pending
+
+		done, pending = d.queue.ReceiptProgress()
+		progress.Receipts.Current, progress.Receipts.Highest = done, done+pending
+	}
+	progress.States.Current, progress.States.Highest = progress.PulledStates, progress.KnownStates
+	d.syncStatsRates.measure(&progress, time.Now())
+
+	if target := atomic.LoadUint64(&d.backfillTarget); target != 0 {
+		progress.BackfillBlock = atomic.LoadUint64(&d.backfillCurrent) + 1
+		progress.BackfillTarget = target
 	}
+	return progress
 }
 
 // Synchronising returns whether the downloader is currently retrieving blocks.
@@ -328,8 +380,16 @@ func (d *Downloader) Synchronise(id string, head common.Hash, td *big.Int, mode
 
 	case errTimeout, errBadPeer, errStallingPeer,
 		errEmptyHeaderSet, errPeersUnavailable, errTooOld,
-		errInvalidAncestor, errInvalidChain:
+		errInvalidAncestor, errInvalidChain, errInvalidCheckpoint:
 		log.Warn("Synchronisation failed, dropping peer", "peer", id, "err", err)
+		if p := d.peers.Peer(id); p != nil {
+			switch err {
+			case errTimeout:
+				p.MarkTimeout()
+			case errBadPeer, errStallingPeer, errInvalidAncestor, errInvalidChain, errInvalidCheckpoint:
+				p.MarkInvalid()
+			}
+		}
 		if d.dropPeer == nil {
 			// The dropPeer method is nil when `--copydb` is used for a local copy.
 			// Timeouts can occur if e.g. compaction hits at the wrong time, and can be ignored
@@ -403,7 +463,12 @@ func (d *Downloader) synchronise(id string, hash common.Hash, td *big.Int, mode
 	if p == nil {
 		return errUnknownPeer
 	}
-	return d.syncWithPeer(p, hash, td)
+	if err := d.syncWithPeer(p, hash, td); err != nil {
+		return err
+	}
+	// Retrieve any history missing below the checkpoint now the recent chain is in
+	d.startBackfill()
+	return nil
 }
 
 // syncWithPeer starts a block synchronization based on the hash chain from the
@@ -435,10 +500,18 @@ func (d *Downloader) syncWithPeer(p *peerConnection, hash common.Hash, td *big.I
 	}
 	height := latest.Number.Uint64()
 
+	// If the local chain is below the trusted checkpoint, skip the history before it
+	checkpoint := d.checkpointUsable(height)
 	origin, err := d.findAncestor(p, latest)
 	if err != nil {
 		return err
 	}
+	if checkpoint && origin < d.checkpointNumber {
+		if err := d.syncCheckpoint(p); err != nil {
+			return err
+		}
+		origin = d.checkpointNumber
+	}
 	d.syncStatsLock.Lock()
 	if d.syncStatsChainHeight <= origin || d.syncStatsChainOrigin > origin {
 		d.syncStatsChainOrigin = origin
@@ -998,6 +1071,7 @@ func (d *Downloader) fetchHeaders(p *peerConnection, from uint64, pivot uint64)
 			// Header retrieval timed out, consider the peer bad and drop
 			p.log.Debug("Header request timed out", "elapsed", ttl)
 			headerTimeoutMeter.Mark(1)
+			p.MarkTimeout()
 			d.dropPeer(p.id)
 
 			// Finish the sync gracefully instead of dumping the gathered data though
@@ -1151,6 +1225,7 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 				// Deliver the received chunk of data and check chain validity
 				accepted, err := deliver(packet)
 				if err == errInvalidChain {
+					peer.MarkInvalid()
 					return err
 				}
 				// Unless a peer delivered something completely else than requested (usually
@@ -1201,6 +1276,8 @@ func (d *Downloader) fetchParts(errCancel error, deliveryCh chan dataPack, deliv
 			// Check for fetch request timeouts and demote the responsible peers
 			for pid, fails := range expire() {
 				if peer := d.peers.Peer(pid); peer != nil {
+					peer.MarkTimeout()
+
 					// If a lot of retrieval elements expired, we might have overestimated the remote peer or perhaps
 					// ourselves. Only reset to minimal throughput but don't drop just yet. If even the minimal times
 					// out that sync wise we need to get rid of the peer.
@@ -1347,7 +1424,11 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 				// L: Sync begins, and finds common ancestor at 11
 				// L: Request new headers up from 11 (R's TD was higher, it must have something)
 				// R: Nothing to give
-				if d.mode != LightSync {
+				//
+				// Neither check is possible while the chain is anchored at a trusted checkpoint
+				// of unknown total difficulty, the local one is only a lower bound until then.
+				provisional := rawdb.ReadCheckpointTail(d.stateDB) != (common.Hash{})
+				if d.mode != LightSync && !provisional {
 					head := d.blockchain.CurrentBlock()
 					if !gotHeaders && td.Cmp(d.blockchain.GetTd(head.Hash(), head.NumberU64())) > 0 {
 						return errStallingPeer
@@ -1360,7 +1441,7 @@ func (d *Downloader) processHeaders(origin uint64, pivot uint64, td *big.Int) er
 				// This check cannot be executed "as is" for full imports, since blocks may still be
 				// queued for processing when the header download completes. However, as long as the
 				// peer gave us something useful, we're already happy/progressed (above check).
-				if d.mode == FastSync || d.mode == LightSync {
+				if (d.mode == FastSync && !provisional) || d.mode == LightSync {
 					head := d.lightchain.CurrentHeader()
 					if td.Cmp(d.lightchain.GetTd(head.Hash(), head.Number.Uint64())) > 0 {
 						return errStallingPeer
@@ -1662,16 +1743,25 @@ func (d *Downloader) commitPivotBlock(result *fetchResult) error {
 // DeliverHeaders injects a new batch of block headers received from a remote
 // node into the download schedule.
 func (d *Downloader) DeliverHeaders(id string, headers []*types.Header) (err error) {
+	if d.deliverBackfill(id, d.backfillHeaderCh, &headerPack{id, headers}) {
+		return nil
+	}
 	return d.deliver(id, d.headerCh, &headerPack{id, headers}, headerInMeter, headerDropMeter)
 }
 
 // DeliverBodies injects a new batch of block bodies received from a remote node.
 func (d *Downloader) DeliverBodies(id string, transactions [][]*types.Transaction, uncles [][]*types.Header) (err error) {
+	if d.deliverBackfill(id, d.backfillBodyCh, &bodyPack{id, transactions, uncles}) {
+		return nil
+	}
 	return d.deliver(id, d.bodyCh, &bodyPack{id, transactions, uncles}, bodyInMeter, bodyDropMeter)
 }
 
 // DeliverReceipts injects a new batch of receipts received from a remote node.
 func (d *Downloader) DeliverReceipts(id string, receipts [][]*types.Receipt) (err error) {
+	if d.deliverBackfill(id, d.backfillReceiptCh, &receiptPack{id, receipts}) {
+		return nil
+	}
 	return d.deliver(id, d.receiptCh, &receiptPack{id, receipts}, receiptInMeter, receiptDropMeter)
 }
 
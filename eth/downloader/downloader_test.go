@@ -17,6 +17,7 @@
 package downloader
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
@@ -32,6 +33,8 @@ import (
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/trie"
 )
 
@@ -57,6 +60,8 @@ type downloadTester struct {
 	ownReceipts map[common.Hash]types.Receipts // Receipts belonging to the tester
 	ownChainTd  map[common.Hash]*big.Int       // Total difficulties of the blocks in the local chain
 
+	checkpointHeaders []*types.Header // Headers imported backwards from a checkpoint, not yet linked
+
 	lock sync.RWMutex
 }
 
@@ -263,7 +268,10 @@ func (dl *downloadTester) InsertReceiptChain(blocks types.Blocks, receipts []typ
 			return i, errors.New("unknown owner")
 		}
 		if _, ok := dl.ownBlocks[blocks[i].ParentHash()]; !ok {
-			return i, errors.New("unknown parent")
+			// History up to a trusted checkpoint is backfilled downwards, lacking parent bodies
+			if cp := dl.downloader.checkpointNumber; cp == 0 || blocks[i].NumberU64() > cp+1 {
+				return i, errors.New("unknown parent")
+			}
 		}
 		dl.ownBlocks[blocks[i].Hash()] = blocks[i]
 		dl.ownReceipts[blocks[i].Hash()] = receipts[i]
@@ -271,6 +279,79 @@ func (dl *downloadTester) InsertReceiptChain(blocks types.Blocks, receipts []typ
 	return len(blocks), nil
 }
 
+// InsertCheckpointHeader injects the header of a trusted checkpoint into the
+// simulated chain, with a provisional total difficulty.
+func (dl *downloadTester) InsertCheckpointHeader(header *types.Header) error {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	if _, ok := dl.ownChainTd[header.Hash()]; ok {
+		return nil
+	}
+	head := dl.ownHashes[len(dl.ownHashes)-1]
+	dl.ownHashes = append(dl.ownHashes, header.Hash())
+	dl.ownHeaders[header.Hash()] = header
+	dl.ownChainTd[header.Hash()] = new(big.Int).Add(dl.ownChainTd[head], header.Difficulty)
+	return nil
+}
+
+// InsertCheckpointChain injects a batch of headers retrieved backwards from a
+// trusted checkpoint into the simulated chain.
+func (dl *downloadTester) InsertCheckpointChain(headers []*types.Header) (bool, error) {
+	dl.lock.Lock()
+	defer dl.lock.Unlock()
+
+	for i := 1; i < len(headers); i++ {
+		if headers[i].Hash() != headers[i-1].ParentHash {
+			return false, errors.New("unlinked checkpoint headers")
+		}
+	}
+	// Headers known with a total difficulty link the span to the local chain
+	link := headers[len(headers)-1].ParentHash
+	for i, header := range headers {
+		if _, ok := dl.ownChainTd[header.Hash()]; ok {
+			link, headers = header.Hash(), headers[:i]
+			break
+		}
+	}
+	for _, header := range headers {
+		dl.ownHeaders[header.Hash()] = header
+	}
+	dl.checkpointHeaders = append(dl.checkpointHeaders, headers...)
+
+	// If the span reached the local chain, fill in the total difficulties
+	td, ok := dl.ownChainTd[link]
+	if !ok {
+		return false, nil
+	}
+	var (
+		top  = dl.checkpointHeaders[0].Hash()
+		span []common.Hash
+	)
+	for i := len(dl.checkpointHeaders) - 1; i >= 0; i-- {
+		header := dl.checkpointHeaders[i]
+
+		td = new(big.Int).Add(td, header.Difficulty)
+		dl.ownChainTd[header.Hash()] = td
+		span = append(span, header.Hash())
+	}
+	dl.checkpointHeaders = nil
+
+	// Splice the span below the checkpoint and correct the chain above it
+	for i, anchor := range dl.ownHashes {
+		if dl.ownHeaders[anchor].ParentHash != top {
+			continue
+		}
+		delta := new(big.Int).Sub(new(big.Int).Add(td, dl.ownHeaders[anchor].Difficulty), dl.ownChainTd[anchor])
+		for _, hash := range dl.ownHashes[i:] {
+			dl.ownChainTd[hash] = new(big.Int).Add(dl.ownChainTd[hash], delta)
+		}
+		dl.ownHashes = append(dl.ownHashes[:i], append(span, dl.ownHashes[i:]...)...)
+		break
+	}
+	return true, nil
+}
+
 // Rollback removes some recently added elements from the chain.
 func (dl *downloadTester) Rollback(hashes []common.Hash) {
 	dl.lock.Lock()
@@ -326,9 +407,11 @@ func (dlp *downloadTesterPeer) Head() (common.Hash, *big.Int) {
 // function can be used to retrieve batches of headers from the particular peer.
 func (dlp *downloadTesterPeer) RequestHeadersByHash(origin common.Hash, amount int, skip int, reverse bool) error {
 	if reverse {
-		panic("reverse header requests not supported")
+		num, _ := dlp.chain.hashToNumber(origin)
+		result := dlp.chain.headersByNumberReverse(num, amount, skip)
+		go dlp.dl.downloader.DeliverHeaders(dlp.id, result)
+		return nil
 	}
-
 	result := dlp.chain.headersByHash(origin, amount, skip)
 	go dlp.dl.downloader.DeliverHeaders(dlp.id, result)
 	return nil
@@ -1168,6 +1251,13 @@ func checkProgress(t *testing.T, d *Downloader, stage string, want ethereum.Sync
 	p := d.Progress()
 	p.KnownStates, p.PulledStates = 0, 0
 	want.KnownStates, want.PulledStates = 0, 0
+
+	// The phase breakdown depends on timing, check only the block boundaries
+	for _, progress := range []*ethereum.SyncProgress{&p, &want} {
+		progress.Headers, progress.Bodies = ethereum.SyncPhaseProgress{}, ethereum.SyncPhaseProgress{}
+		progress.Receipts, progress.States = ethereum.SyncPhaseProgress{}, ethereum.SyncPhaseProgress{}
+		progress.PendingStates = 0
+	}
 	if p != want {
 		t.Fatalf("%s progress mismatch:\nhave %+v\nwant %+v", stage, p, want)
 	}
@@ -1569,3 +1659,237 @@ func TestRemoteHeaderRequestSpan(t *testing.T) {
 		}
 	}
 }
+
+// Tests that a fast syncing node below a trusted checkpoint syncs forward from
+// it, retrieving the headers and blocks below the checkpoint in the background.
+func TestCheckpointSync63Fast(t *testing.T) { testCheckpointSync(t, 63) }
+func TestCheckpointSync64Fast(t *testing.T) { testCheckpointSync(t, 64) }
+
+func testCheckpointSync(t *testing.T, protocol int) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	// Create a chain with a checkpoint deep enough to need multiple header batches
+	chain := testChainBase.shorten(blockCacheItems - 15)
+	checkpoint := uint64(chain.len() / 2)
+
+	tester.downloader.checkpointNumber = checkpoint
+	tester.downloader.checkpointHash = chain.chain[checkpoint]
+	tester.newPeer("peer", protocol, chain)
+
+	// The sync cycle itself must not retrieve anything below the checkpoint
+	var origin uint64
+	tester.downloader.syncInitHook = func(from, height uint64) {
+		origin = from
+	}
+	if err := tester.sync("peer", nil, FastSync); err != nil {
+		t.Fatalf("failed to synchronise blocks: %v", err)
+	}
+	if origin != checkpoint {
+		t.Fatalf("sync origin mismatch: have %d, want %d", origin, checkpoint)
+	}
+	// All headers must be backfilled, with the blocks below the checkpoint
+	for i := 0; atomic.LoadInt32(&tester.downloader.backfilling) == 1; i++ {
+		if i == 100 {
+			t.Fatalf("history backfill timed out")
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if progress := tester.downloader.Progress(); progress.BackfillTarget != 0 {
+		t.Fatalf("backfill progress not reset: %+v", progress)
+	}
+	if _, _, ok := rawdb.ReadBackfillRange(tester.stateDb); ok {
+		t.Fatalf("backfill range not cleaned up")
+	}
+	tester.lock.RLock()
+	if hs := len(tester.ownHeaders); hs != chain.len() {
+		t.Fatalf("synchronised headers mismatch: have %v, want %v", hs, chain.len())
+	}
+	for number := uint64(1); number < uint64(chain.len()); number++ {
+		hash := chain.chain[number]
+		if number <= checkpoint {
+			if _, ok := tester.ownReceipts[hash]; !ok || tester.ownBlocks[hash] == nil {
+				t.Fatalf("block #%d below checkpoint not backfilled", number)
+			}
+		}
+		// The provisional difficulties above the checkpoint must be corrected too
+		if td := tester.ownChainTd[hash]; td == nil || td.Cmp(chain.td(hash)) != 0 {
+			t.Fatalf("td #%d mismatch: have %v, want %v", number, td, chain.td(hash))
+		}
+	}
+	tester.lock.RUnlock()
+
+	if head := tester.CurrentHeader().Number.Uint64(); head != uint64(chain.len()-1) {
+		t.Fatalf("head header mismatch: have %d, want %d", head, chain.len()-1)
+	}
+	if head := tester.CurrentFastBlock().NumberU64(); head != uint64(chain.len()-1) {
+		t.Fatalf("head block mismatch: have %d, want %d", head, chain.len()-1)
+	}
+	if tail := rawdb.ReadCheckpointTail(tester.stateDb); tail != (common.Hash{}) {
+		t.Fatalf("checkpoint tail not cleaned up: %x", tail)
+	}
+}
+
+// Tests that peers on a chain not containing the trusted checkpoint are rejected.
+func TestCheckpointMismatch(t *testing.T) {
+	t.Parallel()
+
+	tester := newTester()
+	defer tester.terminate()
+
+	chain := testChainBase.shorten(blockCacheItems - 15)
+	tester.downloader.checkpointNumber = uint64(chain.len() / 2)
+	tester.downloader.checkpointHash = common.Hash{0xde, 0xad}
+	tester.newPeer("peer", 63, chain)
+
+	if err := tester.sync("peer", nil, FastSync); err != errInvalidCheckpoint {
+		t.Fatalf("synchronisation error mismatch: have %v, want %v", err, errInvalidCheckpoint)
+	}
+	if hs := len(tester.ownHeaders); hs != 1 {
+		t.Fatalf("headers imported from mismatching peer: have %d, want %d", hs, 1)
+	}
+}
+
+// Tests that only the responses matching an in-flight backfill request are routed
+// to the backfill, other deliveries of the same peer reach the sync cycles.
+func TestBackfillDeliveryRouting(t *testing.T) {
+	tester := newTester()
+	defer tester.terminate()
+	d := tester.downloader
+
+	chain := testChainBase.shorten(10)
+	origin := chain.headerm[chain.chain[5]]
+	d.claimBackfill(&backfillRequest{peer: "peer", ch: d.backfillHeaderCh, origin: origin.Hash(), count: 3})
+	defer d.releaseBackfill()
+
+	other := []*types.Header{chain.headerm[chain.chain[7]]}
+	if d.deliverBackfill("other", d.backfillHeaderCh, &headerPack{"other", []*types.Header{origin}}) {
+		t.Fatalf("delivery of other peer routed to backfill")
+	}
+	if d.deliverBackfill("peer", d.backfillHeaderCh, &headerPack{"peer", other}) {
+		t.Fatalf("headers not starting at the origin routed to backfill")
+	}
+	if d.deliverBackfill("peer", d.backfillHeaderCh, &headerPack{"peer", nil}) {
+		t.Fatalf("empty headers routed to backfill")
+	}
+	if d.deliverBackfill("peer", d.backfillBodyCh, &bodyPack{"peer", nil, nil}) {
+		t.Fatalf("unrequested bodies routed to backfill")
+	}
+	if !d.deliverBackfill("peer", d.backfillHeaderCh, &headerPack{"peer", []*types.Header{origin}}) {
+		t.Fatalf("matching headers not routed to backfill")
+	}
+	if d.deliverBackfill("peer", d.backfillHeaderCh, &headerPack{"peer", []*types.Header{origin}}) {
+		t.Fatalf("second delivery routed to answered backfill request")
+	}
+	if packet := <-d.backfillHeaderCh; packet.(*headerPack).headers[0] != origin {
+		t.Fatalf("wrong packet routed to backfill")
+	}
+}
+
+// qualityTesterPeer is a tester peer whose quality metrics are persisted.
+type qualityTesterPeer struct {
+	*downloadTesterPeer
+	quality enode.Quality
+}
+
+func (p *qualityTesterPeer) Quality() enode.Quality         { return p.quality }
+func (p *qualityTesterPeer) UpdateQuality(q enode.Quality) { p.quality = q }
+
+// Tests that the quality metrics of a peer are restored on registration and
+// persisted with the session's failures on departure.
+func TestPeerQualityPersistence(t *testing.T) {
+	ps := newPeerSet()
+
+	known := &qualityTesterPeer{quality: enode.Quality{Latency: 2 * time.Second, Throughput: 100, Timeouts: 1}}
+	if err := ps.Register(newPeerConnection("known", 63, known, log.New())); err != nil {
+		t.Fatalf("failed to register peer: %v", err)
+	}
+	if err := ps.Register(newPeerConnection("fresh", 63, &qualityTesterPeer{}, log.New())); err != nil {
+		t.Fatalf("failed to register peer: %v", err)
+	}
+	p := ps.Peer("known")
+	if p.rtt != 2*time.Second {
+		t.Fatalf("restored rtt mismatch: have %v, want %v", p.rtt, 2*time.Second)
+	}
+	if p.blockThroughput != 100 {
+		t.Fatalf("restored throughput mismatch: have %v, want %v", p.blockThroughput, 100)
+	}
+	// Peers of equal measured throughput are ordered by their quality
+	ps.Reset()
+	if peers, _ := ps.BodyIdlePeers(); peers[0].id != "known" {
+		t.Fatalf("persisted quality ignored: have %s first, want %s", peers[0].id, "known")
+	}
+	p.MarkTimeout()
+	p.MarkInvalid()
+	ps.Unregister("known")
+
+	if known.quality.Timeouts != 2 || known.quality.Invalid != 1 {
+		t.Fatalf("persisted failures mismatch: have %v/%v, want %v/%v", known.quality.Timeouts, known.quality.Invalid, 2, 1)
+	}
+	if known.quality.Throughput != 100 {
+		t.Fatalf("persisted throughput mismatch: have %v, want %v", known.quality.Throughput, 100)
+	}
+}
+
+// Tests that the sync phase rates are averaged independently of the sampling
+// frequency, and that the completion estimates are derived from them.
+func TestSyncPhaseRates(t *testing.T) {
+	var (
+		meter rateMeter
+		start = time.Now()
+	)
+	phase := ethereum.SyncPhaseProgress{Current: 100, Highest: 1100}
+	measurePhase(&phase, &meter, start)
+	if phase.Rate != 0 || phase.ETA != 0 {
+		t.Fatalf("first sample measured: rate %v, eta %v", phase.Rate, phase.ETA)
+	}
+	// Progressing at 100 items per second should estimate the remaining time
+	phase = ethereum.SyncPhaseProgress{Current: 300, Highest: 1100}
+	measurePhase(&phase, &meter, start.Add(2*time.Second))
+	if phase.Rate != 100 || phase.ETA != 8*time.Second {
+		t.Fatalf("rate mismatch: have %v/%v, want %v/%v", phase.Rate, phase.ETA, 100, 8*time.Second)
+	}
+	// Samples too close to each other should not change the rate
+	phase = ethereum.SyncPhaseProgress{Current: 1000, Highest: 1100}
+	measurePhase(&phase, &meter, start.Add(2*time.Second+time.Millisecond))
+	if phase.Rate != 100 {
+		t.Fatalf("rate changed by noisy sample: have %v, want %v", phase.Rate, 100)
+	}
+	// A stalled phase should slowly decay its rate
+	phase = ethereum.SyncPhaseProgress{Current: 300, Highest: 1100}
+	measurePhase(&phase, &meter, start.Add(time.Minute))
+	if phase.Rate <= 0 || phase.Rate >= 100 {
+		t.Fatalf("stalled rate not decayed: have %v", phase.Rate)
+	}
+	// A new sync cycle should restart the measurements
+	phase = ethereum.SyncPhaseProgress{Current: 10, Highest: 1100}
+	measurePhase(&phase, &meter, start.Add(2*time.Minute))
+	if phase.Rate != 0 || phase.ETA != 0 {
+		t.Fatalf("restarted phase measured: rate %v, eta %v", phase.Rate, phase.ETA)
+	}
+}
+
+// Tests that the syncing subscription reports the progress in the same RPC format
+// as eth_syncing, with hex quantities and durations in seconds.
+func TestSyncingResultJSON(t *testing.T) {
+	progress := ethereum.SyncProgress{
+		StartingBlock: 1,
+		CurrentBlock:  16,
+		HighestBlock:  256,
+		Headers:       ethereum.SyncPhaseProgress{Current: 128, Highest: 256, Rate: 31.6, ETA: 4 * time.Second},
+	}
+	blob, err := json.Marshal(&SyncingResult{Syncing: true, Status: RPCMarshalProgress(progress)})
+	if err != nil {
+		t.Fatalf("failed to marshal syncing result: %v", err)
+	}
+	zero := `{"current":"0x0","eta":"0x0","highest":"0x0","rate":"0x0"}`
+	want := `{"syncing":true,"status":{"bodies":` + zero + `,"currentBlock":"0x10",` +
+		`"headers":{"current":"0x80","eta":"0x4","highest":"0x100","rate":"0x20"},` +
+		`"highestBlock":"0x100","knownStates":"0x0","pendingStates":"0x0","pulledStates":"0x0",` +
+		`"receipts":` + zero + `,"startingBlock":"0x1","states":` + zero + `}}`
+	if string(blob) != want {
+		t.Errorf("syncing result mismatch:\nhave %s\nwant %s", blob, want)
+	}
+}
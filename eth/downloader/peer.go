@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/event"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
 )
 
 const (
@@ -68,6 +69,10 @@ type peerConnection struct {
 
 	lacking map[common.Hash]struct{} // Set of hashes not to request (didn't have previously)
 
+	quality  enode.Quality // Quality metrics persisted from the previous sessions with the peer
+	timeouts uint64        // Number of requests timed out during this session (atomic access)
+	invalid  uint64        // Number of invalid responses delivered during this session (atomic access)
+
 	peer Peer
 
 	version int        // Eth protocol version number to switch strategies
@@ -90,6 +95,13 @@ type Peer interface {
 	RequestNodeData([]common.Hash) error
 }
 
+// qualityPeer is implemented by peers whose quality metrics are persisted across
+// sessions (e.g. p2p peers backed by the node database).
+type qualityPeer interface {
+	Quality() enode.Quality
+	UpdateQuality(enode.Quality)
+}
+
 // lightPeerWrapper wraps a LightPeer struct, stubbing out the Peer-only methods.
 type lightPeerWrapper struct {
 	peer LightPeer
@@ -114,7 +126,7 @@ func (w *lightPeerWrapper) RequestNodeData([]common.Hash) error {
 
 // newPeerConnection creates a new downloader peer.
 func newPeerConnection(id string, version int, peer Peer, logger log.Logger) *peerConnection {
-	return &peerConnection{
+	p := &peerConnection{
 		id:      id,
 		lacking: make(map[common.Hash]struct{}),
 
@@ -123,6 +135,10 @@ func newPeerConnection(id string, version int, peer Peer, logger log.Logger) *pe
 		version: version,
 		log:     logger,
 	}
+	if qp, ok := peer.(qualityPeer); ok {
+		p.quality = qp.Quality()
+	}
+	return p
 }
 
 // Reset clears the internal state of a peer entity.
@@ -161,6 +177,24 @@ func (p *peerConnection) FetchHeaders(from uint64, count int) error {
 	return nil
 }
 
+// FetchHeadersByHash sends a header retrieval request to the remote peer, walking
+// backwards from the given origin hash.
+func (p *peerConnection) FetchHeadersByHash(origin common.Hash, count int) error {
+	// Sanity check the protocol version
+	if p.version < 62 {
+		panic(fmt.Sprintf("header fetch [eth/62+] requested on eth/%d", p.version))
+	}
+	// Short circuit if the peer is already fetching
+	if !atomic.CompareAndSwapInt32(&p.headerIdle, 0, 1) {
+		return errAlreadyFetching
+	}
+	p.headerStarted = time.Now()
+
+	go p.peer.RequestHeadersByHash(origin, count, 0, true)
+
+	return nil
+}
+
 // FetchBodies sends a block body retrieval request to the remote peer.
 func (p *peerConnection) FetchBodies(request *fetchRequest) error {
 	// Sanity check the protocol version
@@ -339,6 +373,46 @@ func (p *peerConnection) Lacks(hash common.Hash) bool {
 	return ok
 }
 
+// MarkTimeout records a request of the peer timing out.
+func (p *peerConnection) MarkTimeout() {
+	atomic.AddUint64(&p.timeouts, 1)
+}
+
+// MarkInvalid records the peer delivering invalid data.
+func (p *peerConnection) MarkInvalid() {
+	atomic.AddUint64(&p.invalid, 1)
+}
+
+// score returns the persisted quality score of the peer, adjusted with the
+// failures recorded during the current session.
+func (p *peerConnection) score() float64 {
+	q := p.quality
+	q.Timeouts += float64(atomic.LoadUint64(&p.timeouts))
+	q.Invalid += float64(atomic.LoadUint64(&p.invalid))
+	return q.Score()
+}
+
+// saveQuality persists the metrics measured during the session with the peer,
+// if the peer supports it.
+func (p *peerConnection) saveQuality() {
+	qp, ok := p.peer.(qualityPeer)
+	if !ok {
+		return
+	}
+	q := p.quality
+
+	p.lock.RLock()
+	q.Latency = p.rtt
+	if p.blockThroughput > 0 {
+		q.Throughput = p.blockThroughput
+	}
+	p.lock.RUnlock()
+
+	q.Timeouts += float64(atomic.LoadUint64(&p.timeouts))
+	q.Invalid += float64(atomic.LoadUint64(&p.invalid))
+	qp.UpdateQuality(q)
+}
+
 // peerSet represents the collection of active peer participating in the chain
 // download procedure.
 type peerSet struct {
@@ -381,10 +455,20 @@ func (ps *peerSet) Reset() {
 //
 // The method also sets the starting throughput values of the new peer to the
 // average of all existing peers, to give it a realistic chance of being used
-// for data retrievals.
+// for data retrievals. If the peer's quality was measured in a previous session,
+// its starting round trip time and throughputs are derived from that instead.
 func (ps *peerSet) Register(p *peerConnection) error {
 	// Retrieve the current median RTT as a sane default
 	p.rtt = ps.medianRTT()
+	if rtt := p.quality.Latency; rtt > 0 {
+		if rtt < rttMinEstimate {
+			rtt = rttMinEstimate
+		}
+		if rtt > rttMaxEstimate {
+			rtt = rttMaxEstimate
+		}
+		p.rtt = rtt
+	}
 
 	// Register the new peer with some meaningful defaults
 	ps.lock.Lock()
@@ -408,6 +492,16 @@ func (ps *peerSet) Register(p *peerConnection) error {
 		p.receiptThroughput /= float64(len(ps.peers))
 		p.stateThroughput /= float64(len(ps.peers))
 	}
+	if known := p.quality.Throughput; known > 0 {
+		// Scale the averages by how the peer performed compared to them
+		if p.blockThroughput > 0 {
+			scale := known / p.blockThroughput
+			p.headerThroughput *= scale
+			p.receiptThroughput *= scale
+			p.stateThroughput *= scale
+		}
+		p.blockThroughput = known
+	}
 	ps.peers[p.id] = p
 	ps.lock.Unlock()
 
@@ -427,6 +521,7 @@ func (ps *peerSet) Unregister(id string) error {
 	delete(ps.peers, id)
 	ps.lock.Unlock()
 
+	p.saveQuality()
 	ps.peerDropFeed.Send(p)
 	return nil
 }
@@ -517,7 +612,8 @@ func (ps *peerSet) NodeDataIdlePeers() ([]*peerConnection, int) {
 
 // idlePeers retrieves a flat list of all currently idle peers satisfying the
 // protocol version constraints, using the provided function to check idleness.
-// The resulting set of peers are sorted by their measure throughput.
+// The resulting set of peers are sorted by their measure throughput, peers with
+// equal throughput by their persisted quality score.
 func (ps *peerSet) idlePeers(minProtocol, maxProtocol int, idleCheck func(*peerConnection) bool, throughput func(*peerConnection) float64) ([]*peerConnection, int) {
 	ps.lock.RLock()
 	defer ps.lock.RUnlock()
@@ -533,7 +629,8 @@ func (ps *peerSet) idlePeers(minProtocol, maxProtocol int, idleCheck func(*peerC
 	}
 	for i := 0; i < len(idle); i++ {
 		for j := i + 1; j < len(idle); j++ {
-			if throughput(idle[i]) < throughput(idle[j]) {
+			ti, tj := throughput(idle[i]), throughput(idle[j])
+			if ti < tj || (ti == tj && idle[i].score() < idle[j].score()) {
 				idle[i], idle[j] = idle[j], idle[i]
 			}
 		}
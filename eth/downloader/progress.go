@@ -0,0 +1,90 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package downloader
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+)
+
+var (
+	rateMeasureInterval = time.Second      // Minimum time between two rate measurements to avoid noise
+	rateTimeConstant    = 30 * time.Second // Time constant of the exponential moving average of the rates
+)
+
+// rateMeter tracks the rate at which a monotonically increasing progress value
+// grows, using an exponential moving average weighted by the time elapsed between
+// the samples, so the result doesn't depend on how often it's queried.
+type rateMeter struct {
+	value uint64    // Progress value at the last measurement
+	time  time.Time // Time of the last measurement
+	rate  float64   // Averaged rate of progress per second
+	warm  bool      // Whether the first measurement was already made
+}
+
+// update samples the current progress value, returning the updated rate. If the
+// value decreased, a new sync cycle is assumed and the meter is restarted.
+func (m *rateMeter) update(value uint64, now time.Time) float64 {
+	if m.time.IsZero() || value < m.value {
+		m.value, m.time, m.rate, m.warm = value, now, 0, false
+		return 0
+	}
+	elapsed := now.Sub(m.time)
+	if elapsed < rateMeasureInterval {
+		return m.rate
+	}
+	measured := float64(value-m.value) / elapsed.Seconds()
+	if m.warm {
+		m.rate += (1 - math.Exp(-float64(elapsed)/float64(rateTimeConstant))) * (measured - m.rate)
+	} else {
+		m.rate, m.warm = measured, true
+	}
+	m.value, m.time = value, now
+	return m.rate
+}
+
+// progressMeters tracks the rates of the individual synchronisation phases.
+type progressMeters struct {
+	headers  rateMeter
+	bodies   rateMeter
+	receipts rateMeter
+	states   rateMeter
+	lock     sync.Mutex
+}
+
+// measure fills in the rates and completion estimates of the sync phases.
+func (pm *progressMeters) measure(progress *ethereum.SyncProgress, now time.Time) {
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
+
+	measurePhase(&progress.Headers, &pm.headers, now)
+	measurePhase(&progress.Bodies, &pm.bodies, now)
+	measurePhase(&progress.Receipts, &pm.receipts, now)
+	measurePhase(&progress.States, &pm.states, now)
+}
+
+// measurePhase updates the rate of a single sync phase and estimates the time
+// needed to complete the items currently known.
+func measurePhase(phase *ethereum.SyncPhaseProgress, meter *rateMeter, now time.Time) {
+	phase.Rate = meter.update(phase.Current, now)
+	if phase.Rate > 0 && phase.Highest > phase.Current {
+		phase.ETA = time.Duration(float64(phase.Highest-phase.Current) / phase.Rate * float64(time.Second))
+	}
+}
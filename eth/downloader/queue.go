@@ -83,11 +83,13 @@ type queue struct {
 	blockTaskQueue *prque.Prque                  // [eth/62] Priority queue of the headers to fetch the blocks (bodies) for
 	blockPendPool  map[string]*fetchRequest      // [eth/62] Currently pending block (body) retrieval operations
 	blockDonePool  map[common.Hash]struct{}      // [eth/62] Set of the completed block (body) fetches
+	blockDelivered uint64                        // [eth/62] Number of block bodies delivered since the last reset
 
 	receiptTaskPool  map[common.Hash]*types.Header // [eth/63] Pending receipt retrieval tasks, mapping hashes to headers
 	receiptTaskQueue *prque.Prque                  // [eth/63] Priority queue of the headers to fetch the receipts for
 	receiptPendPool  map[string]*fetchRequest      // [eth/63] Currently pending receipt retrieval operations
 	receiptDonePool  map[common.Hash]struct{}      // [eth/63] Set of the completed receipt fetches
+	receiptDelivered uint64                        // [eth/63] Number of block receipts delivered since the last reset
 
 	resultCache  []*fetchResult     // Downloaded but not yet delivered fetch results
 	resultOffset uint64             // Offset of the first cached fetch result in the block chain
@@ -133,11 +135,13 @@ func (q *queue) Reset() {
 	q.blockTaskQueue.Reset()
 	q.blockPendPool = make(map[string]*fetchRequest)
 	q.blockDonePool = make(map[common.Hash]struct{})
+	q.blockDelivered = 0
 
 	q.receiptTaskPool = make(map[common.Hash]*types.Header)
 	q.receiptTaskQueue.Reset()
 	q.receiptPendPool = make(map[string]*fetchRequest)
 	q.receiptDonePool = make(map[common.Hash]struct{})
+	q.receiptDelivered = 0
 
 	q.resultCache = make([]*fetchResult, blockCacheItems)
 	q.resultOffset = 0
@@ -176,6 +180,33 @@ func (q *queue) PendingReceipts() int {
 	return q.receiptTaskQueue.Size()
 }
 
+// BlockProgress retrieves the number of block bodies delivered since the last
+// reset, along with the number of bodies still queued or in flight.
+func (q *queue) BlockProgress() (uint64, uint64) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.blockDelivered, uint64(q.blockTaskQueue.Size()) + pendingItems(q.blockPendPool)
+}
+
+// ReceiptProgress retrieves the number of block receipts delivered since the
+// last reset, along with the number of receipts still queued or in flight.
+func (q *queue) ReceiptProgress() (uint64, uint64) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	return q.receiptDelivered, uint64(q.receiptTaskQueue.Size()) + pendingItems(q.receiptPendPool)
+}
+
+// pendingItems counts the headers of the in-flight requests of a pending pool.
+func pendingItems(pendPool map[string]*fetchRequest) uint64 {
+	var items uint64
+	for _, request := range pendPool {
+		items += uint64(len(request.Headers))
+	}
+	return items
+}
+
 // InFlightHeaders retrieves whether there are header fetch requests currently
 // in flight.
 func (q *queue) InFlightHeaders() bool {
@@ -775,7 +806,9 @@ func (q *queue) DeliverBodies(id string, txLists [][]*types.Transaction, uncleLi
 		result.Uncles = uncleLists[index]
 		return nil
 	}
-	return q.deliver(id, q.blockTaskPool, q.blockTaskQueue, q.blockPendPool, q.blockDonePool, bodyReqTimer, len(txLists), reconstruct)
+	accepted, err := q.deliver(id, q.blockTaskPool, q.blockTaskQueue, q.blockPendPool, q.blockDonePool, bodyReqTimer, len(txLists), reconstruct)
+	q.blockDelivered += uint64(accepted)
+	return accepted, err
 }
 
 // DeliverReceipts injects a receipt retrieval response into the results queue.
@@ -792,7 +825,9 @@ func (q *queue) DeliverReceipts(id string, receiptList [][]*types.Receipt) (int,
 		result.Receipts = receiptList[index]
 		return nil
 	}
-	return q.deliver(id, q.receiptTaskPool, q.receiptTaskQueue, q.receiptPendPool, q.receiptDonePool, receiptReqTimer, len(receiptList), reconstruct)
+	accepted, err := q.deliver(id, q.receiptTaskPool, q.receiptTaskQueue, q.receiptPendPool, q.receiptDonePool, receiptReqTimer, len(receiptList), reconstruct)
+	q.receiptDelivered += uint64(accepted)
+	return accepted, err
 }
 
 // deliver injects a data retrieval response into the results queue.
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"hash"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
@@ -101,6 +102,7 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 			req.timer.Stop()
 			req.peer.SetNodeDataIdle(len(req.items))
 		}
+		atomic.StoreUint64(&d.syncStatsStateReqs, 0)
 	}()
 	// Run the state sync.
 	go s.run()
@@ -112,6 +114,13 @@ func (d *Downloader) runStateSync(s *stateSync) *stateSync {
 	defer peerSub.Unsubscribe()
 
 	for {
+		// Publish the number of state entries currently requested
+		var requested int
+		for _, req := range active {
+			requested += len(req.items)
+		}
+		atomic.StoreUint64(&d.syncStatsStateReqs, uint64(requested))
+
 		// Enable sending of the first buffered element if there is one.
 		var (
 			deliverReq   *stateReq
@@ -187,6 +187,17 @@ func (tc *testChain) headersByNumber(origin uint64, amount int, skip int) []*typ
 	return result
 }
 
+// headersByNumberReverse returns headers in descending order from the given number.
+func (tc *testChain) headersByNumberReverse(origin uint64, amount int, skip int) []*types.Header {
+	result := make([]*types.Header, 0, amount)
+	for num := int64(origin); num >= 0 && num < int64(len(tc.chain)) && len(result) < amount; num -= int64(skip) + 1 {
+		if header, ok := tc.headerm[tc.chain[int(num)]]; ok {
+			result = append(result, header)
+		}
+	}
+	return result
+}
+
 // receipts returns the receipts of the given block hashes.
 func (tc *testChain) receipts(hashes []common.Hash) [][]*types.Receipt {
 	results := make([][]*types.Receipt, 0, len(hashes))
@@ -0,0 +1,65 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package eth
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/forkid"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/eth/downloader"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// Tests that the dial filter only accepts nodes announcing a compatible fork ID
+// in their "eth" entry.
+func TestDialFilter(t *testing.T) {
+	pm, _ := newTestProtocolManagerMust(t, downloader.FullSync, 0, nil, nil)
+	defer pm.Stop()
+
+	filter := pm.SubProtocols[0].DialFilter
+	if filter == nil {
+		t.Fatal("eth protocol has no dial filter")
+	}
+	local := forkid.NewID(pm.blockchain)
+	tests := []struct {
+		entry enr.Entry
+		want  bool
+	}{
+		{nil, false},
+		{ethEntry{ForkID: local}, true},
+		{ethEntry{ForkID: forkid.ID{Hash: [4]byte{0xde, 0xad, 0xbe, 0xef}}}, false},
+	}
+	for i, tt := range tests {
+		var r enr.Record
+		if tt.entry != nil {
+			r.Set(tt.entry)
+		}
+		key, _ := crypto.GenerateKey()
+		if err := enode.SignV4(&r, key); err != nil {
+			t.Fatal(err)
+		}
+		n, err := enode.New(enode.ValidSchemes, &r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := filter(n); have != tt.want {
+			t.Errorf("test %d: filter result mismatch: have %t, want %t", i, have, tt.want)
+		}
+	}
+}
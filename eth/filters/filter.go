@@ -47,6 +47,16 @@ type Backend interface {
 	ServiceFilter(ctx context.Context, session *bloombits.MatcherSession)
 }
 
+// batchBackend is implemented by backends capable of retrieving the logs of a
+// batch of blocks at once, e.g. light clients requesting them from the network.
+type batchBackend interface {
+	GetLogsBatch(ctx context.Context, headers []*types.Header) ([][][]*types.Log, error)
+}
+
+// logsBatchSize is the maximum number of potentially matching blocks whose logs
+// are retrieved at once from backends supporting batched retrievals.
+const logsBatchSize = 256
+
 // Filter can be used to retrieve and filter logs.
 type Filter struct {
 	backend Backend
@@ -191,18 +201,35 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 				}
 				return logs, err
 			}
-			f.begin = int64(number) + 1
-
-			// Retrieve the suggested block and pull any truly matching logs
-			header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
-			if header == nil || err != nil {
-				return logs, err
+			// Gather any other matches already available to process them in one batch
+			numbers := []uint64{number}
+		gather:
+			for len(numbers) < f.batchSize() {
+				select {
+				case number, ok := <-matches:
+					if !ok {
+						break gather
+					}
+					numbers = append(numbers, number)
+				default:
+					break gather
+				}
 			}
-			found, err := f.checkMatches(ctx, header)
+			// Retrieve the suggested blocks and pull any truly matching logs
+			headers := make([]*types.Header, 0, len(numbers))
+			for _, number := range numbers {
+				header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
+				if header == nil || err != nil {
+					return logs, err
+				}
+				headers = append(headers, header)
+			}
+			found, err := f.checkMatchesBatch(ctx, headers)
+			logs = append(logs, found...)
 			if err != nil {
 				return logs, err
 			}
-			logs = append(logs, found...)
+			f.begin = int64(numbers[len(numbers)-1]) + 1
 
 		case <-ctx.Done():
 			return logs, ctx.Err()
@@ -213,18 +240,29 @@ func (f *Filter) indexedLogs(ctx context.Context, end uint64) ([]*types.Log, err
 // indexedLogs returns the logs matching the filter criteria based on raw block
 // iteration and bloom matching.
 func (f *Filter) unindexedLogs(ctx context.Context, end uint64) ([]*types.Log, error) {
-	var logs []*types.Log
-
-	for ; f.begin <= int64(end); f.begin++ {
-		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(f.begin))
+	var (
+		logs    []*types.Log
+		headers []*types.Header
+	)
+	for number := f.begin; number <= int64(end); number++ {
+		header, err := f.backend.HeaderByNumber(ctx, rpc.BlockNumber(number))
 		if header == nil || err != nil {
 			return logs, err
 		}
-		found, err := f.blockLogs(ctx, header)
+		if bloomFilter(header.Bloom, f.addresses, f.topics) {
+			headers = append(headers, header)
+		}
+		// Check the potential matches once enough gathered or the range ends
+		if len(headers) < f.batchSize() && number < int64(end) {
+			continue
+		}
+		found, err := f.checkMatchesBatch(ctx, headers)
+		logs = append(logs, found...)
 		if err != nil {
 			return logs, err
 		}
-		logs = append(logs, found...)
+		headers = headers[:0]
+		f.begin = number + 1
 	}
 	return logs, nil
 }
@@ -241,6 +279,44 @@ func (f *Filter) blockLogs(ctx context.Context, header *types.Header) (logs []*t
 	return logs, nil
 }
 
+// batchSize returns the number of potentially matching blocks to check at once.
+func (f *Filter) batchSize() int {
+	if _, ok := f.backend.(batchBackend); ok {
+		return logsBatchSize
+	}
+	return 1
+}
+
+// checkMatchesBatch checks the logs of a batch of potentially matching blocks,
+// retrieving them at once if the backend supports it.
+func (f *Filter) checkMatchesBatch(ctx context.Context, headers []*types.Header) ([]*types.Log, error) {
+	var logs []*types.Log
+
+	backend, ok := f.backend.(batchBackend)
+	if !ok || len(headers) <= 1 {
+		for _, header := range headers {
+			found, err := f.checkMatches(ctx, header)
+			if err != nil {
+				return logs, err
+			}
+			logs = append(logs, found...)
+		}
+		return logs, nil
+	}
+	logsLists, err := backend.GetLogsBatch(ctx, headers)
+	if err != nil {
+		return nil, err
+	}
+	for i, header := range headers {
+		found, err := f.matchLogs(ctx, header, logsLists[i])
+		if err != nil {
+			return logs, err
+		}
+		logs = append(logs, found...)
+	}
+	return logs, nil
+}
+
 // checkMatches checks if the receipts belonging to the given header contain any log events that
 // match the filter criteria. This function is called when the bloom filter signals a potential match.
 func (f *Filter) checkMatches(ctx context.Context, header *types.Header) (logs []*types.Log, err error) {
@@ -249,6 +325,12 @@ func (f *Filter) checkMatches(ctx context.Context, header *types.Header) (logs [
 	if err != nil {
 		return nil, err
 	}
+	return f.matchLogs(ctx, header, logsList)
+}
+
+// matchLogs filters the logs of a block, resolving the full logs of any match
+// if only the raw logs were retrieved.
+func (f *Filter) matchLogs(ctx context.Context, header *types.Header, logsList [][]*types.Log) (logs []*types.Log, err error) {
 	var unfiltered []*types.Log
 	for _, logs := range logsList {
 		unfiltered = append(unfiltered, logs...)
@@ -228,4 +228,32 @@ func TestFilters(t *testing.T) {
 	if len(logs) != 0 {
 		t.Error("expected 0 log, got", len(logs))
 	}
+
+	// Backends supporting batched retrievals should yield the same logs
+	batched := &batchTestBackend{testBackend: backend}
+	filter = NewRangeFilter(batched, 0, -1, []common.Address{addr}, [][]common.Hash{{hash1, hash2, hash3, hash4}})
+
+	logs, _ = filter.Logs(context.Background())
+	if len(logs) != 4 {
+		t.Error("expected 4 log, got", len(logs))
+	}
+	if batched.batches != 1 {
+		t.Error("expected 1 batch, got", batched.batches)
+	}
+}
+
+// batchTestBackend is a test backend retrieving the logs of multiple blocks at once.
+type batchTestBackend struct {
+	*testBackend
+	batches int
+}
+
+func (b *batchTestBackend) GetLogsBatch(ctx context.Context, headers []*types.Header) ([][][]*types.Log, error) {
+	b.batches++
+
+	logs := make([][][]*types.Log, len(headers))
+	for i, header := range headers {
+		logs[i], _ = b.GetLogs(ctx, header.Hash())
+	}
+	return logs, nil
 }
@@ -12,6 +12,8 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/eth/gasprice"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
 )
 
 var _ = (*configMarshaling)(nil)
@@ -23,14 +25,18 @@ func (c Config) MarshalTOML() (interface{}, error) {
 		NetworkId               uint64
 		SyncMode                downloader.SyncMode
 		NoPruning               bool
+		BlockAnnounceLimit      p2p.MsgLimit
+		TxAnnounceLimit         p2p.MsgLimit
 		LightServ               int `toml:",omitempty"`
 		LightBandwidthIn        int `toml:",omitempty"`
 		LightBandwidthOut       int `toml:",omitempty"`
 		LightPeers              int `toml:",omitempty"`
 		OnlyAnnounce            bool
-		ULC                     *ULCConfig `toml:",omitempty"`
-		SkipBcVersionCheck      bool       `toml:"-"`
-		DatabaseHandles         int        `toml:"-"`
+		ULC                     *ULCConfig                     `toml:",omitempty"`
+		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
+		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
+		SkipBcVersionCheck      bool                           `toml:"-"`
+		DatabaseHandles         int                            `toml:"-"`
 		DatabaseCache           int
 		TrieCleanCache          int
 		TrieDirtyCache          int
@@ -56,12 +62,16 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.NetworkId = c.NetworkId
 	enc.SyncMode = c.SyncMode
 	enc.NoPruning = c.NoPruning
+	enc.BlockAnnounceLimit = c.BlockAnnounceLimit
+	enc.TxAnnounceLimit = c.TxAnnounceLimit
 	enc.LightServ = c.LightServ
 	enc.LightBandwidthIn = c.LightBandwidthIn
 	enc.LightBandwidthOut = c.LightBandwidthOut
 	enc.LightPeers = c.LightPeers
 	enc.OnlyAnnounce = c.OnlyAnnounce
 	enc.ULC = c.ULC
+	enc.Checkpoint = c.Checkpoint
+	enc.CheckpointOracle = c.CheckpointOracle
 	enc.SkipBcVersionCheck = c.SkipBcVersionCheck
 	enc.DatabaseHandles = c.DatabaseHandles
 	enc.DatabaseCache = c.DatabaseCache
@@ -94,14 +104,18 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 		NetworkId               *uint64
 		SyncMode                *downloader.SyncMode
 		NoPruning               *bool
+		BlockAnnounceLimit      *p2p.MsgLimit
+		TxAnnounceLimit         *p2p.MsgLimit
 		LightServ               *int `toml:",omitempty"`
 		LightBandwidthIn        *int `toml:",omitempty"`
 		LightBandwidthOut       *int `toml:",omitempty"`
 		LightPeers              *int `toml:",omitempty"`
 		OnlyAnnounce            *bool
-		ULC                     *ULCConfig `toml:",omitempty"`
-		SkipBcVersionCheck      *bool      `toml:"-"`
-		DatabaseHandles         *int       `toml:"-"`
+		ULC                     *ULCConfig                     `toml:",omitempty"`
+		Checkpoint              *params.TrustedCheckpoint      `toml:",omitempty"`
+		CheckpointOracle        *params.CheckpointOracleConfig `toml:",omitempty"`
+		SkipBcVersionCheck      *bool                          `toml:"-"`
+		DatabaseHandles         *int                           `toml:"-"`
 		DatabaseCache           *int
 		TrieCleanCache          *int
 		TrieDirtyCache          *int
@@ -138,6 +152,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.NoPruning != nil {
 		c.NoPruning = *dec.NoPruning
 	}
+	if dec.BlockAnnounceLimit != nil {
+		c.BlockAnnounceLimit = *dec.BlockAnnounceLimit
+	}
+	if dec.TxAnnounceLimit != nil {
+		c.TxAnnounceLimit = *dec.TxAnnounceLimit
+	}
 	if dec.LightServ != nil {
 		c.LightServ = *dec.LightServ
 	}
@@ -156,6 +176,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.ULC != nil {
 		c.ULC = dec.ULC
 	}
+	if dec.Checkpoint != nil {
+		c.Checkpoint = dec.Checkpoint
+	}
+	if dec.CheckpointOracle != nil {
+		c.CheckpointOracle = dec.CheckpointOracle
+	}
 	if dec.SkipBcVersionCheck != nil {
 		c.SkipBcVersionCheck = *dec.SkipBcVersionCheck
 	}
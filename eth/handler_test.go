@@ -21,6 +21,7 @@ import (
 	"math"
 	"math/big"
 	"math/rand"
+	"reflect"
 	"testing"
 	"time"
 
@@ -482,7 +483,7 @@ func testDAOChallenge(t *testing.T, localForked, remoteForked bool, timeout bool
 	if err != nil {
 		t.Fatalf("failed to create new blockchain: %v", err)
 	}
-	pm, err := NewProtocolManager(config, downloader.FullSync, DefaultConfig.NetworkId, evmux, new(testTxPool), pow, blockchain, db, nil)
+	pm, err := NewProtocolManager(config, downloader.FullSync, DefaultConfig.NetworkId, evmux, new(testTxPool), pow, blockchain, db, nil, msgLimits(DefaultConfig.BlockAnnounceLimit, DefaultConfig.TxAnnounceLimit))
 	if err != nil {
 		t.Fatalf("failed to start test protocol manager: %v", err)
 	}
@@ -563,7 +564,7 @@ func testBroadcastBlock(t *testing.T, totalPeers, broadcastExpected int) {
 	if err != nil {
 		t.Fatalf("failed to create new blockchain: %v", err)
 	}
-	pm, err := NewProtocolManager(config, downloader.FullSync, DefaultConfig.NetworkId, evmux, new(testTxPool), pow, blockchain, db, nil)
+	pm, err := NewProtocolManager(config, downloader.FullSync, DefaultConfig.NetworkId, evmux, new(testTxPool), pow, blockchain, db, nil, msgLimits(DefaultConfig.BlockAnnounceLimit, DefaultConfig.TxAnnounceLimit))
 	if err != nil {
 		t.Fatalf("failed to start test protocol manager: %v", err)
 	}
@@ -615,3 +616,33 @@ outer:
 		t.Errorf("block broadcast to %d peers, expected %d", receivedCount, broadcastExpected)
 	}
 }
+
+// Tests that the configured announcement limits are handed to every protocol
+// version of the protocol manager.
+func TestAnnounceLimits(t *testing.T) {
+	var (
+		evmux  = new(event.TypeMux)
+		pow    = ethash.NewFaker()
+		db     = rawdb.NewMemoryDatabase()
+		config = params.TestChainConfig
+		gspec  = &core.Genesis{Config: config}
+	)
+	gspec.MustCommit(db)
+	blockchain, err := core.NewBlockChain(db, nil, config, pow, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create new blockchain: %v", err)
+	}
+	defer blockchain.Stop()
+
+	block, tx := p2p.MsgLimit{Rate: 20, Burst: 8}, p2p.MsgLimit{Rate: 500, Burst: 2000}
+	pm, err := NewProtocolManager(config, downloader.FullSync, DefaultConfig.NetworkId, evmux, new(testTxPool), pow, blockchain, db, nil, msgLimits(block, tx))
+	if err != nil {
+		t.Fatalf("failed to create protocol manager: %v", err)
+	}
+	want := map[uint64]p2p.MsgLimit{NewBlockHashesMsg: block, NewBlockMsg: block, TxMsg: tx}
+	for _, proto := range pm.SubProtocols {
+		if !reflect.DeepEqual(proto.MsgLimits, want) {
+			t.Errorf("%s/%d: limits mismatch: have %v, want %v", proto.Name, proto.Version, proto.MsgLimits, want)
+		}
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/ethereum/go-ethereum/core"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/p2p"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -45,6 +46,17 @@ var ProtocolLengths = []uint64{17, 8}
 
 const ProtocolMaxMsgSize = 10 * 1024 * 1024 // Maximum cap on the size of a protocol message
 
+// msgLimits assembles the rate limits of unsolicited announcements. Honest peers
+// announce every block and transaction once, so the limits only need to cover the
+// block rate of the chain and the transaction throughput of the network.
+func msgLimits(block, tx p2p.MsgLimit) map[uint64]p2p.MsgLimit {
+	return map[uint64]p2p.MsgLimit{
+		NewBlockHashesMsg: block,
+		NewBlockMsg:       block,
+		TxMsg:             tx,
+	}
+}
+
 // eth protocol message codes
 const (
 	// Protocol messages belonging to eth/62
@@ -23,6 +23,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
@@ -280,6 +281,31 @@ type rpcProgress struct {
 	HighestBlock  hexutil.Uint64
 	PulledStates  hexutil.Uint64
 	KnownStates   hexutil.Uint64
+
+	BackfillBlock  hexutil.Uint64
+	BackfillTarget hexutil.Uint64
+
+	PendingStates hexutil.Uint64
+	Headers       rpcSyncPhase
+	Bodies        rpcSyncPhase
+	Receipts      rpcSyncPhase
+	States        rpcSyncPhase
+}
+
+type rpcSyncPhase struct {
+	Current hexutil.Uint64
+	Highest hexutil.Uint64
+	Rate    hexutil.Uint64
+	ETA     hexutil.Uint64
+}
+
+func (p rpcSyncPhase) progress() ethereum.SyncPhaseProgress {
+	return ethereum.SyncPhaseProgress{
+		Current: uint64(p.Current),
+		Highest: uint64(p.Highest),
+		Rate:    float64(p.Rate),
+		ETA:     time.Duration(p.ETA) * time.Second,
+	}
 }
 
 // SyncProgress retrieves the current progress of the sync algorithm. If there's
@@ -304,6 +330,15 @@ func (ec *Client) SyncProgress(ctx context.Context) (*ethereum.SyncProgress, err
 		HighestBlock:  uint64(progress.HighestBlock),
 		PulledStates:  uint64(progress.PulledStates),
 		KnownStates:   uint64(progress.KnownStates),
+
+		BackfillBlock:  uint64(progress.BackfillBlock),
+		BackfillTarget: uint64(progress.BackfillTarget),
+
+		Headers:       progress.Headers.progress(),
+		Bodies:        progress.Bodies.progress(),
+		Receipts:      progress.Receipts.progress(),
+		States:        progress.States.progress(),
+		PendingStates: uint64(progress.PendingStates),
 	}, nil
 }
 
@@ -18,16 +18,19 @@
 package graphql
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"sort"
 	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus/clique"
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -789,6 +792,82 @@ func (b *Block) Logs(ctx context.Context, args struct{ Filter BlockFilterCriteri
 	return runFilter(ctx, b.backend, filter)
 }
 
+// CliqueTally represents the running score of a clique authorization proposal.
+type CliqueTally struct {
+	address common.Address
+	tally   clique.Tally
+}
+
+func (t *CliqueTally) Address(ctx context.Context) common.Address {
+	return t.address
+}
+
+func (t *CliqueTally) Authorize(ctx context.Context) bool {
+	return t.tally.Authorize
+}
+
+func (t *CliqueTally) Votes(ctx context.Context) int32 {
+	return int32(t.tally.Votes)
+}
+
+// clique returns the clique consensus engine of the chain, or nil if the chain
+// runs a different one.
+func (b *Block) clique() *clique.Clique {
+	engine, _ := b.backend.Engine().(*clique.Clique)
+	return engine
+}
+
+func (b *Block) CliqueSigner(ctx context.Context) (*common.Address, error) {
+	engine := b.clique()
+	if engine == nil {
+		return nil, nil
+	}
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return nil, err
+	}
+	signer, err := engine.Author(header)
+	if err != nil {
+		return nil, err
+	}
+	return &signer, nil
+}
+
+func (b *Block) CliqueInTurn(ctx context.Context) (*bool, error) {
+	if b.clique() == nil {
+		return nil, nil
+	}
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return nil, err
+	}
+	inturn := header.Difficulty.Cmp(common.Big2) == 0
+	return &inturn, nil
+}
+
+func (b *Block) CliqueTally(ctx context.Context) (*[]*CliqueTally, error) {
+	engine := b.clique()
+	if engine == nil {
+		return nil, nil
+	}
+	header, err := b.resolveHeader(ctx)
+	if err != nil || header == nil {
+		return nil, err
+	}
+	snap, err := engine.SnapshotAt(b.backend.BlockChain(), header)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*CliqueTally, 0, len(snap.Tally))
+	for address, tally := range snap.Tally {
+		ret = append(ret, &CliqueTally{address: address, tally: tally})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return bytes.Compare(ret[i].address[:], ret[j].address[:]) < 0
+	})
+	return &ret, nil
+}
+
 func (b *Block) Account(ctx context.Context, args struct {
 	Address common.Address
 }) (*Account, error) {
@@ -1128,6 +1207,47 @@ func (s *SyncState) KnownStates() *hexutil.Uint64 {
 	return &ret
 }
 
+func (s *SyncState) PendingStates() hexutil.Uint64 {
+	return hexutil.Uint64(s.progress.PendingStates)
+}
+
+func (s *SyncState) Headers() *SyncPhase {
+	return &SyncPhase{s.progress.Headers}
+}
+
+func (s *SyncState) Bodies() *SyncPhase {
+	return &SyncPhase{s.progress.Bodies}
+}
+
+func (s *SyncState) Receipts() *SyncPhase {
+	return &SyncPhase{s.progress.Receipts}
+}
+
+func (s *SyncState) States() *SyncPhase {
+	return &SyncPhase{s.progress.States}
+}
+
+// SyncPhase represents the progress of a single phase of the synchronisation.
+type SyncPhase struct {
+	progress ethereum.SyncPhaseProgress
+}
+
+func (p *SyncPhase) Current() hexutil.Uint64 {
+	return hexutil.Uint64(p.progress.Current)
+}
+
+func (p *SyncPhase) Highest() hexutil.Uint64 {
+	return hexutil.Uint64(p.progress.Highest)
+}
+
+func (p *SyncPhase) Rate() float64 {
+	return p.progress.Rate
+}
+
+func (p *SyncPhase) Eta() hexutil.Uint64 {
+	return hexutil.Uint64(p.progress.ETA / time.Second)
+}
+
 // Syncing returns false in case the node is currently not syncing with the network. It can be up to date or has not
 // yet received the latest block headers from its pears. In case it is synchronizing:
 // - startingBlock: block number this node started to synchronise from
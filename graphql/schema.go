@@ -137,6 +137,17 @@ const schema string = `
         topics: [[Bytes32!]!]
     }
 
+    # CliqueTally is the running score of a clique authorization proposal.
+    type CliqueTally {
+        # Address is the account being voted on.
+        address: Address!
+        # Authorize is true if the votes are for authorizing the account, false
+        # if they are for dropping it.
+        authorize: Boolean!
+        # Votes is the number of signers in favour of the proposal.
+        votes: Int!
+    }
+
     # Block is an Ethereum block.
     type Block {
         # Number is the number of this block, starting at 0 for the genesis block.
@@ -199,6 +210,17 @@ const schema string = `
         transactionAt(index: Int!): Transaction
         # Logs returns a filtered set of logs from this block.
         logs(filter: BlockFilterCriteria!): [Log!]!
+        # CliqueSigner is the signer that sealed this block. If the chain does
+        # not run clique proof-of-authority, this field will be null.
+        cliqueSigner: Address
+        # CliqueInTurn is true if this block was sealed by the in-turn signer.
+        # If the chain does not run clique proof-of-authority, this field will
+        # be null.
+        cliqueInTurn: Boolean
+        # CliqueTally is the clique vote tally after this block, ordered by the
+        # account being voted on. If the chain does not run clique
+        # proof-of-authority, this field will be null.
+        cliqueTally: [CliqueTally!]
         # Account fetches an Ethereum account at the current block's state.
         account(address: Address!): Account!
         # Call executes a local call operation at the current block's state.
@@ -274,6 +296,29 @@ const schema string = `
         # KnownStates is the number of states the node knows of so far, or null
         # if this is not known or not relevant.
         knownStates: Long
+        # PendingStates is the number of state entries currently requested from peers.
+        pendingStates: Long!
+        # Headers is the progress of the header chain retrieval, in block numbers.
+        headers: SyncPhase!
+        # Bodies is the progress of the block body retrieval.
+        bodies: SyncPhase!
+        # Receipts is the progress of the receipt retrieval.
+        receipts: SyncPhase!
+        # States is the progress of the state trie retrieval.
+        states: SyncPhase!
+    }
+
+    # SyncPhase contains the progress of a single phase of the synchronisation.
+    type SyncPhase {
+        # Current is the number of items the phase has completed.
+        current: Long!
+        # Highest is the number of items the phase is known to need in total.
+        highest: Long!
+        # Rate is the number of items completed per second over the recent past.
+        rate: Float!
+        # Eta is the estimated number of seconds needed to complete the known
+        # items, or zero if not known.
+        eta: Long!
     }
 
     # Pending represents the current pending state.
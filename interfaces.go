@@ -21,6 +21,7 @@ import (
 	"context"
 	"errors"
 	"math/big"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -103,6 +104,25 @@ type SyncProgress struct {
 	HighestBlock  uint64 // Highest alleged block number in the chain
 	PulledStates  uint64 // Number of state trie entries already downloaded
 	KnownStates   uint64 // Total number of state trie entries known about
+
+	BackfillBlock  uint64 // Oldest block backfilled below the sync checkpoint, headers first, then bodies and receipts
+	BackfillTarget uint64 // Oldest block the history backfill retrieves (zero if not backfilling)
+
+	Headers       SyncPhaseProgress // Header chain retrieval, measured in block numbers
+	Bodies        SyncPhaseProgress // Block body retrieval, measured in bodies known to be needed
+	Receipts      SyncPhaseProgress // Receipt retrieval, measured in receipt sets known to be needed
+	States        SyncPhaseProgress // State trie retrieval, measured in trie entries known about
+	PendingStates uint64            // Number of state trie entries currently requested from peers
+}
+
+// SyncPhaseProgress gives progress indications about a single phase of the
+// synchronisation. The target of a phase may grow while the sync progresses,
+// so the estimated completion time only accounts for the work known so far.
+type SyncPhaseProgress struct {
+	Current uint64        // Number of items the phase has completed
+	Highest uint64        // Number of items the phase is known to need in total
+	Rate    float64       // Items completed per second, averaged over the recent past
+	ETA     time.Duration // Estimated time to complete the known items (zero if unknown)
 }
 
 // ChainSyncReader wraps access to the node's current sync status. If there's no
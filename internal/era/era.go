@@ -0,0 +1,299 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package era implements a segmented chain archive format, used to export the
+// chain history along with the receipts and to import it from local directories
+// or HTTP mirrors.
+//
+// An archive consists of a manifest file and a number of segment files. Each
+// segment contains a contiguous range of blocks, their receipts and total
+// difficulties as a stream of RLP items. The manifest lists the segments along
+// with their block ranges, accumulators over the block hashes and difficulties,
+// and the SHA256 checksums of the segment files.
+package era
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// Version is the version of the archive format.
+	Version = 1
+
+	// ManifestName is the name of the manifest file within an archive.
+	ManifestName = "manifest.json"
+
+	// DefaultSegmentSize is the default number of blocks in a segment.
+	DefaultSegmentSize = 8192
+)
+
+// httpTimeout is the time after which a request to an HTTP mirror is aborted if
+// it didn't make any progress.
+var httpTimeout = 30 * time.Second
+
+var (
+	errUnknownVersion = errors.New("unknown archive version")
+	errChecksum       = errors.New("segment checksum mismatch")
+	errAccumulator    = errors.New("segment accumulator mismatch")
+)
+
+// Manifest describes the contents of an archive.
+type Manifest struct {
+	Version  uint64      `json:"version"`
+	Genesis  common.Hash `json:"genesis"`
+	Segments []*Segment  `json:"segments"`
+}
+
+// Segment describes a single segment file of an archive.
+type Segment struct {
+	File        string      `json:"file"`        // Name of the segment file within the archive
+	First       uint64      `json:"first"`       // Number of the first block in the segment
+	Last        uint64      `json:"last"`        // Number of the last block in the segment
+	Accumulator common.Hash `json:"accumulator"` // Accumulator over the block hashes and total difficulties
+	Checksum    common.Hash `json:"sha256"`      // SHA256 checksum of the segment file
+}
+
+// segmentName returns the file name of the segment with the given block range.
+func segmentName(first, last uint64) string {
+	return fmt.Sprintf("%010d-%010d.era", first, last)
+}
+
+// segmentHeader is the first RLP item of a segment file.
+type segmentHeader struct {
+	Version     uint64
+	First, Last uint64
+}
+
+// segmentEntry is the RLP item of a single block within a segment file.
+type segmentEntry struct {
+	Block    *types.Block
+	Receipts []*types.ReceiptForStorage
+	TD       *big.Int
+}
+
+// accumulate extends a segment accumulator with the hash and total difficulty
+// of the next block.
+func accumulate(acc common.Hash, hash common.Hash, td *big.Int) common.Hash {
+	return crypto.Keccak256Hash(acc[:], hash[:], common.LeftPadBytes(td.Bytes(), 32))
+}
+
+// Source is a location archives are read from.
+type Source interface {
+	// Open opens a file of the archive for reading.
+	Open(name string) (io.ReadCloser, error)
+}
+
+// NewSource creates an archive source reading from a local directory or, if the
+// location is an http(s) URL, from an HTTP mirror.
+func NewSource(location string) Source {
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		return &httpSource{base: strings.TrimSuffix(location, "/")}
+	}
+	return dirSource(location)
+}
+
+// dirSource reads an archive from a local directory.
+type dirSource string
+
+func (dir dirSource) Open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(string(dir), name))
+}
+
+// httpSource reads an archive from an HTTP mirror.
+type httpSource struct {
+	base string
+}
+
+// Open requests a file from the mirror. Segment files may take long to download,
+// so instead of limiting the duration of the entire request, it's aborted if the
+// mirror doesn't respond or stalls delivering the file for httpTimeout.
+func (src *httpSource) Open(name string) (io.ReadCloser, error) {
+	url := src.base + "/" + name
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	timer := time.AfterFunc(httpTimeout, cancel)
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		timer.Stop()
+		cancel()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		timer.Stop()
+		cancel()
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to fetch %s: %s", url, resp.Status)
+	}
+	return &httpBody{body: resp.Body, timer: timer, cancel: cancel}, nil
+}
+
+// httpBody is the body of a mirror response, aborting the request if it stalls.
+type httpBody struct {
+	body   io.ReadCloser
+	timer  *time.Timer
+	cancel context.CancelFunc
+}
+
+func (b *httpBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	b.timer.Reset(httpTimeout)
+	return n, err
+}
+
+func (b *httpBody) Close() error {
+	b.timer.Stop()
+	b.cancel()
+	return b.body.Close()
+}
+
+// ReadManifest retrieves and validates the manifest of an archive.
+func ReadManifest(src Source) (*Manifest, error) {
+	r, err := src.Open(ManifestName)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	manifest := new(Manifest)
+	if err := json.NewDecoder(r).Decode(manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %v", err)
+	}
+	if manifest.Version != Version {
+		return nil, errUnknownVersion
+	}
+	for _, seg := range manifest.Segments {
+		if seg.First > seg.Last || seg.File == "" || strings.ContainsAny(seg.File, "/\\") {
+			return nil, fmt.Errorf("invalid segment %q [%d, %d]", seg.File, seg.First, seg.Last)
+		}
+	}
+	return manifest, nil
+}
+
+// segmentData is the verified content of a segment.
+type segmentData struct {
+	blocks   types.Blocks
+	receipts []types.Receipts
+	tds      []*big.Int
+}
+
+// readSegment reads a segment from the archive, verifying its checksum, the
+// linkage of the blocks, their transaction, uncle and receipt roots, and the
+// accumulator over the block hashes and total difficulties.
+func readSegment(src Source, seg *Segment) (*segmentData, error) {
+	r, err := src.Open(seg.File)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var (
+		hasher = sha256.New()
+		stream = rlp.NewStream(io.TeeReader(r, hasher), 0)
+		header segmentHeader
+	)
+	if err := stream.Decode(&header); err != nil {
+		return nil, fmt.Errorf("invalid segment header: %v", err)
+	}
+	if header.Version != Version {
+		return nil, errUnknownVersion
+	}
+	if header.First != seg.First || header.Last != seg.Last {
+		return nil, fmt.Errorf("segment range mismatch: have [%d, %d], want [%d, %d]", header.First, header.Last, seg.First, seg.Last)
+	}
+	var (
+		data = new(segmentData)
+		acc  common.Hash
+	)
+	for number := seg.First; number <= seg.Last; number++ {
+		var entry segmentEntry
+		if err := stream.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("invalid block #%d: %v", number, err)
+		}
+		receipts := make(types.Receipts, len(entry.Receipts))
+		for i, receipt := range entry.Receipts {
+			receipts[i] = (*types.Receipt)(receipt)
+		}
+		if err := verifyEntry(data, number, entry.Block, receipts, entry.TD); err != nil {
+			return nil, fmt.Errorf("invalid block #%d: %v", number, err)
+		}
+		data.blocks = append(data.blocks, entry.Block)
+		data.receipts = append(data.receipts, receipts)
+		data.tds = append(data.tds, entry.TD)
+
+		acc = accumulate(acc, entry.Block.Hash(), entry.TD)
+	}
+	if err := stream.Decode(new(rlp.RawValue)); err != io.EOF {
+		return nil, errors.New("trailing data after last block")
+	}
+	if checksum(hasher) != seg.Checksum {
+		return nil, errChecksum
+	}
+	if acc != seg.Accumulator {
+		return nil, errAccumulator
+	}
+	return data, nil
+}
+
+// verifyEntry checks a block entry against the previous ones of the segment.
+func verifyEntry(data *segmentData, number uint64, block *types.Block, receipts types.Receipts, td *big.Int) error {
+	if block == nil || td == nil {
+		return errors.New("incomplete entry")
+	}
+	if block.NumberU64() != number {
+		return fmt.Errorf("unexpected block #%d", block.NumberU64())
+	}
+	if n := len(data.blocks); n > 0 {
+		if block.ParentHash() != data.blocks[n-1].Hash() {
+			return errors.New("not linked to parent")
+		}
+		if new(big.Int).Add(data.tds[n-1], block.Difficulty()).Cmp(td) != 0 {
+			return errors.New("total difficulty mismatch")
+		}
+	}
+	if types.DeriveSha(block.Transactions()) != block.TxHash() || types.CalcUncleHash(block.Uncles()) != block.UncleHash() {
+		return errors.New("body doesn't match header")
+	}
+	if len(receipts) != len(block.Transactions()) || types.DeriveSha(receipts) != block.ReceiptHash() {
+		return errors.New("receipts don't match header")
+	}
+	return nil
+}
+
+// checksum returns the SHA256 sum accumulated by a hasher.
+func checksum(h hash.Hash) common.Hash {
+	return common.BytesToHash(h.Sum(nil))
+}
@@ -0,0 +1,238 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus/ethash"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+var (
+	testKey, _  = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	testAddress = crypto.PubkeyToAddress(testKey.PublicKey)
+	testGenesis = &core.Genesis{
+		Config: params.TestChainConfig,
+		Alloc:  core.GenesisAlloc{testAddress: {Balance: big.NewInt(1000000000)}},
+	}
+)
+
+// newTestChain creates a blockchain on top of a fresh test genesis, inserting
+// the given number of generated blocks with a transfer in each.
+func newTestChain(t *testing.T, blocks int) *core.BlockChain {
+	db := rawdb.NewMemoryDatabase()
+	genesis := testGenesis.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, testGenesis.Config, ethash.NewFaker(), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	if blocks == 0 {
+		return chain
+	}
+	signer := types.HomesteadSigner{}
+	generated, _ := core.GenerateChain(testGenesis.Config, genesis, ethash.NewFaker(), db, blocks, func(i int, gen *core.BlockGen) {
+		tx, _ := types.SignTx(types.NewTransaction(gen.TxNonce(testAddress), common.Address{0x01}, big.NewInt(1000), params.TxGas, nil, nil), signer, testKey)
+		gen.AddTx(tx)
+	})
+	if n, err := chain.InsertChain(generated); err != nil {
+		t.Fatalf("failed to insert block #%d: %v", n, err)
+	}
+	return chain
+}
+
+// Tests that an exported archive can be imported both trusted and untrusted, from
+// a local directory as well as from an HTTP mirror.
+func TestExportImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "era-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := newTestChain(t, 25)
+	defer source.Stop()
+
+	// Export the chain in two steps to check that the manifest is extended
+	if err := Export(source, dir, 0, 9, 4); err != nil {
+		t.Fatalf("failed to export archive: %v", err)
+	}
+	if err := Export(source, dir, 8, 25, 4); err != nil {
+		t.Fatalf("failed to extend archive: %v", err)
+	}
+	manifest, err := ReadManifest(NewSource(dir))
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	if len(manifest.Segments) != 7 {
+		t.Fatalf("segment count mismatch: have %d, want %d", len(manifest.Segments), 7)
+	}
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	defer server.Close()
+
+	for _, location := range []string{dir, server.URL} {
+		for _, trusted := range []bool{false, true} {
+			chain := newTestChain(t, 0)
+			if err := Import(chain, NewSource(location), trusted, 3, nil); err != nil {
+				t.Fatalf("%s (trusted %v): import failed: %v", location, trusted, err)
+			}
+			head := chain.CurrentBlock()
+			if trusted {
+				head = chain.CurrentFastBlock()
+			}
+			if head.Hash() != source.CurrentBlock().Hash() {
+				t.Errorf("%s (trusted %v): head mismatch: have #%d, want #%d", location, trusted, head.NumberU64(), source.CurrentBlock().NumberU64())
+			}
+			for n := uint64(1); n <= head.NumberU64(); n++ {
+				block := source.GetBlockByNumber(n)
+				if receipts := chain.GetReceiptsByHash(block.Hash()); types.DeriveSha(receipts) != block.ReceiptHash() {
+					t.Errorf("%s (trusted %v): receipts of block #%d mismatch", location, trusted, n)
+				}
+			}
+			// Importing again must be a no-op
+			if err := Import(chain, NewSource(location), trusted, 3, nil); err != nil {
+				t.Errorf("%s (trusted %v): reimport failed: %v", location, trusted, err)
+			}
+			chain.Stop()
+		}
+	}
+}
+
+// Tests that corrupted segments are rejected.
+func TestImportCorrupted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "era-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := newTestChain(t, 8)
+	defer source.Stop()
+
+	if err := Export(source, dir, 0, 8, 4); err != nil {
+		t.Fatalf("failed to export archive: %v", err)
+	}
+	// Flip a byte at the end of the last segment, within the last receipt
+	path := filepath.Join(dir, segmentName(8, 8))
+	blob, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob[len(blob)-1] ^= 0xff
+	if err := ioutil.WriteFile(path, blob, 0644); err != nil {
+		t.Fatal(err)
+	}
+	chain := newTestChain(t, 0)
+	defer chain.Stop()
+
+	if err := Import(chain, NewSource(dir), true, 2, nil); err == nil {
+		t.Fatalf("corrupted archive imported")
+	}
+	// The segments preceding the corrupted one are imported
+	if head := chain.CurrentFastBlock().NumberU64(); head != 7 {
+		t.Fatalf("head mismatch: have #%d, want #%d", head, 7)
+	}
+}
+
+// Tests that exporting a range partially overlapping existing segments keeps the
+// blocks of those segments outside of the range.
+func TestExportSplit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "era-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	source := newTestChain(t, 20)
+	defer source.Stop()
+
+	if err := Export(source, dir, 0, 20, 8); err != nil {
+		t.Fatalf("failed to export archive: %v", err)
+	}
+	if err := Export(source, dir, 5, 10, 8); err != nil {
+		t.Fatalf("failed to reexport range: %v", err)
+	}
+	manifest, err := ReadManifest(NewSource(dir))
+	if err != nil {
+		t.Fatalf("failed to read manifest: %v", err)
+	}
+	want := [][2]uint64{{0, 4}, {5, 10}, {11, 15}, {16, 20}}
+	if len(manifest.Segments) != len(want) {
+		t.Fatalf("segment count mismatch: have %d, want %d", len(manifest.Segments), len(want))
+	}
+	for i, seg := range manifest.Segments {
+		if seg.First != want[i][0] || seg.Last != want[i][1] {
+			t.Errorf("segment %d range mismatch: have [%d, %d], want [%d, %d]", i, seg.First, seg.Last, want[i][0], want[i][1])
+		}
+	}
+	chain := newTestChain(t, 0)
+	defer chain.Stop()
+
+	if err := Import(chain, NewSource(dir), false, 3, nil); err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+	if head := chain.CurrentBlock(); head.Hash() != source.CurrentBlock().Hash() {
+		t.Fatalf("head mismatch: have #%d, want #%d", head.NumberU64(), source.CurrentBlock().NumberU64())
+	}
+}
+
+// Tests that requests to a stalling HTTP mirror are aborted.
+func TestHTTPSourceTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { httpTimeout = timeout }(httpTimeout)
+	httpTimeout = 100 * time.Millisecond
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	body, err := NewSource(server.URL).Open(ManifestName)
+	if err != nil {
+		t.Fatalf("failed to open file: %v", err)
+	}
+	defer body.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ioutil.ReadAll(body)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("stalled read succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled read not aborted")
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Export writes the canonical blocks in the range [first, last] along with their
+// receipts into segments of the given size within an archive directory. Segments
+// already present in the archive are replaced if they overlap the exported range,
+// with the blocks of partially overlapping ones outside of it exported anew into
+// segments of their own.
+func Export(chain *core.BlockChain, dir string, first, last, size uint64) error {
+	if size == 0 {
+		size = DefaultSegmentSize
+	}
+	if first > last {
+		return fmt.Errorf("invalid export range [%d, %d]", first, last)
+	}
+	if head := chain.CurrentFastBlock().NumberU64(); last > head {
+		return fmt.Errorf("export range [%d, %d] beyond head block #%d", first, last, head)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// Load any existing manifest to extend the archive
+	genesis := chain.Genesis().Hash()
+
+	manifest, err := ReadManifest(dirSource(dir))
+	switch {
+	case os.IsNotExist(err):
+		manifest = &Manifest{Version: Version, Genesis: genesis}
+	case err != nil:
+		return err
+	case manifest.Genesis != genesis:
+		return fmt.Errorf("archive genesis mismatch: have %x, want %x", manifest.Genesis, genesis)
+	}
+	// Split off the parts of existing segments outside of the exported range
+	var splits [][2]uint64
+	for _, old := range manifest.Segments {
+		if old.Last < first || old.First > last {
+			continue
+		}
+		if old.First < first {
+			splits = append(splits, [2]uint64{old.First, first - 1})
+		}
+		if old.Last > last {
+			if head := chain.CurrentFastBlock().NumberU64(); old.Last > head {
+				return fmt.Errorf("archive segment %s overlapping export range beyond head block #%d", old.File, head)
+			}
+			splits = append(splits, [2]uint64{last + 1, old.Last})
+		}
+	}
+	// Export the segments one by one and update the manifest with them
+	for start := first; start <= last; start += size {
+		end := start + size - 1
+		if end > last || end < start {
+			end = last
+		}
+		seg, err := exportSegment(chain, dir, start, end)
+		if err != nil {
+			return err
+		}
+		manifest.replace(seg)
+
+		if end == last {
+			break
+		}
+	}
+	for _, split := range splits {
+		seg, err := exportSegment(chain, dir, split[0], split[1])
+		if err != nil {
+			return err
+		}
+		manifest.replace(seg)
+	}
+	sort.Slice(manifest.Segments, func(i, j int) bool {
+		return manifest.Segments[i].First < manifest.Segments[j].First
+	})
+	return writeManifest(dir, manifest)
+}
+
+// replace adds a segment to the manifest, removing the ones it overlaps.
+func (m *Manifest) replace(seg *Segment) {
+	segments := m.Segments[:0]
+	for _, old := range m.Segments {
+		if old.Last < seg.First || old.First > seg.Last {
+			segments = append(segments, old)
+		}
+	}
+	m.Segments = append(segments, seg)
+}
+
+// exportSegment writes a single segment file, returning its manifest entry.
+func exportSegment(chain *core.BlockChain, dir string, first, last uint64) (*Segment, error) {
+	var (
+		seg    = &Segment{File: segmentName(first, last), First: first, Last: last}
+		path   = filepath.Join(dir, seg.File)
+		start  = time.Now()
+		hasher = sha256.New()
+	)
+	log.Info("Exporting archive segment", "file", seg.File)
+
+	fh, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path + ".tmp")
+	defer fh.Close()
+
+	buf := bufio.NewWriter(fh)
+	w := io.MultiWriter(buf, hasher)
+
+	if err := rlp.Encode(w, &segmentHeader{Version: Version, First: first, Last: last}); err != nil {
+		return nil, err
+	}
+	for number := first; number <= last; number++ {
+		block := chain.GetBlockByNumber(number)
+		if block == nil {
+			return nil, fmt.Errorf("export failed on #%d: not found", number)
+		}
+		td := chain.GetTd(block.Hash(), number)
+		if td == nil {
+			return nil, fmt.Errorf("export failed on #%d: total difficulty not found", number)
+		}
+		receipts := chain.GetReceiptsByHash(block.Hash())
+		if len(receipts) != len(block.Transactions()) {
+			return nil, fmt.Errorf("export failed on #%d: receipts not found", number)
+		}
+		entry := &segmentEntry{Block: block, Receipts: make([]*types.ReceiptForStorage, len(receipts)), TD: td}
+		for i, receipt := range receipts {
+			entry.Receipts[i] = (*types.ReceiptForStorage)(receipt)
+		}
+		if err := rlp.Encode(w, entry); err != nil {
+			return nil, err
+		}
+		seg.Accumulator = accumulate(seg.Accumulator, block.Hash(), td)
+	}
+	if err := buf.Flush(); err != nil {
+		return nil, err
+	}
+	if err := fh.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, err
+	}
+	seg.Checksum = checksum(hasher)
+
+	log.Info("Exported archive segment", "file", seg.File, "blocks", last-first+1, "checksum", seg.Checksum, "elapsed", common.PrettyDuration(time.Since(start)))
+	return seg, nil
+}
+
+// writeManifest atomically replaces the manifest of an archive directory.
+func writeManifest(dir string, manifest *Manifest) error {
+	blob, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, ManifestName)
+	if err := ioutil.WriteFile(path+".tmp", blob, 0644); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
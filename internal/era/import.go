@@ -0,0 +1,201 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/log"
+)
+
+// importBatchSize is the number of blocks executed at once when importing an
+// untrusted archive.
+const importBatchSize = 2500
+
+// errInterrupted is returned if an import is aborted via its stop channel.
+var errInterrupted = errors.New("import interrupted")
+
+// fetchResult is the outcome of retrieving and verifying a single segment.
+type fetchResult struct {
+	data *segmentData
+	err  error
+}
+
+// Import inserts the segments of an archive into the chain. Segments are fetched
+// and verified concurrently by the given number of workers, but inserted in order.
+//
+// Blocks of trusted archives are not executed: their headers are inserted and the
+// bodies are stored along with the receipts of the archive, the same way as fast
+// sync does. Blocks of untrusted archives are fully processed.
+func Import(chain *core.BlockChain, src Source, trusted bool, workers int, stop <-chan struct{}) error {
+	if workers <= 0 {
+		workers = 1
+	}
+	manifest, err := ReadManifest(src)
+	if err != nil {
+		return err
+	}
+	if genesis := chain.Genesis().Hash(); manifest.Genesis != genesis {
+		return fmt.Errorf("archive genesis mismatch: have %x, want %x", manifest.Genesis, genesis)
+	}
+	segments := make([]*Segment, len(manifest.Segments))
+	copy(segments, manifest.Segments)
+	sort.Slice(segments, func(i, j int) bool { return segments[i].First < segments[j].First })
+	for i := 1; i < len(segments); i++ {
+		if segments[i].First != segments[i-1].Last+1 {
+			return fmt.Errorf("archive segments not contiguous: %s follows %s", segments[i].File, segments[i-1].File)
+		}
+	}
+	// Skip the segments already imported into the chain
+	head := chain.CurrentBlock().NumberU64()
+	if trusted {
+		head = chain.CurrentFastBlock().NumberU64()
+	}
+	for len(segments) > 0 && segments[0].Last <= head {
+		segments = segments[1:]
+	}
+	if len(segments) == 0 {
+		log.Info("Archive already imported", "head", head)
+		return nil
+	}
+	if segments[0].First > head+1 {
+		return fmt.Errorf("archive starts at #%d, beyond local head #%d", segments[0].First, head)
+	}
+	// Fetch and verify the segments concurrently, limiting the number of segments
+	// held in memory to the number of workers
+	var (
+		results = make([]chan fetchResult, len(segments))
+		slots   = make(chan struct{}, workers)
+		quit    = make(chan struct{})
+	)
+	defer close(quit)
+
+	for i := range results {
+		results[i] = make(chan fetchResult, 1)
+	}
+	go func() {
+		for i, seg := range segments {
+			select {
+			case slots <- struct{}{}:
+			case <-quit:
+				return
+			}
+			go func(seg *Segment, res chan fetchResult) {
+				data, err := readSegment(src, seg)
+				res <- fetchResult{data, err}
+			}(seg, results[i])
+		}
+	}()
+	// Insert the segments in order as they become available
+	var (
+		parent = chain.GetHeaderByNumber(segments[0].First - 1)
+		start  = time.Now()
+	)
+	for i, seg := range segments {
+		var res fetchResult
+		select {
+		case res = <-results[i]:
+		case <-stop:
+			return errInterrupted
+		}
+		if res.err != nil {
+			return fmt.Errorf("segment %s: %v", seg.File, res.err)
+		}
+		if err := insertSegment(chain, seg, res.data, parent, trusted, stop); err != nil {
+			return fmt.Errorf("segment %s: %v", seg.File, err)
+		}
+		parent = res.data.blocks[len(res.data.blocks)-1].Header()
+		<-slots
+
+		log.Info("Imported archive segment", "file", seg.File, "blocks", len(res.data.blocks), "trusted", trusted, "elapsed", common.PrettyDuration(time.Since(start)))
+	}
+	return nil
+}
+
+// insertSegment inserts the verified content of a segment into the chain.
+func insertSegment(chain *core.BlockChain, seg *Segment, data *segmentData, parent *types.Header, trusted bool, stop <-chan struct{}) error {
+	blocks, receipts, tds := data.blocks, data.receipts, data.tds
+
+	// The genesis block is never imported, only matched against the local one
+	if seg.First == 0 {
+		if blocks[0].Hash() != chain.Genesis().Hash() {
+			return fmt.Errorf("genesis mismatch: have %x, want %x", blocks[0].Hash(), chain.Genesis().Hash())
+		}
+		blocks, receipts, tds = blocks[1:], receipts[1:], tds[1:]
+		parent = chain.Genesis().Header()
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	if parent == nil || blocks[0].ParentHash() != parent.Hash() {
+		return fmt.Errorf("block #%d not linked to the local chain", blocks[0].NumberU64())
+	}
+	if td := chain.GetTd(parent.Hash(), parent.Number.Uint64()); td == nil || new(big.Int).Add(td, blocks[0].Difficulty()).Cmp(tds[0]) != 0 {
+		return fmt.Errorf("total difficulty of block #%d doesn't match the local chain", blocks[0].NumberU64())
+	}
+	// Drop the blocks already present in the chain
+	for len(blocks) > 0 && isKnown(chain, blocks[0], trusted) {
+		blocks, receipts = blocks[1:], receipts[1:]
+	}
+	if len(blocks) == 0 {
+		return nil
+	}
+	if trusted {
+		headers := make([]*types.Header, len(blocks))
+		for i, block := range blocks {
+			headers[i] = block.Header()
+		}
+		if n, err := chain.InsertHeaderChain(headers, 100); err != nil {
+			return fmt.Errorf("invalid header #%d: %v", headers[n].Number, err)
+		}
+		if n, err := chain.InsertReceiptChain(blocks, receipts); err != nil {
+			return fmt.Errorf("invalid receipts of block #%d: %v", blocks[n].NumberU64(), err)
+		}
+		return nil
+	}
+	for len(blocks) > 0 {
+		select {
+		case <-stop:
+			return errInterrupted
+		default:
+		}
+		batch := blocks
+		if len(batch) > importBatchSize {
+			batch = batch[:importBatchSize]
+		}
+		if n, err := chain.InsertChain(batch); err != nil {
+			return fmt.Errorf("invalid block #%d: %v", batch[n].NumberU64(), err)
+		}
+		blocks = blocks[len(batch):]
+	}
+	return nil
+}
+
+// isKnown reports whether a block was already imported into the chain.
+func isKnown(chain *core.BlockChain, block *types.Block, trusted bool) bool {
+	if trusted {
+		return chain.HasBlock(block.Hash(), block.NumberU64())
+	}
+	return chain.HasBlockAndState(block.Hash(), block.NumberU64())
+}
@@ -0,0 +1,68 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package utesting
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRunTests(t *testing.T) {
+	tests := []Test{
+		{Name: "ok", Fn: func(t *T) { t.Log("output") }},
+		{Name: "fail", Fn: func(t *T) { t.Errorf("error %d", 1) }},
+		{Name: "fatal", Fn: func(t *T) {
+			t.Fatal("fatal")
+			t.Log("not reached")
+		}},
+		{Name: "panic", Fn: func(t *T) { panic("oops") }},
+	}
+	var report bytes.Buffer
+	results := RunTests(tests, &report)
+
+	if n := CountFailures(results); n != 3 {
+		t.Errorf("wrong failure count %d, want 3", n)
+	}
+	if results[0].Failed || results[0].Output != "output\n" {
+		t.Errorf("wrong result for passing test: %+v", results[0])
+	}
+	if !results[1].Failed || results[1].Output != "error 1\n" {
+		t.Errorf("wrong result for failing test: %+v", results[1])
+	}
+	if !results[2].Failed || results[2].Output != "fatal\n" {
+		t.Errorf("wrong result for fatal test: %+v", results[2])
+	}
+	if !results[3].Failed || !strings.HasPrefix(results[3].Output, "panic: oops") {
+		t.Errorf("wrong result for panicking test: %+v", results[3])
+	}
+	for _, name := range []string{"-- OK ok", "-- FAIL fail", "-- FAIL fatal", "-- FAIL panic"} {
+		if !strings.Contains(report.String(), name) {
+			t.Errorf("report does not contain %q", name)
+		}
+	}
+}
+
+func TestMatchTests(t *testing.T) {
+	tests := []Test{{Name: "Ping"}, {Name: "PingWrongTo"}, {Name: "Findnode"}}
+	if got := MatchTests(tests, "^Ping"); len(got) != 2 {
+		t.Errorf("wrong number of matches: %d", len(got))
+	}
+	if got := MatchTests(tests, "["); got != nil {
+		t.Errorf("invalid expression matched %d tests", len(got))
+	}
+}
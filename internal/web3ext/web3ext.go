@@ -25,6 +25,7 @@ var Modules = map[string]string{
 	"ethash":     EthashJs,
 	"debug":      DebugJs,
 	"eth":        EthJs,
+	"evm":        EvmJs,
 	"miner":      MinerJs,
 	"net":        NetJs,
 	"personal":   PersonalJs,
@@ -101,6 +102,23 @@ web3._extend({
 			call: 'clique_discard',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'status',
+			call: 'clique_status',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'getSigner',
+			call: 'clique_getSigner',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'getTallyHistory',
+			call: 'clique_getTallyHistory',
+			params: 1,
+			inputFormatter: [null]
+		}),
 	],
 	properties: [
 		new web3._extend.Property({
@@ -135,6 +153,71 @@ web3._extend({
 			call: 'ethash_submitHashRate',
 			params: 2,
 		}),
+		new web3._extend.Method({
+			name: 'getWorkers',
+			call: 'ethash_getWorkers',
+			params: 0
+		}),
+	]
+});
+`
+
+const EvmJs = `
+web3._extend({
+	property: 'evm',
+	methods: [
+		new web3._extend.Method({
+			name: 'mine',
+			call: 'evm_mine',
+			params: 1,
+			inputFormatter: [null]
+		}),
+		new web3._extend.Method({
+			name: 'increaseTime',
+			call: 'evm_increaseTime',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'setNextBlockTimestamp',
+			call: 'evm_setNextBlockTimestamp',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'setAutomine',
+			call: 'evm_setAutomine',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'snapshot',
+			call: 'evm_snapshot',
+			outputFormatter: web3._extend.utils.toDecimal
+		}),
+		new web3._extend.Method({
+			name: 'revert',
+			call: 'evm_revert',
+			params: 1,
+			inputFormatter: [web3._extend.utils.fromDecimal]
+		}),
+		new web3._extend.Method({
+			name: 'impersonateAccount',
+			call: 'evm_impersonateAccount',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'stopImpersonatingAccount',
+			call: 'evm_stopImpersonatingAccount',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputAddressFormatter]
+		}),
+		new web3._extend.Method({
+			name: 'sendTransaction',
+			call: 'evm_sendTransaction',
+			params: 1,
+			inputFormatter: [web3._extend.formatters.inputTransactionFormatter]
+		}),
 	]
 });
 `
@@ -163,6 +246,22 @@ web3._extend({
 			call: 'admin_removeTrustedPeer',
 			params: 1
 		}),
+		new web3._extend.Method({
+			name: 'banPeer',
+			call: 'admin_banPeer',
+			// The duration and reason may be omitted, the formatters pad them with null.
+			params: 3,
+			inputFormatter: [null, null, null]
+		}),
+		new web3._extend.Method({
+			name: 'unbanPeer',
+			call: 'admin_unbanPeer',
+			params: 1
+		}),
+		new web3._extend.Method({
+			name: 'listBans',
+			call: 'admin_listBans'
+		}),
 		new web3._extend.Method({
 			name: 'exportChain',
 			call: 'admin_exportChain',
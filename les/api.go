@@ -32,6 +32,9 @@ var (
 	ErrMinCap               = errors.New("capacity too small")
 	ErrTotalCap             = errors.New("total capacity exceeded")
 	ErrUnknownBenchmarkType = errors.New("unknown benchmark type")
+	ErrNoCheckpoint         = errors.New("no local checkpoint provided")
+	ErrNotActivated         = errors.New("checkpoint registrar is not activated")
+	ErrNoBalances           = errors.New("client balances are not tracked")
 
 	dropCapacityDelay = time.Second // delay applied to decreasing capacity changes
 )
@@ -49,6 +52,41 @@ func NewPrivateLightServerAPI(server *LesServer) *PrivateLightServerAPI {
 	}
 }
 
+// LatestCheckpoint returns the latest local checkpoint package, consisting of
+// the hex encoded section index, section head hash, canonical hash trie root
+// and bloom trie root.
+func (api *PrivateLightServerAPI) LatestCheckpoint() ([4]string, error) {
+	var res [4]string
+	cp := api.server.latestLocalCheckpoint()
+	if cp.Empty() {
+		return res, ErrNoCheckpoint
+	}
+	res[0] = hexutil.EncodeUint64(cp.SectionIndex)
+	res[1], res[2], res[3] = cp.SectionHead.Hex(), cp.CHTRoot.Hex(), cp.BloomRoot.Hex()
+	return res, nil
+}
+
+// GetCheckpoint returns the local checkpoint package of the given section,
+// consisting of the hex encoded section head hash, canonical hash trie root
+// and bloom trie root.
+func (api *PrivateLightServerAPI) GetCheckpoint(index uint64) ([3]string, error) {
+	var res [3]string
+	cp := api.server.getLocalCheckpoint(index)
+	if cp.Empty() {
+		return res, ErrNoCheckpoint
+	}
+	res[0], res[1], res[2] = cp.SectionHead.Hex(), cp.CHTRoot.Hex(), cp.BloomRoot.Hex()
+	return res, nil
+}
+
+// GetCheckpointContractAddress returns the checkpoint oracle address in hex format.
+func (api *PrivateLightServerAPI) GetCheckpointContractAddress() (string, error) {
+	if api.server.oracle == nil {
+		return "", ErrNotActivated
+	}
+	return api.server.oracle.config.Address.Hex(), nil
+}
+
 // TotalCapacity queries total available capacity for all clients
 func (api *PrivateLightServerAPI) TotalCapacity() hexutil.Uint64 {
 	return hexutil.Uint64(api.server.priorityClientPool.totalCapacity())
@@ -129,6 +167,45 @@ func (api *PrivateLightServerAPI) GetClientCapacity(id enode.ID) hexutil.Uint64
 	return hexutil.Uint64(api.server.priorityClientPool.clients[id].cap)
 }
 
+// AddBalance credits the given amount to the token balance of a client, paying
+// off its negative balance first. A client with a positive balance is granted a
+// priority connection and capacity until its balance is spent. The positive
+// balance before and after the update is returned.
+func (api *PrivateLightServerAPI) AddBalance(id enode.ID, amount uint64) ([2]hexutil.Uint64, error) {
+	pool := api.server.priorityClientPool
+	if pool == nil || pool.balances == nil {
+		return [2]hexutil.Uint64{}, ErrNoBalances
+	}
+	old, balance := pool.balances.addBalance(id, amount)
+	if balance > 0 {
+		pool.balanceCredited(id)
+	}
+	return [2]hexutil.Uint64{hexutil.Uint64(old), hexutil.Uint64(balance)}, nil
+}
+
+// GetBalance returns the positive and negative token balance of a client.
+func (api *PrivateLightServerAPI) GetBalance(id enode.ID) (map[string]hexutil.Uint64, error) {
+	pool := api.server.priorityClientPool
+	if pool == nil || pool.balances == nil {
+		return nil, ErrNoBalances
+	}
+	pos, neg := pool.balances.getBalance(id)
+	return map[string]hexutil.Uint64{"positive": hexutil.Uint64(pos), "negative": hexutil.Uint64(neg)}, nil
+}
+
+// SetPriceFactors sets the prices charged from the positive balance of paying
+// clients and accumulated as negative balance for free clients: TimeFactor per
+// second of connection, CapacityFactor per second and unit of capacity and
+// RequestFactor per unit of request serving cost.
+func (api *PrivateLightServerAPI) SetPriceFactors(positive, negative priceFactors) error {
+	pool := api.server.priorityClientPool
+	if pool == nil || pool.balances == nil {
+		return ErrNoBalances
+	}
+	pool.balances.setPriceFactors(positive, negative)
+	return nil
+}
+
 // clientPool is implemented by both the free and priority client pools
 type clientPool interface {
 	peerSetNotify
@@ -141,6 +218,7 @@ type priorityClientPool struct {
 	child                            clientPool
 	ps                               *peerSet
 	clients                          map[enode.ID]priorityClientInfo
+	balances                         *balanceTracker // nil if client balances are not tracked
 	totalCap, totalCapAnnounced      uint64
 	totalConnectedCap, freeClientCap uint64
 	maxPeers, priorityCount          int
@@ -159,6 +237,7 @@ type scheduledUpdate struct {
 // priorityClientInfo entries exist for all prioritized clients and currently connected non-priority clients
 type priorityClientInfo struct {
 	cap       uint64 // zero for non-priority clients
+	paid      bool   // priority granted by a positive balance instead of an assigned capacity
 	connected bool
 	peer      *peer
 }
@@ -188,6 +267,13 @@ func (v *priorityClientPool) registerPeer(p *peer) {
 	if c.connected {
 		return
 	}
+	// Clients without an assigned capacity are prioritised while they can pay for it
+	if v.balances != nil {
+		paying := v.balances.connect(id, v.freeClientCap)
+		if c.cap == 0 && paying && v.totalConnectedCap+v.freeClientCap <= v.totalCap {
+			c.cap, c.paid = v.freeClientCap, true
+		}
+	}
 	if c.cap == 0 && v.child != nil {
 		v.child.registerPeer(p)
 	}
@@ -206,6 +292,9 @@ func (v *priorityClientPool) registerPeer(p *peer) {
 			v.child.setLimits(v.maxPeers-v.priorityCount, v.totalCap-v.totalConnectedCap)
 		}
 		p.updateCapacity(c.cap)
+		if v.balances != nil {
+			v.balances.setCapacity(id, c.cap)
+		}
 	}
 }
 
@@ -216,13 +305,20 @@ func (v *priorityClientPool) unregisterPeer(p *peer) {
 	defer v.lock.Unlock()
 
 	id := p.ID()
+	if v.balances != nil {
+		v.balances.disconnect(id)
+	}
 	c := v.clients[id]
 	if !c.connected {
 		return
 	}
 	if c.cap != 0 {
 		c.connected = false
-		v.clients[id] = c
+		if c.paid {
+			delete(v.clients, id)
+		} else {
+			v.clients[id] = c
+		}
 		v.priorityCount--
 		v.totalConnectedCap -= c.cap
 		if v.child != nil {
@@ -338,8 +434,41 @@ func (v *priorityClientPool) setClientCapacity(id enode.ID, cap uint64) error {
 	v.lock.Lock()
 	defer v.lock.Unlock()
 
+	return v.setCapacity(id, cap, false)
+}
+
+// balanceCredited grants priority to a connected free client that just got a
+// positive balance, if the total capacity allows it.
+func (v *priorityClientPool) balanceCredited(id enode.ID) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if c := v.clients[id]; c.connected && c.cap == 0 {
+		v.setCapacity(id, v.freeClientCap, true)
+	}
+}
+
+// balanceDepleted revokes the priority of a paying client whose balance ran out.
+func (v *priorityClientPool) balanceDepleted(id enode.ID) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if c := v.clients[id]; c.paid {
+		v.setCapacity(id, 0, false)
+	}
+}
+
+// setCapacity sets the priority capacity of a given client, either assigned
+// explicitly or granted by a positive balance.
+//
+// The caller must hold the pool lock.
+func (v *priorityClientPool) setCapacity(id enode.ID, cap uint64, paid bool) error {
 	c := v.clients[id]
 	if c.cap == cap {
+		c.paid = c.paid && paid
+		if cap != 0 {
+			v.clients[id] = c
+		}
 		return nil
 	}
 	if c.connected {
@@ -367,9 +496,16 @@ func (v *priorityClientPool) setClientCapacity(id enode.ID, cap uint64) error {
 		} else {
 			c.peer.updateCapacity(cap)
 		}
+		if v.balances != nil {
+			if cap == 0 {
+				v.balances.setCapacity(id, v.freeClientCap)
+			} else {
+				v.balances.setCapacity(id, cap)
+			}
+		}
 	}
 	if cap != 0 || c.connected {
-		c.cap = cap
+		c.cap, c.paid = cap, paid
 		v.clients[id] = c
 	} else {
 		delete(v.clients, id)
@@ -95,12 +95,31 @@ func (b *LesApiBackend) GetReceipts(ctx context.Context, hash common.Hash) (type
 }
 
 func (b *LesApiBackend) GetLogs(ctx context.Context, hash common.Hash) ([][]*types.Log, error) {
-	if number := rawdb.ReadHeaderNumber(b.eth.chainDb, hash); number != nil {
-		return light.GetBlockLogs(ctx, b.eth.odr, hash, *number)
+	if header := b.eth.blockchain.GetHeaderByHash(hash); header != nil {
+		logs, err := b.GetLogsBatch(ctx, []*types.Header{header})
+		if err != nil {
+			return nil, err
+		}
+		return logs[0], nil
 	}
 	return nil, nil
 }
 
+func (b *LesApiBackend) GetLogsBatch(ctx context.Context, headers []*types.Header) ([][][]*types.Log, error) {
+	receipts, err := b.eth.blockchain.GetReceiptsRange(ctx, headers)
+	if err != nil {
+		return nil, err
+	}
+	logs := make([][][]*types.Log, len(receipts))
+	for i, blockReceipts := range receipts {
+		logs[i] = make([][]*types.Log, len(blockReceipts))
+		for j, receipt := range blockReceipts {
+			logs[i][j] = receipt.Logs
+		}
+	}
+	return logs, nil
+}
+
 func (b *LesApiBackend) GetTd(hash common.Hash) *big.Int {
 	return b.eth.blockchain.GetTdByHash(hash)
 }
@@ -119,6 +138,10 @@ func (b *LesApiBackend) RemoveTx(txHash common.Hash) {
 	b.eth.txPool.RemoveTx(txHash)
 }
 
+func (b *LesApiBackend) GetTransaction(ctx context.Context, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	return light.GetTransaction(ctx, b.eth.odr, txHash)
+}
+
 func (b *LesApiBackend) GetPoolTransactions() (types.Transactions, error) {
 	return b.eth.txPool.GetTransactions()
 }
@@ -162,6 +162,9 @@ func New(ctx *node.ServiceContext, config *eth.Config) (*LightEthereum, error) {
 		return nil, err
 	}
 
+	leth.oracle = newCheckpointOracle(config.CheckpointOracle, leth.getLocalCheckpoint)
+	leth.protocolManager.oracle = leth.oracle
+
 	if leth.protocolManager.isULCEnabled() {
 		log.Warn("Ultra light client is enabled", "trustedNodes", len(leth.protocolManager.ulc.trustedKeys), "minTrustedFraction", leth.protocolManager.ulc.minTrustedFraction)
 		leth.blockchain.DisableCheckFreq()
@@ -0,0 +1,327 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	balanceDbPrefix      = "_clientBalance-" // database key prefix of the client balances
+	balanceCheckInterval = time.Second * 10  // interval of charging the connected clients
+	negBalanceExpTC      = time.Hour         // time constant of the exponential decay of negative balances
+)
+
+// priceFactors determine the amount charged from a client's balance for being
+// connected and served: TimeFactor is charged per second of connection time,
+// CapacityFactor per second and unit of assigned capacity and RequestFactor
+// per unit of the real cost of the served requests.
+type priceFactors struct {
+	TimeFactor, CapacityFactor, RequestFactor float64
+}
+
+// defaultPriceFactors charges the clients for the real cost of their requests.
+var defaultPriceFactors = priceFactors{RequestFactor: 1}
+
+// tokenBalance is the RLP representation of a client's persisted balance.
+type tokenBalance struct {
+	Positive, Negative uint64
+}
+
+// balanceEntry is the in-memory state of a connected client's balance.
+type balanceEntry struct {
+	balance    tokenBalance
+	paying     bool           // whether the client is prioritised for its positive balance
+	capacity   uint64         // capacity currently assigned to the client
+	lastCharge mclock.AbsTime // last time the connection time was charged
+}
+
+// balanceTracker keeps track of the token balances of LES clients. Clients with
+// a positive balance are paying customers: their balance is spent while they
+// are connected and served, and they are granted priority while it lasts. The
+// service consumed by free clients is accounted as a negative balance, which
+// decays exponentially over time and has to be paid off before a positive
+// balance can be accumulated.
+//
+// Note: negative balances only decay while the server is running.
+type balanceTracker struct {
+	lock      sync.Mutex
+	db        ethdb.Database
+	clock     mclock.Clock
+	connected map[enode.ID]*balanceEntry
+
+	posFactors, negFactors priceFactors
+
+	onDepleted func(id enode.ID) // Called when the positive balance of a connected client runs out
+	quit       chan struct{}
+}
+
+// newBalanceTracker creates a balance tracker persisting the balances in the
+// given database, and starts charging the connected clients periodically.
+func newBalanceTracker(db ethdb.Database, clock mclock.Clock, onDepleted func(id enode.ID)) *balanceTracker {
+	bt := &balanceTracker{
+		db:         db,
+		clock:      clock,
+		connected:  make(map[enode.ID]*balanceEntry),
+		posFactors: defaultPriceFactors,
+		negFactors: defaultPriceFactors,
+		onDepleted: onDepleted,
+		quit:       make(chan struct{}),
+	}
+	go bt.loop()
+	return bt
+}
+
+// stop charges the connected clients up to now, persists their balances and
+// terminates the charging loop.
+func (bt *balanceTracker) stop() {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+
+	now := bt.clock.Now()
+	for id, e := range bt.connected {
+		bt.charge(e, now)
+		bt.storeBalance(id, e.balance)
+	}
+	close(bt.quit)
+}
+
+// loop periodically charges the connected clients for their connection time.
+func (bt *balanceTracker) loop() {
+	for {
+		select {
+		case <-bt.clock.After(balanceCheckInterval):
+			bt.chargeAll()
+		case <-bt.quit:
+			return
+		}
+	}
+}
+
+// chargeAll charges every connected client up to now, notifying about the
+// paying ones that ran out of balance.
+func (bt *balanceTracker) chargeAll() {
+	bt.lock.Lock()
+	var (
+		now      = bt.clock.Now()
+		depleted []enode.ID
+	)
+	for id, e := range bt.connected {
+		bt.charge(e, now)
+		if e.paying && e.balance.Positive == 0 {
+			e.paying = false
+			depleted = append(depleted, id)
+		}
+	}
+	bt.lock.Unlock()
+
+	for _, id := range depleted {
+		bt.onDepleted(id)
+	}
+}
+
+// setPriceFactors sets the prices charged from positive and negative balances.
+func (bt *balanceTracker) setPriceFactors(pos, neg priceFactors) {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+
+	now := bt.clock.Now()
+	for _, e := range bt.connected {
+		bt.charge(e, now)
+	}
+	bt.posFactors, bt.negFactors = pos, neg
+}
+
+// connect starts tracking the balance of a newly connected client with the
+// given initial capacity, returning whether the client has a positive balance.
+func (bt *balanceTracker) connect(id enode.ID, capacity uint64) bool {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+
+	e := &balanceEntry{
+		balance:    bt.loadBalance(id),
+		capacity:   capacity,
+		lastCharge: bt.clock.Now(),
+	}
+	e.paying = e.balance.Positive > 0
+	bt.connected[id] = e
+	return e.paying
+}
+
+// disconnect charges a client up to now and persists its balance.
+func (bt *balanceTracker) disconnect(id enode.ID) {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+
+	if e := bt.connected[id]; e != nil {
+		bt.charge(e, bt.clock.Now())
+		bt.storeBalance(id, e.balance)
+		delete(bt.connected, id)
+	}
+}
+
+// setCapacity updates the capacity a connected client is charged for.
+func (bt *balanceTracker) setCapacity(id enode.ID, capacity uint64) {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+
+	if e := bt.connected[id]; e != nil {
+		bt.charge(e, bt.clock.Now())
+		e.capacity = capacity
+	}
+}
+
+// requestCost charges a connected client for a served request.
+func (bt *balanceTracker) requestCost(id enode.ID, cost uint64) {
+	bt.lock.Lock()
+	e := bt.connected[id]
+	if e == nil {
+		bt.lock.Unlock()
+		return
+	}
+	if e.balance.Positive > 0 {
+		e.balance.Positive = subBalance(e.balance.Positive, bt.posFactors.RequestFactor*float64(cost))
+	} else {
+		e.balance.Negative = addBalance(e.balance.Negative, bt.negFactors.RequestFactor*float64(cost))
+	}
+	depleted := e.paying && e.balance.Positive == 0
+	if depleted {
+		e.paying = false
+	}
+	bt.lock.Unlock()
+
+	if depleted {
+		bt.onDepleted(id)
+	}
+}
+
+// getBalance returns the current positive and negative balance of a client.
+func (bt *balanceTracker) getBalance(id enode.ID) (uint64, uint64) {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+
+	if e := bt.connected[id]; e != nil {
+		bt.charge(e, bt.clock.Now())
+		return e.balance.Positive, e.balance.Negative
+	}
+	balance := bt.loadBalance(id)
+	return balance.Positive, balance.Negative
+}
+
+// addBalance credits the given amount to a client, paying off any negative
+// balance first. It returns the positive balance before and after the update.
+func (bt *balanceTracker) addBalance(id enode.ID, amount uint64) (uint64, uint64) {
+	bt.lock.Lock()
+	defer bt.lock.Unlock()
+
+	e := bt.connected[id]
+	if e != nil {
+		bt.charge(e, bt.clock.Now())
+	} else {
+		e = &balanceEntry{balance: bt.loadBalance(id)}
+	}
+	old := e.balance.Positive
+	if amount <= e.balance.Negative {
+		e.balance.Negative -= amount
+	} else {
+		amount -= e.balance.Negative
+		e.balance.Negative = 0
+		if e.balance.Positive+amount < e.balance.Positive {
+			e.balance.Positive = math.MaxUint64
+		} else {
+			e.balance.Positive += amount
+		}
+	}
+	e.paying = e.balance.Positive > 0
+	bt.storeBalance(id, e.balance)
+	return old, e.balance.Positive
+}
+
+// charge updates a client's balance with the cost of its connection time since
+// the last charge, also decaying its negative balance.
+//
+// The caller must hold the tracker lock.
+func (bt *balanceTracker) charge(e *balanceEntry, now mclock.AbsTime) {
+	dt := time.Duration(now - e.lastCharge)
+	if dt <= 0 {
+		return
+	}
+	e.lastCharge = now
+
+	if e.balance.Negative > 0 {
+		e.balance.Negative = uint64(float64(e.balance.Negative) * math.Exp(-float64(dt)/float64(negBalanceExpTC)))
+	}
+	if e.balance.Positive > 0 {
+		factors := bt.posFactors
+		e.balance.Positive = subBalance(e.balance.Positive, dt.Seconds()*(factors.TimeFactor+factors.CapacityFactor*float64(e.capacity)))
+		return
+	}
+	factors := bt.negFactors
+	e.balance.Negative = addBalance(e.balance.Negative, dt.Seconds()*(factors.TimeFactor+factors.CapacityFactor*float64(e.capacity)))
+}
+
+// loadBalance retrieves a client's balance from the database.
+func (bt *balanceTracker) loadBalance(id enode.ID) tokenBalance {
+	var balance tokenBalance
+	enc, err := bt.db.Get(append([]byte(balanceDbPrefix), id.Bytes()...))
+	if err != nil {
+		return balance
+	}
+	if err := rlp.DecodeBytes(enc, &balance); err != nil {
+		log.Error("Failed to decode client balance", "id", id, "err", err)
+	}
+	return balance
+}
+
+// storeBalance persists a client's balance, deleting it if empty.
+func (bt *balanceTracker) storeBalance(id enode.ID, balance tokenBalance) {
+	key := append([]byte(balanceDbPrefix), id.Bytes()...)
+	if balance == (tokenBalance{}) {
+		bt.db.Delete(key)
+		return
+	}
+	enc, err := rlp.EncodeToBytes(balance)
+	if err != nil {
+		log.Error("Failed to encode client balance", "id", id, "err", err)
+		return
+	}
+	bt.db.Put(key, enc)
+}
+
+// addBalance adds a charge to a balance, saturating at the maximum.
+func addBalance(balance uint64, charge float64) uint64 {
+	if charge >= float64(math.MaxUint64-balance) {
+		return math.MaxUint64
+	}
+	return balance + uint64(charge)
+}
+
+// subBalance subtracts a charge from a balance, saturating at zero.
+func subBalance(balance uint64, charge float64) uint64 {
+	if charge >= float64(balance) {
+		return 0
+	}
+	return balance - uint64(charge)
+}
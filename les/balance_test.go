@@ -0,0 +1,89 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+func TestBalanceTracker(t *testing.T) {
+	var (
+		clock    mclock.Simulated
+		db       = rawdb.NewMemoryDatabase()
+		id       = enode.ID{0x01}
+		depleted = make(chan enode.ID, 1)
+		bt       = newBalanceTracker(db, &clock, func(id enode.ID) { depleted <- id })
+	)
+	bt.setPriceFactors(priceFactors{TimeFactor: 1}, priceFactors{TimeFactor: 2, RequestFactor: 1})
+
+	// Free clients accumulate a negative balance for their connection time and requests
+	if bt.connect(id, 1) {
+		t.Fatalf("unknown client reported paying")
+	}
+	clock.Run(5 * time.Second)
+	if pos, neg := bt.getBalance(id); pos != 0 || neg != 10 {
+		t.Fatalf("free client balance mismatch: have %d/%d, want %d/%d", pos, neg, 0, 10)
+	}
+	bt.requestCost(id, 10)
+	if pos, neg := bt.getBalance(id); pos != 0 || neg != 20 {
+		t.Fatalf("free client balance mismatch: have %d/%d, want %d/%d", pos, neg, 0, 20)
+	}
+	// Credits pay off the negative balance first
+	if old, pos := bt.addBalance(id, 15); old != 0 || pos != 0 {
+		t.Fatalf("positive balance mismatch: have %d->%d, want %d->%d", old, pos, 0, 0)
+	}
+	if old, pos := bt.addBalance(id, 105); old != 0 || pos != 100 {
+		t.Fatalf("positive balance mismatch: have %d->%d, want %d->%d", old, pos, 0, 100)
+	}
+	// Paying clients spend their balance and get notified when it runs out
+	bt.disconnect(id)
+	if !bt.connect(id, 1) {
+		t.Fatalf("client with positive balance not reported paying")
+	}
+	clock.Run(99 * time.Second)
+	bt.chargeAll()
+	select {
+	case <-depleted:
+		t.Fatalf("balance depleted too early")
+	case <-time.After(100 * time.Millisecond):
+	}
+	clock.Run(10 * time.Second)
+	bt.chargeAll()
+	select {
+	case got := <-depleted:
+		if got != id {
+			t.Fatalf("depleted client mismatch: have %x, want %x", got, id)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("depleted balance not reported")
+	}
+	// Balances are persisted across restarts
+	bt.addBalance(id, 50)
+	bt.stop()
+
+	bt = newBalanceTracker(db, &clock, func(enode.ID) {})
+	defer bt.stop()
+
+	if pos, neg := bt.getBalance(id); pos != 50 || neg != 0 {
+		t.Fatalf("persisted balance mismatch: have %d/%d, want %d/%d", pos, neg, 50, 0)
+	}
+}
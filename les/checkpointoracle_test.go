@@ -0,0 +1,81 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package les
+
+import (
+	"crypto/ecdsa"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/contracts/checkpointoracle"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// Tests that checkpoint approvals are only accepted if enough distinct trusted
+// signers voted for them.
+func TestCheckpointSignerVerification(t *testing.T) {
+	var (
+		keys    []*ecdsa.PrivateKey
+		signers []common.Address
+	)
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateKey()
+		keys = append(keys, key)
+		signers = append(signers, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	config := &params.CheckpointOracleConfig{
+		Address:   common.HexToAddress("0x0102030405060708090a0b0c0d0e0f1011121314"),
+		Signers:   signers[:2],
+		Threshold: 2,
+	}
+	oracle := newCheckpointOracle(config, nil)
+
+	checkpoint := params.TrustedCheckpoint{
+		SectionIndex: 1,
+		SectionHead:  common.HexToHash("0x01"),
+		CHTRoot:      common.HexToHash("0x02"),
+		BloomRoot:    common.HexToHash("0x03"),
+	}
+	sign := func(key *ecdsa.PrivateKey) []byte {
+		sig, err := crypto.Sign(checkpointoracle.SignedHash(config.Address, checkpoint.SectionIndex, checkpoint.Hash()).Bytes(), key)
+		if err != nil {
+			t.Fatalf("failed to sign checkpoint: %v", err)
+		}
+		sig[64] += 27
+		return sig
+	}
+	tests := []struct {
+		sigs  [][]byte
+		valid bool
+	}{
+		{[][]byte{sign(keys[0])}, false},                              // Below threshold
+		{[][]byte{sign(keys[0]), sign(keys[0])}, false},               // Duplicate signer
+		{[][]byte{sign(keys[0]), sign(keys[2])}, false},               // Untrusted signer
+		{[][]byte{sign(keys[1]), sign(keys[0])}, true},                // Enough trusted signers
+		{[][]byte{sign(keys[2]), sign(keys[0]), sign(keys[1])}, true}, // Untrusted signer ignored
+	}
+	for i, tt := range tests {
+		valid, approvers := oracle.verifySigners(checkpoint.SectionIndex, checkpoint.Hash(), tt.sigs)
+		if valid != tt.valid {
+			t.Errorf("test %d: validity mismatch: have %v, want %v", i, valid, tt.valid)
+		}
+		if valid && len(approvers) != 2 {
+			t.Errorf("test %d: approver count mismatch: have %d, want %d", i, len(approvers), 2)
+		}
+	}
+}
@@ -37,6 +37,7 @@ type lesCommons struct {
 	chainDb                      ethdb.Database
 	protocolManager              *ProtocolManager
 	chtIndexer, bloomTrieIndexer *core.ChainIndexer
+	oracle                       *checkpointOracle
 }
 
 // NodeInfo represents a short summary of the Ethereum sub-protocol metadata
@@ -76,7 +77,25 @@ func (c *lesCommons) makeProtocols(versions []uint) []p2p.Protocol {
 
 // nodeInfo retrieves some protocol metadata about the running host node.
 func (c *lesCommons) nodeInfo() interface{} {
-	var cht params.TrustedCheckpoint
+	cht := c.latestLocalCheckpoint()
+
+	chain := c.protocolManager.blockchain
+	head := chain.CurrentHeader()
+	hash := head.Hash()
+	return &NodeInfo{
+		Network:    c.config.NetworkId,
+		Difficulty: chain.GetTd(hash, head.Number.Uint64()),
+		Genesis:    chain.Genesis().Hash(),
+		Config:     chain.Config(),
+		Head:       chain.CurrentHeader().Hash(),
+		CHT:        cht,
+	}
+}
+
+// latestLocalCheckpoint finds the common stored section index and returns a set
+// of post-processed trie roots (CHT and BloomTrie) associated with it, or an
+// empty checkpoint if no section is available yet.
+func (c *lesCommons) latestLocalCheckpoint() params.TrustedCheckpoint {
 	sections, _, _ := c.chtIndexer.Sections()
 	sections2, _, _ := c.bloomTrieIndexer.Sections()
 
@@ -84,37 +103,30 @@ func (c *lesCommons) nodeInfo() interface{} {
 		// convert to client section size if running in server mode
 		sections /= c.iConfig.PairChtSize / c.iConfig.ChtSize
 	}
-
 	if sections2 < sections {
 		sections = sections2
 	}
-	if sections > 0 {
-		sectionIndex := sections - 1
-		sectionHead := c.bloomTrieIndexer.SectionHead(sectionIndex)
-		var chtRoot common.Hash
-		if c.protocolManager.lightSync {
-			chtRoot = light.GetChtRoot(c.chainDb, sectionIndex, sectionHead)
-		} else {
-			idxV2 := (sectionIndex+1)*c.iConfig.PairChtSize/c.iConfig.ChtSize - 1
-			chtRoot = light.GetChtRoot(c.chainDb, idxV2, sectionHead)
-		}
-		cht = params.TrustedCheckpoint{
-			SectionIndex: sectionIndex,
-			SectionHead:  sectionHead,
-			CHTRoot:      chtRoot,
-			BloomRoot:    light.GetBloomTrieRoot(c.chainDb, sectionIndex, sectionHead),
-		}
+	if sections == 0 {
+		return params.TrustedCheckpoint{}
 	}
+	return c.getLocalCheckpoint(sections - 1)
+}
 
-	chain := c.protocolManager.blockchain
-	head := chain.CurrentHeader()
-	hash := head.Hash()
-	return &NodeInfo{
-		Network:    c.config.NetworkId,
-		Difficulty: chain.GetTd(hash, head.Number.Uint64()),
-		Genesis:    chain.Genesis().Hash(),
-		Config:     chain.Config(),
-		Head:       chain.CurrentHeader().Hash(),
-		CHT:        cht,
+// getLocalCheckpoint returns a set of post-processed trie roots (CHT and BloomTrie)
+// associated with the given section index, as generated by the local indexers.
+func (c *lesCommons) getLocalCheckpoint(sectionIndex uint64) params.TrustedCheckpoint {
+	sectionHead := c.bloomTrieIndexer.SectionHead(sectionIndex)
+	var chtRoot common.Hash
+	if c.protocolManager.lightSync {
+		chtRoot = light.GetChtRoot(c.chainDb, sectionIndex, sectionHead)
+	} else {
+		idxV2 := (sectionIndex+1)*c.iConfig.PairChtSize/c.iConfig.ChtSize - 1
+		chtRoot = light.GetChtRoot(c.chainDb, idxV2, sectionHead)
+	}
+	return params.TrustedCheckpoint{
+		SectionIndex: sectionIndex,
+		SectionHead:  sectionHead,
+		CHTRoot:      chtRoot,
+		BloomRoot:    light.GetBloomTrieRoot(c.chainDb, sectionIndex, sectionHead),
 	}
 }
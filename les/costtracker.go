@@ -46,6 +46,7 @@ var (
 		SendTxMsg:              {0, 450000},
 		SendTxV2Msg:            {0, 450000},
 		GetTxStatusMsg:         {0, 250000},
+		GetTxLookupMsg:         {0, 500000},
 	}
 	// maximum incoming message size estimates
 	reqMaxInSize = requestCostTable{
@@ -60,6 +61,7 @@ var (
 		SendTxMsg:              {0, 66000},
 		SendTxV2Msg:            {0, 66000},
 		GetTxStatusMsg:         {0, 50},
+		GetTxLookupMsg:         {0, 50},
 	}
 	// maximum outgoing message size estimates
 	reqMaxOutSize = requestCostTable{
@@ -74,6 +76,7 @@ var (
 		SendTxMsg:              {0, 0},
 		SendTxV2Msg:            {0, 100},
 		GetTxStatusMsg:         {0, 100},
+		GetTxLookupMsg:         {0, 4500},
 	}
 	minBufLimit = uint64(50000000 * maxCostFactor)  // minimum buffer limit allowed for a client
 	minCapacity = (minBufLimit-1)/bufLimitRatio + 1 // minimum capacity allowed for a client
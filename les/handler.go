@@ -17,6 +17,7 @@
 package les
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -57,6 +58,7 @@ const (
 	MaxHelperTrieProofsFetch = 64  // Amount of merkle proofs to be fetched per retrieval request
 	MaxTxSend                = 64  // Amount of transactions to be send per request
 	MaxTxStatus              = 256 // Amount of transactions to queried per request
+	MaxTxLookup              = 64  // Amount of transaction positions to be proven per request
 
 	disableClientRemovePeer = false
 )
@@ -102,6 +104,7 @@ type ProtocolManager struct {
 	reqDist      *requestDistributor
 	retriever    *retrieveManager
 	servingQueue *servingQueue
+	oracle       *checkpointOracle // nil if the checkpoint registrar is not configured
 
 	downloader *downloader.Downloader
 	fetcher    *lightFetcher
@@ -391,6 +394,9 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 			realCost = maxCost
 		}
 		bv := p.fcClient.RequestProcessed(reqID, responseCount, maxCost, realCost)
+		if pm.server.balances != nil {
+			pm.server.balances.requestCost(p.ID(), realCost)
+		}
 		if reply != nil {
 			p.queueSend(func() {
 				if err := reply.send(bv); err != nil {
@@ -1223,6 +1229,60 @@ func (pm *ProtocolManager) handleMsg(p *peer) error {
 
 		p.fcServer.ReceivedReply(resp.ReqID, resp.BV)
 
+	case GetTxLookupMsg:
+		p.Log().Trace("Received transaction lookup request")
+		// Decode the retrieval message
+		var req struct {
+			ReqID  uint64
+			Hashes []common.Hash
+		}
+		if err := msg.Decode(&req); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+		reqCnt := len(req.Hashes)
+		if !accept(req.ReqID, uint64(reqCnt), MaxTxLookup) {
+			return errResp(ErrRequestRejected, "")
+		}
+		go func() {
+			var (
+				bytes   int
+				lookups = make([]txLookup, 0, len(req.Hashes))
+			)
+			for i, hash := range req.Hashes {
+				if i != 0 && !task.waitOrStop() {
+					return
+				}
+				if bytes >= softResponseLimit {
+					break
+				}
+				lookup := pm.txLookup(hash)
+				lookups = append(lookups, lookup)
+				bytes += lookup.Proof.DataSize()
+			}
+			sendResponse(req.ReqID, uint64(reqCnt), p.ReplyTxLookup(req.ReqID, lookups), task.done())
+		}()
+
+	case TxLookupMsg:
+		if pm.odr == nil {
+			return errResp(ErrUnexpectedResponse, "")
+		}
+
+		p.Log().Trace("Received transaction lookup response")
+		var resp struct {
+			ReqID, BV uint64
+			Lookups   []txLookup
+		}
+		if err := msg.Decode(&resp); err != nil {
+			return errResp(ErrDecode, "msg %v: %v", msg, err)
+		}
+
+		p.fcServer.ReceivedReply(resp.ReqID, resp.BV)
+		deliverMsg = &Msg{
+			MsgType: MsgTxLookup,
+			ReqID:   resp.ReqID,
+			Obj:     resp.Lookups,
+		}
+
 	default:
 		p.Log().Trace("Received unknown message", "code", msg.Code)
 		return errResp(ErrInvalidMsgCode, "%v", msg.Code)
@@ -1294,6 +1354,45 @@ func (pm *ProtocolManager) txStatus(hash common.Hash) txStatus {
 	return stat
 }
 
+// txLookup retrieves the position of a transaction in the local canonical chain
+// along with a merkle proof of its inclusion in the block's transaction trie.
+func (pm *ProtocolManager) txLookup(hash common.Hash) txLookup {
+	var lookup txLookup
+	_, blockHash, blockNumber, index := rawdb.ReadTransaction(pm.chainDb, hash)
+	if blockHash == (common.Hash{}) {
+		return lookup
+	}
+	header := pm.blockchain.GetHeader(blockHash, blockNumber)
+	body := rawdb.ReadBody(pm.chainDb, blockHash, blockNumber)
+	if header == nil || body == nil {
+		return lookup
+	}
+	// Rebuild the transaction trie of the block and prove the entry
+	var (
+		keybuf = new(bytes.Buffer)
+		txTrie = new(trie.Trie)
+	)
+	for i, tx := range body.Transactions {
+		keybuf.Reset()
+		rlp.Encode(keybuf, uint(i))
+		enc, _ := rlp.EncodeToBytes(tx)
+		txTrie.Update(keybuf.Bytes(), enc)
+	}
+	key, _ := rlp.EncodeToBytes(uint(index))
+	nodes := light.NewNodeSet()
+	if err := txTrie.Prove(key, 0, nodes); err != nil {
+		log.Warn("Failed to prove transaction inclusion", "hash", hash, "err", err)
+		return lookup
+	}
+	return txLookup{
+		BlockHash:   blockHash,
+		BlockNumber: blockNumber,
+		Index:       index,
+		Header:      header,
+		Proof:       nodes.NodeList(),
+	}
+}
+
 // isULCEnabled returns true if we can use ULC
 func (pm *ProtocolManager) isULCEnabled() bool {
 	if pm.ulc == nil || len(pm.ulc.trustedKeys) == 0 {
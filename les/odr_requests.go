@@ -43,6 +43,7 @@ var (
 	errCHTHashMismatch     = errors.New("cht hash mismatch")
 	errCHTNumberMismatch   = errors.New("cht number mismatch")
 	errUselessNodes        = errors.New("useless nodes in merkle proof nodeset")
+	errTxLookupMismatch    = errors.New("transaction lookup mismatch")
 )
 
 type LesOdrRequest interface {
@@ -58,6 +59,8 @@ func LesRequest(req light.OdrRequest) LesOdrRequest {
 		return (*BlockRequest)(r)
 	case *light.ReceiptsRequest:
 		return (*ReceiptsRequest)(r)
+	case *light.ReceiptsRangeRequest:
+		return (*ReceiptsRangeRequest)(r)
 	case *light.TrieRequest:
 		return (*TrieRequest)(r)
 	case *light.CodeRequest:
@@ -66,6 +69,8 @@ func LesRequest(req light.OdrRequest) LesOdrRequest {
 		return (*ChtRequest)(r)
 	case *light.BloomRequest:
 		return (*BloomRequest)(r)
+	case *light.TxLookupRequest:
+		return (*TxLookupRequest)(r)
 	default:
 		return nil
 	}
@@ -164,7 +169,10 @@ func (r *ReceiptsRequest) Validate(db ethdb.Database, msg *Msg) error {
 	receipt := receipts[0]
 
 	// Retrieve our stored header and validate receipt content against it
-	header := rawdb.ReadHeader(db, r.Hash, r.Number)
+	header := r.Header
+	if header == nil {
+		header = rawdb.ReadHeader(db, r.Hash, r.Number)
+	}
 	if header == nil {
 		return errHeaderUnavailable
 	}
@@ -176,6 +184,60 @@ func (r *ReceiptsRequest) Validate(db ethdb.Database, msg *Msg) error {
 	return nil
 }
 
+// ReceiptsRangeRequest is the ODR request type for the receipts of a batch of blocks
+type ReceiptsRangeRequest light.ReceiptsRangeRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *ReceiptsRangeRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetReceiptsMsg, len(r.Headers))
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *ReceiptsRangeRequest) CanSend(peer *peer) bool {
+	for _, header := range r.Headers {
+		if !peer.HasBlock(header.Hash(), header.Number.Uint64(), false) {
+			return false
+		}
+	}
+	return true
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *ReceiptsRangeRequest) Request(reqID uint64, peer *peer) error {
+	hashes := make([]common.Hash, len(r.Headers))
+	for i, header := range r.Headers {
+		hashes[i] = header.Hash()
+	}
+	peer.Log().Debug("Requesting block receipts range", "count", len(hashes), "first", r.Headers[0].Number)
+	return peer.RequestReceipts(reqID, r.GetCost(peer), hashes)
+}
+
+// Valid processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *ReceiptsRangeRequest) Validate(db ethdb.Database, msg *Msg) error {
+	log.Debug("Validating block receipts range", "count", len(r.Headers))
+
+	// Ensure we have a correct message with all the requested receipts
+	if msg.MsgType != MsgReceipts {
+		return errInvalidMessageType
+	}
+	receipts := msg.Obj.([]types.Receipts)
+	if len(receipts) != len(r.Headers) {
+		return errInvalidEntryCount
+	}
+	// Validate the receipts against the requested headers
+	for i, header := range r.Headers {
+		if header.ReceiptHash != types.DeriveSha(receipts[i]) {
+			return errReceiptHashMismatch
+		}
+	}
+	// Validations passed, store and return
+	r.Receipts = receipts
+	return nil
+}
+
 type ProofReq struct {
 	BHash       common.Hash
 	AccKey, Key []byte
@@ -191,7 +253,7 @@ func (r *TrieRequest) GetCost(peer *peer) uint64 {
 	switch peer.version {
 	case lpv1:
 		return peer.GetRequestCost(GetProofsV1Msg, 1)
-	case lpv2:
+	case lpv2, lpv3:
 		return peer.GetRequestCost(GetProofsV2Msg, 1)
 	default:
 		panic(nil)
@@ -351,7 +413,7 @@ func (r *ChtRequest) GetCost(peer *peer) uint64 {
 	switch peer.version {
 	case lpv1:
 		return peer.GetRequestCost(GetHeaderProofsMsg, 1)
-	case lpv2:
+	case lpv2, lpv3:
 		return peer.GetRequestCost(GetHelperTrieProofsMsg, 1)
 	default:
 		panic(nil)
@@ -363,6 +425,9 @@ func (r *ChtRequest) CanSend(peer *peer) bool {
 	peer.lock.RLock()
 	defer peer.lock.RUnlock()
 
+	if r.Untrusted {
+		return peer.version >= lpv2 && peer.id == r.PeerId && peer.headInfo.Number >= r.BlockNum
+	}
 	return peer.headInfo.Number >= r.Config.ChtConfirms && r.ChtNum <= (peer.headInfo.Number-r.Config.ChtConfirms)/r.Config.ChtSize
 }
 
@@ -387,7 +452,7 @@ func (r *ChtRequest) Request(reqID uint64, peer *peer) error {
 		// convert HelperTrie request to old CHT request
 		reqsV1 = ChtReq{ChtNum: (req.TrieIdx + 1) * (r.Config.ChtSize / r.Config.PairChtSize), BlockNum: blockNum, FromLevel: req.FromLevel}
 		return peer.RequestHelperTrieProofs(reqID, r.GetCost(peer), []ChtReq{reqsV1})
-	case lpv2:
+	case lpv2, lpv3:
 		return peer.RequestHelperTrieProofs(reqID, r.GetCost(peer), []HelperTrieReq{req})
 	default:
 		panic(nil)
@@ -441,7 +506,14 @@ func (r *ChtRequest) Validate(db ethdb.Database, msg *Msg) error {
 		if err := rlp.DecodeBytes(headerEnc, header); err != nil {
 			return errHeaderUnavailable
 		}
-
+		// Untrusted headers are beyond our CHTs, skip the proof
+		if r.Untrusted {
+			if r.BlockNum != header.Number.Uint64() {
+				return errCHTNumberMismatch
+			}
+			r.Header = header
+			return nil
+		}
 		// Verify the CHT
 		var encNumber [8]byte
 		binary.BigEndian.PutUint64(encNumber[:], r.BlockNum)
@@ -555,6 +627,73 @@ func (r *BloomRequest) Validate(db ethdb.Database, msg *Msg) error {
 	return nil
 }
 
+// TxLookupRequest is the ODR request type for proven transaction positions
+type TxLookupRequest light.TxLookupRequest
+
+// GetCost returns the cost of the given ODR request according to the serving
+// peer's cost table (implementation of LesOdrRequest)
+func (r *TxLookupRequest) GetCost(peer *peer) uint64 {
+	return peer.GetRequestCost(GetTxLookupMsg, 1)
+}
+
+// CanSend tells if a certain peer is suitable for serving the given request
+func (r *TxLookupRequest) CanSend(peer *peer) bool {
+	return peer.version >= lpv3
+}
+
+// Request sends an ODR request to the LES network (implementation of LesOdrRequest)
+func (r *TxLookupRequest) Request(reqID uint64, peer *peer) error {
+	peer.Log().Debug("Requesting transaction lookup", "hash", r.Hash)
+	return peer.RequestTxLookup(reqID, r.GetCost(peer), []common.Hash{r.Hash})
+}
+
+// Valid processes an ODR request reply message from the LES network
+// returns true and stores results in memory if the message was a valid reply
+// to the request (implementation of LesOdrRequest)
+func (r *TxLookupRequest) Validate(db ethdb.Database, msg *Msg) error {
+	log.Debug("Validating transaction lookup", "hash", r.Hash)
+
+	// Ensure we have a correct message with a single lookup entry
+	if msg.MsgType != MsgTxLookup {
+		return errInvalidMessageType
+	}
+	lookups := msg.Obj.([]txLookup)
+	if len(lookups) != 1 {
+		return errInvalidEntryCount
+	}
+	lookup := lookups[0]
+
+	// An empty entry means the transaction is unknown to the server
+	if lookup.Header == nil {
+		return nil
+	}
+	if lookup.Header.Hash() != lookup.BlockHash || lookup.Header.Number.Uint64() != lookup.BlockNumber {
+		return errHeaderUnavailable
+	}
+	// Verify the inclusion proof against the header's transaction trie
+	key, _ := rlp.EncodeToBytes(uint(lookup.Index))
+	nodeSet := lookup.Proof.NodeSet()
+	reads := &readTraceDB{db: nodeSet}
+	value, _, err := trie.VerifyProof(lookup.Header.TxHash, key, reads)
+	if err != nil {
+		return fmt.Errorf("merkle proof verification failed: %v", err)
+	}
+	if len(reads.reads) != nodeSet.KeyCount() {
+		return errUselessNodes
+	}
+	tx := new(types.Transaction)
+	if err := rlp.DecodeBytes(value, tx); err != nil {
+		return err
+	}
+	if tx.Hash() != r.Hash {
+		return errTxLookupMismatch
+	}
+	// Validations passed, store and return
+	r.BlockHash, r.BlockNumber, r.Index = lookup.BlockHash, lookup.BlockNumber, lookup.Index
+	r.Header, r.Tx = lookup.Header, tx
+	return nil
+}
+
 // readTraceDB stores the keys of database reads. We use this to check that received node
 // sets contain only the trie nodes necessary to make proofs pass.
 type readTraceDB struct {
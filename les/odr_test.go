@@ -78,6 +78,50 @@ func odrGetReceipts(ctx context.Context, db ethdb.Database, config *params.Chain
 	return rlp
 }
 
+func TestOdrGetReceiptsRangeLes1(t *testing.T) { testOdrGetReceiptsRange(t, 1) }
+
+func TestOdrGetReceiptsRangeLes2(t *testing.T) { testOdrGetReceiptsRange(t, 2) }
+
+// testOdrGetReceiptsRange tests that light clients can retrieve the receipts of
+// multiple blocks at once and reuse them without network access afterwards.
+func testOdrGetReceiptsRange(t *testing.T, protocol int) {
+	// Assemble the test environment
+	server, client, tearDown := newClientServerEnv(t, 4, protocol, nil, true)
+	defer tearDown()
+	client.pm.synchronise(client.rPeer)
+
+	client.peers.lock.Lock()
+	client.rPeer.hasBlock = func(common.Hash, uint64, bool) bool { return true }
+	client.peers.lock.Unlock()
+
+	var headers []*types.Header
+	for i := uint64(0); i <= server.pm.blockchain.CurrentHeader().Number.Uint64(); i++ {
+		headers = append(headers, server.pm.blockchain.GetHeaderByNumber(i))
+	}
+	check := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+
+		receipts, err := light.GetBlockReceiptsRange(ctx, client.pm.odr, headers)
+		if err != nil {
+			t.Fatalf("failed to retrieve receipts: %v", err)
+		}
+		for i, header := range headers {
+			want, _ := rlp.EncodeToBytes(rawdb.ReadReceipts(server.db, header.Hash(), header.Number.Uint64()))
+			have, _ := rlp.EncodeToBytes(receipts[i])
+			if !bytes.Equal(have, want) {
+				t.Fatalf("block %d: receipts mismatch", i)
+			}
+		}
+	}
+	check()
+
+	// The retrieved receipts should be available without any peers now
+	client.peers.Unregister(client.rPeer.id)
+	time.Sleep(time.Millisecond * 10) // ensure that all peerSetNotify callbacks are executed
+	check()
+}
+
 func TestOdrAccountsLes1(t *testing.T) { testOdr(t, 1, 1, odrAccounts) }
 
 func TestOdrAccountsLes2(t *testing.T) { testOdr(t, 2, 1, odrAccounts) }
@@ -202,3 +246,54 @@ func testOdr(t *testing.T, protocol int, expFail uint64, fn odrTestFn) {
 	time.Sleep(time.Millisecond * 10) // ensure that all peerSetNotify callbacks are executed
 	test(5)
 }
+
+func TestOdrTxLookupLes2(t *testing.T) { testOdrTxLookup(t, 2) }
+
+func TestOdrTxLookupLes3(t *testing.T) { testOdrTxLookup(t, 3) }
+
+// testOdrTxLookup tests that light clients can retrieve proven transaction
+// positions from servers supporting the transaction lookup request.
+func testOdrTxLookup(t *testing.T, protocol int) {
+	// Assemble the test environment
+	server, client, tearDown := newClientServerEnv(t, 4, protocol, nil, true)
+	defer tearDown()
+	client.pm.synchronise(client.rPeer)
+
+	var checked int
+	for i := uint64(0); i <= server.pm.blockchain.CurrentHeader().Number.Uint64(); i++ {
+		block := server.pm.blockchain.(*core.BlockChain).GetBlockByNumber(i)
+		for index, want := range block.Transactions() {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			tx, blockHash, blockNumber, txIndex, err := light.GetTransaction(ctx, client.pm.odr, want.Hash())
+			cancel()
+
+			if protocol < lpv3 {
+				if err == nil {
+					t.Fatalf("tx %x: transaction lookup succeeded on les/%d", want.Hash(), protocol)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("tx %x: failed to look up transaction: %v", want.Hash(), err)
+			}
+			if tx == nil || tx.Hash() != want.Hash() {
+				t.Fatalf("tx %x: transaction mismatch: have %v", want.Hash(), tx)
+			}
+			if blockHash != block.Hash() || blockNumber != i || txIndex != uint64(index) {
+				t.Fatalf("tx %x: position mismatch: have %x/%d/%d, want %x/%d/%d", want.Hash(), blockHash, blockNumber, txIndex, block.Hash(), i, index)
+			}
+			checked++
+		}
+	}
+	if protocol >= lpv3 && checked == 0 {
+		t.Fatalf("no transactions checked")
+	}
+	// Unknown transactions should be reported as such
+	if protocol >= lpv3 {
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		if tx, _, _, _, err := light.GetTransaction(ctx, client.pm.odr, common.Hash{0x01}); tx != nil || err != nil {
+			t.Fatalf("unknown transaction found: %v, %v", tx, err)
+		}
+	}
+}
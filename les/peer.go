@@ -30,6 +30,7 @@ import (
 	"github.com/ethereum/go-ethereum/les/flowcontrol"
 	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rlp"
 )
 
@@ -95,6 +96,10 @@ type peer struct {
 
 	isTrusted      bool
 	isOnlyAnnounce bool
+
+	// Checkpoint relative fields
+	checkpoint       params.TrustedCheckpoint
+	checkpointNumber uint64
 }
 
 func newPeer(version int, network uint64, isTrusted bool, p *p2p.Peer, rw p2p.MsgReadWriter) *peer {
@@ -248,7 +253,7 @@ func (p *peer) GetTxRelayCost(amount, size int) uint64 {
 	switch p.version {
 	case lpv1:
 		msgcode = SendTxMsg
-	case lpv2:
+	case lpv2, lpv3:
 		msgcode = SendTxV2Msg
 	default:
 		panic(nil)
@@ -337,6 +342,12 @@ func (p *peer) ReplyTxStatus(reqID uint64, stats []txStatus) *reply {
 	return &reply{p.rw, TxStatusMsg, reqID, data}
 }
 
+// ReplyTxLookup creates a reply with a batch of proven transaction positions, corresponding to the ones requested.
+func (p *peer) ReplyTxLookup(reqID uint64, lookups []txLookup) *reply {
+	data, _ := rlp.EncodeToBytes(lookups)
+	return &reply{p.rw, TxLookupMsg, reqID, data}
+}
+
 // RequestHeadersByHash fetches a batch of blocks' headers corresponding to the
 // specified header query, based on the hash of an origin block.
 func (p *peer) RequestHeadersByHash(reqID, cost uint64, origin common.Hash, amount int, skip int, reverse bool) error {
@@ -377,7 +388,7 @@ func (p *peer) RequestProofs(reqID, cost uint64, reqs []ProofReq) error {
 	switch p.version {
 	case lpv1:
 		return sendRequest(p.rw, GetProofsV1Msg, reqID, cost, reqs)
-	case lpv2:
+	case lpv2, lpv3:
 		return sendRequest(p.rw, GetProofsV2Msg, reqID, cost, reqs)
 	default:
 		panic(nil)
@@ -394,7 +405,7 @@ func (p *peer) RequestHelperTrieProofs(reqID, cost uint64, data interface{}) err
 		}
 		p.Log().Debug("Fetching batch of header proofs", "count", len(reqs))
 		return sendRequest(p.rw, GetHeaderProofsMsg, reqID, cost, reqs)
-	case lpv2:
+	case lpv2, lpv3:
 		reqs, ok := data.([]HelperTrieReq)
 		if !ok {
 			return errInvalidHelpTrieReq
@@ -412,13 +423,19 @@ func (p *peer) RequestTxStatus(reqID, cost uint64, txHashes []common.Hash) error
 	return sendRequest(p.rw, GetTxStatusMsg, reqID, cost, txHashes)
 }
 
+// RequestTxLookup fetches a batch of proven transaction positions from a remote node.
+func (p *peer) RequestTxLookup(reqID, cost uint64, txHashes []common.Hash) error {
+	p.Log().Debug("Requesting transaction lookups", "count", len(txHashes))
+	return sendRequest(p.rw, GetTxLookupMsg, reqID, cost, txHashes)
+}
+
 // SendTxStatus creates a reply with a batch of transactions to be added to the remote transaction pool.
 func (p *peer) SendTxs(reqID, cost uint64, txs rlp.RawValue) error {
 	p.Log().Debug("Sending batch of transactions", "size", len(txs))
 	switch p.version {
 	case lpv1:
 		return p2p.Send(p.rw, SendTxMsg, txs) // old message format does not include reqID
-	case lpv2:
+	case lpv2, lpv3:
 		return sendRequest(p.rw, SendTxV2Msg, reqID, cost, txs)
 	default:
 		panic(nil)
@@ -526,6 +543,16 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 		send = send.add("flowControl/MRC", costList)
 		p.fcCosts = costList.decode()
 		p.fcParams = server.defParams
+
+		// Add advertised checkpoint and register block height which
+		// client can verify the checkpoint validity.
+		if server.oracle != nil && server.oracle.isRunning() {
+			cp, height := server.oracle.stableCheckpoint()
+			if cp != nil {
+				send = send.add("checkpoint/value", cp)
+				send = send.add("checkpoint/registerHeight", height)
+			}
+		}
 	} else {
 		//on client node
 		p.announceType = announceTypeSimple
@@ -617,6 +644,13 @@ func (p *peer) Handshake(td *big.Int, head common.Hash, headNum uint64, genesis
 		p.fcParams = params
 		p.fcServer = flowcontrol.NewServerNode(params, &mclock.System{})
 		p.fcCosts = MRC.decode()
+
+		// The checkpoint is optional, servers without a registrar don't announce it
+		if recv.get("checkpoint/value", &p.checkpoint) == nil {
+			if err := recv.get("checkpoint/registerHeight", &p.checkpointNumber); err != nil {
+				return err
+			}
+		}
 	}
 	p.headInfo = &announceData{Td: rTd, Hash: rHash, Number: rNum}
 	return nil
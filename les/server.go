@@ -20,6 +20,7 @@ import (
 	"crypto/ecdsa"
 	"sync"
 
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/core"
@@ -30,6 +31,7 @@ import (
 	"github.com/ethereum/go-ethereum/light"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/discv5"
 	"github.com/ethereum/go-ethereum/params"
 	"github.com/ethereum/go-ethereum/rpc"
@@ -54,6 +56,7 @@ type LesServer struct {
 	freeClientCap      uint64
 	freeClientPool     *freeClientPool
 	priorityClientPool *priorityClientPool
+	balances           *balanceTracker
 }
 
 func NewLesServer(eth *eth.Ethereum, config *eth.Config) (*LesServer, error) {
@@ -99,6 +102,9 @@ func NewLesServer(eth *eth.Ethereum, config *eth.Config) (*LesServer, error) {
 		onlyAnnounce: config.OnlyAnnounce,
 	}
 
+	srv.oracle = newCheckpointOracle(config.CheckpointOracle, srv.getLocalCheckpoint)
+	pm.oracle = srv.oracle
+
 	logger := log.New()
 	pm.server = srv
 	srv.thcNormal = config.LightServ * 4 / 100
@@ -211,6 +217,8 @@ func (s *LesServer) Start(srvr *p2p.Server) {
 
 	s.freeClientPool = newFreeClientPool(s.chainDb, s.freeClientCap, 10000, mclock.System{}, func(id string) { go s.protocolManager.removePeer(id) })
 	s.priorityClientPool = newPriorityClientPool(s.freeClientCap, s.protocolManager.peers, s.freeClientPool)
+	s.balances = newBalanceTracker(s.chainDb, mclock.System{}, s.priorityClientPool.balanceDepleted)
+	s.priorityClientPool.balances = s.balances
 
 	s.protocolManager.peers.notify(s.priorityClientPool)
 	s.startEventLoop()
@@ -227,10 +235,31 @@ func (s *LesServer) Start(srvr *p2p.Server) {
 			}()
 		}
 	}
+	if srvr.DiscV51 != nil {
+		for _, topic := range s.lesTopics {
+			topic := discover.Topic(topic)
+			go func() {
+				logger := log.New("topic", topic, "discovery", "v5.1")
+				logger.Info("Starting topic registration")
+				defer logger.Info("Terminated topic registration")
+
+				srvr.DiscV51.RegisterTopic(topic, s.quitSync)
+			}()
+		}
+	}
 	s.privateKey = srvr.PrivateKey
 	s.protocolManager.blockLoop()
 }
 
+// SetContractBackend sets the contract backend used by the checkpoint oracle
+// to retrieve the latest checkpoint registered on-chain.
+func (s *LesServer) SetContractBackend(backend bind.ContractBackend) {
+	if s.oracle == nil {
+		return
+	}
+	s.oracle.start(backend)
+}
+
 func (s *LesServer) SetBloomBitsIndexer(bloomIndexer *core.ChainIndexer) {
 	bloomIndexer.AddChildIndexer(s.bloomTrieIndexer)
 }
@@ -243,6 +272,7 @@ func (s *LesServer) Stop() {
 		<-s.protocolManager.noMorePeers
 	}()
 	s.freeClientPool.stop()
+	s.balances.stop()
 	s.costTracker.stop()
 	s.protocolManager.Stop()
 }
@@ -32,6 +32,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/discv5"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -171,7 +172,7 @@ func (pool *serverPool) start(server *p2p.Server, topic discv5.Topic) {
 	pool.loadNodes()
 	pool.connectToTrustedNodes()
 
-	if pool.server.DiscV5 != nil {
+	if pool.server.DiscV5 != nil || pool.server.DiscV51 != nil {
 		pool.discSetPeriod = make(chan time.Duration, 1)
 		pool.discNodes = make(chan *enode.Node, 100)
 		pool.discLookups = make(chan bool, 100)
@@ -181,19 +182,41 @@ func (pool *serverPool) start(server *p2p.Server, topic discv5.Topic) {
 	go pool.eventLoop()
 }
 
-// discoverNodes wraps SearchTopic, converting result nodes to enode.Node.
+// discoverNodes runs the topic search on all enabled discovery protocols, passing
+// the search period on to each of them. Nodes found by discovery v5 are converted
+// to enode.Node.
 func (pool *serverPool) discoverNodes() {
-	ch := make(chan *discv5.Node)
-	go func() {
-		pool.server.DiscV5.SearchTopic(pool.topic, pool.discSetPeriod, ch, pool.discLookups)
-		close(ch)
-	}()
-	for n := range ch {
-		pubkey, err := decodePubkey64(n.ID[:])
-		if err != nil {
-			continue
+	var periods []chan time.Duration
+	if pool.server.DiscV51 != nil {
+		setPeriod := make(chan time.Duration, 1)
+		periods = append(periods, setPeriod)
+		go pool.server.DiscV51.SearchTopic(discover.Topic(pool.topic), setPeriod, pool.discNodes, pool.discLookups)
+	}
+	if pool.server.DiscV5 != nil {
+		setPeriod := make(chan time.Duration, 1)
+		periods = append(periods, setPeriod)
+		ch := make(chan *discv5.Node)
+		go func() {
+			pool.server.DiscV5.SearchTopic(pool.topic, setPeriod, ch, pool.discLookups)
+			close(ch)
+		}()
+		go func() {
+			for n := range ch {
+				pubkey, err := decodePubkey64(n.ID[:])
+				if err != nil {
+					continue
+				}
+				pool.discNodes <- enode.NewV4(pubkey, n.IP, int(n.TCP), int(n.UDP))
+			}
+		}()
+	}
+	for period := range pool.discSetPeriod {
+		for _, setPeriod := range periods {
+			setPeriod <- period
 		}
-		pool.discNodes <- enode.NewV4(pubkey, n.IP, int(n.TCP), int(n.UDP))
+	}
+	for _, setPeriod := range periods {
+		close(setPeriod)
 	}
 }
 
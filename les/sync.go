@@ -18,11 +18,13 @@ package les
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/eth/downloader"
 	"github.com/ethereum/go-ethereum/light"
+	"github.com/ethereum/go-ethereum/log"
 )
 
 // syncer is responsible for periodically synchronising with the network, both
@@ -74,6 +76,56 @@ func (pm *ProtocolManager) synchronise(peer *peer) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
-	pm.blockchain.(*light.LightChain).SyncCht(ctx)
+
+	lc := pm.blockchain.(*light.LightChain)
+	if pm.newerCheckpoint(peer) {
+		// Adopt the checkpoint announced by the server if the registrar
+		// signers approved it on-chain, falling back to the local one.
+		if err := pm.verifyCheckpoint(ctx, peer); err != nil {
+			log.Debug("Failed to verify announced checkpoint", "peer", peer.id, "err", err)
+		} else {
+			lc.AddTrustedCheckpoint(&peer.checkpoint)
+		}
+	}
+	lc.SyncCht(ctx)
 	pm.downloader.Synchronise(peer.id, peer.Head(), peer.Td(), downloader.LightSync)
 }
+
+// newerCheckpoint reports whether the checkpoint announced by the remote peer
+// is more recent than any section the local indexers know about.
+func (pm *ProtocolManager) newerCheckpoint(peer *peer) bool {
+	if pm.oracle == nil || peer.checkpoint.Empty() {
+		return false
+	}
+	sections, _, _ := pm.odr.ChtIndexer().Sections()
+	return peer.checkpoint.SectionIndex+1 > sections
+}
+
+// verifyCheckpoint verifies the checkpoint announced by the remote peer against
+// the votes of the registrar signers. The block the checkpoint was registered
+// in and its logs are retrieved from the same peer, authenticated only by the
+// signatures in the logs themselves.
+func (pm *ProtocolManager) verifyCheckpoint(ctx context.Context, peer *peer) error {
+	header, err := light.GetUntrustedHeaderByNumber(ctx, pm.odr, peer.checkpointNumber, peer.id)
+	if err != nil {
+		return err
+	}
+	logs, err := light.GetUntrustedBlockLogs(ctx, pm.odr, header)
+	if err != nil {
+		return err
+	}
+	var (
+		checkpoint = peer.checkpoint
+		events     = pm.oracle.contract.LookupCheckpointEvents(logs, checkpoint.SectionIndex, checkpoint.Hash())
+		signatures [][]byte
+	)
+	for _, event := range events {
+		signatures = append(signatures, append(append(event.R[:], event.S[:]...), event.V))
+	}
+	valid, signers := pm.oracle.verifySigners(checkpoint.SectionIndex, checkpoint.Hash(), signatures)
+	if !valid {
+		return errors.New("invalid approval signatures")
+	}
+	log.Info("Verified advertised checkpoint", "peer", peer.id, "signers", len(signers), "section", checkpoint.SectionIndex)
+	return nil
+}
@@ -41,8 +41,9 @@ import (
 )
 
 var (
-	bodyCacheLimit  = 256
-	blockCacheLimit = 256
+	bodyCacheLimit     = 256
+	blockCacheLimit    = 256
+	receiptsCacheLimit = 1024
 )
 
 // LightChain represents a canonical chain that by default only handles block
@@ -60,9 +61,10 @@ type LightChain struct {
 	scope         event.SubscriptionScope
 	genesisBlock  *types.Block
 
-	bodyCache    *lru.Cache // Cache for the most recent block bodies
-	bodyRLPCache *lru.Cache // Cache for the most recent block bodies in RLP encoded format
-	blockCache   *lru.Cache // Cache for the most recent entire blocks
+	bodyCache     *lru.Cache // Cache for the most recent block bodies
+	bodyRLPCache  *lru.Cache // Cache for the most recent block bodies in RLP encoded format
+	blockCache    *lru.Cache // Cache for the most recent entire blocks
+	receiptsCache *lru.Cache // Cache for the most recent verified block receipts
 
 	chainmu sync.RWMutex // protects header inserts
 	quit    chan struct{}
@@ -81,6 +83,7 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 	bodyCache, _ := lru.New(bodyCacheLimit)
 	bodyRLPCache, _ := lru.New(bodyCacheLimit)
 	blockCache, _ := lru.New(blockCacheLimit)
+	receiptsCache, _ := lru.New(receiptsCacheLimit)
 
 	bc := &LightChain{
 		chainDb:       odr.Database(),
@@ -90,6 +93,7 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 		bodyCache:     bodyCache,
 		bodyRLPCache:  bodyRLPCache,
 		blockCache:    blockCache,
+		receiptsCache: receiptsCache,
 		engine:        engine,
 	}
 	var err error
@@ -102,7 +106,7 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 		return nil, core.ErrNoGenesis
 	}
 	if cp, ok := trustedCheckpoints[bc.genesisBlock.Hash()]; ok {
-		bc.addTrustedCheckpoint(cp)
+		bc.AddTrustedCheckpoint(cp)
 	}
 	if err := bc.loadLastState(); err != nil {
 		return nil, err
@@ -118,8 +122,8 @@ func NewLightChain(odr OdrBackend, config *params.ChainConfig, engine consensus.
 	return bc, nil
 }
 
-// addTrustedCheckpoint adds a trusted checkpoint to the blockchain
-func (lc *LightChain) addTrustedCheckpoint(cp *params.TrustedCheckpoint) {
+// AddTrustedCheckpoint adds a trusted checkpoint to the blockchain
+func (lc *LightChain) AddTrustedCheckpoint(cp *params.TrustedCheckpoint) {
 	if lc.odr.ChtIndexer() != nil {
 		StoreChtRoot(lc.chainDb, cp.SectionIndex, cp.SectionHead, cp.CHTRoot)
 		lc.odr.ChtIndexer().AddCheckpoint(cp.SectionIndex, cp.SectionHead)
@@ -256,6 +260,37 @@ func (lc *LightChain) GetBodyRLP(ctx context.Context, hash common.Hash) (rlp.Raw
 	return body, nil
 }
 
+// GetReceiptsRange retrieves the receipts of a batch of blocks from the cache,
+// the database or the ODR service, caching them if found. The derived fields of
+// the receipts are not filled.
+func (lc *LightChain) GetReceiptsRange(ctx context.Context, headers []*types.Header) ([]types.Receipts, error) {
+	var (
+		receipts = make([]types.Receipts, len(headers))
+		missing  []*types.Header
+		indexes  []int
+	)
+	for i, header := range headers {
+		if cached, ok := lc.receiptsCache.Get(header.Hash()); ok {
+			receipts[i] = cached.(types.Receipts)
+			continue
+		}
+		missing, indexes = append(missing, header), append(indexes, i)
+	}
+	if len(missing) == 0 {
+		return receipts, nil
+	}
+	fetched, err := GetBlockReceiptsRange(ctx, lc.odr, missing)
+	if err != nil {
+		return nil, err
+	}
+	// Cache the found receipts for next time and return
+	for i, index := range indexes {
+		receipts[index] = fetched[i]
+		lc.receiptsCache.Add(missing[i].Hash(), fetched[i])
+	}
+	return receipts, nil
+}
+
 // HasBlock checks if a block is fully present in the database or not, caching
 // it if present.
 func (lc *LightChain) HasBlock(hash common.Hash, number uint64) bool {
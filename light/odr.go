@@ -122,19 +122,58 @@ func (req *BlockRequest) StoreResult(db ethdb.Database) {
 // ReceiptsRequest is the ODR request type for retrieving block bodies
 type ReceiptsRequest struct {
 	OdrRequest
-	Hash     common.Hash
-	Number   uint64
-	Receipts types.Receipts
+	Untrusted bool // Indicator whether the result retrieved is trusted or not
+	Hash      common.Hash
+	Number    uint64
+	Header    *types.Header
+	Receipts  types.Receipts
 }
 
 // StoreResult stores the retrieved data in local database
 func (req *ReceiptsRequest) StoreResult(db ethdb.Database) {
-	rawdb.WriteReceipts(db, req.Hash, req.Number, req.Receipts)
+	if !req.Untrusted {
+		rawdb.WriteReceipts(db, req.Hash, req.Number, req.Receipts)
+	}
+}
+
+// ReceiptsRangeRequest is the ODR request type for retrieving the receipts of
+// a batch of blocks at once, validated against their given headers
+type ReceiptsRangeRequest struct {
+	OdrRequest
+	Headers  []*types.Header
+	Receipts []types.Receipts
+}
+
+// StoreResult stores the retrieved data in local database
+func (req *ReceiptsRangeRequest) StoreResult(db ethdb.Database) {
+	for i, header := range req.Headers {
+		rawdb.WriteReceipts(db, header.Hash(), header.Number.Uint64(), req.Receipts[i])
+	}
+}
+
+// TxLookupRequest is the ODR request type for retrieving the position of a
+// transaction in the chain, proven against the header of the including block
+type TxLookupRequest struct {
+	OdrRequest
+	Hash        common.Hash
+	BlockHash   common.Hash
+	BlockNumber uint64
+	Index       uint64
+	Header      *types.Header
+	Tx          *types.Transaction
+}
+
+// StoreResult stores the retrieved data in local database
+func (req *TxLookupRequest) StoreResult(db ethdb.Database) {
+	// Transaction positions are not cached, since they may change with chain
+	// reorganisations and the header might not be canonical at all.
 }
 
 // ChtRequest is the ODR request type for state/storage trie entries
 type ChtRequest struct {
 	OdrRequest
+	Untrusted        bool   // Indicator whether the result retrieved is trusted or not
+	PeerId           string // The specified peer id from which to retrieve data.
 	Config           *IndexerConfig
 	ChtNum, BlockNum uint64
 	ChtRoot          common.Hash
@@ -145,6 +184,9 @@ type ChtRequest struct {
 
 // StoreResult stores the retrieved data in local database
 func (req *ChtRequest) StoreResult(db ethdb.Database) {
+	if req.Untrusted {
+		return
+	}
 	hash, num := req.Header.Hash(), req.Header.Number.Uint64()
 
 	rawdb.WriteHeader(db, req.Header)
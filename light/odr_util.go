@@ -19,6 +19,7 @@ package light
 import (
 	"bytes"
 	"context"
+	"sync"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core"
@@ -30,6 +31,11 @@ import (
 
 var sha3Nil = crypto.Keccak256Hash(nil)
 
+const (
+	receiptsRangeSize    = 16 // Number of blocks whose receipts are requested at once
+	receiptsRangeWorkers = 8  // Number of receipt range requests retrieved concurrently
+)
+
 func GetHeaderByNumber(ctx context.Context, odr OdrBackend, number uint64) (*types.Header, error) {
 	db := odr.Database()
 	hash := rawdb.ReadCanonicalHash(db, number)
@@ -69,6 +75,24 @@ func GetHeaderByNumber(ctx context.Context, odr OdrBackend, number uint64) (*typ
 	return r.Header, nil
 }
 
+// GetUntrustedHeaderByNumber fetches specified block header without correctness
+// checking from the given peer. Note this function should only be used when
+// the authenticity of the header is verified by other means, e.g. when
+// checking checkpoint announcements signed by trusted parties.
+func GetUntrustedHeaderByNumber(ctx context.Context, odr OdrBackend, number uint64, peerId string) (*types.Header, error) {
+	r := &ChtRequest{
+		Untrusted: true,
+		PeerId:    peerId,
+		Config:    odr.IndexerConfig(),
+		ChtNum:    number / odr.IndexerConfig().ChtSize,
+		BlockNum:  number,
+	}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
+	return r.Header, nil
+}
+
 func GetCanonicalHash(ctx context.Context, odr OdrBackend, number uint64) (common.Hash, error) {
 	hash := rawdb.ReadCanonicalHash(odr.Database(), number)
 	if (hash != common.Hash{}) {
@@ -124,6 +148,33 @@ func GetBlock(ctx context.Context, odr OdrBackend, hash common.Hash, number uint
 	return types.NewBlockWithHeader(header).WithBody(body.Transactions, body.Uncles), nil
 }
 
+// GetTransaction retrieves a canonical transaction by hash along with its
+// position in the chain. The position is proven by the serving peer against
+// the header of the including block, which in turn is checked against the
+// local canonical chain or the CHT. A nil transaction is returned if the
+// transaction is not known.
+func GetTransaction(ctx context.Context, odr OdrBackend, txHash common.Hash) (*types.Transaction, common.Hash, uint64, uint64, error) {
+	if tx, blockHash, blockNumber, index := rawdb.ReadTransaction(odr.Database(), txHash); tx != nil {
+		return tx, blockHash, blockNumber, index, nil
+	}
+	r := &TxLookupRequest{Hash: txHash}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, common.Hash{}, 0, 0, err
+	}
+	if r.Tx == nil {
+		return nil, common.Hash{}, 0, 0, nil
+	}
+	// Make sure the including block is part of the canonical chain
+	canonHash, err := GetCanonicalHash(ctx, odr, r.BlockNumber)
+	if err != nil {
+		return nil, common.Hash{}, 0, 0, err
+	}
+	if canonHash != r.BlockHash {
+		return nil, common.Hash{}, 0, 0, nil
+	}
+	return r.Tx, r.BlockHash, r.BlockNumber, r.Index, nil
+}
+
 // GetBlockReceipts retrieves the receipts generated by the transactions included
 // in a block given by its hash.
 func GetBlockReceipts(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) (types.Receipts, error) {
@@ -153,6 +204,79 @@ func GetBlockReceipts(ctx context.Context, odr OdrBackend, hash common.Hash, num
 	return receipts, nil
 }
 
+// GetBlockReceiptsRange retrieves the receipts of a batch of blocks given by
+// their headers. The receipts missing from the local database are requested
+// from the network in ranges, several of them in parallel. Similarly to
+// GetBlockLogs, the derived fields of the receipts are not filled.
+func GetBlockReceiptsRange(ctx context.Context, odr OdrBackend, headers []*types.Header) ([]types.Receipts, error) {
+	var (
+		db       = odr.Database()
+		receipts = make([]types.Receipts, len(headers))
+		missing  []int
+	)
+	for i, header := range headers {
+		if receipts[i] = rawdb.ReadReceipts(db, header.Hash(), header.Number.Uint64()); receipts[i] == nil && header.ReceiptHash != types.EmptyRootHash {
+			missing = append(missing, i)
+		}
+	}
+	// Split the missing blocks into ranges and retrieve them concurrently
+	var (
+		wg      sync.WaitGroup
+		slots   = make(chan struct{}, receiptsRangeWorkers)
+		errLock sync.Mutex
+		failure error
+	)
+	for len(missing) > 0 {
+		batch := missing
+		if len(batch) > receiptsRangeSize {
+			batch = batch[:receiptsRangeSize]
+		}
+		missing = missing[len(batch):]
+
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+		errLock.Lock()
+		failed := failure != nil
+		errLock.Unlock()
+		if failed {
+			<-slots
+			break
+		}
+		wg.Add(1)
+		go func(batch []int) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			r := &ReceiptsRangeRequest{Headers: make([]*types.Header, len(batch))}
+			for i, index := range batch {
+				r.Headers[i] = headers[index]
+			}
+			if err := odr.Retrieve(ctx, r); err != nil {
+				errLock.Lock()
+				if failure == nil {
+					failure = err
+				}
+				errLock.Unlock()
+				return
+			}
+			for i, index := range batch {
+				receipts[index] = r.Receipts[i]
+			}
+		}(batch)
+	}
+	wg.Wait()
+
+	if failure != nil {
+		return nil, failure
+	}
+	return receipts, nil
+}
+
 // GetBlockLogs retrieves the logs generated by the transactions included in a
 // block given by its hash.
 func GetBlockLogs(ctx context.Context, odr OdrBackend, hash common.Hash, number uint64) ([][]*types.Log, error) {
@@ -173,6 +297,21 @@ func GetBlockLogs(ctx context.Context, odr OdrBackend, hash common.Hash, number
 	return logs, nil
 }
 
+// GetUntrustedBlockLogs retrieves the logs generated by the transactions included
+// in a block whose header wasn't verified. The logs are only checked against the
+// receipt root of the header and are not stored in the database.
+func GetUntrustedBlockLogs(ctx context.Context, odr OdrBackend, header *types.Header) ([][]*types.Log, error) {
+	r := &ReceiptsRequest{Untrusted: true, Hash: header.Hash(), Number: header.Number.Uint64(), Header: header}
+	if err := odr.Retrieve(ctx, r); err != nil {
+		return nil, err
+	}
+	logs := make([][]*types.Log, len(r.Receipts))
+	for i, receipt := range r.Receipts {
+		logs[i] = receipt.Logs
+	}
+	return logs, nil
+}
+
 // GetBloomBits retrieves a batch of compressed bloomBits vectors belonging to the given bit index and section indexes
 func GetBloomBits(ctx context.Context, odr OdrBackend, bitIdx uint, sectionIdxList []uint64) ([][]byte, error) {
 	var (
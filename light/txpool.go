@@ -348,6 +348,9 @@ func (pool *TxPool) validateTx(ctx context.Context, tx *types.Transaction) error
 
 	// Validate the transaction sender and it's sig. Throw
 	// if the from fields is invalid.
+	if _, ok := types.ImpersonatedSender(tx); ok {
+		return core.ErrImpersonated
+	}
 	if from, err = types.Sender(pool.signer, tx); err != nil {
 		return core.ErrInvalidSender
 	}
@@ -0,0 +1,142 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package devsealer
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// API exposes the developer chain controls in the evm namespace.
+type API struct {
+	sealer *Sealer
+}
+
+// Mine seals a new block, optionally with the given timestamp, returning its hash.
+func (api *API) Mine(timestamp *hexutil.Uint64) (common.Hash, error) {
+	var ts *uint64
+	if timestamp != nil {
+		ts = (*uint64)(timestamp)
+	}
+	block, err := api.sealer.Mine(ts)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return block.Hash(), nil
+}
+
+// IncreaseTime shifts the timestamps of future blocks by the given number of
+// seconds, returning the total shift.
+func (api *API) IncreaseTime(seconds hexutil.Uint64) int64 {
+	return api.sealer.IncreaseTime(uint64(seconds))
+}
+
+// SetNextBlockTimestamp sets the timestamp of the next sealed block.
+func (api *API) SetNextBlockTimestamp(timestamp hexutil.Uint64) error {
+	return api.sealer.SetNextTimestamp(uint64(timestamp))
+}
+
+// SetAutomine sets whether a block is sealed for every new transaction.
+func (api *API) SetAutomine(enabled bool) {
+	api.sealer.SetAutomine(enabled)
+}
+
+// Snapshot records the current state of the chain, returning an identifier
+// to revert to it with.
+func (api *API) Snapshot() hexutil.Uint64 {
+	return hexutil.Uint64(api.sealer.Snapshot())
+}
+
+// Revert rewinds the chain to the given snapshot.
+func (api *API) Revert(id hexutil.Uint64) (bool, error) {
+	if err := api.sealer.Revert(uint64(id)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ImpersonateAccount allows sending unsigned transactions on behalf of an account
+// via evm_sendTransaction.
+func (api *API) ImpersonateAccount(account common.Address) {
+	api.sealer.Impersonate(account)
+}
+
+// StopImpersonatingAccount revokes the impersonation of an account.
+func (api *API) StopImpersonatingAccount(account common.Address) {
+	api.sealer.StopImpersonating(account)
+}
+
+// SendTxArgs represents the arguments to send an impersonated transaction.
+type SendTxArgs struct {
+	From     common.Address  `json:"from"`
+	To       *common.Address `json:"to"`
+	Gas      *hexutil.Uint64 `json:"gas"`
+	GasPrice *hexutil.Big    `json:"gasPrice"`
+	Value    *hexutil.Big    `json:"value"`
+	Nonce    *hexutil.Uint64 `json:"nonce"`
+	Data     *hexutil.Bytes  `json:"data"`
+	Input    *hexutil.Bytes  `json:"input"`
+}
+
+// SendTransaction sends an unsigned transaction on behalf of an impersonated
+// account, returning its hash.
+//
+// Note, the transaction carries an impersonation marker instead of a signature,
+// which the chain recovers the sender from. Such transactions are never accepted
+// from the network, they can only be included by the local sealer.
+func (api *API) SendTransaction(args SendTxArgs) (common.Hash, error) {
+	var nonce uint64
+	if args.Nonce != nil {
+		nonce = uint64(*args.Nonce)
+	} else {
+		var err error
+		if nonce, err = api.sealer.pendingNonce(args.From); err != nil {
+			return common.Hash{}, err
+		}
+	}
+	gas := api.sealer.chain.CurrentBlock().GasLimit()
+	if args.Gas != nil {
+		gas = uint64(*args.Gas)
+	}
+	price, value := new(big.Int), new(big.Int)
+	if args.GasPrice != nil {
+		price = args.GasPrice.ToInt()
+	}
+	if args.Value != nil {
+		value = args.Value.ToInt()
+	}
+	var input []byte
+	if args.Data != nil {
+		input = *args.Data
+	} else if args.Input != nil {
+		input = *args.Input
+	}
+	var tx *types.Transaction
+	if args.To == nil {
+		tx = types.NewContractCreation(nonce, value, gas, price, input)
+	} else {
+		tx = types.NewTransaction(nonce, *args.To, value, gas, price, input)
+	}
+	tx, err := api.sealer.SendImpersonated(args.From, tx)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
@@ -0,0 +1,488 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package devsealer implements an instant block sealer for developer chains.
+//
+// Instead of assembling blocks asynchronously like the miner does, the sealer
+// synchronously creates a block whenever a transaction arrives or one is
+// requested via RPC. Block timestamps can be shifted into the future and the
+// chain can be rewound to earlier snapshots, which together with account
+// impersonation is what contract test suites need.
+package devsealer
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/clique"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// txChanSize is the size of channel listening to NewTxsEvent.
+const txChanSize = 4096
+
+var (
+	errNotClique        = errors.New("developer sealer requires a clique chain")
+	errTimestampTooLow  = errors.New("timestamp not after the current head")
+	errUnknownSnapshot  = errors.New("unknown snapshot")
+	errNotImpersonated  = errors.New("account not impersonated")
+	errNoImpersonation  = errors.New("chain config doesn't enable impersonation")
+	errSealerNotStarted = errors.New("developer sealer not started")
+)
+
+// Backend wraps all methods required for sealing blocks.
+type Backend interface {
+	AccountManager() *accounts.Manager
+	BlockChain() *core.BlockChain
+	TxPool() *core.TxPool
+	EventMux() *event.TypeMux
+	Engine() consensus.Engine
+	Etherbase() (common.Address, error)
+}
+
+// Config is the configuration parameters of the sealer.
+type Config struct {
+	GasFloor uint64 // Target gas floor for sealed blocks
+	GasCeil  uint64 // Target gas ceiling for sealed blocks
+	Extra    []byte // Extra data to embed into sealed blocks
+}
+
+// snapshot is a point the chain can be reverted to.
+type snapshot struct {
+	number uint64 // Head block number when the snapshot was taken
+	offset int64  // Clock offset when the snapshot was taken
+}
+
+// Sealer is a node.Service sealing blocks instantly on a developer chain.
+type Sealer struct {
+	config  *Config
+	backend Backend
+	chain   *core.BlockChain
+	engine  *clique.Clique
+
+	account  accounts.Account // Local signer account sealing the blocks
+	wallet   accounts.Wallet  // Wallet holding the signer account
+	automine bool             // Whether to seal a block for every new transaction
+
+	offset int64   // Seconds to shift the wall clock by for new blocks
+	next   *uint64 // Timestamp of the next block, if explicitly requested
+
+	snapshots    map[uint64]snapshot         // Chain snapshots to revert to
+	snapshotID   uint64                      // Identifier of the last snapshot taken
+	impersonated map[common.Address]struct{} // Accounts allowed to send unsigned transactions
+	queue        []*types.Transaction        // Impersonated transactions waiting for inclusion
+	signer       func(*big.Int) types.Signer // Transaction signer constructor for a block number
+	now          func() time.Time            // Wall clock, overridable for testing
+	lock         sync.Mutex                  // Protects all the fields above
+
+	txsCh  chan core.NewTxsEvent
+	txsSub event.Subscription
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// New creates an instant sealer for the developer chain managed by the backend.
+func New(backend Backend, config *Config) (*Sealer, error) {
+	engine, ok := backend.Engine().(*clique.Clique)
+	if !ok {
+		return nil, errNotClique
+	}
+	chain := backend.BlockChain()
+	return &Sealer{
+		config:       config,
+		backend:      backend,
+		chain:        chain,
+		engine:       engine,
+		automine:     true,
+		snapshots:    make(map[uint64]snapshot),
+		impersonated: make(map[common.Address]struct{}),
+		signer: func(number *big.Int) types.Signer {
+			return types.MakeSigner(chain.Config(), number)
+		},
+		now:   time.Now,
+		txsCh: make(chan core.NewTxsEvent, txChanSize),
+		quit:  make(chan struct{}),
+	}, nil
+}
+
+// Protocols implements node.Service, returning the P2P network protocols used
+// by the sealer (none).
+func (s *Sealer) Protocols() []p2p.Protocol { return nil }
+
+// APIs implements node.Service, returning the RPC API endpoints controlling the
+// developer chain.
+func (s *Sealer) APIs() []rpc.API {
+	return []rpc.API{{
+		Namespace: "evm",
+		Version:   "1.0",
+		Service:   &API{s},
+		Public:    true,
+	}}
+}
+
+// Start implements node.Service, authorizing the local etherbase to seal blocks
+// and sealing a block for every new transaction from then on.
+func (s *Sealer) Start(server *p2p.Server) error {
+	etherbase, err := s.backend.Etherbase()
+	if err != nil {
+		return fmt.Errorf("etherbase missing: %v", err)
+	}
+	account := accounts.Account{Address: etherbase}
+	wallet, err := s.backend.AccountManager().Find(account)
+	if err != nil {
+		return fmt.Errorf("signer missing: %v", err)
+	}
+	s.engine.Authorize(etherbase, wallet.SignData)
+
+	s.lock.Lock()
+	s.account, s.wallet = account, wallet
+	s.lock.Unlock()
+
+	s.txsSub = s.backend.TxPool().SubscribeNewTxsEvent(s.txsCh)
+	s.wg.Add(1)
+	go s.loop()
+
+	// Seal anything that might have been pending before startup
+	s.txsCh <- core.NewTxsEvent{}
+	return nil
+}
+
+// Stop implements node.Service, terminating the sealer.
+func (s *Sealer) Stop() error {
+	if s.txsSub != nil {
+		s.txsSub.Unsubscribe()
+	}
+	close(s.quit)
+	s.wg.Wait()
+	return nil
+}
+
+// loop seals new blocks as transactions arrive, if automatic sealing is on.
+func (s *Sealer) loop() {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-s.txsCh:
+			s.lock.Lock()
+			if s.automine {
+				if _, err := s.seal(false); err != nil {
+					log.Error("Failed to seal developer block", "err", err)
+				}
+			}
+			s.lock.Unlock()
+
+		case <-s.txsSub.Err():
+			return
+		case <-s.quit:
+			return
+		}
+	}
+}
+
+// timestamp returns the timestamp of a new block on top of the given parent,
+// consuming any explicitly requested one.
+func (s *Sealer) timestamp(parent *types.Block) uint64 {
+	timestamp := uint64(s.now().Unix() + s.offset)
+	if s.next != nil {
+		timestamp, s.next = *s.next, nil
+	}
+	if timestamp <= parent.Time().Uint64() {
+		timestamp = parent.Time().Uint64() + 1
+	}
+	return timestamp
+}
+
+// seal assembles a block out of the pending impersonated and pooled transactions
+// on top of the current head, seals it and inserts it into the chain. Empty
+// blocks are only sealed if explicitly allowed, nil being returned otherwise.
+//
+// The method assumes the lock is held.
+func (s *Sealer) seal(empty bool) (*types.Block, error) {
+	if s.wallet == nil {
+		return nil, errSealerNotStarted
+	}
+	parent := s.chain.CurrentBlock()
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number(), common.Big1),
+		GasLimit:   core.CalcGasLimit(parent, s.config.GasFloor, s.config.GasCeil),
+		Extra:      s.config.Extra,
+	}
+	if err := s.engine.Prepare(s.chain, header); err != nil {
+		return nil, err
+	}
+	// The engine requires timestamps to follow the wall clock, override it
+	timestamp := s.timestamp(parent)
+	header.Time = new(big.Int).SetUint64(timestamp)
+
+	statedb, err := s.chain.StateAt(parent.Root())
+	if err != nil {
+		return nil, err
+	}
+	var (
+		signer   = s.signer(header.Number)
+		coinbase = s.account.Address
+		gaspool  = new(core.GasPool).AddGas(header.GasLimit)
+		txs      types.Transactions
+		receipts types.Receipts
+	)
+	apply := func(tx *types.Transaction) error {
+		snap := statedb.Snapshot()
+		statedb.Prepare(tx.Hash(), common.Hash{}, len(txs))
+
+		receipt, _, err := core.ApplyTransaction(s.chain.Config(), s.chain, &coinbase, gaspool, statedb, header, tx, &header.GasUsed, *s.chain.GetVMConfig())
+		if err != nil {
+			statedb.RevertToSnapshot(snap)
+			return err
+		}
+		txs = append(txs, tx)
+		receipts = append(receipts, receipt)
+		return nil
+	}
+	// Include the impersonated transactions first, dropping any failing ones
+	for _, tx := range s.queue {
+		if err := apply(tx); err != nil {
+			log.Warn("Dropping impersonated transaction", "hash", tx.Hash(), "err", err)
+		}
+	}
+	s.queue = nil
+
+	// Include all the executable transactions of the pool
+	pending, err := s.backend.TxPool().Pending()
+	if err != nil {
+		return nil, err
+	}
+	ordered := types.NewTransactionsByPriceAndNonce(signer, pending)
+	for tx := ordered.Peek(); tx != nil; tx = ordered.Peek() {
+		if gaspool.Gas() < params.TxGas {
+			break
+		}
+		switch err := apply(tx); err {
+		case core.ErrGasLimitReached, core.ErrNonceTooHigh:
+			ordered.Pop()
+		default:
+			ordered.Shift()
+		}
+	}
+	if len(txs) == 0 && !empty {
+		return nil, nil
+	}
+	block, err := s.engine.Finalize(s.chain, header, statedb, txs, nil, receipts)
+	if err != nil {
+		return nil, err
+	}
+	// Sign the block directly, the engine would wait for the timestamp to pass
+	header = block.Header()
+	sig, err := s.wallet.SignData(s.account, accounts.MimetypeClique, clique.CliqueRLP(header))
+	if err != nil {
+		return nil, err
+	}
+	copy(header.Extra[len(header.Extra)-65:], sig)
+	block = block.WithSeal(header)
+
+	// Update the block hash in all logs now that it is available
+	var logs []*types.Log
+	for _, receipt := range receipts {
+		for _, log := range receipt.Logs {
+			log.BlockHash = block.Hash()
+		}
+		logs = append(logs, receipt.Logs...)
+	}
+	if _, err := s.chain.WriteBlockWithState(block, receipts, statedb); err != nil {
+		return nil, err
+	}
+	log.Info("Sealed new developer block", "number", block.Number(), "hash", block.Hash(), "txs", len(txs), "time", timestamp)
+
+	s.backend.EventMux().Post(core.NewMinedBlockEvent{Block: block})
+	s.chain.PostChainEvents([]interface{}{
+		core.ChainEvent{Block: block, Hash: block.Hash(), Logs: logs},
+		core.ChainHeadEvent{Block: block},
+	}, logs)
+
+	return block, nil
+}
+
+// Mine seals a new block on top of the current head, even if there are no
+// transactions to include. If a timestamp is given, the block is sealed with
+// it, and subsequent blocks continue from there.
+func (s *Sealer) Mine(timestamp *uint64) (*types.Block, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if timestamp != nil {
+		if err := s.setNextTimestamp(*timestamp); err != nil {
+			return nil, err
+		}
+	}
+	return s.seal(true)
+}
+
+// SetAutomine sets whether a block is sealed for every new transaction.
+func (s *Sealer) SetAutomine(enabled bool) {
+	s.lock.Lock()
+	s.automine = enabled
+	s.lock.Unlock()
+
+	if enabled {
+		select {
+		case s.txsCh <- core.NewTxsEvent{}:
+		default:
+		}
+	}
+}
+
+// IncreaseTime shifts the clock used for new blocks by the given number of
+// seconds, returning the total shift.
+func (s *Sealer) IncreaseTime(seconds uint64) int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.offset += int64(seconds)
+	return s.offset
+}
+
+// SetNextTimestamp sets the timestamp of the next sealed block, shifting the
+// clock used for subsequent blocks accordingly.
+func (s *Sealer) SetNextTimestamp(timestamp uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.setNextTimestamp(timestamp)
+}
+
+// setNextTimestamp is the unlocked version of SetNextTimestamp.
+func (s *Sealer) setNextTimestamp(timestamp uint64) error {
+	if timestamp <= s.chain.CurrentBlock().Time().Uint64() {
+		return errTimestampTooLow
+	}
+	s.next = &timestamp
+	s.offset = int64(timestamp) - s.now().Unix()
+	return nil
+}
+
+// Snapshot records the current head of the chain and the clock shift, returning
+// an identifier the chain can be reverted with.
+func (s *Sealer) Snapshot() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.snapshotID++
+	s.snapshots[s.snapshotID] = snapshot{
+		number: s.chain.CurrentBlock().NumberU64(),
+		offset: s.offset,
+	}
+	return s.snapshotID
+}
+
+// Revert rewinds the chain and the clock shift to the given snapshot. The
+// snapshot and all the ones taken after it are discarded.
+func (s *Sealer) Revert(id uint64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	snap, ok := s.snapshots[id]
+	if !ok {
+		return errUnknownSnapshot
+	}
+	for other := range s.snapshots {
+		if other >= id {
+			delete(s.snapshots, other)
+		}
+	}
+	if err := s.chain.SetHead(snap.number); err != nil {
+		return err
+	}
+	s.offset, s.next = snap.offset, nil
+	s.queue = nil
+
+	// Let the transaction pool and other subscribers catch up with the new head
+	s.chain.PostChainEvents([]interface{}{core.ChainHeadEvent{Block: s.chain.CurrentBlock()}}, nil)
+	return nil
+}
+
+// Impersonate allows sending unsigned transactions on behalf of an account.
+func (s *Sealer) Impersonate(account common.Address) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.impersonated[account] = struct{}{}
+}
+
+// StopImpersonating disallows sending unsigned transactions on behalf of an
+// account.
+func (s *Sealer) StopImpersonating(account common.Address) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.impersonated, account)
+}
+
+// SendImpersonated queues an unsigned transaction of an impersonated account
+// for inclusion, sealing it right away if automatic sealing is on. The queued
+// transaction carries the impersonation marker of the account, so its sender
+// can be recovered from the chain later on, and is returned.
+func (s *Sealer) SendImpersonated(from common.Address, tx *types.Transaction) (*types.Transaction, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.chain.Config().Impersonation {
+		return nil, errNoImpersonation
+	}
+	if _, ok := s.impersonated[from]; !ok {
+		return nil, errNotImpersonated
+	}
+	tx = types.Impersonate(tx, from)
+	s.queue = append(s.queue, tx)
+
+	if s.automine {
+		if _, err := s.seal(false); err != nil {
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+// pendingNonce returns the next nonce of an impersonated account, taking the
+// queued transactions into account.
+func (s *Sealer) pendingNonce(from common.Address) (uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	statedb, err := s.chain.State()
+	if err != nil {
+		return 0, err
+	}
+	nonce := statedb.GetNonce(from)
+	for _, tx := range s.queue {
+		if sender, _ := types.ImpersonatedSender(tx); sender == from {
+			nonce++
+		}
+	}
+	return nonce, nil
+}
@@ -0,0 +1,384 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package devsealer
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/consensus"
+	"github.com/ethereum/go-ethereum/consensus/clique"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/event"
+	"github.com/ethereum/go-ethereum/params"
+)
+
+// testBackend is a developer chain with an unlocked, pre-funded signer.
+type testBackend struct {
+	db        ethdb.Database
+	am        *accounts.Manager
+	ks        *keystore.KeyStore
+	chain     *core.BlockChain
+	pool      *core.TxPool
+	mux       *event.TypeMux
+	engine    *clique.Clique
+	etherbase accounts.Account
+}
+
+func (b *testBackend) AccountManager() *accounts.Manager  { return b.am }
+func (b *testBackend) BlockChain() *core.BlockChain       { return b.chain }
+func (b *testBackend) TxPool() *core.TxPool               { return b.pool }
+func (b *testBackend) EventMux() *event.TypeMux           { return b.mux }
+func (b *testBackend) Engine() consensus.Engine           { return b.engine }
+func (b *testBackend) Etherbase() (common.Address, error) { return b.etherbase.Address, nil }
+
+// newTestSealer creates a started sealer on top of a fresh developer chain, with
+// its clock frozen.
+func newTestSealer(t *testing.T) (*Sealer, *testBackend, func()) {
+	dir, err := ioutil.TempDir("", "devsealer-test")
+	if err != nil {
+		t.Fatalf("failed to create keystore dir: %v", err)
+	}
+	ks := keystore.NewKeyStore(dir, keystore.LightScryptN, keystore.LightScryptP)
+	developer, err := ks.NewAccount("")
+	if err != nil {
+		t.Fatalf("failed to create developer account: %v", err)
+	}
+	if err := ks.Unlock(developer, ""); err != nil {
+		t.Fatalf("failed to unlock developer account: %v", err)
+	}
+	db := rawdb.NewMemoryDatabase()
+	spec := core.DeveloperGenesisBlock(0, developer.Address)
+	spec.Config.Impersonation = true
+	genesis := spec.MustCommit(db)
+
+	engine := clique.New(spec.Config.Clique, db)
+	chain, err := core.NewBlockChain(db, nil, spec.Config, engine, vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	poolConfig := core.DefaultTxPoolConfig
+	poolConfig.Journal = ""
+
+	backend := &testBackend{
+		db:        db,
+		am:        accounts.NewManager(ks),
+		ks:        ks,
+		chain:     chain,
+		pool:      core.NewTxPool(poolConfig, spec.Config, chain),
+		mux:       new(event.TypeMux),
+		engine:    engine,
+		etherbase: developer,
+	}
+	sealer, err := New(backend, &Config{GasFloor: genesis.GasLimit(), GasCeil: genesis.GasLimit()})
+	if err != nil {
+		t.Fatalf("failed to create sealer: %v", err)
+	}
+	now := time.Unix(int64(genesis.Time().Uint64())+1000, 0)
+	sealer.now = func() time.Time { return now }
+
+	if err := sealer.Start(nil); err != nil {
+		t.Fatalf("failed to start sealer: %v", err)
+	}
+	return sealer, backend, func() {
+		sealer.Stop()
+		backend.pool.Stop()
+		chain.Stop()
+		os.RemoveAll(dir)
+	}
+}
+
+// transfer signs a value transfer from the developer account.
+func (b *testBackend) transfer(t *testing.T, nonce uint64, to common.Address) *types.Transaction {
+	tx := types.NewTransaction(nonce, to, big.NewInt(1), params.TxGas, big.NewInt(1), nil)
+	signed, err := b.ks.SignTx(b.etherbase, tx, b.chain.Config().ChainID)
+	if err != nil {
+		t.Fatalf("failed to sign transaction: %v", err)
+	}
+	return signed
+}
+
+// Tests that pooled transactions are sealed into a block right away, and that
+// empty blocks are only sealed on demand.
+func TestInstantSeal(t *testing.T) {
+	sealer, backend, teardown := newTestSealer(t)
+	defer teardown()
+
+	heads := make(chan core.ChainHeadEvent, 1)
+	sub := backend.chain.SubscribeChainHeadEvent(heads)
+
+	to := common.Address{0xff}
+	if err := backend.pool.AddLocal(backend.transfer(t, 0, to)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	select {
+	case head := <-heads:
+		if head.Block.NumberU64() != 1 || len(head.Block.Transactions()) != 1 {
+			t.Fatalf("sealed block mismatch: number %d, txs %d", head.Block.NumberU64(), len(head.Block.Transactions()))
+		}
+		if err := backend.engine.VerifyHeader(backend.chain, head.Block.Header(), true); err != nil {
+			t.Fatalf("sealed block invalid: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("transaction not sealed")
+	}
+	sub.Unsubscribe()
+
+	// Disabling automatic sealing must leave transactions in the pool
+	sealer.SetAutomine(false)
+	if err := backend.pool.AddLocal(backend.transfer(t, 1, to)); err != nil {
+		t.Fatalf("failed to add transaction: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if number := backend.chain.CurrentBlock().NumberU64(); number != 1 {
+		t.Fatalf("block sealed with automine disabled: head %d", number)
+	}
+	block, err := sealer.Mine(nil)
+	if err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+	if block.NumberU64() != 2 || len(block.Transactions()) != 1 {
+		t.Fatalf("mined block mismatch: number %d, txs %d", block.NumberU64(), len(block.Transactions()))
+	}
+	// Mining on demand must seal empty blocks too
+	if block, err = sealer.Mine(nil); err != nil || len(block.Transactions()) != 0 {
+		t.Fatalf("failed to mine empty block: %v", err)
+	}
+}
+
+// Tests that block timestamps follow the shifted clock.
+func TestTimeTravel(t *testing.T) {
+	sealer, _, teardown := newTestSealer(t)
+	defer teardown()
+
+	now := uint64(sealer.now().Unix())
+	block, err := sealer.Mine(nil)
+	if err != nil {
+		t.Fatalf("failed to mine block: %v", err)
+	}
+	if block.Time().Uint64() != now {
+		t.Errorf("timestamp mismatch: have %d, want %d", block.Time(), now)
+	}
+	// Consecutive blocks must have increasing timestamps even with a frozen clock
+	if block, _ = sealer.Mine(nil); block.Time().Uint64() != now+1 {
+		t.Errorf("timestamp mismatch: have %d, want %d", block.Time(), now+1)
+	}
+	if offset := sealer.IncreaseTime(3600); offset != 3600 {
+		t.Errorf("offset mismatch: have %d, want %d", offset, 3600)
+	}
+	if block, _ = sealer.Mine(nil); block.Time().Uint64() != now+3600 {
+		t.Errorf("timestamp mismatch: have %d, want %d", block.Time(), now+3600)
+	}
+	// Explicit timestamps must be in the future and shift the clock for later blocks
+	if err := sealer.SetNextTimestamp(block.Time().Uint64()); err != errTimestampTooLow {
+		t.Errorf("stale timestamp error mismatch: have %v, want %v", err, errTimestampTooLow)
+	}
+	if err := sealer.SetNextTimestamp(now + 7200); err != nil {
+		t.Fatalf("failed to set next timestamp: %v", err)
+	}
+	if block, _ = sealer.Mine(nil); block.Time().Uint64() != now+7200 {
+		t.Errorf("timestamp mismatch: have %d, want %d", block.Time(), now+7200)
+	}
+	ts := now + 10000
+	if block, _ = sealer.Mine(&ts); block.Time().Uint64() != ts {
+		t.Errorf("timestamp mismatch: have %d, want %d", block.Time(), ts)
+	}
+	if block, _ = sealer.Mine(nil); block.Time().Uint64() != ts+1 {
+		t.Errorf("timestamp mismatch: have %d, want %d", block.Time(), ts+1)
+	}
+}
+
+// Tests that the chain and the clock can be reverted to earlier snapshots.
+func TestSnapshotRevert(t *testing.T) {
+	sealer, backend, teardown := newTestSealer(t)
+	defer teardown()
+
+	sealer.Mine(nil)
+	first := sealer.Snapshot()
+	sealer.IncreaseTime(100)
+	sealer.Mine(nil)
+	second := sealer.Snapshot()
+	sealer.Mine(nil)
+
+	if err := sealer.Revert(second); err != nil {
+		t.Fatalf("failed to revert to second snapshot: %v", err)
+	}
+	if number := backend.chain.CurrentBlock().NumberU64(); number != 2 {
+		t.Errorf("head mismatch after second revert: have %d, want %d", number, 2)
+	}
+	if err := sealer.Revert(first); err != nil {
+		t.Fatalf("failed to revert to first snapshot: %v", err)
+	}
+	if number := backend.chain.CurrentBlock().NumberU64(); number != 1 {
+		t.Errorf("head mismatch after first revert: have %d, want %d", number, 1)
+	}
+	if sealer.offset != 0 {
+		t.Errorf("offset mismatch after revert: have %d, want %d", sealer.offset, 0)
+	}
+	if err := sealer.Revert(second); err != errUnknownSnapshot {
+		t.Errorf("discarded snapshot error mismatch: have %v, want %v", err, errUnknownSnapshot)
+	}
+	// The chain must continue on top of the reverted head
+	block, err := sealer.Mine(nil)
+	if err != nil {
+		t.Fatalf("failed to mine after revert: %v", err)
+	}
+	if block.NumberU64() != 2 || backend.chain.CurrentBlock().Hash() != block.Hash() {
+		t.Errorf("head mismatch after mining: have %d, want %d", backend.chain.CurrentBlock().NumberU64(), 2)
+	}
+}
+
+// Tests that unsigned transactions of impersonated accounts are executed.
+func TestImpersonation(t *testing.T) {
+	sealer, backend, teardown := newTestSealer(t)
+	defer teardown()
+
+	var (
+		api   = &API{sealer}
+		from  = backend.etherbase.Address
+		to    = common.Address{0xff}
+		value = (*hexutil.Big)(big.NewInt(1000))
+	)
+	if _, err := api.SendTransaction(SendTxArgs{From: from, To: &to, Value: value}); err != errNotImpersonated {
+		t.Fatalf("non-impersonated error mismatch: have %v, want %v", err, errNotImpersonated)
+	}
+	api.ImpersonateAccount(from)
+	for i := 0; i < 2; i++ {
+		if _, err := api.SendTransaction(SendTxArgs{From: from, To: &to, Value: value}); err != nil {
+			t.Fatalf("failed to send impersonated transaction %d: %v", i, err)
+		}
+	}
+	statedb, _ := backend.chain.State()
+	if balance := statedb.GetBalance(to); balance.Cmp(big.NewInt(2000)) != 0 {
+		t.Errorf("recipient balance mismatch: have %v, want %v", balance, 2000)
+	}
+	if nonce := statedb.GetNonce(from); nonce != 2 {
+		t.Errorf("sender nonce mismatch: have %d, want %d", nonce, 2)
+	}
+	api.StopImpersonatingAccount(from)
+	if _, err := api.SendTransaction(SendTxArgs{From: from, To: &to, Value: value}); err != errNotImpersonated {
+		t.Fatalf("stopped impersonation error mismatch: have %v, want %v", err, errNotImpersonated)
+	}
+}
+
+// Tests that the senders of impersonated transactions can be recovered from a
+// reloaded chain, and that the blocks containing them can be re-executed.
+func TestImpersonationReload(t *testing.T) {
+	sealer, backend, teardown := newTestSealer(t)
+	defer teardown()
+
+	var (
+		api   = &API{sealer}
+		from  = common.Address{0xaa}
+		to    = common.Address{0xff}
+		value = (*hexutil.Big)(big.NewInt(0))
+		price = (*hexutil.Big)(big.NewInt(0))
+	)
+	api.ImpersonateAccount(from)
+	hash, err := api.SendTransaction(SendTxArgs{From: from, To: &to, Value: value, GasPrice: price})
+	if err != nil {
+		t.Fatalf("failed to send impersonated transaction: %v", err)
+	}
+	// Reload the chain with the configuration stored in the database
+	config, _, err := core.SetupGenesisBlock(backend.db, nil)
+	if err != nil {
+		t.Fatalf("failed to load chain config: %v", err)
+	}
+	chain, err := core.NewBlockChain(backend.db, nil, config, clique.New(config.Clique, backend.db), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to reload chain: %v", err)
+	}
+	defer chain.Stop()
+
+	block := chain.GetBlockByNumber(1)
+	if block == nil || len(block.Transactions()) != 1 || block.Transactions()[0].Hash() != hash {
+		t.Fatalf("impersonated transaction missing from reloaded chain")
+	}
+	sender, err := types.Sender(types.MakeSigner(config, block.Number()), block.Transactions()[0])
+	if err != nil || sender != from {
+		t.Fatalf("sender mismatch: have %x (%v), want %x", sender, err, from)
+	}
+	statedb, err := chain.StateAt(chain.GetBlockByNumber(0).Root())
+	if err != nil {
+		t.Fatalf("failed to retrieve parent state: %v", err)
+	}
+	receipts, _, usedGas, err := chain.Processor().Process(block, statedb, vm.Config{})
+	if err != nil {
+		t.Fatalf("failed to re-execute block: %v", err)
+	}
+	if err := chain.Validator().ValidateState(block, statedb, receipts, usedGas); err != nil {
+		t.Fatalf("re-executed block invalid: %v", err)
+	}
+	// Marked transactions must be rejected by chains not enabling impersonation
+	if _, err := types.Sender(types.MakeSigner(params.AllCliqueProtocolChanges, block.Number()), block.Transactions()[0]); err == nil {
+		t.Fatalf("impersonated sender accepted without impersonation enabled")
+	}
+}
+
+// Tests that impersonated transactions are only accepted from the local sealer,
+// neither the transaction pool nor block imports accept them from the network.
+func TestImpersonationFromNetwork(t *testing.T) {
+	sealer, backend, teardown := newTestSealer(t)
+	defer teardown()
+
+	var (
+		api   = &API{sealer}
+		from  = common.Address{0xaa}
+		to    = common.Address{0xff}
+		value = (*hexutil.Big)(big.NewInt(0))
+		price = (*hexutil.Big)(big.NewInt(0))
+	)
+	api.ImpersonateAccount(from)
+	if _, err := api.SendTransaction(SendTxArgs{From: from, To: &to, Value: value, GasPrice: price}); err != nil {
+		t.Fatalf("failed to send impersonated transaction: %v", err)
+	}
+	block := backend.chain.GetBlockByNumber(1)
+	if block == nil || len(block.Transactions()) != 1 {
+		t.Fatalf("impersonated transaction not sealed")
+	}
+	// Gossiped transactions carrying the marker are rejected
+	tx := types.Impersonate(types.NewTransaction(1, to, big.NewInt(0), params.TxGas, big.NewInt(0), nil), from)
+	if err := backend.pool.AddRemote(tx); err != core.ErrImpersonated {
+		t.Fatalf("pool error mismatch: have %v, want %v", err, core.ErrImpersonated)
+	}
+	// Another node of the same chain rejects the sealed block
+	db := rawdb.NewMemoryDatabase()
+	spec := core.DeveloperGenesisBlock(0, backend.etherbase.Address)
+	spec.Config.Impersonation = true
+	spec.MustCommit(db)
+
+	chain, err := core.NewBlockChain(db, nil, spec.Config, clique.New(spec.Config.Clique, db), vm.Config{}, nil)
+	if err != nil {
+		t.Fatalf("failed to create chain: %v", err)
+	}
+	defer chain.Stop()
+
+	if _, err := chain.InsertChain(types.Blocks{block}); err != core.ErrImpersonated {
+		t.Fatalf("import error mismatch: have %v, want %v", err, core.ErrImpersonated)
+	}
+}
@@ -354,7 +354,7 @@ func (w *worker) newWorkLoop(recommit time.Duration) {
 		case <-timer.C:
 			// If mining is running resubmit a new work cycle periodically to pull in
 			// higher priced transactions. Disable this overhead for pending blocks.
-			if w.isRunning() && (w.config.Clique == nil || w.config.Clique.Period > 0) {
+			if w.isRunning() && !w.sealsOnDemand() {
 				// Short circuit if no new transaction arrives.
 				if atomic.LoadInt32(&w.newTxs) == 0 {
 					timer.Reset(recommit)
@@ -469,7 +469,7 @@ func (w *worker) mainLoop() {
 				w.updateSnapshot()
 			} else {
 				// If we're mining, but nothing is being processed, wake on new transactions
-				if w.config.Clique != nil && w.config.Clique.Period == 0 {
+				if w.sealsOnDemand() {
 					w.commitNewWork(nil, false, time.Now().Unix())
 				}
 			}
@@ -606,6 +606,12 @@ func (w *worker) resultLoop() {
 	}
 }
 
+// sealsOnDemand returns whether the consensus engine is configured to only seal
+// blocks when there are transactions to include, instead of periodically.
+func (w *worker) sealsOnDemand() bool {
+	return (w.config.Clique != nil && w.config.Clique.Period == 0) || (w.config.IBFT != nil && w.config.IBFT.BlockPeriod == 0)
+}
+
 // makeCurrent creates a new environment for the current cycle.
 func (w *worker) makeCurrent(parent *types.Block, header *types.Header) error {
 	state, err := w.chain.StateAt(parent.Root())
@@ -19,6 +19,7 @@ package node
 import (
 	"context"
 	"fmt"
+	"net"
 	"strings"
 	"time"
 
@@ -106,6 +107,103 @@ func (api *PrivateAdminAPI) RemoveTrustedPeer(url string) (bool, error) {
 	return true, nil
 }
 
+// BanInfo is the JSON representation of a ban list entry.
+type BanInfo struct {
+	ID      string     `json:"id,omitempty"`      // Banned node ID
+	Net     string     `json:"net,omitempty"`     // Banned network in CIDR notation
+	Reason  string     `json:"reason,omitempty"`  // Reason given for the ban
+	Expires *time.Time `json:"expires,omitempty"` // End of the ban, absent if permanent
+}
+
+// BanPeer adds a node or network to the ban list and disconnects all peers the ban
+// applies to. The target is an enode URL, an IP address or a network in CIDR
+// notation. The ban lasts for the given number of seconds, or forever if no
+// duration is given. Both the duration and the reason are optional.
+func (api *PrivateAdminAPI) BanPeer(target string, seconds *uint64, reason *string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	ban, err := parseBanTarget(target)
+	if err != nil {
+		return false, err
+	}
+	if seconds != nil && *seconds > 0 {
+		ban.Expires = time.Now().Add(time.Duration(*seconds) * time.Second)
+	}
+	if reason != nil {
+		ban.Reason = *reason
+	}
+	if err := server.Ban(ban); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UnbanPeer removes a node or network from the ban list. The target is given in
+// the same format as for BanPeer.
+func (api *PrivateAdminAPI) UnbanPeer(target string) (bool, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return false, ErrNodeStopped
+	}
+	ban, err := parseBanTarget(target)
+	if err != nil {
+		return false, err
+	}
+	return server.Unban(ban)
+}
+
+// ListBans returns the current ban list.
+func (api *PrivateAdminAPI) ListBans() ([]*BanInfo, error) {
+	// Make sure the server is running, fail otherwise
+	server := api.node.Server()
+	if server == nil {
+		return nil, ErrNodeStopped
+	}
+	bans, err := server.Bans()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]*BanInfo, 0, len(bans))
+	for _, b := range bans {
+		info := &BanInfo{Reason: b.Reason}
+		if b.Net != nil {
+			info.Net = b.Net.String()
+		} else {
+			info.ID = b.ID.String()
+		}
+		if !b.Expires.IsZero() {
+			expires := b.Expires
+			info.Expires = &expires
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// parseBanTarget creates a ban for an enode URL, an IP address or a network
+// in CIDR notation.
+func parseBanTarget(target string) (enode.Ban, error) {
+	if _, network, err := net.ParseCIDR(target); err == nil {
+		return enode.Ban{Net: network}, nil
+	}
+	if ip := net.ParseIP(target); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		bits := len(ip) * 8
+		return enode.Ban{Net: &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}}, nil
+	}
+	node, err := enode.ParseV4(target)
+	if err != nil {
+		return enode.Ban{}, fmt.Errorf("invalid ban target %q: want enode URL, IP address or CIDR", target)
+	}
+	return enode.Ban{ID: node.ID()}, nil
+}
+
 // PeerEvents creates an RPC subscription which receives peer events from the
 // node's p2p.Server
 func (api *PrivateAdminAPI) PeerEvents(ctx context.Context) (*rpc.Subscription, error) {
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"sort"
 	"time"
 
 	"github.com/ethereum/go-ethereum/log"
@@ -37,6 +38,10 @@ const (
 	// once every few seconds.
 	lookupInterval = 4 * time.Second
 
+	// This is the number of dial candidates read from Server.discmix
+	// by a single discovery task.
+	discoverBatchSize = 16
+
 	// If no peers are found for this amount of time, the initial bootnodes are
 	// attempted to be connected.
 	fallbackInterval = 20 * time.Second
@@ -70,11 +75,14 @@ func (t TCPDialer) Dial(dest *enode.Node) (net.Conn, error) {
 type dialstate struct {
 	maxDynDials int
 	ntab        discoverTable
+	filter      func(*enode.Node) bool // protocol dial filter, may be nil
+	netlimit    *netLimit              // peer IP diversity limit, may be nil
+	nodedb      *enode.DB              // node database for dial prioritisation and bans, may be nil
 	netrestrict *netutil.Netlist
 	self        enode.ID
 
 	lookupRunning bool
-	dialing       map[enode.ID]connFlag
+	dialing       map[enode.ID]*dialTask
 	lookupBuf     []*enode.Node // current discovery lookup results
 	randomNodes   []*enode.Node // filled from Table
 	static        map[enode.ID]*dialTask
@@ -87,8 +95,8 @@ type dialstate struct {
 type discoverTable interface {
 	Close()
 	Resolve(*enode.Node) *enode.Node
-	LookupRandom() []*enode.Node
 	ReadRandomNodes([]*enode.Node) int
+	RequestENR(*enode.Node) (*enode.Node, error)
 }
 
 // the dial history remembers recent dials.
@@ -113,9 +121,8 @@ type dialTask struct {
 	resolveDelay time.Duration
 }
 
-// discoverTask runs discovery table operations.
+// discoverTask reads dial candidates from the server's candidate sources.
 // Only one discoverTask is active at any time.
-// discoverTask.Do performs a random lookup.
 type discoverTask struct {
 	results []*enode.Node
 }
@@ -126,14 +133,15 @@ type waitExpireTask struct {
 	time.Duration
 }
 
-func newDialState(self enode.ID, static []*enode.Node, bootnodes []*enode.Node, ntab discoverTable, maxdyn int, netrestrict *netutil.Netlist) *dialstate {
+func newDialState(self enode.ID, static []*enode.Node, bootnodes []*enode.Node, ntab discoverTable, nodedb *enode.DB, maxdyn int, netrestrict *netutil.Netlist) *dialstate {
 	s := &dialstate{
 		maxDynDials: maxdyn,
 		ntab:        ntab,
+		nodedb:      nodedb,
 		self:        self,
 		netrestrict: netrestrict,
 		static:      make(map[enode.ID]*dialTask),
-		dialing:     make(map[enode.ID]connFlag),
+		dialing:     make(map[enode.ID]*dialTask),
 		bootnodes:   make([]*enode.Node, len(bootnodes)),
 		randomNodes: make([]*enode.Node, maxdyn/2),
 		hist:        new(dialHistory),
@@ -166,12 +174,17 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 
 	var newtasks []task
 	addDial := func(flag connFlag, n *enode.Node) bool {
-		if err := s.checkDial(n, peers); err != nil {
+		err := s.checkDial(n, peers)
+		if err == nil && !s.netlimit.allow(peers, s.dialingIPs(), n.IP()) {
+			err = errSubnetLimit
+		}
+		if err != nil {
 			log.Trace("Skipping dial candidate", "id", n.ID(), "addr", &net.TCPAddr{IP: n.IP(), Port: n.TCP()}, "err", err)
 			return false
 		}
-		s.dialing[n.ID()] = flag
-		newtasks = append(newtasks, &dialTask{flags: flag, dest: n})
+		t := &dialTask{flags: flag, dest: n}
+		s.dialing[n.ID()] = t
+		newtasks = append(newtasks, t)
 		return true
 	}
 
@@ -182,8 +195,8 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 			needDynDials--
 		}
 	}
-	for _, flag := range s.dialing {
-		if flag&dynDialedConn != 0 {
+	for _, t := range s.dialing {
+		if t.flags&dynDialedConn != 0 {
 			needDynDials--
 		}
 	}
@@ -199,7 +212,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 			log.Warn("Removing static dial candidate", "id", t.dest.ID, "addr", &net.TCPAddr{IP: t.dest.IP(), Port: t.dest.TCP()}, "err", err)
 			delete(s.static, t.dest.ID())
 		case nil:
-			s.dialing[id] = t.flags
+			s.dialing[id] = t
 			newtasks = append(newtasks, t)
 		}
 	}
@@ -216,18 +229,26 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 		}
 	}
 	// Use random nodes from the table for half of the necessary
-	// dynamic dials.
+	// dynamic dials. Nodes with a known record must pass the protocol
+	// dial filter, the candidate sources filter their nodes themselves.
 	randomCandidates := needDynDials / 2
-	if randomCandidates > 0 {
+	if randomCandidates > 0 && s.ntab != nil {
 		n := s.ntab.ReadRandomNodes(s.randomNodes)
+		s.sortByQuality(s.randomNodes[:n])
 		for i := 0; i < randomCandidates && i < n; i++ {
-			if addDial(dynDialedConn, s.randomNodes[i]) {
+			node := s.randomNodes[i]
+			if s.filter != nil && hasRecord(node) && !s.filter(node) {
+				log.Trace("Skipping dial candidate", "id", node.ID(), "addr", &net.TCPAddr{IP: node.IP(), Port: node.TCP()}, "err", errFiltered)
+				continue
+			}
+			if addDial(dynDialedConn, node) {
 				needDynDials--
 			}
 		}
 	}
-	// Create dynamic dials from random lookup results, removing tried
+	// Create dynamic dials from the candidate sources, removing tried
 	// items from the result buffer.
+	s.sortByQuality(s.lookupBuf)
 	i := 0
 	for ; i < len(s.lookupBuf) && needDynDials > 0; i++ {
 		if addDial(dynDialedConn, s.lookupBuf[i]) {
@@ -235,7 +256,7 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 		}
 	}
 	s.lookupBuf = s.lookupBuf[:copy(s.lookupBuf, s.lookupBuf[i:])]
-	// Launch a discovery lookup if more candidates are needed.
+	// Read more candidates if needed.
 	if len(s.lookupBuf) < needDynDials && !s.lookupRunning {
 		s.lookupRunning = true
 		newtasks = append(newtasks, &discoverTask{})
@@ -252,14 +273,100 @@ func (s *dialstate) newTasks(nRunning int, peers map[enode.ID]*Peer, now time.Ti
 	return newtasks
 }
 
+// sortByQuality orders dial candidates by the quality measured on previous
+// connections to them, best first. Unknown nodes are placed in between the
+// ones that performed well and the ones that misbehaved.
+func (s *dialstate) sortByQuality(nodes []*enode.Node) {
+	if s.nodedb == nil || len(nodes) < 2 {
+		return
+	}
+	scores := make(map[enode.ID]float64, len(nodes))
+	for _, n := range nodes {
+		scores[n.ID()] = s.nodedb.NodeQuality(n.ID()).Score()
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return scores[nodes[i].ID()] > scores[nodes[j].ID()]
+	})
+}
+
 var (
 	errSelf             = errors.New("is self")
 	errAlreadyDialing   = errors.New("already dialing")
 	errAlreadyConnected = errors.New("already connected")
 	errRecentlyDialed   = errors.New("recently dialed")
 	errNotWhitelisted   = errors.New("not contained in netrestrict whitelist")
+	errBanned           = errors.New("banned")
+	errSubnetLimit      = errors.New("too many peers in subnet")
+	errFiltered         = errors.New("rejected by protocol dial filter")
 )
 
+// hasRecord reports whether the node carries a record obtained from the node itself,
+// as opposed to one created from discovery v4 endpoint information.
+func hasRecord(n *enode.Node) bool {
+	return n.Seq() > 0
+}
+
+// protocolDialFilter combines the dial filters of the given protocols. Nodes are
+// accepted if any filter accepts them, nil is returned if no protocol has a filter.
+func protocolDialFilter(protocols []Protocol) func(*enode.Node) bool {
+	var filters []func(*enode.Node) bool
+	for _, p := range protocols {
+		if p.DialFilter != nil {
+			filters = append(filters, p.DialFilter)
+		}
+	}
+	if len(filters) == 0 {
+		return nil
+	}
+	return func(n *enode.Node) bool {
+		for _, f := range filters {
+			if f(n) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// enrRequester is implemented by the discovery tables. It fetches the record of a node.
+type enrRequester interface {
+	RequestENR(*enode.Node) (*enode.Node, error)
+}
+
+// checkDiscoveredNode is the dial filter for nodes found by discovery. It fetches
+// the record of the node from the table it was found in if it isn't known yet and
+// checks it against the protocol dial filter. Nodes whose record can't be fetched
+// are accepted.
+func (srv *Server) checkDiscoveredNode(tab enrRequester, n *enode.Node) bool {
+	if srv.dialFilter == nil {
+		return true
+	}
+	if !hasRecord(n) {
+		if rn, err := tab.RequestENR(n); err == nil {
+			n = rn
+		}
+	}
+	if hasRecord(n) && !srv.dialFilter(n) {
+		log.Trace("Skipping dial candidate", "id", n.ID(), "addr", &net.TCPAddr{IP: n.IP(), Port: n.TCP()}, "err", errFiltered)
+		return false
+	}
+	return true
+}
+
+// discoveredNodeFilter returns checkDiscoveredNode for nodes found in tab.
+func (srv *Server) discoveredNodeFilter(tab enrRequester) func(*enode.Node) bool {
+	return func(n *enode.Node) bool { return srv.checkDiscoveredNode(tab, n) }
+}
+
+// dialingIPs returns the IP addresses of all in-flight dials.
+func (s *dialstate) dialingIPs() []net.IP {
+	ips := make([]net.IP, 0, len(s.dialing))
+	for _, t := range s.dialing {
+		ips = append(ips, t.dest.IP())
+	}
+	return ips
+}
+
 func (s *dialstate) checkDial(n *enode.Node, peers map[enode.ID]*Peer) error {
 	_, dialing := s.dialing[n.ID()]
 	switch {
@@ -273,6 +380,8 @@ func (s *dialstate) checkDial(n *enode.Node, peers map[enode.ID]*Peer) error {
 		return errNotWhitelisted
 	case s.hist.contains(n.ID()):
 		return errRecentlyDialed
+	case s.nodedb != nil && s.nodedb.CheckBan(n.ID(), n.IP()) != nil:
+		return errBanned
 	}
 	return nil
 }
@@ -368,7 +477,7 @@ func (t *discoverTask) Do(srv *Server) {
 		time.Sleep(next.Sub(now))
 	}
 	srv.lastLookup = time.Now()
-	t.results = srv.ntab.LookupRandom()
+	t.results = enode.ReadNodes(srv.discmix, discoverBatchSize)
 }
 
 func (t *discoverTask) String() string {
@@ -18,6 +18,7 @@ package p2p
 
 import (
 	"encoding/binary"
+	"errors"
 	"net"
 	"reflect"
 	"testing"
@@ -85,11 +86,19 @@ func (t fakeTable) Close()                                {}
 func (t fakeTable) LookupRandom() []*enode.Node           { return nil }
 func (t fakeTable) Resolve(*enode.Node) *enode.Node       { return nil }
 func (t fakeTable) ReadRandomNodes(buf []*enode.Node) int { return copy(buf, t) }
+func (t fakeTable) RequestENR(n *enode.Node) (*enode.Node, error) {
+	for _, tn := range t {
+		if tn.ID() == n.ID() {
+			return tn, nil
+		}
+	}
+	return nil, errors.New("not found")
+}
 
 // This test checks that dynamic dials are launched from discovery results.
 func TestDialStateDynDial(t *testing.T) {
 	runDialTest(t, dialtest{
-		init: newDialState(enode.ID{}, nil, nil, fakeTable{}, 5, nil),
+		init: newDialState(enode.ID{}, nil, nil, fakeTable{}, nil, 5, nil),
 		rounds: []round{
 			// A discovery query is launched.
 			{
@@ -236,7 +245,7 @@ func TestDialStateDynDialBootnode(t *testing.T) {
 		newNode(uintID(8), nil),
 	}
 	runDialTest(t, dialtest{
-		init: newDialState(enode.ID{}, nil, bootnodes, table, 5, nil),
+		init: newDialState(enode.ID{}, nil, bootnodes, table, nil, 5, nil),
 		rounds: []round{
 			// 2 dynamic dials attempted, bootnodes pending fallback interval
 			{
@@ -324,7 +333,7 @@ func TestDialStateDynDialFromTable(t *testing.T) {
 	}
 
 	runDialTest(t, dialtest{
-		init: newDialState(enode.ID{}, nil, nil, table, 10, nil),
+		init: newDialState(enode.ID{}, nil, nil, table, nil, 10, nil),
 		rounds: []round{
 			// 5 out of 8 of the nodes returned by ReadRandomNodes are dialed.
 			{
@@ -430,7 +439,7 @@ func TestDialStateNetRestrict(t *testing.T) {
 	restrict.Add("127.0.2.0/24")
 
 	runDialTest(t, dialtest{
-		init: newDialState(enode.ID{}, nil, nil, table, 10, restrict),
+		init: newDialState(enode.ID{}, nil, nil, table, nil, 10, restrict),
 		rounds: []round{
 			{
 				new: []task{
@@ -442,6 +451,156 @@ func TestDialStateNetRestrict(t *testing.T) {
 	})
 }
 
+// This test checks that candidates are read from the candidate sources when
+// there is no discovery table.
+func TestDialStateNoTable(t *testing.T) {
+	runDialTest(t, dialtest{
+		init: newDialState(enode.ID{}, nil, nil, nil, nil, 2, nil),
+		rounds: []round{
+			{
+				new: []task{&discoverTask{}},
+			},
+			// Candidates are dialed when the task completes.
+			{
+				done: []task{
+					&discoverTask{results: []*enode.Node{
+						newNode(uintID(1), nil),
+						newNode(uintID(2), nil),
+						newNode(uintID(3), nil),
+					}},
+				},
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: newNode(uintID(1), nil)},
+					&dialTask{flags: dynDialedConn, dest: newNode(uintID(2), nil)},
+				},
+			},
+			// No more dials are launched while the peer slots are filled.
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(1), nil)}},
+					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(2), nil)}},
+				},
+				done: []task{
+					&dialTask{flags: dynDialedConn, dest: newNode(uintID(1), nil)},
+					&dialTask{flags: dynDialedConn, dest: newNode(uintID(2), nil)},
+				},
+				new: []task{&waitExpireTask{Duration: dialHistoryExpiration}},
+			},
+		},
+	})
+}
+
+// This test checks that discoverTask reads candidates from the server's sources.
+func TestDiscoverTaskSources(t *testing.T) {
+	nodes := []*enode.Node{newNode(uintID(1), nil), newNode(uintID(2), nil), newNode(uintID(3), nil)}
+	srv := &Server{discmix: enode.NewFairMix(0)}
+	defer srv.discmix.Close()
+	srv.discmix.AddSource(enode.CycleNodes(nodes))
+
+	task := new(discoverTask)
+	task.Do(srv)
+	if len(task.results) != len(nodes) {
+		t.Fatalf("wrong number of results: got %d, want %d", len(task.results), len(nodes))
+	}
+	for _, n := range nodes {
+		found := false
+		for _, rn := range task.results {
+			found = found || rn.ID() == n.ID()
+		}
+		if !found {
+			t.Errorf("node %v missing from results", n.ID())
+		}
+	}
+}
+
+// This test checks that dynamic dial candidates with a known record are only
+// dialed if the protocol dial filter accepts them.
+func TestDialStateFilter(t *testing.T) {
+	table := fakeTable{
+		newFilterNode(uintID(1), true),
+		newFilterNode(uintID(2), false),
+		newFilterNode(uintID(3), true),
+		newNode(uintID(4), nil), // record unknown
+	}
+	state := newDialState(enode.ID{}, nil, nil, table, nil, 10, nil)
+	state.filter = testDialFilter
+
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+			{
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: table[0]},
+					&dialTask{flags: dynDialedConn, dest: table[2]},
+					&dialTask{flags: dynDialedConn, dest: table[3]},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
+// This test checks that banned nodes are not dialed.
+func TestDialStateBanned(t *testing.T) {
+	db, _ := enode.OpenDB("")
+	defer db.Close()
+	db.AddBan(enode.Ban{ID: uintID(2)})
+
+	state := newDialState(enode.ID{}, nil, nil, nil, db, 10, nil)
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+			{
+				new: []task{&discoverTask{}},
+			},
+			{
+				done: []task{
+					&discoverTask{results: []*enode.Node{
+						newNode(uintID(1), nil),
+						newNode(uintID(2), nil),
+					}},
+				},
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: newNode(uintID(1), nil)},
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
+
+// This test checks that the record of nodes found by discovery is fetched
+// before they are checked against the dial filter.
+func TestCheckDiscoveredNode(t *testing.T) {
+	rejected := newFilterNode(uintID(1), false)
+	tab := fakeTable{rejected}
+	srv := &Server{dialFilter: testDialFilter}
+
+	if srv.checkDiscoveredNode(tab, newNode(uintID(1), net.IP{127, 0, 0, 1})) {
+		t.Fatalf("node with rejected record passed filter")
+	}
+	// Nodes whose record can't be fetched are accepted.
+	if !srv.checkDiscoveredNode(tab, newNode(uintID(2), net.IP{127, 0, 0, 1})) {
+		t.Fatalf("node without record rejected")
+	}
+}
+
+// newFilterNode creates a node with a known record, which is accepted by
+// testDialFilter if ok is true.
+func newFilterNode(id enode.ID, ok bool) *enode.Node {
+	var r enr.Record
+	r.Set(enr.IP{127, 0, 0, 1})
+	if ok {
+		r.Set(enr.WithEntry("test", uint(1)))
+	}
+	r.SetSeq(1)
+	return enode.SignNull(&r, id)
+}
+
+func testDialFilter(n *enode.Node) bool {
+	return n.Load(enr.WithEntry("test", new(uint))) == nil
+}
+
 // This test checks that static dials are launched.
 func TestDialStateStaticDial(t *testing.T) {
 	wantStatic := []*enode.Node{
@@ -453,7 +612,7 @@ func TestDialStateStaticDial(t *testing.T) {
 	}
 
 	runDialTest(t, dialtest{
-		init: newDialState(enode.ID{}, wantStatic, nil, fakeTable{}, 0, nil),
+		init: newDialState(enode.ID{}, wantStatic, nil, fakeTable{}, nil, 0, nil),
 		rounds: []round{
 			// Static dials are launched for the nodes that
 			// aren't yet connected.
@@ -557,7 +716,7 @@ func TestDialStaticAfterReset(t *testing.T) {
 		},
 	}
 	dTest := dialtest{
-		init:   newDialState(enode.ID{}, wantStatic, nil, fakeTable{}, 0, nil),
+		init:   newDialState(enode.ID{}, wantStatic, nil, fakeTable{}, nil, 0, nil),
 		rounds: rounds,
 	}
 	runDialTest(t, dTest)
@@ -578,7 +737,7 @@ func TestDialStateCache(t *testing.T) {
 	}
 
 	runDialTest(t, dialtest{
-		init: newDialState(enode.ID{}, wantStatic, nil, fakeTable{}, 0, nil),
+		init: newDialState(enode.ID{}, wantStatic, nil, fakeTable{}, nil, 0, nil),
 		rounds: []round{
 			// Static dials are launched for the nodes that
 			// aren't yet connected.
@@ -640,7 +799,7 @@ func TestDialStateCache(t *testing.T) {
 func TestDialResolve(t *testing.T) {
 	resolved := newNode(uintID(1), net.IP{127, 0, 55, 234})
 	table := &resolveMock{answer: resolved}
-	state := newDialState(enode.ID{}, nil, nil, table, 0, nil)
+	state := newDialState(enode.ID{}, nil, nil, table, nil, 0, nil)
 
 	// Check that the task is generated with an incomplete ID.
 	dest := newNode(uintID(1), nil)
@@ -703,3 +862,6 @@ func (t *resolveMock) Self() *enode.Node                     { return new(enode.
 func (t *resolveMock) Close()                                {}
 func (t *resolveMock) LookupRandom() []*enode.Node           { return nil }
 func (t *resolveMock) ReadRandomNodes(buf []*enode.Node) int { return 0 }
+func (t *resolveMock) RequestENR(n *enode.Node) (*enode.Node, error) {
+	return nil, errors.New("not supported")
+}
@@ -0,0 +1,86 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// lookupRetryDelay is the time waited before retrying a random lookup which
+// didn't yield any nodes, e.g. because the table is empty.
+const lookupRetryDelay = 4 * time.Second
+
+// lookupIterator performs random lookups in the discovery table, returning the
+// nodes found by each lookup.
+type lookupIterator struct {
+	tab    *Table
+	buffer []*enode.Node
+	cur    *enode.Node
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// RandomNodes returns an iterator which finds random nodes in the network by
+// performing lookups.
+func (tab *Table) RandomNodes() enode.Iterator {
+	return &lookupIterator{tab: tab, closed: make(chan struct{})}
+}
+
+// Next moves to the next node, running a new lookup when the results of the
+// previous one have been consumed.
+func (it *lookupIterator) Next() bool {
+	for {
+		select {
+		case <-it.closed:
+			return false
+		case <-it.tab.closeReq:
+			return false
+		default:
+		}
+		if len(it.buffer) > 0 {
+			break
+		}
+		it.buffer = it.tab.LookupRandom()
+		if len(it.buffer) > 0 {
+			break
+		}
+		// Wait a bit before retrying to avoid spinning on an empty table.
+		select {
+		case <-time.After(lookupRetryDelay):
+		case <-it.closed:
+			return false
+		case <-it.tab.closeReq:
+			return false
+		}
+	}
+	it.cur, it.buffer = it.buffer[0], it.buffer[1:]
+	return true
+}
+
+// Node returns the current node.
+func (it *lookupIterator) Node() *enode.Node {
+	return it.cur
+}
+
+// Close ends the iterator.
+func (it *lookupIterator) Close() {
+	it.closeOnce.Do(func() { close(it.closed) })
+}
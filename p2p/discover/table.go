@@ -88,8 +88,9 @@ type Table struct {
 // sockets and without generating a private key.
 type transport interface {
 	self() *enode.Node
-	ping(enode.ID, *net.UDPAddr) error
-	findnode(toid enode.ID, addr *net.UDPAddr, target encPubkey) ([]*node, error)
+	ping(*enode.Node) (seq uint64, err error)
+	findnode(n *node, target encPubkey) ([]*node, error)
+	requestENR(*enode.Node) (*enode.Node, error)
 	close()
 }
 
@@ -174,10 +175,10 @@ func (tab *Table) ReadRandomNodes(buf []*enode.Node) (n int) {
 			buckets = append(buckets[:j], buckets[j+1:]...)
 		}
 		if len(buckets) == 0 {
-			break
+			return i + 1
 		}
 	}
-	return i + 1
+	return i
 }
 
 // Close terminates the network listener and flushes the node database.
@@ -237,6 +238,11 @@ func (tab *Table) Resolve(n *enode.Node) *enode.Node {
 	return nil
 }
 
+// RequestENR fetches the current record of the given node via the network.
+func (tab *Table) RequestENR(n *enode.Node) (*enode.Node, error) {
+	return tab.net.requestENR(n)
+}
+
 // LookupRandom finds random nodes in the network.
 func (tab *Table) LookupRandom() []*enode.Node {
 	var target encPubkey
@@ -248,8 +254,16 @@ func (tab *Table) LookupRandom() []*enode.Node {
 // target by querying nodes that are closer to it on each iteration. The given target does
 // not need to be an actual node identifier.
 func (tab *Table) lookup(targetKey encPubkey, refreshIfEmpty bool) []*node {
+	target := enode.ID(crypto.Keccak256Hash(targetKey[:]))
+	return tab.lookupWith(target, refreshIfEmpty, func(n *node) ([]*node, error) {
+		return tab.net.findnode(n, targetKey)
+	})
+}
+
+// lookupWith performs a lookup towards target, using the query function to ask
+// each node for the nodes it knows about near the target.
+func (tab *Table) lookupWith(target enode.ID, refreshIfEmpty bool, query func(*node) ([]*node, error)) []*node {
 	var (
-		target         = enode.ID(crypto.Keccak256Hash(targetKey[:]))
 		asked          = make(map[enode.ID]bool)
 		seen           = make(map[enode.ID]bool)
 		reply          = make(chan []*node, alpha)
@@ -283,7 +297,7 @@ func (tab *Table) lookup(targetKey encPubkey, refreshIfEmpty bool) []*node {
 			if !asked[n.ID()] {
 				asked[n.ID()] = true
 				pendingQueries++
-				go tab.findnode(n, targetKey, reply)
+				go tab.findnode(n, query, reply)
 			}
 		}
 		if pendingQueries == 0 {
@@ -306,9 +320,9 @@ func (tab *Table) lookup(targetKey encPubkey, refreshIfEmpty bool) []*node {
 	return result.entries
 }
 
-func (tab *Table) findnode(n *node, targetKey encPubkey, reply chan<- []*node) {
+func (tab *Table) findnode(n *node, query func(*node) ([]*node, error), reply chan<- []*node) {
 	fails := tab.db.FindFails(n.ID(), n.IP())
-	r, err := tab.net.findnode(n.ID(), n.addr(), targetKey)
+	r, err := query(n)
 	if err == errClosed {
 		// Avoid recording failures on shutdown.
 		reply <- nil
@@ -459,7 +473,17 @@ func (tab *Table) doRevalidate(done chan<- struct{}) {
 	}
 
 	// Ping the selected node and wait for a pong.
-	err := tab.net.ping(last.ID(), last.addr())
+	remoteSeq, err := tab.net.ping(unwrapNode(last))
+
+	// Also fetch record if the node replied and returned a higher sequence number.
+	if err == nil && last.Seq() < remoteSeq {
+		n, rerr := tab.net.requestENR(unwrapNode(last))
+		if rerr != nil {
+			log.Debug("ENR request failed", "id", last.ID(), "addr", last.addr(), "err", rerr)
+		} else {
+			last = &node{Node: *n, addedAt: last.addedAt, livenessChecks: last.livenessChecks}
+		}
+	}
 
 	tab.mutex.Lock()
 	defer tab.mutex.Unlock()
@@ -535,6 +559,20 @@ func (tab *Table) closest(target enode.ID, nresults int) *nodesByDistance {
 	return close
 }
 
+// getNode returns the node with the given ID or nil if it isn't in the table.
+func (tab *Table) getNode(id enode.ID) *enode.Node {
+	tab.mutex.Lock()
+	defer tab.mutex.Unlock()
+
+	b := tab.bucket(id)
+	for _, e := range b.entries {
+		if e.ID() == id {
+			return unwrapNode(e)
+		}
+	}
+	return nil
+}
+
 func (tab *Table) len() (n int) {
 	for _, b := range &tab.buckets {
 		n += len(b.entries)
@@ -545,6 +583,11 @@ func (tab *Table) len() (n int) {
 // bucket returns the bucket for the given node ID hash.
 func (tab *Table) bucket(id enode.ID) *bucket {
 	d := enode.LogDist(tab.self().ID(), id)
+	return tab.bucketAtDistance(d)
+}
+
+// bucketAtDistance returns the bucket holding nodes at the given log distance.
+func (tab *Table) bucketAtDistance(d int) *bucket {
 	if d <= bucketMinDistance {
 		return tab.buckets[0]
 	}
@@ -710,6 +753,11 @@ func (tab *Table) bumpInBucket(b *bucket, n *node) bool {
 					return false
 				}
 			}
+			// Keep the stored record if the update carries an older one
+			// for the same endpoint.
+			if old := b.entries[i]; n.Seq() < old.Seq() && n.IP().Equal(old.IP()) && n.UDP() == old.UDP() {
+				n = old
+			}
 			// Move it to the front.
 			copy(b.entries[1:], b.entries[:i])
 			b.entries[0] = n
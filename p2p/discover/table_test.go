@@ -282,6 +282,24 @@ func TestTable_ReadRandomNodesGetAll(t *testing.T) {
 	}
 }
 
+// This test checks that ReadRandomNodes doesn't overflow the buffer when the
+// table contains more nodes than requested.
+func TestTable_ReadRandomNodesBufferFull(t *testing.T) {
+	transport := newPingRecorder()
+	tab, db := newTestTable(transport)
+	defer db.Close()
+	defer tab.Close()
+	<-tab.initDone
+
+	for ld := 240; ld < 256; ld++ {
+		fillTable(tab, []*node{nodeAtDistance(tab.self().ID(), ld, intIP(ld))})
+	}
+	buf := make([]*enode.Node, 4)
+	if n := tab.ReadRandomNodes(buf); n != len(buf) {
+		t.Errorf("wrong number of nodes, got %d, want %d", n, len(buf))
+	}
+}
+
 type closeTest struct {
 	Self   enode.ID
 	Target enode.ID
@@ -368,6 +386,72 @@ func TestTable_addSeenNode(t *testing.T) {
 	checkIPLimitInvariant(t, tab)
 }
 
+// This test checks that ENR updates happen during revalidation. If a node in the table
+// announces a new sequence number, the new record should be pulled.
+func TestTable_revalidateSyncRecord(t *testing.T) {
+	transport := newPingRecorder()
+	tab, db := newTestTable(transport)
+	<-tab.initDone
+	defer db.Close()
+	defer tab.Close()
+
+	// Insert a node.
+	var r enr.Record
+	r.Set(enr.IP(net.IP{127, 0, 0, 1}))
+	id := enode.ID{1}
+	n1 := wrapNode(enode.SignNull(&r, id))
+	tab.addSeenNode(n1)
+
+	// Update the node record.
+	r.Set(enr.WithEntry("foo", "bar"))
+	n2 := enode.SignNull(&r, id)
+	transport.updateRecord(n2)
+
+	tab.doRevalidate(make(chan struct{}, 1))
+	tab.mutex.Lock()
+	intable := unwrapNode(tab.bucket(id).entries[0])
+	tab.mutex.Unlock()
+	if !reflect.DeepEqual(intable, n2) {
+		t.Fatalf("table contains old record with seq %d, want seq %d", intable.Seq(), n2.Seq())
+	}
+
+	// Pinging the table with the outdated record must not replace it.
+	tab.addVerifiedNode(n1)
+	tab.mutex.Lock()
+	intable = unwrapNode(tab.bucket(id).entries[0])
+	tab.mutex.Unlock()
+	if intable.Seq() != n2.Seq() {
+		t.Fatalf("table record replaced by older one: have seq %d, want seq %d", intable.Seq(), n2.Seq())
+	}
+}
+
+// This test checks that RandomNodes returns the results of lookups and ends when
+// it is closed.
+func TestTable_RandomNodes(t *testing.T) {
+	tab, db := newTestTable(newPingRecorder())
+	<-tab.initDone
+	defer db.Close()
+	defer tab.Close()
+
+	for i := 0; i < 10; i++ {
+		ld := 256 - i
+		n := nodeAtDistance(tab.self().ID(), ld, intIP(ld))
+		n.livenessChecks = 1
+		fillTable(tab, []*node{n})
+	}
+	it := tab.RandomNodes()
+	if !it.Next() {
+		t.Fatal("Next returned false")
+	}
+	if n := it.Node(); !contains(tab.bucket(n.ID()).entries, n.ID()) {
+		t.Errorf("returned node %v not in table", n.ID())
+	}
+	it.Close()
+	if it.Next() {
+		t.Fatal("Next returned true after Close")
+	}
+}
+
 func TestTable_Lookup(t *testing.T) {
 	tab, db := newTestTable(lookupTestnet)
 	defer db.Close()
@@ -605,15 +689,15 @@ func (tn *preminedTestnet) self() *enode.Node {
 	return nullNode
 }
 
-func (tn *preminedTestnet) findnode(toid enode.ID, toaddr *net.UDPAddr, target encPubkey) ([]*node, error) {
+func (tn *preminedTestnet) findnode(n *node, target encPubkey) ([]*node, error) {
 	// current log distance is encoded in port number
-	// fmt.Println("findnode query at dist", toaddr.Port)
-	if toaddr.Port == 0 {
+	// fmt.Println("findnode query at dist", n.UDP())
+	if n.UDP() == 0 {
 		panic("query to node at distance 0")
 	}
-	next := toaddr.Port - 1
+	next := n.UDP() - 1
 	var result []*node
-	for i, ekey := range tn.dists[toaddr.Port] {
+	for i, ekey := range tn.dists[n.UDP()] {
 		key, _ := decodePubkey(ekey)
 		node := wrapNode(enode.NewV4(key, net.ParseIP("127.0.0.1"), i, next))
 		result = append(result, node)
@@ -621,8 +705,9 @@ func (tn *preminedTestnet) findnode(toid enode.ID, toaddr *net.UDPAddr, target e
 	return result, nil
 }
 
-func (*preminedTestnet) close()                                        {}
-func (*preminedTestnet) ping(toid enode.ID, toaddr *net.UDPAddr) error { return nil }
+func (*preminedTestnet) close()                                      {}
+func (*preminedTestnet) ping(*enode.Node) (uint64, error)            { return 0, nil }
+func (*preminedTestnet) requestENR(*enode.Node) (*enode.Node, error) { return nil, errTimeout }
 
 var _ = (*preminedTestnet).mine // avoid linter warning about mine being dead code.
 
@@ -48,6 +48,14 @@ func nodeAtDistance(base enode.ID, ld int, ip net.IP) *node {
 	return wrapNode(enode.SignNull(&r, idAtDistance(base, ld)))
 }
 
+// nodeAt creates a node with the given ID and UDP endpoint.
+func nodeAt(id enode.ID, addr *net.UDPAddr) *node {
+	var r enr.Record
+	r.Set(enr.IP(addr.IP))
+	r.Set(enr.UDP(addr.Port))
+	return wrapNode(enode.SignNull(&r, id))
+}
+
 // idAtDistance returns a random hash such that enode.LogDist(a, b) == n
 func idAtDistance(a enode.ID, n int) (b enode.ID) {
 	if n == 0 {
@@ -93,6 +101,7 @@ func fillTable(tab *Table, nodes []*node) {
 type pingRecorder struct {
 	mu           sync.Mutex
 	dead, pinged map[enode.ID]bool
+	records      map[enode.ID]*enode.Node
 	n            *enode.Node
 }
 
@@ -102,9 +111,10 @@ func newPingRecorder() *pingRecorder {
 	n := enode.SignNull(&r, enode.ID{})
 
 	return &pingRecorder{
-		dead:   make(map[enode.ID]bool),
-		pinged: make(map[enode.ID]bool),
-		n:      n,
+		dead:    make(map[enode.ID]bool),
+		pinged:  make(map[enode.ID]bool),
+		records: make(map[enode.ID]*enode.Node),
+		n:       n,
 	}
 }
 
@@ -112,20 +122,40 @@ func (t *pingRecorder) self() *enode.Node {
 	return nullNode
 }
 
-func (t *pingRecorder) findnode(toid enode.ID, toaddr *net.UDPAddr, target encPubkey) ([]*node, error) {
+func (t *pingRecorder) findnode(n *node, target encPubkey) ([]*node, error) {
 	return nil, nil
 }
 
-func (t *pingRecorder) ping(toid enode.ID, toaddr *net.UDPAddr) error {
+// updateRecord sets the record the recorder announces for the given node.
+func (t *pingRecorder) updateRecord(n *enode.Node) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.records[n.ID()] = n
+}
+
+func (t *pingRecorder) ping(n *enode.Node) (uint64, error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
+	toid := n.ID()
 	t.pinged[toid] = true
 	if t.dead[toid] {
-		return errTimeout
-	} else {
-		return nil
+		return 0, errTimeout
+	}
+	if t.records[toid] != nil {
+		return t.records[toid].Seq(), nil
+	}
+	return 0, nil
+}
+
+func (t *pingRecorder) requestENR(n *enode.Node) (*enode.Node, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.dead[n.ID()] || t.records[n.ID()] == nil {
+		return nil, errTimeout
 	}
+	return t.records[n.ID()], nil
 }
 
 func (t *pingRecorder) close() {}
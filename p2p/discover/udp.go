@@ -28,6 +28,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover/v4wire"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/netutil"
 	"github.com/ethereum/go-ethereum/rlp"
@@ -35,8 +36,6 @@ import (
 
 // Errors
 var (
-	errPacketTooSmall   = errors.New("too small")
-	errBadHash          = errors.New("bad hash")
 	errExpired          = errors.New("expired")
 	errUnsolicitedReply = errors.New("unsolicited reply")
 	errUnknownNode      = errors.New("unknown node")
@@ -61,80 +60,7 @@ const (
 	maxPacketSize = 1280
 )
 
-// RPC packet types
-const (
-	pingPacket = iota + 1 // zero is 'reserved'
-	pongPacket
-	findnodePacket
-	neighborsPacket
-)
-
-// RPC request structures
-type (
-	ping struct {
-		senderKey *ecdsa.PublicKey // filled in by preverify
-
-		Version    uint
-		From, To   rpcEndpoint
-		Expiration uint64
-		// Ignore additional fields (for forward compatibility).
-		Rest []rlp.RawValue `rlp:"tail"`
-	}
-
-	// pong is the reply to ping.
-	pong struct {
-		// This field should mirror the UDP envelope address
-		// of the ping packet, which provides a way to discover the
-		// the external address (after NAT).
-		To rpcEndpoint
-
-		ReplyTok   []byte // This contains the hash of the ping packet.
-		Expiration uint64 // Absolute timestamp at which the packet becomes invalid.
-		// Ignore additional fields (for forward compatibility).
-		Rest []rlp.RawValue `rlp:"tail"`
-	}
-
-	// findnode is a query for nodes close to the given target.
-	findnode struct {
-		Target     encPubkey
-		Expiration uint64
-		// Ignore additional fields (for forward compatibility).
-		Rest []rlp.RawValue `rlp:"tail"`
-	}
-
-	// reply to findnode
-	neighbors struct {
-		Nodes      []rpcNode
-		Expiration uint64
-		// Ignore additional fields (for forward compatibility).
-		Rest []rlp.RawValue `rlp:"tail"`
-	}
-
-	rpcNode struct {
-		IP  net.IP // len 4 for IPv4 or 16 for IPv6
-		UDP uint16 // for discovery protocol
-		TCP uint16 // for RLPx protocol
-		ID  encPubkey
-	}
-
-	rpcEndpoint struct {
-		IP  net.IP // len 4 for IPv4 or 16 for IPv6
-		UDP uint16 // for discovery protocol
-		TCP uint16 // for RLPx protocol
-	}
-)
-
-func makeEndpoint(addr *net.UDPAddr, tcpPort uint16) rpcEndpoint {
-	ip := net.IP{}
-	if ip4 := addr.IP.To4(); ip4 != nil {
-		ip = ip4
-	} else if ip6 := addr.IP.To16(); ip6 != nil {
-		ip = ip6
-	}
-	return rpcEndpoint{IP: ip, UDP: uint16(addr.Port), TCP: tcpPort}
-}
-
-func (t *udp) nodeFromRPC(sender *net.UDPAddr, rn rpcNode) (*node, error) {
+func (t *udp) nodeFromRPC(sender *net.UDPAddr, rn v4wire.Node) (*node, error) {
 	if rn.UDP <= 1024 {
 		return nil, errors.New("low port")
 	}
@@ -144,7 +70,7 @@ func (t *udp) nodeFromRPC(sender *net.UDPAddr, rn rpcNode) (*node, error) {
 	if t.netrestrict != nil && !t.netrestrict.Contains(rn.IP) {
 		return nil, errors.New("not contained in netrestrict whitelist")
 	}
-	key, err := decodePubkey(rn.ID)
+	key, err := decodePubkey(encPubkey(rn.ID))
 	if err != nil {
 		return nil, err
 	}
@@ -153,23 +79,24 @@ func (t *udp) nodeFromRPC(sender *net.UDPAddr, rn rpcNode) (*node, error) {
 	return n, err
 }
 
-func nodeToRPC(n *node) rpcNode {
+func nodeToRPC(n *node) v4wire.Node {
 	var key ecdsa.PublicKey
-	var ekey encPubkey
+	var ekey v4wire.Pubkey
 	if err := n.Load((*enode.Secp256k1)(&key)); err == nil {
-		ekey = encodePubkey(&key)
+		ekey = v4wire.EncodePubkey(&key)
 	}
-	return rpcNode{ID: ekey, IP: n.IP(), UDP: uint16(n.UDP()), TCP: uint16(n.TCP())}
+	return v4wire.Node{ID: ekey, IP: n.IP(), UDP: uint16(n.UDP()), TCP: uint16(n.TCP())}
 }
 
-// packet is implemented by all protocol messages.
-type packet interface {
+// packetHandler wraps a packet with handler functions.
+type packetHandler struct {
+	v4wire.Packet
+	senderKey *ecdsa.PublicKey // used for ping
+
 	// preverify checks whether the packet is valid and should be handled at all.
-	preverify(t *udp, from *net.UDPAddr, fromID enode.ID, fromKey encPubkey) error
+	preverify func(p *packetHandler, from *net.UDPAddr, fromID enode.ID, fromKey v4wire.Pubkey) error
 	// handle handles the packet.
-	handle(t *udp, from *net.UDPAddr, fromID enode.ID, mac []byte)
-	// name returns the name of the packet for logging purposes.
-	name() string
+	handle func(req *packetHandler, from *net.UDPAddr, fromID enode.ID, mac []byte)
 }
 
 type conn interface {
@@ -223,13 +150,13 @@ type replyMatcher struct {
 	errc chan<- error
 }
 
-type replyMatchFunc func(interface{}) (matched bool, requestDone bool)
+type replyMatchFunc func(v4wire.Packet) (matched bool, requestDone bool)
 
 type reply struct {
 	from  enode.ID
 	ip    net.IP
 	ptype byte
-	data  packet
+	data  v4wire.Packet
 
 	// loop indicates whether there was
 	// a matching request by sending on this channel.
@@ -295,27 +222,31 @@ func (t *udp) close() {
 	t.wg.Wait()
 }
 
-func (t *udp) ourEndpoint() rpcEndpoint {
+func (t *udp) ourEndpoint() v4wire.Endpoint {
 	n := t.self()
 	a := &net.UDPAddr{IP: n.IP(), Port: n.UDP()}
-	return makeEndpoint(a, uint16(n.TCP()))
+	return v4wire.NewEndpoint(a, uint16(n.TCP()))
 }
 
-// ping sends a ping message to the given node and waits for a reply.
-func (t *udp) ping(toid enode.ID, toaddr *net.UDPAddr) error {
-	return <-t.sendPing(toid, toaddr, nil)
+// ping sends a ping message to the given node and waits for a reply. It returns
+// the ENR sequence number announced in the pong.
+func (t *udp) ping(n *enode.Node) (seq uint64, err error) {
+	addr := &net.UDPAddr{IP: n.IP(), Port: n.UDP()}
+	err = <-t.sendPing(n.ID(), addr, func(p *v4wire.Pong) { seq = p.ENRSeq() })
+	return seq, err
 }
 
 // sendPing sends a ping message to the given node and invokes the callback
 // when the reply arrives.
-func (t *udp) sendPing(toid enode.ID, toaddr *net.UDPAddr, callback func()) <-chan error {
-	req := &ping{
+func (t *udp) sendPing(toid enode.ID, toaddr *net.UDPAddr, callback func(*v4wire.Pong)) <-chan error {
+	req := &v4wire.Ping{
 		Version:    4,
 		From:       t.ourEndpoint(),
-		To:         makeEndpoint(toaddr, 0), // TODO: maybe use known TCP port from DB
+		To:         v4wire.NewEndpoint(toaddr, 0), // TODO: maybe use known TCP port from DB
 		Expiration: uint64(time.Now().Add(expiration).Unix()),
+		Rest:       v4wire.SeqField(t.localNode.Node().Seq()),
 	}
-	packet, hash, err := encodePacket(t.priv, pingPacket, req)
+	packet, hash, err := t.encode(req)
 	if err != nil {
 		errc := make(chan error, 1)
 		errc <- err
@@ -323,36 +254,31 @@ func (t *udp) sendPing(toid enode.ID, toaddr *net.UDPAddr, callback func()) <-ch
 	}
 	// Add a matcher for the reply to the pending reply queue. Pongs are matched if they
 	// reference the ping we're about to send.
-	errc := t.pending(toid, toaddr.IP, pongPacket, func(p interface{}) (matched bool, requestDone bool) {
-		matched = bytes.Equal(p.(*pong).ReplyTok, hash)
+	errc := t.pending(toid, toaddr.IP, v4wire.PongPacket, func(p v4wire.Packet) (matched bool, requestDone bool) {
+		matched = bytes.Equal(p.(*v4wire.Pong).ReplyTok, hash)
 		if matched && callback != nil {
-			callback()
+			callback(p.(*v4wire.Pong))
 		}
 		return matched, matched
 	})
 	// Send the packet.
 	t.localNode.UDPContact(toaddr)
-	t.write(toaddr, toid, req.name(), packet)
+	t.write(toaddr, toid, req.Name(), packet)
 	return errc
 }
 
 // findnode sends a findnode request to the given node and waits until
 // the node has sent up to k neighbors.
-func (t *udp) findnode(toid enode.ID, toaddr *net.UDPAddr, target encPubkey) ([]*node, error) {
-	// If we haven't seen a ping from the destination node for a while, it won't remember
-	// our endpoint proof and reject findnode. Solicit a ping first.
-	if time.Since(t.db.LastPingReceived(toid, toaddr.IP)) > bondExpiration {
-		t.ping(toid, toaddr)
-		// Wait for them to ping back and process our pong.
-		time.Sleep(respTimeout)
-	}
+func (t *udp) findnode(n *node, target encPubkey) ([]*node, error) {
+	toid, toaddr := n.ID(), n.addr()
+	t.ensureBond(toid, toaddr)
 
 	// Add a matcher for 'neighbours' replies to the pending reply queue. The matcher is
 	// active until enough nodes have been received.
 	nodes := make([]*node, 0, bucketSize)
 	nreceived := 0
-	errc := t.pending(toid, toaddr.IP, neighborsPacket, func(r interface{}) (matched bool, requestDone bool) {
-		reply := r.(*neighbors)
+	errc := t.pending(toid, toaddr.IP, v4wire.NeighborsPacket, func(r v4wire.Packet) (matched bool, requestDone bool) {
+		reply := r.(*v4wire.Neighbors)
 		for _, rn := range reply.Nodes {
 			nreceived++
 			n, err := t.nodeFromRPC(toaddr, rn)
@@ -364,13 +290,69 @@ func (t *udp) findnode(toid enode.ID, toaddr *net.UDPAddr, target encPubkey) ([]
 		}
 		return true, nreceived >= bucketSize
 	})
-	t.send(toaddr, toid, findnodePacket, &findnode{
-		Target:     target,
+	t.send(toaddr, toid, &v4wire.Findnode{
+		Target:     v4wire.Pubkey(target),
 		Expiration: uint64(time.Now().Add(expiration).Unix()),
 	})
 	return nodes, <-errc
 }
 
+// requestENR sends an ENR request to the given node and waits for a response.
+// The returned node carries the record of the response, unless the response
+// record is older than the one already known.
+func (t *udp) requestENR(n *enode.Node) (*enode.Node, error) {
+	addr := &net.UDPAddr{IP: n.IP(), Port: n.UDP()}
+	t.ensureBond(n.ID(), addr)
+
+	req := &v4wire.ENRRequest{
+		Expiration: uint64(time.Now().Add(expiration).Unix()),
+	}
+	packet, hash, err := t.encode(req)
+	if err != nil {
+		return nil, err
+	}
+	// Add a matcher for the reply to the pending reply queue. Responses are matched
+	// if they reference the request we're about to send.
+	var resp *v4wire.ENRResponse
+	errc := t.pending(n.ID(), addr.IP, v4wire.ENRResponsePacket, func(r v4wire.Packet) (matched bool, requestDone bool) {
+		matched = bytes.Equal(r.(*v4wire.ENRResponse).ReplyTok, hash)
+		if matched {
+			resp = r.(*v4wire.ENRResponse)
+		}
+		return matched, matched
+	})
+	// Send the packet and wait for the reply.
+	t.write(addr, n.ID(), req.Name(), packet)
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	// Verify the response record.
+	respN, err := enode.New(enode.ValidSchemes, &resp.Record)
+	if err != nil {
+		return nil, err
+	}
+	if respN.ID() != n.ID() {
+		return nil, fmt.Errorf("invalid ID in response record")
+	}
+	if respN.Seq() < n.Seq() {
+		return n, nil // response record is older
+	}
+	if err := netutil.CheckRelayIP(addr.IP, respN.IP()); err != nil {
+		return nil, fmt.Errorf("invalid IP in response record: %v", err)
+	}
+	return respN, nil
+}
+
+// ensureBond solicits a ping from a node if we haven't seen a ping from it for a
+// while. Such nodes won't remember our endpoint proof and would reject requests.
+func (t *udp) ensureBond(toid enode.ID, toaddr *net.UDPAddr) {
+	if time.Since(t.db.LastPingReceived(toid, toaddr.IP)) > bondExpiration {
+		<-t.sendPing(toid, toaddr, nil)
+		// Wait for them to ping back and process our pong.
+		time.Sleep(respTimeout)
+	}
+}
+
 // pending adds a reply matcher to the pending reply queue.
 // see the documentation of type replyMatcher for a detailed explanation.
 func (t *udp) pending(id enode.ID, ip net.IP, ptype byte, callback replyMatchFunc) <-chan error {
@@ -387,7 +369,7 @@ func (t *udp) pending(id enode.ID, ip net.IP, ptype byte, callback replyMatchFun
 
 // handleReply dispatches a reply packet, invoking reply matchers. It returns
 // whether any matcher considered the packet acceptable.
-func (t *udp) handleReply(from enode.ID, fromIP net.IP, ptype byte, req packet) bool {
+func (t *udp) handleReply(from enode.ID, fromIP net.IP, ptype byte, req v4wire.Packet) bool {
 	matched := make(chan bool, 1)
 	select {
 	case t.gotreply <- reply{from, fromIP, ptype, req, matched}:
@@ -491,24 +473,14 @@ func (t *udp) loop() {
 	}
 }
 
-const (
-	macSize  = 256 / 8
-	sigSize  = 520 / 8
-	headSize = macSize + sigSize // space of packet frame data
-)
-
-var (
-	headSpace = make([]byte, headSize)
-
-	// Neighbors replies are sent across multiple packets to
-	// stay below the packet size limit. We compute the maximum number
-	// of entries by stuffing a packet until it grows too large.
-	maxNeighbors int
-)
+// Neighbors replies are sent across multiple packets to
+// stay below the packet size limit. We compute the maximum number
+// of entries by stuffing a packet until it grows too large.
+var maxNeighbors int
 
 func init() {
-	p := neighbors{Expiration: ^uint64(0)}
-	maxSizeNode := rpcNode{IP: make(net.IP, 16), UDP: ^uint16(0), TCP: ^uint16(0)}
+	p := v4wire.Neighbors{Expiration: ^uint64(0)}
+	maxSizeNode := v4wire.Node{IP: make(net.IP, 16), UDP: ^uint16(0), TCP: ^uint16(0)}
 	for n := 0; ; n++ {
 		p.Nodes = append(p.Nodes, maxSizeNode)
 		size, _, err := rlp.EncodeToReader(p)
@@ -516,19 +488,19 @@ func init() {
 			// If this ever happens, it will be caught by the unit tests.
 			panic("cannot encode: " + err.Error())
 		}
-		if headSize+size+1 >= maxPacketSize {
+		if v4wire.HeadSize+size+1 >= maxPacketSize {
 			maxNeighbors = n
 			break
 		}
 	}
 }
 
-func (t *udp) send(toaddr *net.UDPAddr, toid enode.ID, ptype byte, req packet) ([]byte, error) {
-	packet, hash, err := encodePacket(t.priv, ptype, req)
+func (t *udp) send(toaddr *net.UDPAddr, toid enode.ID, req v4wire.Packet) ([]byte, error) {
+	packet, hash, err := t.encode(req)
 	if err != nil {
 		return hash, err
 	}
-	return hash, t.write(toaddr, toid, req.name(), packet)
+	return hash, t.write(toaddr, toid, req.Name(), packet)
 }
 
 func (t *udp) write(toaddr *net.UDPAddr, toid enode.ID, what string, packet []byte) error {
@@ -537,27 +509,12 @@ func (t *udp) write(toaddr *net.UDPAddr, toid enode.ID, what string, packet []by
 	return err
 }
 
-func encodePacket(priv *ecdsa.PrivateKey, ptype byte, req interface{}) (packet, hash []byte, err error) {
-	b := new(bytes.Buffer)
-	b.Write(headSpace)
-	b.WriteByte(ptype)
-	if err := rlp.Encode(b, req); err != nil {
-		log.Error("Can't encode discv4 packet", "err", err)
-		return nil, nil, err
-	}
-	packet = b.Bytes()
-	sig, err := crypto.Sign(crypto.Keccak256(packet[headSize:]), priv)
+func (t *udp) encode(req v4wire.Packet) (packet, hash []byte, err error) {
+	packet, hash, err = v4wire.Encode(t.priv, req)
 	if err != nil {
-		log.Error("Can't sign discv4 packet", "err", err)
-		return nil, nil, err
+		log.Error("Can't encode discv4 packet", "type", req.Name(), "err", err)
 	}
-	copy(packet[macSize:], sig)
-	// add the hash to the front. Note: this doesn't protect the
-	// packet in any way. Our public key will be part of this hash in
-	// The future.
-	hash = crypto.Keccak256(packet[macSize:])
-	copy(packet, hash)
-	return packet, hash, nil
+	return packet, hash, err
 }
 
 // readLoop runs in its own goroutine. it handles incoming UDP packets.
@@ -580,8 +537,11 @@ func (t *udp) readLoop(unhandled chan<- ReadPacket) {
 			return
 		}
 		if t.handlePacket(from, buf[:nbytes]) != nil && unhandled != nil {
+			// The packet is copied because buf is reused for the next read.
+			data := make([]byte, nbytes)
+			copy(data, buf)
 			select {
-			case unhandled <- ReadPacket{buf[:nbytes], from}:
+			case unhandled <- ReadPacket{data, from}:
 			default:
 			}
 		}
@@ -589,80 +549,79 @@ func (t *udp) readLoop(unhandled chan<- ReadPacket) {
 }
 
 func (t *udp) handlePacket(from *net.UDPAddr, buf []byte) error {
-	packet, fromKey, hash, err := decodePacket(buf)
+	rawpacket, fromKey, hash, err := v4wire.Decode(buf)
 	if err != nil {
 		log.Debug("Bad discv4 packet", "addr", from, "err", err)
 		return err
 	}
-	fromID := fromKey.id()
-	if err == nil {
-		err = packet.preverify(t, from, fromID, fromKey)
+	packet := t.wrapPacket(rawpacket)
+	fromID := fromKey.ID()
+	if err == nil && packet.preverify != nil {
+		err = packet.preverify(packet, from, fromID, fromKey)
 	}
-	log.Trace("<< "+packet.name(), "id", fromID, "addr", from, "err", err)
-	if err == nil {
-		packet.handle(t, from, fromID, hash)
+	log.Trace("<< "+packet.Name(), "id", fromID, "addr", from, "err", err)
+	if err == nil && packet.handle != nil {
+		packet.handle(packet, from, fromID, hash)
 	}
 	return err
 }
 
-func decodePacket(buf []byte) (packet, encPubkey, []byte, error) {
-	if len(buf) < headSize+1 {
-		return nil, encPubkey{}, nil, errPacketTooSmall
-	}
-	hash, sig, sigdata := buf[:macSize], buf[macSize:headSize], buf[headSize:]
-	shouldhash := crypto.Keccak256(buf[macSize:])
-	if !bytes.Equal(hash, shouldhash) {
-		return nil, encPubkey{}, nil, errBadHash
-	}
-	fromKey, err := recoverNodeKey(crypto.Keccak256(buf[headSize:]), sig)
-	if err != nil {
-		return nil, fromKey, hash, err
-	}
-
-	var req packet
-	switch ptype := sigdata[0]; ptype {
-	case pingPacket:
-		req = new(ping)
-	case pongPacket:
-		req = new(pong)
-	case findnodePacket:
-		req = new(findnode)
-	case neighborsPacket:
-		req = new(neighbors)
-	default:
-		return nil, fromKey, hash, fmt.Errorf("unknown type: %d", ptype)
-	}
-	s := rlp.NewStream(bytes.NewReader(sigdata[1:]), 0)
-	err = s.Decode(req)
-	return req, fromKey, hash, err
-}
-
-// Packet Handlers
-
-func (req *ping) preverify(t *udp, from *net.UDPAddr, fromID enode.ID, fromKey encPubkey) error {
-	if expired(req.Expiration) {
+// wrapPacket returns the handler functions applicable to a packet.
+func (t *udp) wrapPacket(p v4wire.Packet) *packetHandler {
+	var h packetHandler
+	h.Packet = p
+	switch p.(type) {
+	case *v4wire.Ping:
+		h.preverify = t.verifyPing
+		h.handle = t.handlePing
+	case *v4wire.Pong:
+		h.preverify = t.verifyPong
+		h.handle = t.handlePong
+	case *v4wire.Findnode:
+		h.preverify = t.verifyFindnode
+		h.handle = t.handleFindnode
+	case *v4wire.Neighbors:
+		h.preverify = t.verifyNeighbors
+	case *v4wire.ENRRequest:
+		h.preverify = t.verifyENRRequest
+		h.handle = t.handleENRRequest
+	case *v4wire.ENRResponse:
+		h.preverify = t.verifyENRResponse
+	}
+	return &h
+}
+
+// PING/v4
+
+func (t *udp) verifyPing(h *packetHandler, from *net.UDPAddr, fromID enode.ID, fromKey v4wire.Pubkey) error {
+	req := h.Packet.(*v4wire.Ping)
+
+	if v4wire.Expired(req.Expiration) {
 		return errExpired
 	}
-	key, err := decodePubkey(fromKey)
+	key, err := v4wire.DecodePubkey(fromKey)
 	if err != nil {
 		return errors.New("invalid public key")
 	}
-	req.senderKey = key
+	h.senderKey = key
 	return nil
 }
 
-func (req *ping) handle(t *udp, from *net.UDPAddr, fromID enode.ID, mac []byte) {
+func (t *udp) handlePing(h *packetHandler, from *net.UDPAddr, fromID enode.ID, mac []byte) {
+	req := h.Packet.(*v4wire.Ping)
+
 	// Reply.
-	t.send(from, fromID, pongPacket, &pong{
-		To:         makeEndpoint(from, req.From.TCP),
+	t.send(from, fromID, &v4wire.Pong{
+		To:         v4wire.NewEndpoint(from, req.From.TCP),
 		ReplyTok:   mac,
 		Expiration: uint64(time.Now().Add(expiration).Unix()),
+		Rest:       v4wire.SeqField(t.localNode.Node().Seq()),
 	})
 
 	// Ping back if our last pong on file is too far in the past.
-	n := wrapNode(enode.NewV4(req.senderKey, from.IP, int(req.From.TCP), from.Port))
+	n := wrapNode(enode.NewV4(h.senderKey, from.IP, int(req.From.TCP), from.Port))
 	if time.Since(t.db.LastPongReceived(n.ID(), from.IP)) > bondExpiration {
-		t.sendPing(fromID, from, func() {
+		t.sendPing(fromID, from, func(*v4wire.Pong) {
 			t.tab.addVerifiedNode(n)
 		})
 	} else {
@@ -674,42 +633,54 @@ func (req *ping) handle(t *udp, from *net.UDPAddr, fromID enode.ID, mac []byte)
 	t.localNode.UDPEndpointStatement(from, &net.UDPAddr{IP: req.To.IP, Port: int(req.To.UDP)})
 }
 
-func (req *ping) name() string { return "PING/v4" }
+// PONG/v4
+
+func (t *udp) verifyPong(h *packetHandler, from *net.UDPAddr, fromID enode.ID, fromKey v4wire.Pubkey) error {
+	req := h.Packet.(*v4wire.Pong)
 
-func (req *pong) preverify(t *udp, from *net.UDPAddr, fromID enode.ID, fromKey encPubkey) error {
-	if expired(req.Expiration) {
+	if v4wire.Expired(req.Expiration) {
 		return errExpired
 	}
-	if !t.handleReply(fromID, from.IP, pongPacket, req) {
+	if !t.handleReply(fromID, from.IP, v4wire.PongPacket, req) {
 		return errUnsolicitedReply
 	}
 	return nil
 }
 
-func (req *pong) handle(t *udp, from *net.UDPAddr, fromID enode.ID, mac []byte) {
+func (t *udp) handlePong(h *packetHandler, from *net.UDPAddr, fromID enode.ID, mac []byte) {
+	req := h.Packet.(*v4wire.Pong)
+
 	t.localNode.UDPEndpointStatement(from, &net.UDPAddr{IP: req.To.IP, Port: int(req.To.UDP)})
 	t.db.UpdateLastPongReceived(fromID, from.IP, time.Now())
 }
 
-func (req *pong) name() string { return "PONG/v4" }
+// FINDNODE/v4
+
+func (t *udp) verifyFindnode(h *packetHandler, from *net.UDPAddr, fromID enode.ID, fromKey v4wire.Pubkey) error {
+	req := h.Packet.(*v4wire.Findnode)
 
-func (req *findnode) preverify(t *udp, from *net.UDPAddr, fromID enode.ID, fromKey encPubkey) error {
-	if expired(req.Expiration) {
+	if v4wire.Expired(req.Expiration) {
 		return errExpired
 	}
-	if time.Since(t.db.LastPongReceived(fromID, from.IP)) > bondExpiration {
-		// No endpoint proof pong exists, we don't process the packet. This prevents an
-		// attack vector where the discovery protocol could be used to amplify traffic in a
-		// DDOS attack. A malicious actor would send a findnode request with the IP address
-		// and UDP port of the target as the source address. The recipient of the findnode
-		// packet would then send a neighbors packet (which is a much bigger packet than
-		// findnode) to the victim.
+	if !t.checkBond(fromID, from.IP) {
 		return errUnknownNode
 	}
 	return nil
 }
 
-func (req *findnode) handle(t *udp, from *net.UDPAddr, fromID enode.ID, mac []byte) {
+// checkBond reports whether the given node has recently answered our ping. Requests
+// from unbonded nodes are not processed. This prevents an attack vector where the
+// discovery protocol could be used to amplify traffic in a DDOS attack. A malicious
+// actor would send a findnode request with the IP address and UDP port of the target
+// as the source address. The recipient of the findnode packet would then send a
+// neighbors packet (which is a much bigger packet than findnode) to the victim.
+func (t *udp) checkBond(id enode.ID, ip net.IP) bool {
+	return time.Since(t.db.LastPongReceived(id, ip)) < bondExpiration
+}
+
+func (t *udp) handleFindnode(h *packetHandler, from *net.UDPAddr, fromID enode.ID, mac []byte) {
+	req := h.Packet.(*v4wire.Findnode)
+
 	// Determine closest nodes.
 	target := enode.ID(crypto.Keccak256Hash(req.Target[:]))
 	t.tab.mutex.Lock()
@@ -718,40 +689,63 @@ func (req *findnode) handle(t *udp, from *net.UDPAddr, fromID enode.ID, mac []by
 
 	// Send neighbors in chunks with at most maxNeighbors per packet
 	// to stay below the packet size limit.
-	p := neighbors{Expiration: uint64(time.Now().Add(expiration).Unix())}
+	p := v4wire.Neighbors{Expiration: uint64(time.Now().Add(expiration).Unix())}
 	var sent bool
 	for _, n := range closest {
 		if netutil.CheckRelayIP(from.IP, n.IP()) == nil {
 			p.Nodes = append(p.Nodes, nodeToRPC(n))
 		}
 		if len(p.Nodes) == maxNeighbors {
-			t.send(from, fromID, neighborsPacket, &p)
+			t.send(from, fromID, &p)
 			p.Nodes = p.Nodes[:0]
 			sent = true
 		}
 	}
 	if len(p.Nodes) > 0 || !sent {
-		t.send(from, fromID, neighborsPacket, &p)
+		t.send(from, fromID, &p)
 	}
 }
 
-func (req *findnode) name() string { return "FINDNODE/v4" }
+// NEIGHBORS/v4
+
+func (t *udp) verifyNeighbors(h *packetHandler, from *net.UDPAddr, fromID enode.ID, fromKey v4wire.Pubkey) error {
+	req := h.Packet.(*v4wire.Neighbors)
 
-func (req *neighbors) preverify(t *udp, from *net.UDPAddr, fromID enode.ID, fromKey encPubkey) error {
-	if expired(req.Expiration) {
+	if v4wire.Expired(req.Expiration) {
 		return errExpired
 	}
-	if !t.handleReply(fromID, from.IP, neighborsPacket, req) {
+	if !t.handleReply(fromID, from.IP, v4wire.NeighborsPacket, req) {
 		return errUnsolicitedReply
 	}
 	return nil
 }
 
-func (req *neighbors) handle(t *udp, from *net.UDPAddr, fromID enode.ID, mac []byte) {
+// ENRREQUEST/v4
+
+func (t *udp) verifyENRRequest(h *packetHandler, from *net.UDPAddr, fromID enode.ID, fromKey v4wire.Pubkey) error {
+	req := h.Packet.(*v4wire.ENRRequest)
+
+	if v4wire.Expired(req.Expiration) {
+		return errExpired
+	}
+	if !t.checkBond(fromID, from.IP) {
+		return errUnknownNode
+	}
+	return nil
+}
+
+func (t *udp) handleENRRequest(h *packetHandler, from *net.UDPAddr, fromID enode.ID, mac []byte) {
+	t.send(from, fromID, &v4wire.ENRResponse{
+		ReplyTok: mac,
+		Record:   *t.localNode.Node().Record(),
+	})
 }
 
-func (req *neighbors) name() string { return "NEIGHBORS/v4" }
+// ENRRESPONSE/v4
 
-func expired(ts uint64) bool {
-	return time.Unix(int64(ts), 0).Before(time.Now())
+func (t *udp) verifyENRResponse(h *packetHandler, from *net.UDPAddr, fromID enode.ID, fromKey v4wire.Pubkey) error {
+	if !t.handleReply(fromID, from.IP, v4wire.ENRResponsePacket, h.Packet) {
+		return errUnsolicitedReply
+	}
+	return nil
 }
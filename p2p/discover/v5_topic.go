@@ -0,0 +1,592 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	crand "crypto/rand"
+	"crypto/sha256"
+	"errors"
+	mrand "math/rand"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover/v5wire"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	topicAdLifetime   = 15 * time.Minute // how long an ad stays in the topic table
+	topicQueueLimit   = 100              // max number of ads per topic
+	topicTableLimit   = 5000             // max number of ads in total
+	topicTicketWindow = 10 * time.Second // time to use a ticket after its wait time has elapsed
+	topicMaxRenewals  = 3                // number of times an ad can be renewed without a ticket
+	topicQueueTickets = topicQueueLimit  // max number of outstanding tickets per topic
+	topicTableTickets = topicTableLimit  // max number of outstanding tickets in total
+
+	topicRegistrars  = 3 // number of nodes a topic is registered with
+	topicSearchNodes = 6 // number of nodes asked in a search round
+)
+
+var errInvalidTicket = errors.New("invalid ticket")
+
+// Topic is a topic name. Nodes advertise themselves under topics so that
+// other nodes can find them without crawling the whole network.
+type Topic string
+
+// Hash returns the hash of the topic, which is also the lookup target used
+// to find the registrars of the topic.
+func (t Topic) Hash() v5wire.TopicHash {
+	return v5wire.TopicHash(sha256.Sum256([]byte(t)))
+}
+
+// topicTable stores the ads placed by other nodes.
+type topicTable struct {
+	clock     mclock.Clock
+	ticketKey cipher.AEAD
+	queues    map[v5wire.TopicHash][]topicAd
+	tickets   map[v5wire.TopicHash][]mclock.AbsTime // end of usage window of issued tickets, ascending
+	count     int                                   // number of ads
+	pending   int                                   // number of outstanding tickets
+}
+
+// topicAd is a registration of a node for a topic.
+type topicAd struct {
+	node     *enode.Node
+	regTime  mclock.AbsTime
+	renewals int
+}
+
+// topicTicket is the content of a ticket. Tickets are encrypted with a key
+// only known to the registrar, which makes them opaque to the registrant.
+type topicTicket struct {
+	ID       enode.ID
+	IP       net.IP
+	Topic    v5wire.TopicHash
+	Issued   uint64 // mclock.AbsTime when the ticket was created
+	WaitTime uint64 // in seconds
+}
+
+func newTopicTable(clock mclock.Clock) *topicTable {
+	key := make([]byte, 16)
+	crand.Read(key)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic("can't create ticket cipher: " + err.Error())
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic("can't create ticket cipher: " + err.Error())
+	}
+	return &topicTable{
+		clock:     clock,
+		ticketKey: aead,
+		queues:    make(map[v5wire.TopicHash][]topicAd),
+		tickets:   make(map[v5wire.TopicHash][]mclock.AbsTime),
+	}
+}
+
+// register handles a registration attempt. It returns zero if the node was
+// registered. Otherwise, it returns the number of seconds to wait and a ticket
+// which the node should present when it tries again.
+//
+// Nodes presenting a valid ticket are always admitted, displacing the oldest ad
+// if there is no space. Existing ads can be renewed without waiting a limited
+// number of times, after which the node has to queue for a ticket again. When
+// too many tickets are outstanding, the node is told to come back later without
+// getting a ticket.
+func (tt *topicTable) register(n *enode.Node, ip net.IP, topic v5wire.TopicHash, ticket []byte) (uint, []byte) {
+	tt.expire()
+	now := tt.clock.Now()
+
+	renewals := 0
+	if i := tt.find(n.ID(), topic); i >= 0 {
+		renewals = tt.queues[topic][i].renewals + 1
+	}
+	if len(ticket) > 0 {
+		t, err := tt.checkTicket(ticket, n.ID(), ip, topic, now)
+		if err == nil {
+			tt.redeemTicket(topic, t)
+			tt.remove(n.ID(), topic)
+			tt.makeRoom(topic)
+			tt.add(n, topic, now, 0)
+			return 0, nil
+		}
+		// An invalid ticket is treated like a first attempt.
+		log.Debug("Rejected topic ticket", "id", n.ID(), "err", err)
+	}
+	if renewals > 0 && renewals <= topicMaxRenewals {
+		tt.remove(n.ID(), topic)
+		tt.add(n, topic, now, renewals)
+		return 0, nil
+	}
+	if len(tt.tickets[topic]) >= topicQueueTickets || tt.pending >= topicTableTickets {
+		// All space that will be freed is reserved already.
+		return uint(topicAdLifetime / time.Second), nil
+	}
+	wait := tt.waitTime(topic, now)
+	if wait <= 0 {
+		tt.remove(n.ID(), topic)
+		tt.add(n, topic, now, 0)
+		return 0, nil
+	}
+	secs := uint((wait + time.Second - 1) / time.Second)
+	t := topicTicket{ID: n.ID(), IP: ip, Topic: topic, Issued: uint64(now), WaitTime: uint64(secs)}
+	tt.addTicket(topic, t.windowEnd())
+	return secs, tt.sealTicket(&t)
+}
+
+// query returns up to limit random ads for the given topic, omitting nodes
+// whose address can't be relayed to the requester.
+func (tt *topicTable) query(topic v5wire.TopicHash, rip net.IP, limit int) []*enode.Node {
+	tt.expire()
+
+	var nodes []*enode.Node
+	q := tt.queues[topic]
+	for _, i := range mrand.Perm(len(q)) {
+		if netutil.CheckRelayIP(rip, q[i].node.IP()) != nil {
+			continue
+		}
+		nodes = append(nodes, q[i].node)
+		if len(nodes) == limit {
+			break
+		}
+	}
+	return nodes
+}
+
+// find returns the index of the ad of the given node, or -1 if there is none.
+func (tt *topicTable) find(id enode.ID, topic v5wire.TopicHash) int {
+	for i, ad := range tt.queues[topic] {
+		if ad.node.ID() == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// add appends an ad to the queue of its topic.
+func (tt *topicTable) add(n *enode.Node, topic v5wire.TopicHash, now mclock.AbsTime, renewals int) {
+	tt.queues[topic] = append(tt.queues[topic], topicAd{node: n, regTime: now, renewals: renewals})
+	tt.count++
+}
+
+// remove deletes the ad of the given node if there is one.
+func (tt *topicTable) remove(id enode.ID, topic v5wire.TopicHash) {
+	i := tt.find(id, topic)
+	if i < 0 {
+		return
+	}
+	q := tt.queues[topic]
+	q = append(q[:i], q[i+1:]...)
+	if len(q) == 0 {
+		delete(tt.queues, topic)
+	} else {
+		tt.queues[topic] = q
+	}
+	tt.count--
+}
+
+// makeRoom removes the oldest ad of the topic if its queue is full, or the oldest
+// ad of any topic if the table is full.
+func (tt *topicTable) makeRoom(topic v5wire.TopicHash) {
+	if q := tt.queues[topic]; len(q) >= topicQueueLimit {
+		tt.remove(q[0].node.ID(), topic)
+		return
+	}
+	if tt.count >= topicTableLimit {
+		var (
+			oldest      mclock.AbsTime
+			oldestTopic v5wire.TopicHash
+			found       bool
+		)
+		for t, q := range tt.queues {
+			if !found || q[0].regTime < oldest {
+				oldest, oldestTopic, found = q[0].regTime, t, true
+			}
+		}
+		tt.remove(tt.queues[oldestTopic][0].node.ID(), oldestTopic)
+	}
+}
+
+// waitTime computes how long it takes until there is space for another ad of
+// the given topic, both in the queue of the topic and in the table. Ads leave in
+// the order they were placed, and the space they free is reserved for the holders
+// of outstanding tickets first.
+func (tt *topicTable) waitTime(topic v5wire.TopicHash, now mclock.AbsTime) time.Duration {
+	q := tt.queues[topic]
+	ads := make([]mclock.AbsTime, len(q))
+	for i, ad := range q {
+		ads[i] = ad.regTime
+	}
+	wait := slotWait(ads, len(tt.tickets[topic]), topicQueueLimit, now)
+
+	if tt.count+tt.pending >= topicTableLimit {
+		ads = ads[:0]
+		for _, q := range tt.queues {
+			for _, ad := range q {
+				ads = append(ads, ad.regTime)
+			}
+		}
+		sort.Slice(ads, func(i, j int) bool { return ads[i] < ads[j] })
+		if w := slotWait(ads, tt.pending, topicTableLimit, now); w > wait {
+			wait = w
+		}
+	}
+	return wait
+}
+
+// slotWait computes how long it takes until there is space for another ad among
+// at most limit ads. The ads are given by their registration times in ascending
+// order. The number of outstanding tickets must be below the limit.
+func slotWait(ads []mclock.AbsTime, tickets int, limit int, now mclock.AbsTime) time.Duration {
+	i := len(ads) + tickets - limit
+	if i < 0 {
+		return 0
+	}
+	return time.Duration(ads[i].Add(topicAdLifetime) - now)
+}
+
+// expire removes ads which have exceeded their lifetime and tickets whose usage
+// window has passed. Queues are ordered by registration time, so only their heads
+// need to be checked.
+func (tt *topicTable) expire() {
+	now := tt.clock.Now()
+	deadline := now.Add(-topicAdLifetime)
+	for topic, q := range tt.queues {
+		i := 0
+		for i < len(q) && q[i].regTime <= deadline {
+			i++
+		}
+		tt.count -= i
+		if i == len(q) {
+			delete(tt.queues, topic)
+		} else {
+			tt.queues[topic] = q[i:]
+		}
+	}
+	for topic, ends := range tt.tickets {
+		i := 0
+		for i < len(ends) && ends[i] < now {
+			i++
+		}
+		tt.pending -= i
+		if i == len(ends) {
+			delete(tt.tickets, topic)
+		} else {
+			tt.tickets[topic] = ends[i:]
+		}
+	}
+}
+
+// addTicket records an issued ticket of the topic by the end of its usage window.
+func (tt *topicTable) addTicket(topic v5wire.TopicHash, end mclock.AbsTime) {
+	ends := tt.tickets[topic]
+	i := sort.Search(len(ends), func(i int) bool { return ends[i] > end })
+	ends = append(ends, 0)
+	copy(ends[i+1:], ends[i:])
+	ends[i] = end
+	tt.tickets[topic] = ends
+	tt.pending++
+}
+
+// redeemTicket removes a used ticket from the outstanding tickets of the topic.
+func (tt *topicTable) redeemTicket(topic v5wire.TopicHash, t *topicTicket) {
+	ends := tt.tickets[topic]
+	for i, end := range ends {
+		if end == t.windowEnd() {
+			if ends = append(ends[:i], ends[i+1:]...); len(ends) == 0 {
+				delete(tt.tickets, topic)
+			} else {
+				tt.tickets[topic] = ends
+			}
+			tt.pending--
+			return
+		}
+	}
+}
+
+// checkTicket verifies that a ticket was issued to the given node and topic,
+// and that it is presented within its usage window.
+func (tt *topicTable) checkTicket(ticket []byte, id enode.ID, ip net.IP, topic v5wire.TopicHash, now mclock.AbsTime) (*topicTicket, error) {
+	var t topicTicket
+	if err := tt.openTicket(ticket, &t); err != nil {
+		return nil, err
+	}
+	if t.ID != id || !t.IP.Equal(ip) || t.Topic != topic {
+		return nil, errInvalidTicket
+	}
+	if now < t.windowStart() || now > t.windowEnd() {
+		return nil, errors.New("ticket used outside of its window")
+	}
+	return &t, nil
+}
+
+// windowStart returns the time from which the ticket can be used.
+func (t *topicTicket) windowStart() mclock.AbsTime {
+	return mclock.AbsTime(t.Issued).Add(time.Duration(t.WaitTime) * time.Second)
+}
+
+// windowEnd returns the time after which the ticket can no longer be used.
+func (t *topicTicket) windowEnd() mclock.AbsTime {
+	return t.windowStart().Add(topicTicketWindow)
+}
+
+func (tt *topicTable) sealTicket(t *topicTicket) []byte {
+	pt, err := rlp.EncodeToBytes(t)
+	if err != nil {
+		panic("can't encode ticket: " + err.Error())
+	}
+	nonce := make([]byte, tt.ticketKey.NonceSize())
+	crand.Read(nonce)
+	return tt.ticketKey.Seal(nonce, nonce, pt, nil)
+}
+
+func (tt *topicTable) openTicket(ticket []byte, t *topicTicket) error {
+	ns := tt.ticketKey.NonceSize()
+	if len(ticket) < ns {
+		return errInvalidTicket
+	}
+	pt, err := tt.ticketKey.Open(nil, ticket[:ns], ticket[ns:], nil)
+	if err != nil {
+		return errInvalidTicket
+	}
+	return rlp.DecodeBytes(pt, t)
+}
+
+// handleRegtopic processes a topic registration request.
+func (t *UDPv5) handleRegtopic(p *v5wire.Regtopic, fromID enode.ID, fromAddr *net.UDPAddr) {
+	n, err := verifyRegtopicRecord(p.ENR, fromID, fromAddr)
+	if err != nil {
+		log.Debug("Invalid "+p.Name(), "id", fromID, "addr", fromAddr, "err", err)
+		return
+	}
+	wait, ticket := t.topics.register(n, fromAddr.IP, p.Topic, p.Ticket)
+	t.sendResponse(fromID, fromAddr, &v5wire.Ticket{ReqID: p.ReqID, Ticket: ticket, WaitTime: wait})
+	if wait == 0 {
+		t.sendResponse(fromID, fromAddr, &v5wire.Regconfirmation{ReqID: p.ReqID, Topic: p.Topic})
+	}
+}
+
+// verifyRegtopicRecord checks that the record in a REGTOPIC request belongs
+// to the sender.
+func verifyRegtopicRecord(r *enr.Record, fromID enode.ID, fromAddr *net.UDPAddr) (*enode.Node, error) {
+	if r == nil {
+		return nil, errors.New("missing record")
+	}
+	n, err := enode.New(enode.ValidSchemes, r)
+	if err != nil {
+		return nil, err
+	}
+	if n.ID() != fromID {
+		return nil, errors.New("record ID doesn't match sender")
+	}
+	if !n.IP().Equal(fromAddr.IP) {
+		return nil, errors.New("record IP doesn't match sender")
+	}
+	return n, nil
+}
+
+// handleTopicQuery returns the ads of a topic.
+func (t *UDPv5) handleTopicQuery(p *v5wire.TopicQuery, fromID enode.ID, fromAddr *net.UDPAddr) {
+	nodes := t.topics.query(p.Topic, fromAddr.IP, findnodeResultLimit)
+	for _, resp := range packNodes(p.ReqID, nodes) {
+		t.sendResponse(fromID, fromAddr, resp)
+	}
+}
+
+// RegisterTopic advertises the local node under the given topic. The ad is
+// placed on the nodes closest to the topic hash and renewed until stop is
+// closed.
+func (t *UDPv5) RegisterTopic(topic Topic, stop <-chan struct{}) {
+	hash := topic.Hash()
+	for {
+		registrars := t.lookup(enode.ID(hash))
+		if len(registrars) > topicRegistrars {
+			registrars = registrars[:topicRegistrars]
+		}
+		done := make(chan struct{}, len(registrars))
+		for _, n := range registrars {
+			go func(n *enode.Node) {
+				t.registerLoop(n, hash, stop)
+				done <- struct{}{}
+			}(unwrapNode(n))
+		}
+		for range registrars {
+			<-done
+		}
+		// All registrars are gone, find new ones after a while.
+		select {
+		case <-time.After(lookupRetryDelay):
+		case <-stop:
+			return
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+// registerLoop keeps the local node registered with n until stop is closed
+// or n stops responding.
+func (t *UDPv5) registerLoop(n *enode.Node, topic v5wire.TopicHash, stop <-chan struct{}) {
+	var ticket []byte
+	for {
+		wait, newTicket, err := t.regtopic(n, topic, ticket)
+		if err != nil {
+			log.Debug("Topic registration failed", "id", n.ID(), "addr", n.IP(), "err", err)
+			return
+		}
+		ticket = newTicket
+		delay := time.Duration(wait) * time.Second
+		if wait == 0 {
+			// Registered, renew before the ad expires.
+			log.Trace("Registered topic", "id", n.ID(), "topic", topic)
+			delay = topicAdLifetime - topicTicketWindow
+		} else if delay > topicAdLifetime {
+			log.Debug("Topic ticket wait time too long", "id", n.ID(), "wait", delay)
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-stop:
+			return
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+// regtopic calls REGTOPIC on a node. It returns the wait time and ticket if
+// the registration wasn't accepted yet.
+func (t *UDPv5) regtopic(n *enode.Node, topic v5wire.TopicHash, ticket []byte) (uint, []byte, error) {
+	req := &v5wire.Regtopic{Topic: topic, ENR: t.localNode.Node().Record(), Ticket: ticket}
+	c := t.call(n, v5wire.TicketMsg, req)
+	defer t.callDone(c)
+
+	for {
+		select {
+		case resp := <-c.ch:
+			switch resp := resp.(type) {
+			case *v5wire.Ticket:
+				if resp.WaitTime > 0 {
+					return resp.WaitTime, resp.Ticket, nil
+				}
+				// The registration was accepted, wait for the confirmation.
+			case *v5wire.Regconfirmation:
+				if resp.Topic != topic {
+					return 0, nil, errors.New("wrong topic in confirmation")
+				}
+				return 0, nil, nil
+			}
+		case err := <-c.err:
+			return 0, nil, err
+		}
+	}
+}
+
+// SearchTopic finds nodes advertising the given topic. Searching starts when a
+// period is sent on setPeriod, which also sets the delay between search rounds.
+// A zero period pauses the search. Found nodes are sent on found. After each round,
+// true is sent on lookup if it isn't nil. SearchTopic returns when setPeriod is closed.
+func (t *UDPv5) SearchTopic(topic Topic, setPeriod <-chan time.Duration, found chan<- *enode.Node, lookup chan<- bool) {
+	var (
+		hash   = topic.Hash()
+		period time.Duration
+		timer  = time.NewTimer(0)
+	)
+	defer timer.Stop()
+	if !timer.Stop() {
+		<-timer.C
+	}
+
+	for {
+		select {
+		case p, ok := <-setPeriod:
+			if !ok {
+				return
+			}
+			if period == 0 && p > 0 {
+				timer.Reset(0)
+			}
+			period = p
+		case <-timer.C:
+			if period == 0 {
+				continue
+			}
+			if !t.searchTopic(hash, found) {
+				return
+			}
+			if lookup != nil {
+				select {
+				case lookup <- true:
+				case <-t.closing:
+					return
+				}
+			}
+			timer.Reset(period)
+		case <-t.closing:
+			return
+		}
+	}
+}
+
+// searchTopic runs a single search round. It returns false if the transport was
+// closed during the search.
+func (t *UDPv5) searchTopic(topic v5wire.TopicHash, found chan<- *enode.Node) bool {
+	registrars := t.lookup(enode.ID(topic))
+	if len(registrars) > topicSearchNodes {
+		registrars = registrars[:topicSearchNodes]
+	}
+	results := make(chan []*enode.Node, len(registrars))
+	for _, n := range registrars {
+		go func(n *enode.Node) {
+			nodes, err := t.topicQuery(n, topic)
+			if err != nil {
+				log.Debug("Topic query failed", "id", n.ID(), "addr", n.IP(), "err", err)
+			}
+			results <- nodes
+		}(unwrapNode(n))
+	}
+	seen := make(map[enode.ID]bool)
+	for range registrars {
+		for _, n := range <-results {
+			if seen[n.ID()] || n.ID() == t.Self().ID() {
+				continue
+			}
+			seen[n.ID()] = true
+			select {
+			case found <- n:
+			case <-t.closing:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// topicQuery calls TOPICQUERY on a node and waits for NODES responses.
+func (t *UDPv5) topicQuery(n *enode.Node, topic v5wire.TopicHash) ([]*enode.Node, error) {
+	c := t.call(n, v5wire.NodesMsg, &v5wire.TopicQuery{Topic: topic})
+	return t.waitForNodes(c, nil)
+}
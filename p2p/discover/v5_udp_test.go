@@ -0,0 +1,440 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discover
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// startLocalhostV5 runs a v5 transport on a localhost UDP socket.
+func startLocalhostV5(t *testing.T) (*UDPv5, *enode.DB) {
+	cfg := Config{PrivateKey: newkey()}
+	db, _ := enode.OpenDB("")
+	ln := enode.NewLocalNode(db, cfg.PrivateKey)
+
+	socket, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	realaddr := socket.LocalAddr().(*net.UDPAddr)
+	ln.SetStaticIP(realaddr.IP)
+	ln.SetFallbackUDP(realaddr.Port)
+	udp, err := ListenV5(socket, ln, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return udp, db
+}
+
+// signedNode creates a node with a valid v4 record on localhost.
+func signedNode(port int) *enode.Node {
+	var r enr.Record
+	r.Set(enr.IP(net.IP{127, 0, 0, 1}))
+	r.Set(enr.UDP(port))
+	if err := enode.SignV4(&r, newkey()); err != nil {
+		panic(err)
+	}
+	n, _ := enode.New(enode.ValidSchemes, &r)
+	return n
+}
+
+// addLiveNode inserts n into the table of t as if it had been revalidated.
+func addLiveNode(t *UDPv5, n *enode.Node) {
+	wn := wrapNode(n)
+	wn.livenessChecks = 1
+	t.tab.addSeenNode(wn)
+}
+
+func TestUDPv5_pingHandshake(t *testing.T) {
+	t.Parallel()
+	a, dba := startLocalhostV5(t)
+	defer dba.Close()
+	defer a.Close()
+	b, dbb := startLocalhostV5(t)
+	defer dbb.Close()
+	defer b.Close()
+
+	// The first ping runs the handshake, the second one uses the session.
+	for i := 0; i < 2; i++ {
+		seq, err := a.ping(b.Self())
+		if err != nil {
+			t.Fatalf("ping %d failed: %v", i, err)
+		}
+		if seq != b.Self().Seq() {
+			t.Errorf("wrong seq in pong: got %d, want %d", seq, b.Self().Seq())
+		}
+	}
+	// The handshake adds the initiator to the table of the recipient.
+	if n := b.tab.getNode(a.Self().ID()); n == nil {
+		t.Error("initiator not added to table after handshake")
+	}
+}
+
+func TestUDPv5_pingTimeout(t *testing.T) {
+	t.Parallel()
+	a, db := startLocalhostV5(t)
+	defer db.Close()
+	defer a.Close()
+
+	// Nobody listens on the port of this node.
+	socket, _ := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	port := socket.LocalAddr().(*net.UDPAddr).Port
+	socket.Close()
+
+	if _, err := a.ping(signedNode(port)); err != errTimeout {
+		t.Fatalf("wrong error: got %v, want %v", err, errTimeout)
+	}
+}
+
+func TestUDPv5_findnode(t *testing.T) {
+	t.Parallel()
+	a, dba := startLocalhostV5(t)
+	defer dba.Close()
+	defer a.Close()
+	b, dbb := startLocalhostV5(t)
+	defer dbb.Close()
+	defer b.Close()
+
+	// Run the handshake first. This adds a to the table of b.
+	if err := a.Ping(b.Self()); err != nil {
+		t.Fatal("ping failed:", err)
+	}
+	// Fill the table of b and group the nodes by their distance.
+	bydist := make(map[uint][]*enode.Node)
+	for i := 0; i < 10; i++ {
+		n := signedNode(30000 + i)
+		if i == 0 {
+			n = a.Self()
+		}
+		addLiveNode(b, n)
+		d := uint(enode.LogDist(b.Self().ID(), n.ID()))
+		bydist[d] = append(bydist[d], n)
+	}
+	for dist, want := range bydist {
+		result, err := a.findnodeAt(b.Self(), []uint{dist})
+		if err != nil {
+			t.Fatalf("findnode at distance %d failed: %v", dist, err)
+		}
+		if !reflect.DeepEqual(sortedIDs(result), sortedIDs(want)) {
+			t.Errorf("wrong result at distance %d: got %d nodes, want %d", dist, len(result), len(want))
+		}
+	}
+
+	// Distance zero returns the record of the node itself.
+	n, err := a.RequestENR(b.Self())
+	if err != nil {
+		t.Fatal("RequestENR failed:", err)
+	}
+	if n.ID() != b.Self().ID() || n.Seq() != b.Self().Seq() {
+		t.Errorf("wrong record in ENR response: %v", n)
+	}
+}
+
+func TestUDPv5_lookup(t *testing.T) {
+	t.Parallel()
+	a, dba := startLocalhostV5(t)
+	defer dba.Close()
+	defer a.Close()
+	b, dbb := startLocalhostV5(t)
+	defer dbb.Close()
+	defer b.Close()
+	c, dbc := startLocalhostV5(t)
+	defer dbc.Close()
+	defer c.Close()
+
+	// a knows b, b knows c. A lookup for c on a must find c through b.
+	addLiveNode(a, b.Self())
+	addLiveNode(b, c.Self())
+	result := a.Lookup(c.Self().ID())
+	if len(result) == 0 || result[0].ID() != c.Self().ID() {
+		t.Fatalf("lookup didn't find target, result: %v", result)
+	}
+	if n := a.Resolve(c.Self()); n.ID() != c.Self().ID() {
+		t.Fatalf("resolve returned wrong node %v", n)
+	}
+}
+
+func TestUDPv5_topic(t *testing.T) {
+	t.Parallel()
+	registrar, db0 := startLocalhostV5(t)
+	defer db0.Close()
+	defer registrar.Close()
+	a, dba := startLocalhostV5(t)
+	defer dba.Close()
+	defer a.Close()
+	b, dbb := startLocalhostV5(t)
+	defer dbb.Close()
+	defer b.Close()
+
+	// a registers the topic, b searches for it.
+	topic := Topic("foo")
+	addLiveNode(a, registrar.Self())
+	addLiveNode(b, registrar.Self())
+	stop := make(chan struct{})
+	defer close(stop)
+	go a.RegisterTopic(topic, stop)
+
+	var (
+		setPeriod = make(chan time.Duration, 1)
+		found     = make(chan *enode.Node)
+	)
+	go b.SearchTopic(topic, setPeriod, found, nil)
+	setPeriod <- 200 * time.Millisecond
+	defer close(setPeriod)
+
+	select {
+	case n := <-found:
+		if n.ID() != a.Self().ID() {
+			t.Fatalf("found wrong node %v", n.ID())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("topic search didn't find registered node")
+	}
+}
+
+func TestTopicTable(t *testing.T) {
+	var (
+		clock mclock.Simulated
+		tt    = newTopicTable(&clock)
+		topic = Topic("foo").Hash()
+		ip    = net.IP{127, 0, 0, 1}
+	)
+	// Fill the queue of the topic.
+	for i := 0; i < topicQueueLimit; i++ {
+		if wait, _ := tt.register(signedNode(30000+i), ip, topic, nil); wait != 0 {
+			t.Fatalf("registration %d not accepted, wait time %d", i, wait)
+		}
+		clock.Run(time.Second)
+	}
+	if n := len(tt.query(topic, ip, 2*topicQueueLimit)); n != topicQueueLimit {
+		t.Fatalf("wrong number of ads: got %d, want %d", n, topicQueueLimit)
+	}
+
+	// The next registrant has to wait until the first ad expires.
+	n := signedNode(40000)
+	wait, ticket := tt.register(n, ip, topic, nil)
+	wantWait := uint((topicAdLifetime - topicQueueLimit*time.Second) / time.Second)
+	if wait != wantWait || ticket == nil {
+		t.Fatalf("wrong wait time %d, want %d", wait, wantWait)
+	}
+	// Using the ticket too early doesn't help.
+	if _, err := tt.checkTicket(ticket, n.ID(), ip, topic, clock.Now()); err == nil {
+		t.Fatal("ticket accepted before wait time")
+	}
+	clock.Run(time.Duration(wait) * time.Second)
+	if _, err := tt.checkTicket(ticket, n.ID(), ip, topic, clock.Now()); err != nil {
+		t.Fatal("ticket not accepted:", err)
+	}
+	if _, err := tt.checkTicket(ticket, enode.ID{1}, ip, topic, clock.Now()); err != errInvalidTicket {
+		t.Fatal("ticket accepted for other node:", err)
+	}
+	if wait, _ := tt.register(n, ip, topic, ticket); wait != 0 {
+		t.Fatalf("registration with ticket not accepted, wait time %d", wait)
+	}
+	if tt.count != topicQueueLimit {
+		t.Fatalf("wrong ad count %d after expiry", tt.count)
+	}
+}
+
+// This test checks that ticket holders are admitted to a full queue whose ads
+// are renewed continuously, and that renewals are limited.
+func TestTopicTableRenewals(t *testing.T) {
+	var (
+		clock   mclock.Simulated
+		tt      = newTopicTable(&clock)
+		topic   = Topic("foo").Hash()
+		ip      = net.IP{127, 0, 0, 1}
+		nodes   []*enode.Node
+		renewed mclock.AbsTime
+	)
+	for i := 0; i < topicQueueLimit; i++ {
+		nodes = append(nodes, signedNode(30000+i))
+		tt.register(nodes[i], ip, topic, nil)
+	}
+	// renewAll re-registers all nodes which have an ad shortly before the ads
+	// expire, like registerLoop does.
+	renewAll := func() (accepted int) {
+		clock.Run(time.Duration(renewed.Add(topicAdLifetime-topicTicketWindow) - clock.Now()))
+		renewed = clock.Now()
+		for _, n := range nodes {
+			if tt.find(n.ID(), topic) < 0 {
+				continue
+			}
+			if wait, _ := tt.register(n, ip, topic, nil); wait == 0 {
+				accepted++
+			}
+		}
+		return accepted
+	}
+
+	// Two new registrants get tickets for consecutive slots.
+	var (
+		waiters = []*enode.Node{signedNode(40000), signedNode(40001)}
+		waits   []uint
+		tickets [][]byte
+	)
+	for _, n := range waiters {
+		wait, ticket := tt.register(n, ip, topic, nil)
+		if wait == 0 || ticket == nil {
+			t.Fatalf("registrant %v admitted to full queue", n.ID())
+		}
+		waits = append(waits, wait)
+		tickets = append(tickets, ticket)
+	}
+	// The existing ads are renewed before the tickets can be used.
+	if n := renewAll(); n != topicQueueLimit {
+		t.Fatalf("only %d of %d renewals accepted", n, topicQueueLimit)
+	}
+	clock.Run(time.Duration(waits[1])*time.Second - time.Duration(clock.Now()))
+	for i, n := range waiters {
+		if wait, _ := tt.register(n, ip, topic, tickets[i]); wait != 0 {
+			t.Fatalf("ticket holder %d not admitted, wait time %d", i, wait)
+		}
+		if tt.find(n.ID(), topic) < 0 {
+			t.Fatalf("ticket holder %d has no ad", i)
+		}
+	}
+	if len(tt.queues[topic]) != topicQueueLimit || tt.count != topicQueueLimit {
+		t.Fatalf("wrong ad count %d, want %d", tt.count, topicQueueLimit)
+	}
+	nodes = append(nodes, waiters...)
+
+	// Renewals are accepted a limited number of times while the queue is full.
+	// The ads of the former ticket holders are younger and can still be renewed
+	// after the limit is reached for the others.
+	for i := 2; i <= topicMaxRenewals; i++ {
+		if n := renewAll(); n != topicQueueLimit {
+			t.Fatalf("renewal %d: %d renewals accepted, want %d", i, n, topicQueueLimit)
+		}
+	}
+	if n := renewAll(); n != len(waiters) {
+		t.Fatalf("%d renewals accepted after the limit, want %d", n, len(waiters))
+	}
+}
+
+// This test checks that every ticket holder of a full queue or table gets its own
+// slot, and that the number of outstanding tickets is limited.
+func TestTopicTableTickets(t *testing.T) {
+	var (
+		clock mclock.Simulated
+		tt    = newTopicTable(&clock)
+		ip    = net.IP{127, 0, 0, 1}
+		nodes []*enode.Node
+	)
+	for i := 0; i < topicQueueLimit; i++ {
+		nodes = append(nodes, signedNode(30000+i))
+	}
+	// Fill the table, placing the ads 100ms apart.
+	topics := topicTableLimit / topicQueueLimit
+	for i := 0; i < topics; i++ {
+		topic := Topic(fmt.Sprint("topic", i)).Hash()
+		for _, n := range nodes {
+			if wait, _ := tt.register(n, ip, topic, nil); wait != 0 {
+				t.Fatalf("registration not accepted, wait time %d", wait)
+			}
+			clock.Run(100 * time.Millisecond)
+		}
+	}
+	if tt.count != topicTableLimit {
+		t.Fatalf("wrong ad count %d, want %d", tt.count, topicTableLimit)
+	}
+	// Registrants of a new topic wait for consecutive ads to expire.
+	topic := Topic("foo").Hash()
+	for i := 0; i < 20; i++ {
+		expiry := topicAdLifetime + time.Duration(i)*100*time.Millisecond - time.Duration(clock.Now())
+		want := uint((expiry + time.Second - 1) / time.Second)
+		if wait, ticket := tt.register(signedNode(40000+i), ip, topic, nil); ticket == nil || wait != want {
+			t.Fatalf("registrant %d: wrong wait time %d, want %d", i, wait, want)
+		}
+	}
+	// Tickets are only issued up to the limit of the topic.
+	topic = Topic("topic0").Hash()
+	for i := 0; i < topicQueueTickets; i++ {
+		if _, ticket := tt.register(signedNode(41000+i), ip, topic, nil); ticket == nil {
+			t.Fatalf("registrant %d got no ticket", i)
+		}
+	}
+	wait, ticket := tt.register(signedNode(50000), ip, topic, nil)
+	if ticket != nil || wait != uint(topicAdLifetime/time.Second) {
+		t.Fatalf("ticket issued above the limit, wait time %d", wait)
+	}
+	if tt.pending != 20+topicQueueTickets {
+		t.Fatalf("wrong number of outstanding tickets %d, want %d", tt.pending, 20+topicQueueTickets)
+	}
+	// Expired tickets no longer count.
+	clock.Run(topicAdLifetime + topicTicketWindow)
+	tt.expire()
+	if tt.pending != 0 || len(tt.tickets) != 0 {
+		t.Fatalf("%d tickets outstanding after expiry", tt.pending)
+	}
+}
+
+func TestLookupDistances(t *testing.T) {
+	var (
+		target = enode.ID{}
+		tests  = []struct {
+			dest enode.ID
+			want []uint
+		}{
+			{idAtDistance(target, 256), []uint{256, 255, 254}},
+			{idAtDistance(target, 100), []uint{100, 101, 99}},
+			{target, []uint{0, 1, 2}},
+		}
+	)
+	for _, test := range tests {
+		if got := lookupDistances(target, test.dest); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("wrong distances for logdist %d: got %v, want %v", enode.LogDist(target, test.dest), got, test.want)
+		}
+	}
+}
+
+func TestPackNodes(t *testing.T) {
+	var nodes []*enode.Node
+	for i := 0; i < 7; i++ {
+		nodes = append(nodes, signedNode(30000+i))
+	}
+	resp := packNodes([]byte{1}, nodes)
+	if len(resp) != 3 {
+		t.Fatalf("wrong number of packets: %d", len(resp))
+	}
+	for _, p := range resp {
+		if p.Total != 3 {
+			t.Errorf("wrong total %d", p.Total)
+		}
+	}
+	if len(packNodes(nil, nil)) != 1 {
+		t.Error("empty result should be sent as one packet")
+	}
+}
+
+func sortedIDs(nodes []*enode.Node) []enode.ID {
+	ids := make([]enode.ID, len(nodes))
+	for i, n := range nodes {
+		ids[i] = n.ID()
+	}
+	sort.Slice(ids, func(i, j int) bool { return enode.DistCmp(enode.ID{}, ids[i], ids[j]) < 0 })
+	return ids
+}
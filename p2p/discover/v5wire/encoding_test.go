@@ -0,0 +1,395 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package v5wire
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/ethereum/go-ethereum/common/mclock"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// This test checks the key derivation function against RFC 5869 test case 1.
+func TestHKDF(t *testing.T) {
+	var (
+		ikm, _  = hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+		salt, _ = hex.DecodeString("000102030405060708090a0b0c")
+		info, _ = hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+		want, _ = hex.DecodeString("3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865")
+	)
+	if okm := hkdf(sha256.New, ikm, salt, info, 42); !bytes.Equal(okm, want) {
+		t.Fatalf("wrong output:\ngot  %x\nwant %x", okm, want)
+	}
+}
+
+// This test checks that both sides of a handshake derive matching keys.
+func TestDeriveKeys(t *testing.T) {
+	var (
+		key1, _   = crypto.GenerateKey()
+		key2, _   = crypto.GenerateKey()
+		id1       = enode.PubkeyToIDV4(&key1.PublicKey)
+		id2       = enode.PubkeyToIDV4(&key2.PublicKey)
+		challenge = []byte("challenge data")
+	)
+	s1 := deriveKeys(sha256.New, key1, &key2.PublicKey, id1, id2, challenge)
+	s2 := deriveKeys(sha256.New, key2, &key1.PublicKey, id1, id2, challenge).keysFlipped()
+	if !bytes.Equal(s1.writeKey, s2.readKey) || !bytes.Equal(s1.readKey, s2.writeKey) {
+		t.Fatalf("keys don't match:\n%x\n%x", s1, s2)
+	}
+}
+
+func TestIDSignature(t *testing.T) {
+	var (
+		key, _    = crypto.GenerateKey()
+		ephkey, _ = crypto.GenerateKey()
+		ln        = newLocalNode(key)
+		challenge = []byte("challenge data")
+		destID    = enode.ID{1}
+	)
+	sig, err := makeIDSignature(sha256.New(), key, challenge, EncodePubkey(&ephkey.PublicKey), destID)
+	if err != nil {
+		t.Fatal("can't sign:", err)
+	}
+	if err := verifyIDSignature(sha256.New(), sig, ln.Node(), challenge, EncodePubkey(&ephkey.PublicKey), destID); err != nil {
+		t.Fatal("signature not valid:", err)
+	}
+	if err := verifyIDSignature(sha256.New(), sig, ln.Node(), challenge, EncodePubkey(&ephkey.PublicKey), enode.ID{2}); err != errInvalidNonceSig {
+		t.Fatalf("wrong error for signature with different dest ID: %v", err)
+	}
+}
+
+// This test runs a full handshake between two codecs.
+func TestHandshake(t *testing.T) {
+	t.Parallel()
+	net := newHandshakeTest()
+	defer net.close()
+
+	// A -> B   RANDOM PACKET
+	packet, _ := net.nodeA.encode(t, net.nodeB, &Findnode{})
+	resp := net.nodeB.expectDecode(t, UnknownPacket, packet)
+
+	// A <- B   WHOAREYOU
+	challenge := &Whoareyou{
+		Nonce:     resp.(*Unknown).Nonce,
+		IDNonce:   testIDnonce,
+		RecordSeq: 0,
+	}
+	whoareyou, _ := net.nodeB.encode(t, net.nodeA, challenge)
+	net.nodeA.expectDecode(t, WhoareyouPacket, whoareyou)
+
+	// A -> B   FINDNODE (handshake packet)
+	findnode, _ := net.nodeA.encodeWithChallenge(t, net.nodeB, challenge, &Findnode{})
+	net.nodeB.expectDecode(t, FindnodeMsg, findnode)
+	if len(net.nodeB.c.sc.handshakes) > 0 {
+		t.Fatalf("node B didn't remove handshake from challenge map")
+	}
+
+	// A <- B   NODES
+	nodes, _ := net.nodeB.encode(t, net.nodeA, &Nodes{Total: 1})
+	net.nodeA.expectDecode(t, NodesMsg, nodes)
+}
+
+// This test checks that handshake attempts are removed within the timeout.
+func TestHandshake_timeout(t *testing.T) {
+	t.Parallel()
+	net := newHandshakeTest()
+	defer net.close()
+
+	// A -> B   RANDOM PACKET
+	packet, _ := net.nodeA.encode(t, net.nodeB, &Findnode{})
+	resp := net.nodeB.expectDecode(t, UnknownPacket, packet)
+
+	// A <- B   WHOAREYOU
+	challenge := &Whoareyou{
+		Nonce:     resp.(*Unknown).Nonce,
+		IDNonce:   testIDnonce,
+		RecordSeq: 0,
+	}
+	whoareyou, _ := net.nodeB.encode(t, net.nodeA, challenge)
+	net.nodeA.expectDecode(t, WhoareyouPacket, whoareyou)
+
+	// A -> B   FINDNODE (handshake packet) after timeout
+	net.clock.Run(handshakeTimeout + 1)
+	findnode, _ := net.nodeA.encodeWithChallenge(t, net.nodeB, challenge, &Findnode{})
+	net.nodeB.expectDecodeErr(t, errUnexpectedHandshake, findnode)
+}
+
+// This test checks handshake behavior when no record is sent in the auth response.
+func TestHandshake_norecord(t *testing.T) {
+	t.Parallel()
+	net := newHandshakeTest()
+	defer net.close()
+
+	// A -> B   RANDOM PACKET
+	packet, _ := net.nodeA.encode(t, net.nodeB, &Findnode{})
+	resp := net.nodeB.expectDecode(t, UnknownPacket, packet)
+
+	// A <- B   WHOAREYOU
+	nodeA := net.nodeA.n()
+	if nodeA.Seq() == 0 {
+		t.Fatal("need non-zero sequence number")
+	}
+	challenge := &Whoareyou{
+		Nonce:     resp.(*Unknown).Nonce,
+		IDNonce:   testIDnonce,
+		RecordSeq: nodeA.Seq(),
+		Node:      nodeA,
+	}
+	whoareyou, _ := net.nodeB.encode(t, net.nodeA, challenge)
+	net.nodeA.expectDecode(t, WhoareyouPacket, whoareyou)
+
+	// A -> B   FINDNODE
+	findnode, _ := net.nodeA.encodeWithChallenge(t, net.nodeB, challenge, &Findnode{})
+	net.nodeB.expectDecode(t, FindnodeMsg, findnode)
+
+	// A <- B   NODES
+	nodes, _ := net.nodeB.encode(t, net.nodeA, &Nodes{Total: 1})
+	net.nodeA.expectDecode(t, NodesMsg, nodes)
+}
+
+// In this test, A tries to send FINDNODE with existing secrets but B doesn't know
+// anything about A.
+func TestHandshake_BadHandshakeAttack(t *testing.T) {
+	t.Parallel()
+	net := newHandshakeTest()
+	defer net.close()
+
+	// A -> B   RANDOM PACKET
+	packet, _ := net.nodeA.encode(t, net.nodeB, &Findnode{})
+	resp := net.nodeB.expectDecode(t, UnknownPacket, packet)
+
+	// A <- B   WHOAREYOU
+	challenge := &Whoareyou{
+		Nonce:     resp.(*Unknown).Nonce,
+		IDNonce:   testIDnonce,
+		RecordSeq: 0,
+	}
+	whoareyou, _ := net.nodeB.encode(t, net.nodeA, challenge)
+	net.nodeA.expectDecode(t, WhoareyouPacket, whoareyou)
+
+	// A -> B   FINDNODE
+	incorrectChallenge := &Whoareyou{
+		IDNonce:   [16]byte{5, 6, 7, 8, 9, 6, 11, 12},
+		RecordSeq: challenge.RecordSeq,
+		Node:      challenge.Node,
+		sent:      challenge.sent,
+	}
+	incorrectFindNode, _ := net.nodeA.encodeWithChallenge(t, net.nodeB, incorrectChallenge, &Findnode{})
+	incorrectFindNode2 := make([]byte, len(incorrectFindNode))
+	copy(incorrectFindNode2, incorrectFindNode)
+
+	net.nodeB.expectDecodeErr(t, errInvalidNonceSig, incorrectFindNode)
+
+	// Reject new findnode as previous handshake is now deleted.
+	net.nodeB.expectDecodeErr(t, errUnexpectedHandshake, incorrectFindNode2)
+
+	// The findnode packet is again rejected even with a valid challenge this time.
+	findnode, _ := net.nodeA.encodeWithChallenge(t, net.nodeB, challenge, &Findnode{})
+	net.nodeB.expectDecodeErr(t, errUnexpectedHandshake, findnode)
+}
+
+// This test checks some malformed packets.
+func TestDecodeErrors(t *testing.T) {
+	t.Parallel()
+	net := newHandshakeTest()
+	defer net.close()
+
+	net.nodeB.expectDecodeErr(t, errTooShort, make([]byte, sizeofStaticPacketData-1))
+
+	// Valid packet with the wrong protocol ID.
+	packet, _ := net.nodeA.encode(t, net.nodeB, &Findnode{})
+	head := Header{StaticHeader: StaticHeader{ProtocolID: [6]byte{'d', 'i', 's', 'c', 'v', '4'}, Version: version}}
+	enc, _ := net.nodeA.c.EncodeRaw(net.nodeB.id(), head, make([]byte, minMessageSize))
+	net.nodeB.expectDecodeErr(t, errInvalidHeader, enc)
+
+	// Valid packet with a too-large auth size.
+	head = Header{StaticHeader: StaticHeader{ProtocolID: protocolID, Version: version, AuthSize: 1000}}
+	enc, _ = net.nodeA.c.EncodeRaw(net.nodeB.id(), head, make([]byte, minMessageSize))
+	net.nodeB.expectDecodeErr(t, errAuthSize, enc)
+
+	// The random packet is still decodable.
+	net.nodeB.expectDecode(t, UnknownPacket, packet)
+}
+
+// This test checks that all message types survive an encode/decode roundtrip.
+func TestMessageRoundtrip(t *testing.T) {
+	t.Parallel()
+	net := newHandshakeTest()
+	defer net.close()
+	net.establishSession(t)
+
+	ln := net.nodeA.ln
+	msgs := []Packet{
+		&Ping{ReqID: []byte{1}, ENRSeq: 2},
+		&Pong{ReqID: []byte{1}, ENRSeq: 2, ToIP: net.ip(), ToPort: 30303},
+		&Findnode{ReqID: []byte{1}, Distances: []uint{255, 256}},
+		&Nodes{ReqID: []byte{1}, Total: 1, Nodes: []*enr.Record{}},
+		&TalkRequest{ReqID: []byte{1}, Protocol: "test", Message: []byte{5}},
+		&TalkResponse{ReqID: []byte{1}, Message: []byte{5}},
+		&Regtopic{ReqID: []byte{1}, Topic: TopicHash{1}, ENR: ln.Node().Record(), Ticket: []byte{2}},
+		&Ticket{ReqID: []byte{1}, Ticket: []byte{2}, WaitTime: 10},
+		&Regconfirmation{ReqID: []byte{1}, Topic: TopicHash{1}},
+		&TopicQuery{ReqID: []byte{1}, Topic: TopicHash{1}},
+	}
+	for _, msg := range msgs {
+		enc, _ := net.nodeA.encode(t, net.nodeB, msg)
+		dec := net.nodeB.expectDecode(t, msg.Kind(), enc)
+		if msg.Kind() == RegtopicMsg {
+			// Records don't compare equal after decoding.
+			if dec.(*Regtopic).ENR.Seq() != ln.Node().Seq() {
+				t.Errorf("wrong record in %s", msg.Name())
+			}
+			continue
+		}
+		if !reflect.DeepEqual(dec, msg) {
+			t.Errorf("%s: decoded message doesn't match:\ngot  %s\nwant %s", msg.Name(), spew.Sdump(dec), spew.Sdump(msg))
+		}
+	}
+}
+
+func TestDecodeMessage_reqID(t *testing.T) {
+	if _, err := DecodeMessage(PingMsg, []byte{0xCB, 0x89, 1, 2, 3, 4, 5, 6, 7, 8, 9, 0x01}); err != ErrInvalidReqID {
+		t.Fatalf("wrong error %v, want %v", err, ErrInvalidReqID)
+	}
+}
+
+// handshakeTest is a test setup for the handshake of two codecs.
+type handshakeTest struct {
+	nodeA, nodeB handshakeTestNode
+	clock        mclock.Simulated
+}
+
+type handshakeTestNode struct {
+	ln *enode.LocalNode
+	c  *Codec
+}
+
+var testIDnonce = [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+func newHandshakeTest() *handshakeTest {
+	t := new(handshakeTest)
+	keyA, _ := crypto.GenerateKey()
+	keyB, _ := crypto.GenerateKey()
+	t.nodeA.init(keyA, net.IP{127, 0, 0, 1}, &t.clock)
+	t.nodeB.init(keyB, net.IP{127, 0, 0, 1}, &t.clock)
+	return t
+}
+
+func (t *handshakeTest) close() {
+	t.nodeA.ln.Database().Close()
+	t.nodeB.ln.Database().Close()
+}
+
+func (t *handshakeTest) ip() net.IP {
+	return net.IP{127, 0, 0, 1}
+}
+
+// establishSession runs the handshake so that A and B share a session.
+func (t *handshakeTest) establishSession(tt *testing.T) {
+	packet, _ := t.nodeA.encode(tt, t.nodeB, &Ping{})
+	resp := t.nodeB.expectDecode(tt, UnknownPacket, packet)
+	challenge := &Whoareyou{Nonce: resp.(*Unknown).Nonce, IDNonce: testIDnonce}
+	whoareyou, _ := t.nodeB.encode(tt, t.nodeA, challenge)
+	t.nodeA.expectDecode(tt, WhoareyouPacket, whoareyou)
+	ping, _ := t.nodeA.encodeWithChallenge(tt, t.nodeB, challenge, &Ping{})
+	t.nodeB.expectDecode(tt, PingMsg, ping)
+}
+
+func newLocalNode(key *ecdsa.PrivateKey) *enode.LocalNode {
+	db, _ := enode.OpenDB("")
+	ln := enode.NewLocalNode(db, key)
+	ln.SetStaticIP(net.IP{127, 0, 0, 1})
+	return ln
+}
+
+func (n *handshakeTestNode) init(key *ecdsa.PrivateKey, ip net.IP, clock mclock.Clock) {
+	n.ln = newLocalNode(key)
+	n.ln.SetStaticIP(ip)
+	n.c = NewCodec(n.ln, key, clock)
+}
+
+func (n *handshakeTestNode) encode(t testing.TB, to handshakeTestNode, p Packet) ([]byte, Nonce) {
+	t.Helper()
+	return n.encodeWithChallenge(t, to, nil, p)
+}
+
+func (n *handshakeTestNode) encodeWithChallenge(t testing.TB, to handshakeTestNode, c *Whoareyou, p Packet) ([]byte, Nonce) {
+	t.Helper()
+
+	// Copy challenge and add destination node. This avoids sharing 'c' among the two codecs.
+	var challenge *Whoareyou
+	if c != nil {
+		challengeCopy := *c
+		challenge = &challengeCopy
+		challenge.Node = to.n()
+	}
+	// Encode to destination.
+	enc, nonce, err := n.c.Encode(to.id(), to.addr(), p, challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("(%s) -> (%s)   %s\n%s", n.ln.ID().TerminalString(), to.id().TerminalString(), p.Name(), hex.Dump(enc))
+	// The codec reuses its buffers, so return a copy.
+	return append([]byte(nil), enc...), nonce
+}
+
+func (n *handshakeTestNode) expectDecode(t *testing.T, ptype byte, p []byte) Packet {
+	t.Helper()
+
+	dec, err := n.decode(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%s %s", n.ln.ID().TerminalString(), spew.Sdump(dec))
+	if dec.Kind() != ptype {
+		t.Fatalf("expected packet type %d, got %d", ptype, dec.Kind())
+	}
+	return dec
+}
+
+func (n *handshakeTestNode) expectDecodeErr(t *testing.T, wantErr error, p []byte) {
+	t.Helper()
+	if _, err := n.decode(p); !reflect.DeepEqual(err, wantErr) {
+		t.Fatal(spew.Sprintf("expected decode error %v, got %v", wantErr, err))
+	}
+}
+
+func (n *handshakeTestNode) decode(input []byte) (Packet, error) {
+	_, _, p, err := n.c.Decode(input, "127.0.0.1")
+	return p, err
+}
+
+func (n *handshakeTestNode) n() *enode.Node {
+	return n.ln.Node()
+}
+
+func (n *handshakeTestNode) addr() string {
+	return n.ln.Node().IP().String()
+}
+
+func (n *handshakeTestNode) id() enode.ID {
+	return n.ln.ID()
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discv5
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// topicIterPeriod is the topic search period requested by TopicNodes iterators.
+const topicIterPeriod = 100 * time.Millisecond
+
+// TopicNodes returns an iterator over nodes which advertise the given topic.
+// The iterator keeps searching for the topic until it is closed.
+func (net *Network) TopicNodes(topic Topic) enode.Iterator {
+	it := &topicIterator{
+		found:     make(chan *Node, 100),
+		setPeriod: make(chan time.Duration, 1),
+		closed:    make(chan struct{}),
+	}
+	it.setPeriod <- topicIterPeriod
+	go net.SearchTopic(topic, it.setPeriod, it.found, nil)
+	return it
+}
+
+type topicIterator struct {
+	found     chan *Node
+	setPeriod chan time.Duration
+	cur       *enode.Node
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+func (it *topicIterator) Next() bool {
+	it.cur = nil
+	for {
+		// Don't return buffered nodes once the iterator is closed
+		select {
+		case <-it.closed:
+			return false
+		default:
+		}
+		select {
+		case n := <-it.found:
+			pubkey, err := n.ID.Pubkey()
+			if err != nil {
+				continue
+			}
+			it.cur = enode.NewV4(pubkey, n.IP, int(n.TCP), int(n.UDP))
+			return true
+		case <-it.closed:
+			return false
+		}
+	}
+}
+
+func (it *topicIterator) Node() *enode.Node {
+	return it.cur
+}
+
+// Close stops the topic search and unblocks any pending Next call.
+func (it *topicIterator) Close() {
+	it.closeOnce.Do(func() {
+		close(it.closed)
+		close(it.setPeriod)
+	})
+}
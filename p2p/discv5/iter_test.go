@@ -0,0 +1,93 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package discv5
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Tests that the topic iterator yields the nodes found by the search, and that
+// closing it stops the search and unblocks Next.
+func TestTopicNodesClose(t *testing.T) {
+	network := &Network{
+		topicSearchReq: make(chan topicSearchReq),
+		closed:         make(chan struct{}),
+	}
+	it := network.TopicNodes("foo")
+
+	// The iterator must start searching the topic
+	var req topicSearchReq
+	select {
+	case req = <-network.topicSearchReq:
+	case <-time.After(time.Second):
+		t.Fatal("topic search not started")
+	}
+	if req.topic != "foo" || req.delay != topicIterPeriod {
+		t.Fatalf("search request mismatch: topic %q, period %v", req.topic, req.delay)
+	}
+	// Found nodes must be delivered
+	key, _ := crypto.GenerateKey()
+	req.found <- NewNode(PubkeyID(&key.PublicKey), net.IP{127, 0, 0, 1}, 30303, 30303)
+	if !it.Next() {
+		t.Fatal("Next returned false before close")
+	}
+	if id := it.Node().Pubkey(); id == nil || id.X.Cmp(key.PublicKey.X) != 0 {
+		t.Fatalf("wrong node delivered: %v", it.Node())
+	}
+	// A blocked Next must be released by Close, and buffered nodes dropped
+	req.found <- NewNode(PubkeyID(&key.PublicKey), net.IP{127, 0, 0, 1}, 30303, 30303)
+	it.Close()
+	if it.Next() {
+		t.Fatal("Next returned true after close")
+	}
+	if it.Node() != nil {
+		t.Fatal("Node returned non-nil after close")
+	}
+	it.Close()
+
+	// Closing must stop the topic search
+	select {
+	case req = <-network.topicSearchReq:
+		if req.delay != 0 {
+			t.Fatalf("search not stopped: period %v", req.delay)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("topic search not stopped")
+	}
+
+	// Closing a blocked iterator must release Next
+	it = network.TopicNodes("bar")
+	<-network.topicSearchReq
+
+	done := make(chan bool)
+	go func() { done <- it.Next() }()
+	time.Sleep(50 * time.Millisecond)
+	it.Close()
+	select {
+	case ok := <-done:
+		if ok {
+			t.Fatal("blocked Next returned true after close")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocked Next not released by close")
+	}
+	<-network.topicSearchReq
+}
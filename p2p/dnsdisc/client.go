@@ -0,0 +1,351 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package dnsdisc implements node discovery via DNS (EIP-1459).
+//
+// Node lists are published as merkle trees of node records in DNS TXT records.
+// The root of a tree is signed by its publisher, whose public key is part of the
+// tree URL, so that clients can authenticate the lists without trusting the DNS
+// provider. Trees may link to other trees, allowing lists to be composed.
+package dnsdisc
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Client discovers nodes by querying DNS servers.
+type Client struct {
+	cfg     Config
+	entries *lru.Cache
+	urls    []*linkEntry
+
+	lock    sync.RWMutex
+	trees   map[string]*clientTree // synced trees by domain
+	nodes   []*enode.Node          // nodes of all synced trees
+	updated chan struct{}          // closed when the set of nodes changes
+
+	closeOnce sync.Once
+	quit      chan struct{}
+}
+
+// Config holds configuration options for the client.
+type Config struct {
+	Timeout         time.Duration      // timeout used for DNS lookups (default 5s)
+	RecheckInterval time.Duration      // time between tree root update checks (default 30min)
+	RetryInterval   time.Duration      // time between sync attempts after a failure (default 1min)
+	CacheLimit      int                // maximum number of cached records (default 1000)
+	ValidSchemes    enr.IdentityScheme // acceptable ENR identity schemes (default enode.ValidSchemes)
+	Resolver        Resolver           // the DNS resolver to use (defaults to system DNS)
+	Logger          log.Logger         // destination of client log messages (defaults to root logger)
+}
+
+// Resolver is a DNS resolver that can query TXT records.
+type Resolver interface {
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+}
+
+func (cfg Config) withDefaults() Config {
+	const (
+		defaultTimeout = 5 * time.Second
+		defaultRecheck = 30 * time.Minute
+		defaultRetry   = time.Minute
+		defaultCache   = 1000
+	)
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.RecheckInterval == 0 {
+		cfg.RecheckInterval = defaultRecheck
+	}
+	if cfg.RetryInterval == 0 {
+		cfg.RetryInterval = defaultRetry
+	}
+	if cfg.CacheLimit == 0 {
+		cfg.CacheLimit = defaultCache
+	}
+	if cfg.ValidSchemes == nil {
+		cfg.ValidSchemes = enode.ValidSchemes
+	}
+	if cfg.Resolver == nil {
+		cfg.Resolver = new(net.Resolver)
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = log.Root()
+	}
+	return cfg
+}
+
+// NewClient creates a client resolving the node lists at the given enrtree://
+// URLs. Call Start to begin syncing the lists in the background.
+func NewClient(cfg Config, urls ...string) (*Client, error) {
+	c := &Client{
+		cfg:     cfg.withDefaults(),
+		trees:   make(map[string]*clientTree),
+		updated: make(chan struct{}),
+		quit:    make(chan struct{}),
+	}
+	var err error
+	if c.entries, err = lru.New(c.cfg.CacheLimit); err != nil {
+		return nil, err
+	}
+	for _, url := range urls {
+		le, err := parseLink(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid enrtree URL %q: %v", url, err)
+		}
+		c.urls = append(c.urls, le)
+	}
+	return c, nil
+}
+
+// SyncTree downloads the entire node tree at the given URL. This doesn't add the
+// tree for later use, but any previously-synced entries are reused.
+func (c *Client) SyncTree(url string) (*Tree, error) {
+	le, err := parseLink(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid enrtree URL: %v", err)
+	}
+	ct := newClientTree(c, le)
+	t := &Tree{entries: make(map[string]entry)}
+	if err := ct.syncAll(t.entries); err != nil {
+		return nil, err
+	}
+	t.root = ct.root
+	return t, nil
+}
+
+// Start launches the background sync of the configured node lists.
+func (c *Client) Start() {
+	go c.loop()
+}
+
+// Close stops the background sync.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() { close(c.quit) })
+}
+
+// ReadRandomNodes fills the given slice with random nodes from the synced lists,
+// returning the number of nodes written.
+func (c *Client) ReadRandomNodes(buf []*enode.Node) int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	n := 0
+	for _, i := range rand.Perm(len(c.nodes)) {
+		if n == len(buf) {
+			break
+		}
+		buf[n] = c.nodes[i]
+		n++
+	}
+	return n
+}
+
+// RandomNodes returns an iterator yielding random nodes from the synced lists.
+// Next blocks until the lists contain any nodes.
+func (c *Client) RandomNodes() enode.Iterator {
+	return &randomIterator{c: c, closed: make(chan struct{})}
+}
+
+// randomIterator traverses the nodes of all synced lists in random order.
+type randomIterator struct {
+	c   *Client
+	cur *enode.Node
+
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// Next moves to a random node, waiting for the lists to be synced if they are empty.
+func (it *randomIterator) Next() bool {
+	for {
+		select {
+		case <-it.closed:
+			return false
+		case <-it.c.quit:
+			return false
+		default:
+		}
+		it.c.lock.RLock()
+		nodes, updated := it.c.nodes, it.c.updated
+		it.c.lock.RUnlock()
+
+		if len(nodes) > 0 {
+			it.cur = nodes[rand.Intn(len(nodes))]
+			return true
+		}
+		select {
+		case <-updated:
+		case <-it.closed:
+			return false
+		case <-it.c.quit:
+			return false
+		}
+	}
+}
+
+// Node returns the current node.
+func (it *randomIterator) Node() *enode.Node {
+	return it.cur
+}
+
+// Close ends the iterator.
+func (it *randomIterator) Close() {
+	it.closeOnce.Do(func() { close(it.closed) })
+}
+
+// loop periodically syncs the configured trees until the client is closed.
+func (c *Client) loop() {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			wait := c.cfg.RecheckInterval
+			if err := c.syncAll(); err != nil {
+				c.cfg.Logger.Debug("DNS discovery sync failed", "err", err)
+				wait = c.cfg.RetryInterval
+			}
+			timer.Reset(wait)
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// syncAll resolves the configured trees and all trees linked from them, replacing
+// the set of known nodes. Trees which can't be synced retain their last known
+// nodes, the error of the first failing tree is returned.
+func (c *Client) syncAll() error {
+	var (
+		firstErr error
+		visited  = make(map[string]bool)
+		queue    = append([]*linkEntry{}, c.urls...)
+		trees    = make(map[string]*clientTree)
+		nodes    []*enode.Node
+		seen     = make(map[enode.ID]bool)
+	)
+	for len(queue) > 0 {
+		le := queue[0]
+		queue = queue[1:]
+		if visited[le.domain] {
+			continue
+		}
+		visited[le.domain] = true
+
+		c.lock.RLock()
+		ct := c.trees[le.domain]
+		c.lock.RUnlock()
+		if ct == nil || ct.loc.String() != le.String() {
+			ct = newClientTree(c, le)
+		}
+		trees[le.domain] = ct
+
+		if err := ct.sync(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		queue = append(queue, ct.links...)
+		for _, n := range ct.nodes {
+			if !seen[n.ID()] {
+				seen[n.ID()] = true
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	c.lock.Lock()
+	c.trees, c.nodes = trees, nodes
+	close(c.updated)
+	c.updated = make(chan struct{})
+	c.lock.Unlock()
+
+	c.cfg.Logger.Debug("Synced DNS node lists", "trees", len(trees), "nodes", len(nodes))
+	return firstErr
+}
+
+// resolveRoot retrieves a root entry via DNS.
+func (c *Client) resolveRoot(ctx context.Context, loc *linkEntry) (rootEntry, error) {
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, loc.domain)
+	c.cfg.Logger.Trace("Updating DNS discovery root", "tree", loc.domain, "err", err)
+	if err != nil {
+		return rootEntry{}, err
+	}
+	for _, txt := range txts {
+		if strings.HasPrefix(txt, rootPrefix) {
+			return parseAndVerifyRoot(txt, loc)
+		}
+	}
+	return rootEntry{}, nameError{loc.domain, errNoRoot}
+}
+
+func parseAndVerifyRoot(txt string, loc *linkEntry) (rootEntry, error) {
+	e, err := parseRoot(txt)
+	if err != nil {
+		return e, err
+	}
+	if !e.verifySignature(loc.pubkey) {
+		return e, entryError{typ: "root", err: errInvalidSig}
+	}
+	return e, nil
+}
+
+// resolveEntry retrieves an entry from the cache or fetches it from the network
+// if it isn't cached.
+func (c *Client) resolveEntry(ctx context.Context, domain, hash string) (entry, error) {
+	if e, ok := c.entries.Get(hash); ok {
+		return e.(entry), nil
+	}
+	e, err := c.doResolveEntry(ctx, domain, hash)
+	if err != nil {
+		return nil, err
+	}
+	c.entries.Add(hash, e)
+	return e, nil
+}
+
+// doResolveEntry fetches an entry via DNS.
+func (c *Client) doResolveEntry(ctx context.Context, domain, hash string) (entry, error) {
+	name := hash + "." + domain
+	txts, err := c.cfg.Resolver.LookupTXT(ctx, name)
+	c.cfg.Logger.Trace("DNS discovery lookup", "name", name, "err", err)
+	if err != nil {
+		return nil, err
+	}
+	for _, txt := range txts {
+		e, err := parseEntry(txt, c.cfg.ValidSchemes)
+		if err == errUnknownEntry {
+			continue
+		}
+		if err != nil {
+			return nil, nameError{name, err}
+		}
+		if subdomain(e) != hash {
+			return nil, nameError{name, errHashMismatch}
+		}
+		return e, nil
+	}
+	return nil, nameError{name, errNoEntry}
+}
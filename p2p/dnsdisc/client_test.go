@@ -0,0 +1,235 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+const (
+	signingKeySeed = 0x111111
+	nodesSeed1     = 0x2945237
+	nodesSeed2     = 0x4567299
+)
+
+func TestClientSyncTree(t *testing.T) {
+	var (
+		key       = testKey(signingKeySeed)
+		nodes     = testNodes(nodesSeed1, 30)
+		tree, url = makeTestTree("n", nodes, nil, key)
+		r         = mapResolver(tree.ToTXT("n"))
+	)
+	c, _ := NewClient(Config{Resolver: r})
+	stree, err := c.SyncTree(url)
+	if err != nil {
+		t.Fatal("sync error:", err)
+	}
+	if !reflect.DeepEqual(sortByID(stree.Nodes()), sortByID(nodes)) {
+		t.Errorf("wrong nodes in synced tree")
+	}
+	if !reflect.DeepEqual(stree.Links(), tree.Links()) {
+		t.Errorf("wrong links in synced tree")
+	}
+	if stree.Seq() != tree.Seq() || stree.Signature() != tree.Signature() {
+		t.Errorf("wrong root in synced tree")
+	}
+}
+
+// Tests that syncing fails on a tree signed by a different key.
+func TestClientSyncTreeBadSignature(t *testing.T) {
+	var (
+		nodes   = testNodes(nodesSeed1, 3)
+		tree, _ = makeTestTree("n", nodes, nil, testKey(signingKeySeed))
+		r       = mapResolver(tree.ToTXT("n"))
+		url     = (&linkEntry{"n", &testKey(nodesSeed2).PublicKey}).String()
+	)
+	c, _ := NewClient(Config{Resolver: r})
+	_, err := c.SyncTree(url)
+	if err != (entryError{"root", errInvalidSig}) {
+		t.Fatal("expected signature error, got", err)
+	}
+}
+
+// Tests that entries not matching their hash are rejected.
+func TestClientSyncTreeBadEntry(t *testing.T) {
+	var (
+		key       = testKey(signingKeySeed)
+		tree, url = makeTestTree("n", testNodes(nodesSeed1, 3), nil, key)
+		r         = mapResolver(tree.ToTXT("n"))
+	)
+	// Replace a node record with one of a different node.
+	for name, txt := range r {
+		if txt[:len(enrPrefix)] == enrPrefix {
+			r[name] = (&enrEntry{testNode(nodesSeed2)}).String()
+			break
+		}
+	}
+	c, _ := NewClient(Config{Resolver: r})
+	_, err := c.SyncTree(url)
+	if nerr, ok := err.(nameError); !ok || nerr.err != errHashMismatch {
+		t.Fatal("expected hash mismatch error, got", err)
+	}
+}
+
+// Tests that the client follows links between trees and serves the nodes of all
+// linked trees, keeping the nodes of trees which can't be resolved anymore.
+func TestClientLinkedTrees(t *testing.T) {
+	var (
+		key         = testKey(signingKeySeed)
+		nodes1      = testNodes(nodesSeed1, 5)
+		nodes2      = testNodes(nodesSeed2, 5)
+		tree2, url2 = makeTestTree("t2", nodes2, nil, key)
+		tree1, url1 = makeTestTree("t1", nodes1, []string{url2}, key)
+		r           = mapResolver{}
+	)
+	r.add(tree1.ToTXT("t1"))
+	r.add(tree2.ToTXT("t2"))
+
+	c, err := NewClient(Config{Resolver: r}, url1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.syncAll(); err != nil {
+		t.Fatal("sync error:", err)
+	}
+	buf := make([]*enode.Node, 20)
+	n := c.ReadRandomNodes(buf)
+	if !reflect.DeepEqual(sortByID(buf[:n]), sortByID(append(nodes1, nodes2...))) {
+		t.Fatalf("wrong nodes: have %d, want %d", n, len(nodes1)+len(nodes2))
+	}
+	if n := c.ReadRandomNodes(buf[:3]); n != 3 {
+		t.Fatalf("wrong node count: have %d, want %d", n, 3)
+	}
+	// Remove the linked tree's root, its nodes should still be served.
+	delete(r, "t2")
+	if err := c.syncAll(); err == nil {
+		t.Fatal("expected sync error for missing root")
+	}
+	if n := c.ReadRandomNodes(buf); n != len(nodes1)+len(nodes2) {
+		t.Fatalf("wrong node count after failed sync: have %d, want %d", n, len(nodes1)+len(nodes2))
+	}
+}
+
+// Tests that the random node iterator returns nodes of the synced lists once
+// they are available.
+func TestClientRandomNodes(t *testing.T) {
+	var (
+		key       = testKey(signingKeySeed)
+		nodes     = testNodes(nodesSeed1, 10)
+		tree, url = makeTestTree("n", nodes, nil, key)
+		r         = mapResolver(tree.ToTXT("n"))
+	)
+	c, _ := NewClient(Config{Resolver: r}, url)
+	defer c.Close()
+
+	it := c.RandomNodes()
+	c.Start()
+
+	known := make(map[enode.ID]bool)
+	for _, n := range nodes {
+		known[n.ID()] = true
+	}
+	for _, n := range enode.ReadNodes(it, 20) {
+		if !known[n.ID()] {
+			t.Errorf("iterator returned unknown node %v", n.ID())
+		}
+	}
+	it.Close()
+	if it.Next() {
+		t.Fatal("Next returned true after Close")
+	}
+}
+
+func makeTestTree(domain string, nodes []*enode.Node, links []string, key *ecdsa.PrivateKey) (*Tree, string) {
+	tree, err := MakeTree(1, nodes, links)
+	if err != nil {
+		panic(err)
+	}
+	url, err := tree.Sign(key, domain)
+	if err != nil {
+		panic(err)
+	}
+	return tree, url
+}
+
+// testKeys creates deterministic private keys for testing.
+func testKeys(seed int64, n int) []*ecdsa.PrivateKey {
+	keys := make([]*ecdsa.PrivateKey, n)
+	for i := range keys {
+		var enc [16]byte
+		binary.BigEndian.PutUint64(enc[:8], uint64(seed))
+		binary.BigEndian.PutUint64(enc[8:], uint64(i))
+		key, err := crypto.ToECDSA(crypto.Keccak256(enc[:]))
+		if err != nil {
+			panic(err)
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+func testKey(seed int64) *ecdsa.PrivateKey {
+	return testKeys(seed, 1)[0]
+}
+
+func testNodes(seed int64, n int) []*enode.Node {
+	keys := testKeys(seed, n)
+	nodes := make([]*enode.Node, n)
+	for i, key := range keys {
+		record := new(enr.Record)
+		record.Set(enr.IP(net.IP{10, 0, byte(i >> 8), byte(i)}))
+		record.Set(enr.TCP(30303))
+		record.SetSeq(uint64(i))
+		enode.SignV4(record, key)
+		n, err := enode.New(enode.ValidSchemes, record)
+		if err != nil {
+			panic(err)
+		}
+		nodes[i] = n
+	}
+	return nodes
+}
+
+func testNode(seed int64) *enode.Node {
+	return testNodes(seed, 1)[0]
+}
+
+// mapResolver is a stub resolver serving TXT records from a map.
+type mapResolver map[string]string
+
+func (mr mapResolver) add(m map[string]string) {
+	for k, v := range m {
+		mr[k] = v
+	}
+}
+
+func (mr mapResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if record, ok := mr[name]; ok {
+		return []string{record}, nil
+	}
+	return nil, errors.New("not found")
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package dnsdisc
+
+import (
+	"context"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+)
+
+// clientTree is a full tree being synced.
+type clientTree struct {
+	c    *Client
+	loc  *linkEntry
+	root *rootEntry
+
+	nodes []*enode.Node // nodes of the last successful sync
+	links []*linkEntry  // links of the last successful sync
+}
+
+func newClientTree(c *Client, loc *linkEntry) *clientTree {
+	return &clientTree{c: c, loc: loc}
+}
+
+// sync updates the tree from DNS, replacing the known nodes and links if all
+// entries could be retrieved. On failure, the previous nodes and links are kept.
+func (ct *clientTree) sync() error {
+	entries := make(map[string]entry)
+	if err := ct.syncAll(entries); err != nil {
+		return err
+	}
+	var (
+		nodes []*enode.Node
+		links []*linkEntry
+	)
+	for _, e := range entries {
+		switch e := e.(type) {
+		case *enrEntry:
+			nodes = append(nodes, e.node)
+		case *linkEntry:
+			links = append(links, e)
+		}
+	}
+	ct.nodes, ct.links = nodes, links
+	return nil
+}
+
+// syncAll retrieves the root and all entries of the tree, storing them in dest.
+func (ct *clientTree) syncAll(dest map[string]entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ct.c.cfg.Timeout)
+	root, err := ct.c.resolveRoot(ctx, ct.loc)
+	cancel()
+	if err != nil {
+		return err
+	}
+	ct.root = &root
+
+	if err := ct.syncSubtree(root.eroot, false, dest); err != nil {
+		return err
+	}
+	return ct.syncSubtree(root.lroot, true, dest)
+}
+
+// syncSubtree retrieves the entry with the given hash and all its descendants.
+// Link subtrees must only contain links, the others only node records.
+func (ct *clientTree) syncSubtree(hash string, link bool, dest map[string]entry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), ct.c.cfg.Timeout)
+	e, err := ct.c.resolveEntry(ctx, ct.loc.domain, hash)
+	cancel()
+	if err != nil {
+		return err
+	}
+	dest[hash] = e
+
+	switch e := e.(type) {
+	case *enrEntry:
+		if link {
+			return nameError{hash + "." + ct.loc.domain, errENRInLinkTree}
+		}
+	case *linkEntry:
+		if !link {
+			return nameError{hash + "." + ct.loc.domain, errLinkInENRTree}
+		}
+	case *branchEntry:
+		for _, child := range e.children {
+			if err := ct.syncSubtree(child, link, dest); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package enode
+
+import (
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+var errInvalidBan = errors.New("ban must apply to either a node ID or a network")
+
+// Ban is an entry of the ban list kept in the node database. A ban applies either to
+// a node ID or to all IP addresses of a network.
+type Ban struct {
+	ID      ID         // Banned node, zero if the ban applies to a network
+	Net     *net.IPNet // Banned network, nil if the ban applies to a node
+	Reason  string     // Reason given for the ban
+	Expires time.Time  // End of the ban, zero if the ban is permanent
+}
+
+// Expired reports whether the ban has ended at the given time.
+func (b *Ban) Expired(now time.Time) bool {
+	return !b.Expires.IsZero() && !now.Before(b.Expires)
+}
+
+// Matches reports whether the ban applies to a node with the given ID and IP.
+func (b *Ban) Matches(id ID, ip net.IP) bool {
+	if b.Net != nil {
+		return ip != nil && b.Net.Contains(ip)
+	}
+	return b.ID == id
+}
+
+// banRLP is the RLP representation of a ban.
+type banRLP struct {
+	ID      ID
+	IP      []byte
+	Mask    []byte
+	Reason  string
+	Expires uint64
+}
+
+// EncodeRLP implements rlp.Encoder.
+func (b *Ban) EncodeRLP(w io.Writer) error {
+	enc := banRLP{ID: b.ID, Reason: b.Reason}
+	if b.Net != nil {
+		enc.IP, enc.Mask = b.Net.IP, b.Net.Mask
+	}
+	if !b.Expires.IsZero() {
+		enc.Expires = uint64(b.Expires.Unix())
+	}
+	return rlp.Encode(w, &enc)
+}
+
+// DecodeRLP implements rlp.Decoder.
+func (b *Ban) DecodeRLP(s *rlp.Stream) error {
+	var dec banRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+	b.ID, b.Reason, b.Net, b.Expires = dec.ID, dec.Reason, nil, time.Time{}
+	if len(dec.IP) > 0 {
+		b.Net = &net.IPNet{IP: dec.IP, Mask: dec.Mask}
+	}
+	if dec.Expires > 0 {
+		b.Expires = time.Unix(int64(dec.Expires), 0)
+	}
+	return nil
+}
@@ -42,6 +42,17 @@ const (
 	dbLocalPrefix  = "local:"
 	dbDiscoverRoot = "v4"
 
+	// Bans are keyed by node ID or network, the full keys are "ban:id:<ID>" and
+	// "ban:net:<CIDR>". Use banKey to create those keys.
+	dbBanPrefix    = "ban:"
+	dbBanIDPrefix  = dbBanPrefix + "id:"
+	dbBanNetPrefix = dbBanPrefix + "net:"
+
+	// Quality metrics are keyed by node ID only, the full key is "quality:<ID>".
+	// They're kept apart from the node entries, so they outlive the discovery data
+	// removed once a node stops answering pings. Use qualityKey to create those keys.
+	dbQualityPrefix = "quality:"
+
 	// These fields are stored per ID and IP, the full key is "n:<ID>:v4:<IP>:findfail".
 	// Use nodeItemKey to create those keys.
 	dbNodeFindFails = "findfail"
@@ -55,9 +66,10 @@ const (
 )
 
 const (
-	dbNodeExpiration = 24 * time.Hour // Time after which an unseen node should be dropped.
-	dbCleanupCycle   = time.Hour      // Time period for running the expiration task.
-	dbVersion        = 9
+	dbNodeExpiration    = 24 * time.Hour      // Time after which an unseen node should be dropped.
+	dbQualityExpiration = 30 * 24 * time.Hour // Time after which unrefreshed quality metrics should be dropped.
+	dbCleanupCycle      = time.Hour           // Time period for running the expiration task.
+	dbVersion           = 9
 )
 
 var zeroIP = make(net.IP, 16)
@@ -180,6 +192,11 @@ func localItemKey(id ID, field string) []byte {
 	return key
 }
 
+// qualityKey returns the key of the quality metrics of a node.
+func qualityKey(id ID) []byte {
+	return append([]byte(dbQualityPrefix), id[:]...)
+}
+
 // fetchInt64 retrieves an integer associated with a particular key.
 func (db *DB) fetchInt64(key []byte) int64 {
 	blob, err := db.lvl.Get(key, nil)
@@ -268,6 +285,7 @@ func (db *DB) Resolve(n *Node) *Node {
 // DeleteNode deletes all information associated with a node.
 func (db *DB) DeleteNode(id ID) {
 	deleteRange(db.lvl, nodeKey(id))
+	db.lvl.Delete(qualityKey(id), nil)
 }
 
 func deleteRange(db *leveldb.DB, prefix []byte) {
@@ -300,6 +318,7 @@ func (db *DB) expirer() {
 		select {
 		case <-tick.C:
 			db.expireNodes()
+			db.expireQuality()
 		case <-db.quit:
 			return
 		}
@@ -345,6 +364,21 @@ func (db *DB) expireNodes() {
 	}
 }
 
+// expireQuality deletes the quality metrics of all nodes that have not been
+// updated for some time.
+func (db *DB) expireQuality() {
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbQualityPrefix)), nil)
+	defer it.Release()
+
+	threshold := time.Now().Add(-dbQualityExpiration)
+	for it.Next() {
+		var q Quality
+		if err := rlp.DecodeBytes(it.Value(), &q); err != nil || q.Updated.Before(threshold) {
+			db.lvl.Delete(it.Key(), nil)
+		}
+	}
+}
+
 // LastPingReceived retrieves the time of the last ping packet received from
 // a remote node.
 func (db *DB) LastPingReceived(id ID, ip net.IP) time.Time {
@@ -378,6 +412,118 @@ func (db *DB) UpdateFindFails(id ID, ip net.IP, fails int) error {
 	return db.storeInt64(nodeItemKey(id, ip, dbNodeFindFails), int64(fails))
 }
 
+// NodeQuality retrieves the persisted quality metrics of a remote node, with
+// the failure counters decayed since they were stored.
+func (db *DB) NodeQuality(id ID) Quality {
+	var q Quality
+	blob, err := db.lvl.Get(qualityKey(id), nil)
+	if err != nil {
+		return q
+	}
+	if err := rlp.DecodeBytes(blob, &q); err != nil {
+		return Quality{}
+	}
+	return q.decay(time.Now())
+}
+
+// UpdateNodeQuality stores the quality metrics of a remote node.
+func (db *DB) UpdateNodeQuality(id ID, q Quality) error {
+	blob, err := rlp.EncodeToBytes(&q)
+	if err != nil {
+		return err
+	}
+	return db.lvl.Put(qualityKey(id), blob, nil)
+}
+
+// banKey returns the database key of a ban.
+func banKey(b *Ban) ([]byte, error) {
+	switch {
+	case b.Net != nil && b.ID == (ID{}):
+		return []byte(dbBanNetPrefix + b.Net.String()), nil
+	case b.Net == nil && b.ID != (ID{}):
+		return append([]byte(dbBanIDPrefix), b.ID[:]...), nil
+	default:
+		return nil, errInvalidBan
+	}
+}
+
+// AddBan stores a ban, replacing any existing ban of the same node or network.
+func (db *DB) AddBan(b Ban) error {
+	key, err := banKey(&b)
+	if err != nil {
+		return err
+	}
+	blob, err := rlp.EncodeToBytes(&b)
+	if err != nil {
+		return err
+	}
+	return db.lvl.Put(key, blob, nil)
+}
+
+// RemoveBan deletes the ban of the node or network given by b. It reports whether
+// the ban existed.
+func (db *DB) RemoveBan(b Ban) (bool, error) {
+	key, err := banKey(&b)
+	if err != nil {
+		return false, err
+	}
+	if ok, err := db.lvl.Has(key, nil); !ok || err != nil {
+		return false, err
+	}
+	return true, db.lvl.Delete(key, nil)
+}
+
+// Bans returns all bans which haven't expired yet. Expired bans are deleted.
+func (db *DB) Bans() []Ban {
+	var (
+		bans []Ban
+		now  = time.Now()
+		it   = db.lvl.NewIterator(util.BytesPrefix([]byte(dbBanPrefix)), nil)
+	)
+	defer it.Release()
+	for it.Next() {
+		if b := db.decodeBan(it.Key(), it.Value(), now); b != nil {
+			bans = append(bans, *b)
+		}
+	}
+	return bans
+}
+
+// CheckBan returns the ban applying to a node with the given ID and IP, or nil if the
+// node isn't banned. Passing a zero ID checks network bans only.
+func (db *DB) CheckBan(id ID, ip net.IP) *Ban {
+	now := time.Now()
+	if id != (ID{}) {
+		key := append([]byte(dbBanIDPrefix), id[:]...)
+		if blob, err := db.lvl.Get(key, nil); err == nil {
+			if b := db.decodeBan(key, blob, now); b != nil {
+				return b
+			}
+		}
+	}
+	if ip == nil {
+		return nil
+	}
+	it := db.lvl.NewIterator(util.BytesPrefix([]byte(dbBanNetPrefix)), nil)
+	defer it.Release()
+	for it.Next() {
+		if b := db.decodeBan(it.Key(), it.Value(), now); b != nil && b.Matches(id, ip) {
+			return b
+		}
+	}
+	return nil
+}
+
+// decodeBan decodes a stored ban. Invalid and expired bans are deleted.
+func (db *DB) decodeBan(key, blob []byte, now time.Time) *Ban {
+	b := new(Ban)
+	if err := rlp.DecodeBytes(blob, b); err != nil || b.Expired(now) {
+		db.lvl.Delete(key, nil)
+		return nil
+	}
+	return b
+}
+
 // LocalSeq retrieves the local record sequence counter.
 func (db *DB) localSeq(id ID) uint64 {
 	return db.fetchUint64(localItemKey(id, dbLocalSeq))
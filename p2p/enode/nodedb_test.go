@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net"
 	"os"
 	"path/filepath"
@@ -154,6 +155,18 @@ func TestDBFetchStore(t *testing.T) {
 	if stored := db.FindFails(node.ID(), node.IP()); stored != num {
 		t.Errorf("find-node fails: value mismatch: have %v, want %v", stored, num)
 	}
+	// Check fetch/store operations on a node quality object
+	quality := Quality{Latency: 250 * time.Millisecond, Throughput: 12.5, Timeouts: 2, Invalid: 1, Updated: inst.Truncate(time.Second)}
+	if stored := db.NodeQuality(node.ID()); stored != (Quality{}) {
+		t.Errorf("quality: non-existing object: %v", stored)
+	}
+	if err := db.UpdateNodeQuality(node.ID(), quality); err != nil {
+		t.Errorf("quality: failed to update: %v", err)
+	}
+	if stored := db.NodeQuality(node.ID()); stored.Latency != quality.Latency || stored.Throughput != quality.Throughput || !stored.Updated.Equal(quality.Updated) ||
+		math.Abs(stored.Timeouts-quality.Timeouts) > 0.01 || math.Abs(stored.Invalid-quality.Invalid) > 0.01 {
+		t.Errorf("quality: value mismatch: have %v, want %v", stored, quality)
+	}
 	// Check fetch/store operations on an actual node object
 	if stored := db.Node(node.ID()); stored != nil {
 		t.Errorf("node: non-existing object: %v", stored)
@@ -462,3 +475,103 @@ func TestDBExpiration(t *testing.T) {
 		}
 	}
 }
+
+// This test checks that stale quality metrics are removed by expiration,
+// regardless of the discovery state of the node.
+func TestDBQualityExpiration(t *testing.T) {
+	db, _ := OpenDB("")
+	defer db.Close()
+
+	var (
+		fresh  = ID{0x01}
+		stale  = ID{0x02}
+		unseen = ID{0x03}
+	)
+	db.UpdateNodeQuality(fresh, Quality{Throughput: 1, Updated: time.Now()})
+	db.UpdateNodeQuality(stale, Quality{Throughput: 1, Updated: time.Now().Add(-dbQualityExpiration - time.Minute)})
+
+	// The quality metrics of a node outlive its discovery data
+	db.UpdateNodeQuality(unseen, Quality{Throughput: 1, Updated: time.Now()})
+	db.UpdateLastPongReceived(unseen, net.IP{127, 0, 0, 1}, time.Now().Add(-dbNodeExpiration-time.Minute))
+
+	db.expireNodes()
+	db.expireQuality()
+
+	if q := db.NodeQuality(fresh); q.Throughput != 1 {
+		t.Errorf("fresh quality metrics removed")
+	}
+	if pong := db.LastPongReceived(unseen, net.IP{127, 0, 0, 1}); pong.Unix() != 0 {
+		t.Errorf("stale pong present after expiration: %v", pong)
+	}
+	if q := db.NodeQuality(unseen); q.Throughput != 1 {
+		t.Errorf("quality metrics removed with stale pong")
+	}
+	if q := db.NodeQuality(stale); q != (Quality{}) {
+		t.Errorf("stale quality metrics present after expiration: %v", q)
+	}
+}
+
+// This test checks that the failure counters of the quality metrics decay over
+// time, so past failures are forgiven.
+func TestDBQualityDecay(t *testing.T) {
+	db, _ := OpenDB("")
+	defer db.Close()
+
+	id := ID{0x01}
+	db.UpdateNodeQuality(id, Quality{Throughput: 1, Timeouts: 4, Invalid: 2, Updated: time.Now().Add(-2 * qualityHalfLife)})
+
+	q := db.NodeQuality(id)
+	if math.Abs(q.Timeouts-1) > 0.01 || math.Abs(q.Invalid-0.5) > 0.01 {
+		t.Errorf("failure counters mismatch: have %v/%v, want %v/%v", q.Timeouts, q.Invalid, 1, 0.5)
+	}
+	if q.Throughput != 1 {
+		t.Errorf("throughput mismatch: have %v, want %v", q.Throughput, 1)
+	}
+}
+
+func TestDBBans(t *testing.T) {
+	db, _ := OpenDB("")
+	defer db.Close()
+
+	var (
+		id         = ID{0x01}
+		_, net1, _ = net.ParseCIDR("10.1.0.0/16")
+		ip1        = net.IP{10, 1, 2, 3}
+		ip2        = net.IP{10, 2, 2, 3}
+	)
+	if err := db.AddBan(Ban{}); err != errInvalidBan {
+		t.Errorf("wrong error for empty ban: %v", err)
+	}
+	if err := db.AddBan(Ban{ID: id, Net: net1}); err != errInvalidBan {
+		t.Errorf("wrong error for ambiguous ban: %v", err)
+	}
+	db.AddBan(Ban{ID: id, Reason: "spam"})
+	db.AddBan(Ban{Net: net1, Expires: time.Now().Add(time.Hour)})
+	db.AddBan(Ban{ID: ID{0x02}, Expires: time.Now().Add(-time.Second)})
+
+	if b := db.CheckBan(id, ip2); b == nil || b.Reason != "spam" {
+		t.Errorf("node ban not found: %v", b)
+	}
+	if b := db.CheckBan(ID{0x03}, ip1); b == nil || b.Net.String() != net1.String() {
+		t.Errorf("network ban not found: %v", b)
+	}
+	if b := db.CheckBan(ID{0x03}, ip2); b != nil {
+		t.Errorf("unexpected ban for node outside of banned network: %v", b)
+	}
+	if b := db.CheckBan(ID{0x02}, nil); b != nil {
+		t.Errorf("expired ban found: %v", b)
+	}
+	if bans := db.Bans(); len(bans) != 2 {
+		t.Errorf("wrong number of bans: got %d, want 2", len(bans))
+	}
+
+	if ok, err := db.RemoveBan(Ban{Net: net1}); !ok || err != nil {
+		t.Errorf("network ban not removed: %t, %v", ok, err)
+	}
+	if ok, _ := db.RemoveBan(Ban{Net: net1}); ok {
+		t.Errorf("removed ban removed again")
+	}
+	if b := db.CheckBan(ID{0x03}, ip1); b != nil {
+		t.Errorf("removed ban found: %v", b)
+	}
+}
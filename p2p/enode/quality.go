@@ -0,0 +1,94 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package enode
+
+import (
+	"io"
+	"math"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// qualityHalfLife is the time it takes for the failure counters of a node to
+// halve, so that past failures are gradually forgiven.
+const qualityHalfLife = 24 * time.Hour
+
+// Quality contains the service quality metrics measured on connections to a
+// remote node by the protocols running on top of them. The metrics are kept
+// in the node database across sessions, so that well performing nodes can be
+// preferred when dialing and evicting peers.
+type Quality struct {
+	Latency    time.Duration // Smoothed round trip time of requests
+	Throughput float64       // Smoothed number of data items delivered per second
+	Timeouts   float64       // Decaying number of requests that timed out
+	Invalid    float64       // Decaying number of invalid responses delivered
+	Updated    time.Time     // Time the metrics were last updated
+}
+
+// qualityRLP is the RLP representation of the quality metrics.
+type qualityRLP struct {
+	Latency    uint64
+	Throughput uint64
+	Timeouts   uint64
+	Invalid    uint64
+	Updated    uint64
+}
+
+// EncodeRLP implements rlp.Encoder.
+func (q *Quality) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, &qualityRLP{
+		Latency:    uint64(q.Latency),
+		Throughput: math.Float64bits(q.Throughput),
+		Timeouts:   math.Float64bits(q.Timeouts),
+		Invalid:    math.Float64bits(q.Invalid),
+		Updated:    uint64(q.Updated.Unix()),
+	})
+}
+
+// DecodeRLP implements rlp.Decoder.
+func (q *Quality) DecodeRLP(s *rlp.Stream) error {
+	var dec qualityRLP
+	if err := s.Decode(&dec); err != nil {
+		return err
+	}
+	q.Latency = time.Duration(dec.Latency)
+	q.Throughput = math.Float64frombits(dec.Throughput)
+	q.Timeouts = math.Float64frombits(dec.Timeouts)
+	q.Invalid = math.Float64frombits(dec.Invalid)
+	q.Updated = time.Unix(int64(dec.Updated), 0)
+	return nil
+}
+
+// decay returns the metrics with the failure counters reduced according to the
+// time passed since the metrics were last updated.
+func (q Quality) decay(now time.Time) Quality {
+	if q.Updated.IsZero() || !now.After(q.Updated) {
+		return q
+	}
+	factor := math.Exp2(-float64(now.Sub(q.Updated)) / float64(qualityHalfLife))
+	q.Timeouts *= factor
+	q.Invalid *= factor
+	return q
+}
+
+// Score rates the measured quality of a node. The score grows with the delivery
+// throughput and is reduced by timed out requests, while every invalid response
+// results in a penalty. Nodes without any measurements score zero.
+func (q Quality) Score() float64 {
+	return q.Throughput/(1+q.Timeouts) - q.Invalid
+}
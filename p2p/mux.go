@@ -0,0 +1,356 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Stream multiplexing splits every message sent on an RLPx connection into
+// chunks of at most muxChunkSize bytes. The chunks of messages sent on
+// different streams are interleaved, so a large message of one subprotocol
+// doesn't hold up the messages of the others. Each subprotocol is sent on its
+// own stream, the base protocol uses stream zero.
+//
+// Chunks are sent as RLPx frames with the header data defined by the RLPx spec:
+// the first chunk of a message carries [stream, context-id, total-size] and
+// the following chunks carry [stream, context-id]. The context-id is a counter
+// of the messages sent on the stream.
+//
+// Multiplexing is advertised in the "mux" ENR entry and offered in the
+// protocol handshake. The connection switches to chunked framing after the
+// handshake if both sides have offered it and uses regular framing otherwise.
+const (
+	muxVersion     = 1
+	muxChunkSize   = 16 * 1024
+	muxMaxStreams  = 64
+	muxMaxBuffered = 2 * int(maxUint24) // limit for partially received messages of all streams
+
+	baseStream = 0 // stream of the base protocol
+)
+
+var (
+	errMuxStream      = newPeerError(errInvalidMsg, "invalid stream")
+	errMuxBuffered    = newPeerError(errInvalidMsg, "too much chunked data buffered")
+	errMuxUnexpected  = errors.New("unexpected chunk")
+	errMuxMessageSize = errors.New("chunked message size mismatch")
+)
+
+// muxEntry is the "mux" ENR entry which advertises support for
+// stream multiplexing.
+type muxEntry struct {
+	Version uint
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// ENRKey implements enr.Entry.
+func (e muxEntry) ENRKey() string {
+	return "mux"
+}
+
+// nodeMuxVersion returns the multiplexing version advertised in the
+// record of n, or zero if the node doesn't support multiplexing.
+func nodeMuxVersion(n *enode.Node) uint {
+	var e muxEntry
+	if n == nil || n.Load(&e) != nil {
+		return 0
+	}
+	return e.Version
+}
+
+// muxOffer is appended to the protocol handshake to offer stream multiplexing.
+type muxOffer struct {
+	Key     string // always "mux"
+	Version uint
+
+	// Ignore additional fields (for forward compatibility).
+	Rest []rlp.RawValue `rlp:"tail"`
+}
+
+// withMux returns a copy of the handshake which offers stream multiplexing.
+func (h *protoHandshake) withMux() *protoHandshake {
+	offer, _ := rlp.EncodeToBytes(&muxOffer{Key: "mux", Version: muxVersion})
+	cpy := *h
+	cpy.Rest = append(append([]rlp.RawValue{}, h.Rest...), offer)
+	return &cpy
+}
+
+// muxVersion returns the multiplexing version offered in the handshake,
+// or zero if multiplexing isn't offered.
+func (h *protoHandshake) muxVersion() uint {
+	for _, raw := range h.Rest {
+		var offer muxOffer
+		if rlp.DecodeBytes(raw, &offer) == nil && offer.Key == "mux" {
+			return offer.Version
+		}
+	}
+	return 0
+}
+
+// multiplexer is implemented by transports which can send the messages of
+// each subprotocol on a separate stream.
+type multiplexer interface {
+	// stream returns a writer for the given stream. It returns nil
+	// if the connection isn't multiplexed.
+	stream(id uint64) MsgWriter
+}
+
+// muxHeader is the frame header data of a chunk.
+type muxHeader struct {
+	Stream  uint64
+	Context uint16
+	Size    []uint32 `rlp:"tail"` // total size, first chunk only
+}
+
+// muxWrite is a message queued for sending.
+type muxWrite struct {
+	ctx     uint16
+	content []byte
+	sent    int
+	done    chan error
+}
+
+// muxRead is a partially received message.
+type muxRead struct {
+	ctx     uint16
+	size    int
+	content []byte
+}
+
+// muxer implements chunked framing on top of rlpx. Writes are queued per
+// stream. Writers take turns at sending the next chunk of any queued message,
+// picking the streams round-robin.
+//
+// The remote side may only send on the base stream and the streams opened for
+// the negotiated subprotocols. The connection fails if the partially received
+// messages of all streams exceed muxMaxBuffered bytes.
+type muxer struct {
+	t *rlpx
+
+	mu      sync.Mutex
+	queue   [muxMaxStreams][]*muxWrite
+	wctx    [muxMaxStreams]uint16
+	next    int    // next stream to send a chunk from
+	werr    error  // set when a write has failed
+	streams uint64 // number of open streams
+
+	unread   [muxMaxStreams]*muxRead
+	buffered int // total size of unread content
+}
+
+func newMuxer(t *rlpx) *muxer {
+	return &muxer{t: t, streams: baseStream + 1}
+}
+
+// open returns the writer of the given stream and allows the remote
+// side to send on all streams up to it.
+func (m *muxer) open(id uint64) *muxStream {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < muxMaxStreams && id >= m.streams {
+		m.streams = id + 1
+	}
+	return &muxStream{m, id}
+}
+
+// muxStream is the writer of a single stream.
+type muxStream struct {
+	m  *muxer
+	id uint64
+}
+
+func (s *muxStream) WriteMsg(msg Msg) error {
+	return s.m.writeMsg(s.id, msg)
+}
+
+// writeMsg queues msg on the given stream and sends chunks until it is written.
+func (m *muxer) writeMsg(stream uint64, msg Msg) error {
+	if stream >= muxMaxStreams {
+		return errMuxStream
+	}
+	content, err := m.t.rw.encodeMsg(msg)
+	if err != nil {
+		return err
+	}
+	if uint32(len(content)) > maxUint24 {
+		return errors.New("message size overflows uint24")
+	}
+	w := &muxWrite{content: content, done: make(chan error, 1)}
+	m.mu.Lock()
+	if m.werr != nil {
+		m.mu.Unlock()
+		return m.werr
+	}
+	w.ctx = m.wctx[stream]
+	m.wctx[stream]++
+	m.queue[stream] = append(m.queue[stream], w)
+	m.mu.Unlock()
+
+	for {
+		select {
+		case err := <-w.done:
+			return err
+		default:
+		}
+		m.t.wmu.Lock()
+		m.sendChunk()
+		m.t.wmu.Unlock()
+	}
+}
+
+// sendChunk sends the next chunk. It must be called with the rlpx write lock held.
+func (m *muxer) sendChunk() {
+	m.mu.Lock()
+	stream, w := m.nextWrite()
+	m.mu.Unlock()
+	if w == nil {
+		return
+	}
+
+	m.t.fd.SetWriteDeadline(time.Now().Add(frameWriteTimeout))
+	n, err := m.writeChunk(stream, w)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.fail(err)
+		return
+	}
+	if w.sent += n; w.sent == len(w.content) {
+		m.queue[stream] = m.queue[stream][1:]
+		w.done <- nil
+	}
+}
+
+// nextWrite returns the first queued message of the next stream
+// that has one, advancing the round-robin position.
+func (m *muxer) nextWrite() (uint64, *muxWrite) {
+	for i := 0; i < muxMaxStreams; i++ {
+		s := (m.next + i) % muxMaxStreams
+		if len(m.queue[s]) > 0 {
+			m.next = (s + 1) % muxMaxStreams
+			return uint64(s), m.queue[s][0]
+		}
+	}
+	return 0, nil
+}
+
+// writeChunk sends the next chunk of w and returns its size.
+func (m *muxer) writeChunk(stream uint64, w *muxWrite) (int, error) {
+	h := muxHeader{Stream: stream, Context: w.ctx}
+	if w.sent == 0 {
+		h.Size = []uint32{uint32(len(w.content))}
+	}
+	header, _ := rlp.EncodeToBytes(&h)
+	chunk := w.content[w.sent:]
+	if len(chunk) > muxChunkSize {
+		chunk = chunk[:muxChunkSize]
+	}
+	return len(chunk), m.t.rw.writeFrame(header, chunk)
+}
+
+// fail aborts all queued writes.
+func (m *muxer) fail(err error) {
+	m.werr = err
+	for s := range m.queue {
+		for _, w := range m.queue[s] {
+			w.done <- err
+		}
+		m.queue[s] = nil
+	}
+}
+
+// sendDisc sends a disconnect message on the base stream. It must be called
+// with the rlpx write lock held.
+func (m *muxer) sendDisc(reason DiscReason) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if q := m.queue[baseStream]; len(q) > 0 && q[0].sent > 0 {
+		return // can't start another message on the stream
+	}
+	size, r, err := rlp.EncodeToReader([]interface{}{reason})
+	if err != nil {
+		return
+	}
+	content, err := m.t.rw.encodeMsg(Msg{Code: discMsg, Size: uint32(size), Payload: r})
+	if err != nil {
+		return
+	}
+	w := &muxWrite{ctx: m.wctx[baseStream], content: content}
+	m.wctx[baseStream]++
+	for w.sent < len(w.content) {
+		n, err := m.writeChunk(baseStream, w)
+		if err != nil {
+			return
+		}
+		w.sent += n
+	}
+}
+
+// readMsg reads chunks until a message is complete. It must be called
+// with the rlpx read lock held.
+func (m *muxer) readMsg() (Msg, error) {
+	for {
+		m.t.fd.SetReadDeadline(time.Now().Add(frameReadTimeout))
+		header, chunk, err := m.t.rw.readFrame()
+		if err != nil {
+			return Msg{}, err
+		}
+		var h muxHeader
+		if err := rlp.NewStream(bytes.NewReader(header), uint64(len(header))).Decode(&h); err != nil {
+			return Msg{}, fmt.Errorf("invalid chunk header: %v", err)
+		}
+		m.mu.Lock()
+		streams := m.streams
+		m.mu.Unlock()
+		if h.Stream >= streams {
+			return Msg{}, errMuxStream
+		}
+		r := m.unread[h.Stream]
+		switch {
+		case len(h.Size) > 0 && r == nil:
+			if h.Size[0] == 0 || h.Size[0] > maxUint24 {
+				return Msg{}, errMuxMessageSize
+			}
+			r = &muxRead{ctx: h.Context, size: int(h.Size[0])}
+			m.unread[h.Stream] = r
+		case len(h.Size) > 0 || r == nil || r.ctx != h.Context:
+			return Msg{}, errMuxUnexpected
+		}
+		if len(r.content)+len(chunk) > r.size {
+			return Msg{}, errMuxMessageSize
+		}
+		if m.buffered += len(chunk); m.buffered > muxMaxBuffered {
+			return Msg{}, errMuxBuffered
+		}
+		r.content = append(r.content, chunk...)
+		if len(r.content) == r.size {
+			m.unread[h.Stream] = nil
+			m.buffered -= r.size
+			return m.t.rw.decodeMsg(r.content)
+		}
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/enr"
+)
+
+// newMuxTestPair runs the handshakes between two rlpx transports,
+// offering multiplexing from the sides which have it enabled.
+func newMuxTestPair(t *testing.T, mux0, mux1 bool) (*rlpx, *rlpx) {
+	var (
+		prv0, _  = crypto.GenerateKey()
+		prv1, _  = crypto.GenerateKey()
+		fd0, fd1 = net.Pipe()
+		c0, c1   = newRLPX(fd0).(*rlpx), newRLPX(fd1).(*rlpx)
+		hs0      = &protoHandshake{Version: baseProtocolVersion, ID: crypto.FromECDSAPub(&prv0.PublicKey)[1:]}
+		hs1      = &protoHandshake{Version: baseProtocolVersion, ID: crypto.FromECDSAPub(&prv1.PublicKey)[1:]}
+	)
+	if mux0 {
+		hs0 = hs0.withMux()
+	}
+	if mux1 {
+		hs1 = hs1.withMux()
+	}
+	errc := make(chan error, 1)
+	go func() {
+		if _, err := c1.doEncHandshake(prv1, nil); err != nil {
+			errc <- err
+			return
+		}
+		_, err := c1.doProtoHandshake(hs1)
+		errc <- err
+	}()
+	if _, err := c0.doEncHandshake(prv0, &prv1.PublicKey); err != nil {
+		t.Fatal("dial side handshake failed:", err)
+	}
+	if _, err := c0.doProtoHandshake(hs0); err != nil {
+		t.Fatal("dial side handshake failed:", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatal("listen side handshake failed:", err)
+	}
+	fd0.SetDeadline(time.Time{})
+	fd1.SetDeadline(time.Time{})
+	return c0, c1
+}
+
+func TestMuxNegotiation(t *testing.T) {
+	tests := []struct {
+		mux0, mux1 bool
+		want       bool
+	}{
+		{false, false, false},
+		{true, false, false},
+		{false, true, false},
+		{true, true, true},
+	}
+	for _, test := range tests {
+		c0, c1 := newMuxTestPair(t, test.mux0, test.mux1)
+		if (c0.mux != nil) != test.want || (c1.mux != nil) != test.want {
+			t.Errorf("offers %t/%t: multiplexed %t/%t, want %t", test.mux0, test.mux1, c0.mux != nil, c1.mux != nil, test.want)
+		}
+		if (c0.stream(1) != nil) != test.want {
+			t.Errorf("offers %t/%t: wrong stream writer", test.mux0, test.mux1)
+		}
+		// Check that both sides use the same framing.
+		go SendItems(c0, 0x10, "foo")
+		if err := ExpectMsg(c1, 0x10, []string{"foo"}); err != nil {
+			t.Errorf("offers %t/%t: %v", test.mux0, test.mux1, err)
+		}
+		go c0.close(DiscQuitting)
+		if err := ExpectMsg(c1, discMsg, []DiscReason{DiscQuitting}); err != nil {
+			t.Errorf("offers %t/%t: error receiving disconnect: %v", test.mux0, test.mux1, err)
+		}
+		c1.close(nil)
+	}
+}
+
+func TestMuxLargeMessage(t *testing.T) {
+	c0, c1 := newMuxTestPair(t, true, true)
+	defer c1.close(nil)
+
+	c1.stream(1)
+	payload := make([]byte, 5*muxChunkSize+100)
+	rand.Read(payload)
+	go c0.stream(1).WriteMsg(Msg{Code: 0x11, Size: uint32(len(payload)), Payload: bytes.NewReader(payload)})
+	msg, err := c1.ReadMsg()
+	if err != nil {
+		t.Fatal("read error:", err)
+	}
+	content, _ := ioutil.ReadAll(msg.Payload)
+	if msg.Code != 0x11 || !bytes.Equal(content, payload) {
+		t.Fatalf("wrong message received: code %d, %d bytes", msg.Code, len(content))
+	}
+}
+
+// This test checks that a small message isn't held up by a large
+// message sent on another stream.
+func TestMuxInterleave(t *testing.T) {
+	c0, c1 := newMuxTestPair(t, true, true)
+	defer c1.close(nil)
+
+	// The payload is random because it would be compressed to a single chunk otherwise.
+	c1.stream(2)
+	large := make([]byte, 20*muxChunkSize)
+	rand.Read(large)
+	go c0.stream(1).WriteMsg(Msg{Code: 0x11, Size: uint32(len(large)), Payload: bytes.NewReader(large)})
+	waitQueued(c0.mux, 1)
+	go SendItems(c0.stream(2), 0x20, "small")
+	waitQueued(c0.mux, 2)
+
+	if err := ExpectMsg(c1, 0x20, []string{"small"}); err != nil {
+		t.Fatal("small message:", err)
+	}
+	msg, err := c1.ReadMsg()
+	if err != nil {
+		t.Fatal("large message:", err)
+	}
+	if msg.Code != 0x11 || msg.Size != uint32(len(large)) {
+		t.Fatalf("wrong large message: code %d, size %d", msg.Code, msg.Size)
+	}
+}
+
+// waitQueued waits until a write is queued on the given stream.
+func waitQueued(m *muxer, stream int) {
+	for {
+		m.mu.Lock()
+		n := len(m.queue[stream])
+		m.mu.Unlock()
+		if n > 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMuxReadErrors(t *testing.T) {
+	c0, c1 := newMuxTestPair(t, true, true)
+	defer c1.close(nil)
+	c1.stream(1)
+
+	// A continuation chunk without a preceding first chunk is rejected.
+	go c0.rw.writeFrame([]byte{0xC2, 0x01, 0x80}, []byte{0x10, 0x80})
+	if _, err := c1.ReadMsg(); err != errMuxUnexpected {
+		t.Fatalf("wrong error: got %v, want %v", err, errMuxUnexpected)
+	}
+}
+
+func TestMuxReadLimits(t *testing.T) {
+	c0, c1 := newMuxTestPair(t, true, true)
+	defer c1.close(nil)
+	c1.stream(1)
+	chunk := make([]byte, 16)
+
+	// Chunks on streams which weren't opened are rejected.
+	go c0.rw.writeFrame([]byte{0xC3, 0x02, 0x80, 0x64}, chunk)
+	if _, err := c1.ReadMsg(); err != errMuxStream {
+		t.Fatalf("wrong error for unopened stream: got %v, want %v", err, errMuxStream)
+	}
+	// Chunks are rejected when the buffer limit is reached.
+	c1.mux.buffered = muxMaxBuffered - len(chunk) + 1
+	go c0.rw.writeFrame([]byte{0xC3, 0x01, 0x80, 0x64}, chunk)
+	if _, err := c1.ReadMsg(); err != errMuxBuffered {
+		t.Fatalf("wrong error for full buffer: got %v, want %v", err, errMuxBuffered)
+	}
+}
+
+func TestNodeMuxVersion(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	var r enr.Record
+	if v := nodeMuxVersion(enode.SignNull(&r, enode.PubkeyToIDV4(&key.PublicKey))); v != 0 {
+		t.Errorf("wrong version for node without mux entry: %d", v)
+	}
+	r.Set(muxEntry{Version: muxVersion})
+	if v := nodeMuxVersion(enode.SignNull(&r, enode.PubkeyToIDV4(&key.PublicKey))); v != muxVersion {
+		t.Errorf("wrong version for node with mux entry: %d", v)
+	}
+}
+
+func TestServerMultiplex(t *testing.T) {
+	tests := []struct{ mux0, mux1, want bool }{
+		{true, true, true},
+		{true, false, false},
+		{false, true, false},
+	}
+	for _, test := range tests {
+		if got := runMuxServers(t, test.mux0, test.mux1); got != test.want {
+			t.Errorf("multiplex %t/%t: got multiplexed %t, want %t", test.mux0, test.mux1, got, test.want)
+		}
+	}
+}
+
+// runMuxServers connects two servers running a protocol which exchanges
+// a message. It returns whether the connection was multiplexed.
+func runMuxServers(t *testing.T, mux0, mux1 bool) bool {
+	result := make(chan bool, 2)
+	proto := Protocol{
+		Name:    "mux",
+		Version: 1,
+		Length:  1,
+		Run: func(p *Peer, rw MsgReadWriter) error {
+			go SendItems(rw, 0, "hello")
+			if err := ExpectMsg(rw, 0, []string{"hello"}); err != nil {
+				t.Error("protocol error:", err)
+			}
+			result <- p.rw.transport.(*rlpx).mux != nil
+			<-p.closed
+			return nil
+		},
+	}
+	newServer := func(mux bool) *Server {
+		srv := &Server{Config: Config{
+			PrivateKey:  newkey(),
+			MaxPeers:    1,
+			ListenAddr:  "127.0.0.1:0",
+			NoDiscovery: true,
+			Protocols:   []Protocol{proto},
+			Multiplex:   mux,
+		}}
+		if err := srv.Start(); err != nil {
+			t.Fatal("can't start server:", err)
+		}
+		return srv
+	}
+	srv0, srv1 := newServer(mux0), newServer(mux1)
+	defer srv0.Stop()
+	defer srv1.Stop()
+
+	srv1.AddPeer(srv0.Self())
+	var muxed [2]bool
+	for i := range muxed {
+		select {
+		case muxed[i] = <-result:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout waiting for protocol")
+		}
+	}
+	if muxed[0] != muxed[1] {
+		t.Errorf("sides disagree about multiplexing")
+	}
+	return muxed[0]
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+)
+
+// Subnet sizes used for the per-subnet peer limit.
+const (
+	peerSubnetIPv4 = 24
+	peerSubnetIPv6 = 64
+)
+
+// netLimit enforces the diversity of peer IP addresses. It limits the number of
+// peers in each IPv4 /24 and IPv6 /64 subnet, and the number of peers from each
+// configured network group.
+type netLimit struct {
+	subnetLimit uint
+	groups      []*netutil.Netlist
+	groupLimit  uint
+}
+
+// newNetLimit creates the limit configured in cfg. It returns nil if there is no limit.
+func newNetLimit(cfg *Config) *netLimit {
+	l := &netLimit{}
+	if cfg.MaxPeersPerSubnet > 0 {
+		l.subnetLimit = uint(cfg.MaxPeersPerSubnet)
+	}
+	if cfg.MaxPeersPerNetGroup > 0 && len(cfg.PeerNetGroups) > 0 {
+		l.groups = cfg.PeerNetGroups
+		l.groupLimit = uint(cfg.MaxPeersPerNetGroup)
+	}
+	if l.subnetLimit == 0 && l.groupLimit == 0 {
+		return nil
+	}
+	return l
+}
+
+// allow reports whether a peer with the given IP can be added to the peer set
+// without exceeding the limits. The IPs of pending connections count towards the
+// limits like connected peers. Calling allow on a nil limit returns true.
+func (l *netLimit) allow(peers map[enode.ID]*Peer, pending []net.IP, ip net.IP) bool {
+	if l == nil || ip == nil {
+		return true
+	}
+	ips := make([]net.IP, 0, len(peers)+len(pending))
+	for _, p := range peers {
+		if pip := p.Node().IP(); pip != nil {
+			ips = append(ips, pip)
+		}
+	}
+	for _, pip := range pending {
+		if pip != nil {
+			ips = append(ips, pip)
+		}
+	}
+	if l.subnetLimit > 0 {
+		var (
+			ip4 = netutil.DistinctNetSet{Subnet: peerSubnetIPv4, Limit: l.subnetLimit}
+			ip6 = netutil.DistinctNetSet{Subnet: peerSubnetIPv6, Limit: l.subnetLimit}
+		)
+		subnet := func(ip net.IP) *netutil.DistinctNetSet {
+			if ip.To4() != nil {
+				return &ip4
+			}
+			return &ip6
+		}
+		for _, pip := range ips {
+			subnet(pip).Add(pip)
+		}
+		if !subnet(ip).Add(ip) {
+			return false
+		}
+	}
+	for _, group := range l.groups {
+		if !group.Contains(ip) {
+			continue
+		}
+		n := uint(0)
+		for _, pip := range ips {
+			if group.Contains(pip) {
+				n++
+			}
+		}
+		if n >= l.groupLimit {
+			return false
+		}
+	}
+	return true
+}
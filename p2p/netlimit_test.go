@@ -0,0 +1,114 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"net"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/p2p/netutil"
+)
+
+func TestNetLimit(t *testing.T) {
+	if newNetLimit(&Config{}) != nil {
+		t.Fatal("limit created without configuration")
+	}
+	group, _ := netutil.ParseNetlist("10.1.0.0/16,10.2.0.0/16")
+	limit := newNetLimit(&Config{
+		MaxPeersPerSubnet:   2,
+		PeerNetGroups:       []*netutil.Netlist{group},
+		MaxPeersPerNetGroup: 2,
+	})
+	peers := make(map[enode.ID]*Peer)
+	addPeer := func(i uint32, ip net.IP) {
+		peers[uintID(i)] = &Peer{rw: &conn{node: newNode(uintID(i), ip)}}
+	}
+	addPeer(1, net.ParseIP("10.0.0.1"))
+	addPeer(2, net.ParseIP("10.0.0.2"))
+	addPeer(3, net.ParseIP("2001:db8::1"))
+	addPeer(4, net.ParseIP("10.1.0.1"))
+	addPeer(5, net.ParseIP("10.2.0.1"))
+
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"10.0.0.3", false},     // /24 is full
+		{"10.0.1.1", true},      // different /24
+		{"2001:db8::2", true},   // /64 has room for one more
+		{"2001:db8:1::1", true}, // different /64
+		{"10.1.1.1", false},     // network group is full
+		{"10.3.0.1", true},      // not in any group
+	}
+	for _, test := range tests {
+		if ok := limit.allow(peers, nil, net.ParseIP(test.ip)); ok != test.want {
+			t.Errorf("allow(%s) = %t, want %t", test.ip, ok, test.want)
+		}
+	}
+	// Pending connections count towards the limits.
+	pending := []net.IP{net.ParseIP("2001:db8::2"), net.ParseIP("10.2.0.2")}
+	if limit.allow(peers, pending, net.ParseIP("2001:db8::3")) {
+		t.Error("allowed IP in subnet filled by pending connection")
+	}
+	if limit.allow(peers, pending, net.ParseIP("10.2.1.1")) {
+		t.Error("allowed IP in network group filled by pending connection")
+	}
+	// Nil limits allow everything.
+	if !(*netLimit)(nil).allow(peers, nil, net.ParseIP("10.0.0.3")) {
+		t.Error("nil limit rejected IP")
+	}
+}
+
+// This test checks that dial candidates are skipped if their subnet is full,
+// counting both connected peers and in-flight dials.
+func TestDialStateNetLimit(t *testing.T) {
+	table := fakeTable{
+		newNode(uintID(1), net.IP{10, 0, 0, 1}),
+		newNode(uintID(2), net.IP{10, 0, 1, 1}),
+		newNode(uintID(4), net.IP{10, 0, 1, 2}),
+	}
+	state := newDialState(enode.ID{}, nil, nil, table, nil, 10, nil)
+	state.netlimit = newNetLimit(&Config{MaxPeersPerSubnet: 1})
+
+	runDialTest(t, dialtest{
+		init: state,
+		rounds: []round{
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(3), net.IP{10, 0, 0, 2})}},
+				},
+				new: []task{
+					&dialTask{flags: dynDialedConn, dest: table[1]},
+					&discoverTask{},
+				},
+			},
+			// The dial to node 2 is still running, so node 4 is skipped.
+			{
+				peers: []*Peer{
+					{rw: &conn{flags: dynDialedConn, node: newNode(uintID(3), net.IP{10, 0, 0, 2})}},
+				},
+				done: []task{
+					&discoverTask{},
+				},
+				new: []task{
+					&discoverTask{},
+				},
+			},
+		},
+	})
+}
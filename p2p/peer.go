@@ -113,6 +113,12 @@ type Peer struct {
 
 	// events receives message send / receive events if set
 	events *event.Feed
+
+	// nodedb persists the quality metrics of the remote node if set
+	nodedb *enode.DB
+
+	// bandwidth limits of the connection, nil if unlimited
+	ingress, egress *trafficLimit
 }
 
 // NewPeer returns a peer for testing purposes.
@@ -178,6 +184,19 @@ func (p *Peer) Inbound() bool {
 
 func newPeer(conn *conn, protocols []Protocol) *Peer {
 	protomap := matchProtocols(protocols, conn.caps, conn)
+	for _, proto := range protomap {
+		proto.msgLimits = newMsgLimits(mclock.System{}, proto.MsgLimits)
+	}
+	// On multiplexed connections, each protocol writes to its own stream.
+	if m, ok := conn.transport.(multiplexer); ok {
+		stream := uint64(baseStream + 1)
+		for _, proto := range protomap {
+			if w := m.stream(stream); w != nil && stream < muxMaxStreams {
+				proto.w, proto.mux = w, true
+				stream++
+			}
+		}
+	}
 	p := &Peer{
 		rw:       conn,
 		running:  protomap,
@@ -194,6 +213,42 @@ func (p *Peer) Log() log.Logger {
 	return p.log
 }
 
+// Quality returns the quality metrics of the remote node persisted by previous
+// sessions, or empty metrics if the node is unknown.
+func (p *Peer) Quality() enode.Quality {
+	if p.nodedb == nil {
+		return enode.Quality{}
+	}
+	return p.nodedb.NodeQuality(p.ID())
+}
+
+// UpdateQuality persists the quality metrics measured on the connection, for
+// them to be taken into account by future dials and sessions.
+func (p *Peer) UpdateQuality(q enode.Quality) {
+	if p.nodedb == nil {
+		return
+	}
+	q.Updated = time.Now()
+	if err := p.nodedb.UpdateNodeQuality(p.ID(), q); err != nil {
+		p.log.Warn("Failed to store peer quality", "err", err)
+	}
+}
+
+// Ban adds the node to the ban list for the given duration and disconnects it.
+// A zero duration bans the node permanently.
+func (p *Peer) Ban(d time.Duration, reason string) {
+	if p.nodedb != nil {
+		b := enode.Ban{ID: p.ID(), Reason: reason}
+		if d > 0 {
+			b.Expires = time.Now().Add(d)
+		}
+		if err := p.nodedb.AddBan(b); err != nil {
+			p.log.Warn("Failed to store peer ban", "err", err)
+		}
+	}
+	p.Disconnect(DiscUselessPeer)
+}
+
 func (p *Peer) run() (remoteRequested bool, err error) {
 	var (
 		writeStart = make(chan struct{}, 1)
@@ -225,6 +280,8 @@ loop:
 			if r, ok := err.(DiscReason); ok {
 				remoteRequested = true
 				reason = r
+			} else if _, ok := err.(*peerError); ok {
+				reason = discReasonForError(err)
 			} else {
 				reason = DiscNetworkError
 			}
@@ -271,6 +328,11 @@ func (p *Peer) readLoop(errc chan<- error) {
 			return
 		}
 		msg.ReceivedAt = time.Now()
+		// Delay reading the next message while the connection is over
+		// its ingress limit.
+		if !p.ingress.wait(msg.Size, p.closed) {
+			return
+		}
 		if err = p.handle(msg); err != nil {
 			errc <- err
 			return
@@ -298,6 +360,10 @@ func (p *Peer) handle(msg Msg) error {
 		if err != nil {
 			return fmt.Errorf("msg code out of range: %v", msg.Code)
 		}
+		if !proto.allowMsg(msg.Code - proto.offset) {
+			msg.Discard()
+			return newPeerError(errMsgRateExceeded, "%s/%d code %d", proto.Name, proto.Version, msg.Code-proto.offset)
+		}
 		select {
 		case proto.in <- msg:
 			return nil
@@ -352,6 +418,7 @@ func (p *Peer) startProtocols(writeStart <-chan struct{}, writeErr chan<- error)
 		proto.closed = p.closed
 		proto.wstart = writeStart
 		proto.werr = writeErr
+		proto.egress = p.egress
 		var rw MsgReadWriter = proto
 		if p.events != nil {
 			rw = newMsgEventer(rw, p.events, p.ID(), proto.Name)
@@ -390,6 +457,10 @@ type protoRW struct {
 	werr   chan<- error    // for write results
 	offset uint64
 	w      MsgWriter
+	mux    bool // w is a stream of a multiplexed connection
+
+	egress    *trafficLimit           // bandwidth limit of the connection, may be nil
+	msgLimits map[uint64]*tokenBucket // rate limits of received messages by code
 }
 
 func (rw *protoRW) WriteMsg(msg Msg) (err error) {
@@ -397,6 +468,14 @@ func (rw *protoRW) WriteMsg(msg Msg) (err error) {
 		return newPeerError(errInvalidMsgCode, "not handled")
 	}
 	msg.Code += rw.offset
+	if !rw.egress.wait(msg.Size, rw.closed) {
+		return ErrShuttingDown
+	}
+	if rw.mux {
+		// Streams interleave their writes, there is no need to wait
+		// for the writes of other protocols.
+		return rw.w.WriteMsg(msg)
+	}
 	select {
 	case <-rw.wstart:
 		err = rw.w.WriteMsg(msg)
@@ -411,6 +490,13 @@ func (rw *protoRW) WriteMsg(msg Msg) (err error) {
 	return err
 }
 
+// allowMsg reports whether a message with the given code may be received
+// under the protocol's message rate limits.
+func (rw *protoRW) allowMsg(code uint64) bool {
+	limit := rw.msgLimits[code]
+	return limit == nil || limit.allow(1)
+}
+
 func (rw *protoRW) ReadMsg() (Msg, error) {
 	select {
 	case msg := <-rw.in:
@@ -120,6 +120,51 @@ func TestPeerProtoEncodeMsg(t *testing.T) {
 	}
 }
 
+func TestPeerMsgLimit(t *testing.T) {
+	proto := Protocol{
+		Name:      "a",
+		Length:    2,
+		MsgLimits: map[uint64]MsgLimit{1: {Rate: 0.001, Burst: 2}},
+		Run: func(peer *Peer, rw MsgReadWriter) error {
+			for {
+				msg, err := rw.ReadMsg()
+				if err != nil {
+					return err
+				}
+				msg.Discard()
+			}
+		},
+	}
+	closer, rw, _, errc := testPeer([]Protocol{proto})
+	defer closer()
+
+	// Messages without a limit and messages within the limit are accepted.
+	for i := 0; i < 5; i++ {
+		if err := SendItems(rw, baseProtocolLength); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := SendItems(rw, baseProtocolLength+1); err != nil {
+			t.Fatalf("write error: %v", err)
+		}
+	}
+	// The next one exceeds the limit.
+	go SendItems(rw, baseProtocolLength+1)
+
+	select {
+	case err := <-errc:
+		if perr, ok := err.(*peerError); !ok || perr.code != errMsgRateExceeded {
+			t.Errorf("wrong error: %v", err)
+		}
+		if reason := discReasonForError(err); reason != DiscProtocolError {
+			t.Errorf("wrong disconnect reason: %v", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("peer not disconnected")
+	}
+}
+
 func TestPeerPing(t *testing.T) {
 	closer, rw, _, _ := testPeer(nil)
 	defer closer()
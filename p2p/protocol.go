@@ -56,6 +56,27 @@ type Protocol struct {
 
 	// Attributes contains protocol specific information for the node record.
 	Attributes []enr.Entry
+
+	// DialFilter is an optional function selecting dynamic dial candidates by
+	// their node record. If any protocol sets a filter, nodes with a known record
+	// are only dialed if at least one of the filters accepts them.
+	DialFilter func(*enode.Node) bool
+
+	// DialCandidates, if non-nil, is a way to tell Server about protocol-specific nodes
+	// that should be dialed. The server continuously reads nodes from the iterator and
+	// attempts to create connections to them. Nodes from this source are only checked
+	// against DialFilter of this protocol, not the filters of other protocols.
+	DialCandidates enode.Iterator
+
+	// DiscoveryTopic, if set, is advertised and searched for in the V5 topic
+	// discovery when it is enabled. The nodes found advertising the topic are
+	// dialed like DialCandidates.
+	DiscoveryTopic string
+
+	// MsgLimits optionally limits the rate at which peers may send messages of
+	// certain codes. The keys are message codes as seen by Run, i.e. starting
+	// at zero. Peers exceeding a limit are disconnected.
+	MsgLimits map[uint64]MsgLimit
 }
 
 func (p Protocol) cap() Cap {
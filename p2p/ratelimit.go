@@ -0,0 +1,157 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+// MsgLimit is a rate limit for received messages of a certain code.
+type MsgLimit struct {
+	Rate  float64 // messages per second
+	Burst int     // number of messages which may be received at once
+}
+
+// tokenBucket is a token bucket rate limiter. Tokens are added at a constant rate
+// until the bucket holds burst tokens.
+type tokenBucket struct {
+	clock mclock.Clock
+	rate  float64 // tokens added per second
+	burst float64 // bucket capacity
+
+	mu     sync.Mutex
+	tokens float64 // may be negative if tokens were taken on credit
+	last   mclock.AbsTime
+}
+
+// newTokenBucket creates a full bucket.
+func newTokenBucket(clock mclock.Clock, rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		clock:  clock,
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   clock.Now(),
+	}
+}
+
+// newByteLimit creates a bucket for a bandwidth limit given in bytes per second.
+// It returns nil if the limit is not positive, i.e. if there is no limit.
+func newByteLimit(clock mclock.Clock, rate int) *tokenBucket {
+	if rate <= 0 {
+		return nil
+	}
+	return newTokenBucket(clock, float64(rate), rate)
+}
+
+// refill adds the tokens accumulated since the last call. b.mu must be held.
+func (b *tokenBucket) refill() {
+	now := b.clock.Now()
+	b.tokens += b.rate * time.Duration(now-b.last).Seconds()
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+}
+
+// allow takes n tokens from the bucket if they are available.
+func (b *tokenBucket) allow(n float64) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	if b.tokens < n {
+		return false
+	}
+	b.tokens -= n
+	return true
+}
+
+// take removes n tokens from the bucket, taking them on credit if they are not
+// available. It returns the time until the bucket is out of debt again.
+func (b *tokenBucket) take(n float64) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.refill()
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
+
+// trafficLimit throttles traffic through a set of byte limits, e.g. the limit of a
+// single connection and the one shared by all connections.
+type trafficLimit struct {
+	clock   mclock.Clock
+	buckets []*tokenBucket
+}
+
+// newTrafficLimit creates a limit from the given buckets, skipping nil buckets.
+// It returns nil if there are no buckets.
+func newTrafficLimit(clock mclock.Clock, buckets ...*tokenBucket) *trafficLimit {
+	l := &trafficLimit{clock: clock}
+	for _, b := range buckets {
+		if b != nil {
+			l.buckets = append(l.buckets, b)
+		}
+	}
+	if len(l.buckets) == 0 {
+		return nil
+	}
+	return l
+}
+
+// wait accounts for n bytes of traffic and blocks until it is within the limits.
+// It returns false if closed is closed while waiting. Calling wait on a nil limit
+// returns immediately.
+func (l *trafficLimit) wait(n uint32, closed <-chan struct{}) bool {
+	if l == nil {
+		return true
+	}
+	var delay time.Duration
+	for _, b := range l.buckets {
+		if d := b.take(float64(n)); d > delay {
+			delay = d
+		}
+	}
+	if delay == 0 {
+		return true
+	}
+	select {
+	case <-l.clock.After(delay):
+		return true
+	case <-closed:
+		return false
+	}
+}
+
+// newMsgLimits creates the message rate limiters of a protocol.
+func newMsgLimits(clock mclock.Clock, limits map[uint64]MsgLimit) map[uint64]*tokenBucket {
+	if len(limits) == 0 {
+		return nil
+	}
+	buckets := make(map[uint64]*tokenBucket, len(limits))
+	for code, limit := range limits {
+		buckets[code] = newTokenBucket(clock, limit.Rate, limit.Burst)
+	}
+	return buckets
+}
@@ -0,0 +1,95 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/mclock"
+)
+
+func TestTokenBucket(t *testing.T) {
+	clock := new(mclock.Simulated)
+	b := newTokenBucket(clock, 10, 5)
+
+	if !b.allow(5) {
+		t.Fatal("full bucket rejected burst")
+	}
+	if b.allow(1) {
+		t.Fatal("empty bucket allowed token")
+	}
+	clock.Run(100 * time.Millisecond)
+	if !b.allow(1) {
+		t.Fatal("token not refilled after 100ms")
+	}
+	if b.allow(1) {
+		t.Fatal("bucket refilled too fast")
+	}
+	if d := b.take(10); d != time.Second {
+		t.Fatalf("wrong delay for tokens taken on credit: got %v, want %v", d, time.Second)
+	}
+	// The bucket never holds more than burst tokens.
+	clock.Run(10 * time.Second)
+	if !b.allow(5) {
+		t.Fatal("refilled bucket rejected burst")
+	}
+	if b.allow(1) {
+		t.Fatal("bucket exceeds burst")
+	}
+}
+
+func TestTrafficLimitWait(t *testing.T) {
+	var (
+		clock   = new(mclock.Simulated)
+		closed  = make(chan struct{})
+		limit   = newTrafficLimit(clock, newByteLimit(clock, 100), nil, newByteLimit(clock, 50))
+		waitErr = make(chan bool, 1)
+	)
+	if newTrafficLimit(clock, newByteLimit(clock, 0)) != nil {
+		t.Fatal("limit without buckets is not nil")
+	}
+	if !(*trafficLimit)(nil).wait(1000, closed) {
+		t.Fatal("nil limit blocked")
+	}
+	if !limit.wait(50, closed) {
+		t.Fatal("traffic within limits blocked")
+	}
+
+	// The second bucket is empty now, so the next wait must be delayed
+	// until it has refilled.
+	go func() { waitErr <- limit.wait(50, closed) }()
+	clock.WaitForTimers(1)
+	clock.Run(999 * time.Millisecond)
+	select {
+	case <-waitErr:
+		t.Fatal("wait returned too early")
+	default:
+	}
+	clock.Run(time.Millisecond)
+	if ok := <-waitErr; !ok {
+		t.Fatal("wait returned false")
+	}
+
+	// Closing the channel interrupts the wait.
+	go func() { waitErr <- limit.wait(1000, closed) }()
+	clock.WaitForTimers(1)
+	close(closed)
+	if ok := <-waitErr; ok {
+		t.Fatal("interrupted wait returned true")
+	}
+}
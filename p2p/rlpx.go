@@ -81,6 +81,7 @@ type rlpx struct {
 
 	rmu, wmu sync.Mutex
 	rw       *rlpxFrameRW
+	mux      *muxer // non-nil if the connection is multiplexed
 }
 
 func newRLPX(fd net.Conn) transport {
@@ -91,11 +92,17 @@ func newRLPX(fd net.Conn) transport {
 func (t *rlpx) ReadMsg() (Msg, error) {
 	t.rmu.Lock()
 	defer t.rmu.Unlock()
+	if t.mux != nil {
+		return t.mux.readMsg()
+	}
 	t.fd.SetReadDeadline(time.Now().Add(frameReadTimeout))
 	return t.rw.ReadMsg()
 }
 
 func (t *rlpx) WriteMsg(msg Msg) error {
+	if t.mux != nil {
+		return t.mux.writeMsg(baseStream, msg)
+	}
 	t.wmu.Lock()
 	defer t.wmu.Unlock()
 	t.fd.SetWriteDeadline(time.Now().Add(frameWriteTimeout))
@@ -114,13 +121,26 @@ func (t *rlpx) close(err error) {
 			// a write deadline. Because of this only try to send
 			// the disconnect reason message if there is no error.
 			if err := t.fd.SetWriteDeadline(time.Now().Add(discWriteTimeout)); err == nil {
-				SendItems(t.rw, discMsg, r)
+				if t.mux != nil {
+					t.mux.sendDisc(r)
+				} else {
+					SendItems(t.rw, discMsg, r)
+				}
 			}
 		}
 	}
 	t.fd.Close()
 }
 
+// stream returns a writer for the given stream of a multiplexed connection.
+// It returns nil if the connection isn't multiplexed.
+func (t *rlpx) stream(id uint64) MsgWriter {
+	if t.mux == nil {
+		return nil
+	}
+	return t.mux.open(id)
+}
+
 func (t *rlpx) doProtoHandshake(our *protoHandshake) (their *protoHandshake, err error) {
 	// Writing our handshake happens concurrently, we prefer
 	// returning the handshake read error. If the remote side
@@ -137,6 +157,10 @@ func (t *rlpx) doProtoHandshake(our *protoHandshake) (their *protoHandshake, err
 	}
 	// If the protocol version supports Snappy encoding, upgrade immediately
 	t.rw.snappy = their.Version >= snappyProtocolVersion
+	// Switch to chunked framing if both sides offered multiplexing.
+	if our.muxVersion() >= muxVersion && their.muxVersion() >= muxVersion {
+		t.mux = newMuxer(t)
+	}
 
 	return their, nil
 }
@@ -551,8 +575,9 @@ var (
 )
 
 // rlpxFrameRW implements a simplified version of RLPx framing.
-// chunked messages are not supported and all headers are equal to
-// zeroHeader.
+// chunked messages are not supported by ReadMsg and WriteMsg and all
+// headers are equal to zeroHeader. Multiplexed connections use the
+// frame level functions to send chunked messages, see muxer.
 //
 // rlpxFrameRW is not safe for concurrent use from multiple goroutines.
 type rlpxFrameRW struct {
@@ -590,27 +615,42 @@ func newRLPXFrameRW(conn io.ReadWriter, s secrets) *rlpxFrameRW {
 }
 
 func (rw *rlpxFrameRW) WriteMsg(msg Msg) error {
+	content, err := rw.encodeMsg(msg)
+	if err != nil {
+		return err
+	}
+	return rw.writeFrame(zeroHeader, content)
+}
+
+// encodeMsg returns the frame content of a message, i.e. the RLP encoded
+// message code followed by the payload, which is compressed if snappy is enabled.
+func (rw *rlpxFrameRW) encodeMsg(msg Msg) ([]byte, error) {
 	ptype, _ := rlp.EncodeToBytes(msg.Code)
 
 	// if snappy is enabled, compress message now
+	if rw.snappy && msg.Size > maxUint24 {
+		return nil, errPlainMessageTooLarge
+	}
+	payload, err := ioutil.ReadAll(msg.Payload)
+	if err != nil {
+		return nil, err
+	}
 	if rw.snappy {
-		if msg.Size > maxUint24 {
-			return errPlainMessageTooLarge
-		}
-		payload, _ := ioutil.ReadAll(msg.Payload)
 		payload = snappy.Encode(nil, payload)
-
-		msg.Payload = bytes.NewReader(payload)
-		msg.Size = uint32(len(payload))
 	}
+	return append(ptype, payload...), nil
+}
+
+// writeFrame writes a single frame with the given header data and content.
+func (rw *rlpxFrameRW) writeFrame(header, content []byte) error {
 	// write header
 	headbuf := make([]byte, 32)
-	fsize := uint32(len(ptype)) + msg.Size
+	fsize := uint32(len(content))
 	if fsize > maxUint24 {
 		return errors.New("message size overflows uint24")
 	}
-	putInt24(fsize, headbuf) // TODO: check overflow
-	copy(headbuf[3:], zeroHeader)
+	putInt24(fsize, headbuf)
+	copy(headbuf[3:16], header)
 	rw.enc.XORKeyStream(headbuf[:16], headbuf[:16]) // first half is now encrypted
 
 	// write header MAC
@@ -622,10 +662,7 @@ func (rw *rlpxFrameRW) WriteMsg(msg Msg) error {
 	// write encrypted frame, updating the egress MAC hash with
 	// the data written to conn.
 	tee := cipher.StreamWriter{S: rw.enc, W: io.MultiWriter(rw.conn, rw.egressMAC)}
-	if _, err := tee.Write(ptype); err != nil {
-		return err
-	}
-	if _, err := io.Copy(tee, msg.Payload); err != nil {
+	if _, err := tee.Write(content); err != nil {
 		return err
 	}
 	if padding := fsize % 16; padding > 0 {
@@ -643,19 +680,29 @@ func (rw *rlpxFrameRW) WriteMsg(msg Msg) error {
 }
 
 func (rw *rlpxFrameRW) ReadMsg() (msg Msg, err error) {
+	_, content, err := rw.readFrame()
+	if err != nil {
+		return msg, err
+	}
+	return rw.decodeMsg(content)
+}
+
+// readFrame reads a single frame. It returns the decrypted header data,
+// including any padding, and the frame content.
+func (rw *rlpxFrameRW) readFrame() (header, content []byte, err error) {
 	// read the header
 	headbuf := make([]byte, 32)
 	if _, err := io.ReadFull(rw.conn, headbuf); err != nil {
-		return msg, err
+		return nil, nil, err
 	}
 	// verify header mac
 	shouldMAC := updateMAC(rw.ingressMAC, rw.macCipher, headbuf[:16])
 	if !hmac.Equal(shouldMAC, headbuf[16:]) {
-		return msg, errors.New("bad header MAC")
+		return nil, nil, errors.New("bad header MAC")
 	}
 	rw.dec.XORKeyStream(headbuf[:16], headbuf[:16]) // first half is now decrypted
 	fsize := readInt24(headbuf)
-	// ignore protocol type for now
+	header = append([]byte(nil), headbuf[3:16]...)
 
 	// read the frame content
 	var rsize = fsize // frame size rounded up to 16 byte boundary
@@ -664,30 +711,34 @@ func (rw *rlpxFrameRW) ReadMsg() (msg Msg, err error) {
 	}
 	framebuf := make([]byte, rsize)
 	if _, err := io.ReadFull(rw.conn, framebuf); err != nil {
-		return msg, err
+		return nil, nil, err
 	}
 
 	// read and validate frame MAC. we can re-use headbuf for that.
 	rw.ingressMAC.Write(framebuf)
 	fmacseed := rw.ingressMAC.Sum(nil)
 	if _, err := io.ReadFull(rw.conn, headbuf[:16]); err != nil {
-		return msg, err
+		return nil, nil, err
 	}
 	shouldMAC = updateMAC(rw.ingressMAC, rw.macCipher, fmacseed)
 	if !hmac.Equal(shouldMAC, headbuf[:16]) {
-		return msg, errors.New("bad frame MAC")
+		return nil, nil, errors.New("bad frame MAC")
 	}
 
 	// decrypt frame content
 	rw.dec.XORKeyStream(framebuf, framebuf)
+	return header, framebuf[:fsize], nil
+}
 
+// decodeMsg decodes the frame content of a message.
+func (rw *rlpxFrameRW) decodeMsg(content []byte) (msg Msg, err error) {
 	// decode message code
-	content := bytes.NewReader(framebuf[:fsize])
-	if err := rlp.Decode(content, &msg.Code); err != nil {
+	r := bytes.NewReader(content)
+	if err := rlp.Decode(r, &msg.Code); err != nil {
 		return msg, err
 	}
-	msg.Size = uint32(content.Len())
-	msg.Payload = content
+	msg.Size = uint32(r.Len())
+	msg.Payload = r
 
 	// if snappy is enabled, verify and decompress message
 	if rw.snappy {
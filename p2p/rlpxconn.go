@@ -0,0 +1,143 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/p2p/enode"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// RLPxConn is an outbound devp2p connection which speaks a single subprotocol. Unlike
+// connections made by Server, it does not run any protocol code. It is meant for tools
+// which need to exchange raw protocol messages with a node, e.g. for testing.
+//
+// Messages of the base protocol are handled by the connection: ReadMsg answers pings
+// and returns the reason of disconnect messages as an error. The message codes used
+// with ReadMsg and WriteMsg are those of the subprotocol, i.e. they do not include the
+// offset of the base protocol.
+type RLPxConn struct {
+	t       *rlpx
+	version uint
+
+	// ReadTimeout is the read deadline for a single message.
+	// It defaults to the timeout used by Server.
+	ReadTimeout time.Duration
+}
+
+// DialRLPx connects to the given node and performs the RLPx handshakes, announcing the
+// given versions of protocol 'name'. The highest version supported by both sides is used
+// for the connection.
+func DialRLPx(dest *enode.Node, prv *ecdsa.PrivateKey, name string, versions []uint) (*RLPxConn, error) {
+	if dest.Pubkey() == nil {
+		return nil, fmt.Errorf("node %v has no secp256k1 key", dest.ID())
+	}
+	addr := &net.TCPAddr{IP: dest.IP(), Port: dest.TCP()}
+	fd, err := net.DialTimeout("tcp", addr.String(), defaultDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	t := newRLPX(fd).(*rlpx)
+	if _, err := t.doEncHandshake(prv, dest.Pubkey()); err != nil {
+		fd.Close()
+		return nil, err
+	}
+	our := &protoHandshake{
+		Version: baseProtocolVersion,
+		Name:    "devp2p-test",
+		ID:      crypto.FromECDSAPub(&prv.PublicKey)[1:],
+	}
+	for _, v := range versions {
+		our.Caps = append(our.Caps, Cap{Name: name, Version: v})
+	}
+	their, err := t.doProtoHandshake(our)
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+	c := &RLPxConn{t: t, ReadTimeout: frameReadTimeout}
+	for _, cap := range their.Caps {
+		for _, v := range versions {
+			if cap.Name == name && cap.Version == v && v > c.version {
+				c.version = v
+			}
+		}
+	}
+	if c.version == 0 {
+		t.close(DiscUselessPeer)
+		return nil, fmt.Errorf("node does not support %s versions %v", name, versions)
+	}
+	fd.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// Version returns the negotiated protocol version.
+func (c *RLPxConn) Version() uint {
+	return c.version
+}
+
+// ReadMsg reads a subprotocol message.
+func (c *RLPxConn) ReadMsg() (Msg, error) {
+	for {
+		msg, err := c.readMsg()
+		if err != nil {
+			return msg, err
+		}
+		switch {
+		case msg.Code == pingMsg:
+			msg.Discard()
+			if err := SendItems(c.t, pongMsg); err != nil {
+				return msg, err
+			}
+		case msg.Code == discMsg:
+			var reason [1]DiscReason
+			// This is the last message. We don't need to discard or
+			// check errors because, the connection will be closed after it.
+			rlp.Decode(msg.Payload, &reason)
+			return msg, reason[0]
+		case msg.Code < baseProtocolLength:
+			msg.Discard()
+		default:
+			msg.Code -= baseProtocolLength
+			return msg, nil
+		}
+	}
+}
+
+func (c *RLPxConn) readMsg() (Msg, error) {
+	c.t.rmu.Lock()
+	defer c.t.rmu.Unlock()
+	c.t.fd.SetReadDeadline(time.Now().Add(c.ReadTimeout))
+	return c.t.rw.ReadMsg()
+}
+
+// WriteMsg sends a subprotocol message. The message code is not checked against the
+// length of the protocol, so messages with invalid codes can be sent.
+func (c *RLPxConn) WriteMsg(msg Msg) error {
+	msg.Code += baseProtocolLength
+	return c.t.WriteMsg(msg)
+}
+
+// Close sends a disconnect message with the given reason and closes the connection.
+func (c *RLPxConn) Close(reason DiscReason) {
+	c.t.close(reason)
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 The go-ethereum Authors
+// This file is part of the go-ethereum library.
+//
+// The go-ethereum library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The go-ethereum library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the go-ethereum library. If not, see <http://www.gnu.org/licenses/>.
+
+package p2p
+
+import "testing"
+
+func TestDialRLPx(t *testing.T) {
+	echo := func(p *Peer, rw MsgReadWriter) error {
+		for {
+			msg, err := rw.ReadMsg()
+			if err != nil {
+				return err
+			}
+			var s string
+			if err := msg.Decode(&s); err != nil {
+				return err
+			}
+			if err := Send(rw, msg.Code+1, s); err != nil {
+				return err
+			}
+		}
+	}
+	srv := &Server{Config: Config{
+		Name:       "test",
+		MaxPeers:   10,
+		ListenAddr: "127.0.0.1:0",
+		PrivateKey: newkey(),
+		Protocols: []Protocol{
+			{Name: "test", Version: 1, Length: 5, Run: echo},
+			{Name: "test", Version: 2, Length: 5, Run: echo},
+		},
+	}}
+	if err := srv.Start(); err != nil {
+		t.Fatal("can't start server:", err)
+	}
+	defer srv.Stop()
+	node := srv.Self()
+
+	// Connect with a matching protocol.
+	c, err := DialRLPx(node, newkey(), "test", []uint{1, 2, 3})
+	if err != nil {
+		t.Fatal("dial error:", err)
+	}
+	if c.Version() != 2 {
+		t.Errorf("wrong negotiated version %d, want 2", c.Version())
+	}
+	if err := Send(c, 3, "hello"); err != nil {
+		t.Fatal("write error:", err)
+	}
+	if err := ExpectMsg(c, 4, "hello"); err != nil {
+		t.Error(err)
+	}
+	// Sending an invalid message code drops the connection.
+	if err := Send(c, 5, "hello"); err != nil {
+		t.Fatal("write error:", err)
+	}
+	if msg, err := c.ReadMsg(); err == nil {
+		t.Errorf("got message %d after invalid message, want disconnect", msg.Code)
+	}
+	c.Close(DiscQuitting)
+
+	// Connect without a matching protocol.
+	if _, err := DialRLPx(node, newkey(), "other", []uint{1}); err == nil {
+		t.Error("dial succeeded without matching protocol")
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"crypto/ecdsa"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"net"
 	"sort"
 	"sync"
@@ -35,6 +36,7 @@ import (
 	"github.com/ethereum/go-ethereum/log"
 	"github.com/ethereum/go-ethereum/p2p/discover"
 	"github.com/ethereum/go-ethereum/p2p/discv5"
+	"github.com/ethereum/go-ethereum/p2p/dnsdisc"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
 	"github.com/ethereum/go-ethereum/p2p/nat"
@@ -50,12 +52,20 @@ const (
 	defaultMaxPendingPeers = 50
 	defaultDialRatio       = 3
 
+	// This is the time the dial candidate mixer waits for a node from the
+	// fairly chosen source before taking one from any other source.
+	discmixTimeout = 5 * time.Second
+
 	// Maximum time allowed for reading a complete message.
 	// This is effectively the amount of time a connection can be idle.
 	frameReadTimeout = 30 * time.Second
 
 	// Maximum amount of time allowed for writing a complete message.
 	frameWriteTimeout = 20 * time.Second
+
+	// Minimum time a peer is kept connected before it may be evicted in
+	// favour of a better performing node.
+	evictionMinAge = time.Minute
 )
 
 var errServerStopped = errors.New("server stopped")
@@ -87,6 +97,10 @@ type Config struct {
 	// protocol should be started or not.
 	DiscoveryV5 bool `toml:",omitempty"`
 
+	// DiscoveryV51 specifies whether the discovery v5.1 protocol should be started
+	// or not. It can run alongside the older V5 protocol on the same UDP socket.
+	DiscoveryV51 bool `toml:",omitempty"`
+
 	// Name sets the node name of this server.
 	// Use common.MakeName to create a name that follows existing conventions.
 	Name string `toml:"-"`
@@ -100,6 +114,19 @@ type Config struct {
 	// protocol.
 	BootstrapNodesV5 []*discv5.Node `toml:",omitempty"`
 
+	// BootstrapNodesV51 are used to establish connectivity
+	// with the rest of the network using the discovery v5.1
+	// protocol.
+	BootstrapNodesV51 []*enode.Node `toml:",omitempty"`
+
+	// DiscoveryDNS contains enrtree:// URLs of DNS node lists. The nodes
+	// of the lists are used as dial candidates, even if NoDiscovery is set.
+	DiscoveryDNS []string `toml:",omitempty"`
+
+	// DiscoveryFile is the path of a JSON file listing enode URLs. The nodes of
+	// the list are used as dial candidates, even if NoDiscovery is set.
+	DiscoveryFile string `toml:",omitempty"`
+
 	// Static nodes are used as pre-configured connections which are always
 	// maintained and re-connected on disconnects.
 	StaticNodes []*enode.Node
@@ -108,11 +135,40 @@ type Config struct {
 	// allowed to connect, even above the peer limit.
 	TrustedNodes []*enode.Node
 
+	// MaxPeerIngress and MaxPeerEgress limit the traffic of a single peer
+	// connection in bytes per second. Connections exceeding a limit are
+	// throttled. Zero means no limit.
+	MaxPeerIngress int `toml:",omitempty"`
+	MaxPeerEgress  int `toml:",omitempty"`
+
+	// MaxIngress and MaxEgress limit the total traffic of all peer connections
+	// in bytes per second. Zero means no limit.
+	MaxIngress int `toml:",omitempty"`
+	MaxEgress  int `toml:",omitempty"`
+
 	// Connectivity can be restricted to certain IP networks.
 	// If this option is set to a non-nil value, only hosts which match one of the
 	// IP networks contained in the list are considered.
 	NetRestrict *netutil.Netlist `toml:",omitempty"`
 
+	// MaxPeersPerSubnet limits the number of peers connected from a single
+	// IPv4 /24 or IPv6 /64 subnet. Trusted and static peers are exempt.
+	// Zero means no limit.
+	MaxPeersPerSubnet int `toml:",omitempty"`
+
+	// PeerNetGroups are lists of networks which are treated as a single network
+	// operator, e.g. all prefixes announced by an autonomous system. At most
+	// MaxPeersPerNetGroup peers are connected from each group. Trusted and
+	// static peers are exempt.
+	PeerNetGroups       []*netutil.Netlist `toml:",omitempty"`
+	MaxPeersPerNetGroup int                `toml:",omitempty"`
+
+	// Multiplex enables stream multiplexing on connections to peers which
+	// support it. The messages of each protocol are sent on a separate stream,
+	// avoiding head-of-line blocking between protocols. Connections to other
+	// peers use regular RLPx framing.
+	Multiplex bool `toml:",omitempty"`
+
 	// NodeDatabase is the path to the database containing the previously seen
 	// live nodes in the network.
 	NodeDatabase string `toml:",omitempty"`
@@ -166,10 +222,17 @@ type Server struct {
 	nodedb       *enode.DB
 	localnode    *enode.LocalNode
 	ntab         discoverTable
+	dnsdisc      *dnsdisc.Client
+	dialFilter   func(*enode.Node) bool // combined DialFilter of all protocols, may be nil
+	netlimit     *netLimit              // peer IP diversity limit, may be nil
+	discmix      *enode.FairMix         // dial candidate sources
+	ingress      *tokenBucket           // global ingress limit, may be nil
+	egress       *tokenBucket           // global egress limit, may be nil
 	listener     net.Listener
 	ourHandshake *protoHandshake
 	lastLookup   time.Time
 	DiscV5       *discv5.Network
+	DiscV51      *discover.UDPv5
 
 	// These are for Peers, PeerCount (and nothing else).
 	peerOp     chan peerOpFunc
@@ -344,6 +407,54 @@ func (srv *Server) RemoveTrustedPeer(node *enode.Node) {
 	}
 }
 
+// Ban adds a node or network to the ban list and disconnects all peers the
+// ban applies to. Banned nodes are neither dialed nor accepted until the ban
+// expires or is removed using Unban. The ban list is kept in the node database.
+func (srv *Server) Ban(b enode.Ban) error {
+	db, err := srv.banList()
+	if err != nil {
+		return err
+	}
+	if err := db.AddBan(b); err != nil {
+		return err
+	}
+	for _, p := range srv.Peers() {
+		if b.Matches(p.ID(), p.Node().IP()) {
+			p.Disconnect(DiscUselessPeer)
+		}
+	}
+	return nil
+}
+
+// Unban removes the ban of the node or network given by b. It reports
+// whether the ban existed.
+func (srv *Server) Unban(b enode.Ban) (bool, error) {
+	db, err := srv.banList()
+	if err != nil {
+		return false, err
+	}
+	return db.RemoveBan(b)
+}
+
+// Bans returns the current ban list.
+func (srv *Server) Bans() ([]enode.Ban, error) {
+	db, err := srv.banList()
+	if err != nil {
+		return nil, err
+	}
+	return db.Bans(), nil
+}
+
+// banList returns the node database holding the ban list.
+func (srv *Server) banList() (*enode.DB, error) {
+	srv.lock.Lock()
+	defer srv.lock.Unlock()
+	if !srv.running {
+		return nil, errServerStopped
+	}
+	return srv.nodedb, nil
+}
+
 // SubscribePeers subscribes the given channel to peer events
 func (srv *Server) SubscribeEvents(ch chan *PeerEvent) event.Subscription {
 	return srv.peerFeed.Subscribe(ch)
@@ -361,6 +472,11 @@ func (srv *Server) Self() *enode.Node {
 	return ln.Node()
 }
 
+// LocalNode returns the local node record.
+func (srv *Server) LocalNode() *enode.LocalNode {
+	return srv.localnode
+}
+
 // Stop terminates the server and all active peer connections.
 // It blocks until all active connections have been closed.
 func (srv *Server) Stop() {
@@ -386,7 +502,7 @@ type sharedUDPConn struct {
 	unhandled chan discover.ReadPacket
 }
 
-// ReadFromUDP implements discv5.conn
+// ReadFromUDP implements the discovery v5 connection interface.
 func (s *sharedUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err error) {
 	packet, ok := <-s.unhandled
 	if !ok {
@@ -400,7 +516,8 @@ func (s *sharedUDPConn) ReadFromUDP(b []byte) (n int, addr *net.UDPAddr, err err
 	return l, packet.Addr, nil
 }
 
-// Close implements discv5.conn
+// Close implements the discovery v5 connection interface. The underlying
+// connection is closed by the listener reading from it.
 func (s *sharedUDPConn) Close() error {
 	return nil
 }
@@ -442,6 +559,8 @@ func (srv *Server) Start() (err error) {
 	srv.removetrusted = make(chan *enode.Node)
 	srv.peerOp = make(chan peerOpFunc)
 	srv.peerOpDone = make(chan struct{})
+	srv.ingress = newByteLimit(mclock.System{}, srv.MaxIngress)
+	srv.egress = newByteLimit(mclock.System{}, srv.MaxEgress)
 
 	if err := srv.setupLocalNode(); err != nil {
 		return err
@@ -451,12 +570,16 @@ func (srv *Server) Start() (err error) {
 			return err
 		}
 	}
+	srv.dialFilter = protocolDialFilter(srv.Protocols)
+	srv.netlimit = newNetLimit(&srv.Config)
 	if err := srv.setupDiscovery(); err != nil {
 		return err
 	}
 
 	dynPeers := srv.maxDialedConns()
-	dialer := newDialState(srv.localnode.ID(), srv.StaticNodes, srv.BootstrapNodes, srv.ntab, dynPeers, srv.NetRestrict)
+	dialer := newDialState(srv.localnode.ID(), srv.StaticNodes, srv.BootstrapNodes, srv.ntab, srv.nodedb, dynPeers, srv.NetRestrict)
+	dialer.filter = srv.dialFilter
+	dialer.netlimit = srv.netlimit
 	srv.loopWG.Add(1)
 	go srv.run(dialer)
 	return nil
@@ -480,6 +603,9 @@ func (srv *Server) setupLocalNode() error {
 	srv.localnode = enode.NewLocalNode(db, srv.PrivateKey)
 	srv.localnode.SetFallbackIP(net.IP{127, 0, 0, 1})
 	srv.localnode.Set(capsByNameAndVersion(srv.ourHandshake.Caps))
+	if srv.Multiplex {
+		srv.localnode.Set(muxEntry{Version: muxVersion})
+	}
 	// TODO: check conflicts
 	for _, p := range srv.Protocols {
 		for _, e := range p.Attributes {
@@ -508,7 +634,38 @@ func (srv *Server) setupLocalNode() error {
 }
 
 func (srv *Server) setupDiscovery() error {
-	if srv.NoDiscovery && !srv.DiscoveryV5 {
+	srv.discmix = enode.NewFairMix(discmixTimeout)
+
+	// Add protocol-specific discovery sources.
+	for _, p := range srv.Protocols {
+		if p.DialCandidates == nil {
+			continue
+		}
+		if p.DialFilter != nil {
+			srv.discmix.AddSource(enode.Filter(p.DialCandidates, p.DialFilter))
+		} else {
+			srv.discmix.AddSource(p.DialCandidates)
+		}
+	}
+	// DNS node lists
+	if len(srv.DiscoveryDNS) > 0 {
+		client, err := dnsdisc.NewClient(dnsdisc.Config{Logger: srv.log}, srv.DiscoveryDNS...)
+		if err != nil {
+			return err
+		}
+		client.Start()
+		srv.dnsdisc = client
+		srv.discmix.AddSource(srv.filterSource(client.RandomNodes()))
+	}
+	// Static node list file
+	if srv.DiscoveryFile != "" {
+		nodes, err := loadNodeFile(srv.DiscoveryFile)
+		if err != nil {
+			return err
+		}
+		srv.discmix.AddSource(srv.filterSource(enode.CycleNodes(nodes)))
+	}
+	if srv.NoDiscovery && !srv.DiscoveryV5 && !srv.DiscoveryV51 {
 		return nil
 	}
 
@@ -529,13 +686,15 @@ func (srv *Server) setupDiscovery() error {
 	}
 	srv.localnode.SetFallbackUDP(realaddr.Port)
 
-	// Discovery V4
-	var unhandled chan discover.ReadPacket
+	// The listeners share the socket. Packets which a listener can't decode are
+	// passed on to the next one, in the order v4, v5.1, v5.
 	var sconn *sharedUDPConn
+
+	// Discovery V4
 	if !srv.NoDiscovery {
-		if srv.DiscoveryV5 {
+		var unhandled chan discover.ReadPacket
+		if srv.DiscoveryV5 || srv.DiscoveryV51 {
 			unhandled = make(chan discover.ReadPacket, 100)
-			sconn = &sharedUDPConn{conn, unhandled}
 		}
 		cfg := discover.Config{
 			PrivateKey:  srv.PrivateKey,
@@ -548,6 +707,38 @@ func (srv *Server) setupDiscovery() error {
 			return err
 		}
 		srv.ntab = ntab
+		srv.discmix.AddSource(enode.Filter(ntab.RandomNodes(), srv.discoveredNodeFilter(ntab)))
+		if unhandled != nil {
+			sconn = &sharedUDPConn{conn, unhandled}
+		}
+	}
+	// Discovery V5.1
+	if srv.DiscoveryV51 {
+		var unhandled chan discover.ReadPacket
+		if srv.DiscoveryV5 {
+			unhandled = make(chan discover.ReadPacket, 100)
+		}
+		cfg := discover.Config{
+			PrivateKey:  srv.PrivateKey,
+			NetRestrict: srv.NetRestrict,
+			Bootnodes:   srv.BootstrapNodesV51,
+			Unhandled:   unhandled,
+		}
+		var ntab *discover.UDPv5
+		var err error
+		if sconn != nil {
+			ntab, err = discover.ListenV5(sconn, srv.localnode, cfg)
+		} else {
+			ntab, err = discover.ListenV5(conn, srv.localnode, cfg)
+		}
+		if err != nil {
+			return err
+		}
+		srv.DiscV51 = ntab
+		srv.discmix.AddSource(enode.Filter(ntab.RandomNodes(), srv.discoveredNodeFilter(ntab)))
+		if unhandled != nil {
+			sconn = &sharedUDPConn{conn, unhandled}
+		}
 	}
 	// Discovery V5
 	if srv.DiscoveryV5 {
@@ -565,10 +756,51 @@ func (srv *Server) setupDiscovery() error {
 			return err
 		}
 		srv.DiscV5 = ntab
+
+		// Advertise and search the discovery topics of the protocols.
+		for _, p := range srv.Protocols {
+			if p.DiscoveryTopic == "" {
+				continue
+			}
+			topic := discv5.Topic(p.DiscoveryTopic)
+			if srv.ListenAddr != "" {
+				go ntab.RegisterTopic(topic, srv.quit)
+			}
+			if p.DialFilter != nil {
+				srv.discmix.AddSource(enode.Filter(ntab.TopicNodes(topic), p.DialFilter))
+			} else {
+				srv.discmix.AddSource(ntab.TopicNodes(topic))
+			}
+		}
 	}
 	return nil
 }
 
+// loadNodeFile reads a JSON list of enode URLs.
+func loadNodeFile(file string) ([]*enode.Node, error) {
+	var urls []string
+	if err := common.LoadJSON(file, &urls); err != nil {
+		return nil, err
+	}
+	nodes := make([]*enode.Node, 0, len(urls))
+	for _, url := range urls {
+		n, err := enode.ParseV4(url)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node URL %s in %s: %v", url, file, err)
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
+
+// filterSource applies the protocol dial filter to a source of dial candidates.
+func (srv *Server) filterSource(it enode.Iterator) enode.Iterator {
+	if srv.dialFilter == nil {
+		return it
+	}
+	return enode.Filter(it, srv.dialFilter)
+}
+
 func (srv *Server) setupListening() error {
 	// Launch the TCP listener.
 	listener, err := net.Listen("tcp", srv.ListenAddr)
@@ -608,6 +840,7 @@ func (srv *Server) run(dialstate dialer) {
 
 	var (
 		peers        = make(map[enode.ID]*Peer)
+		evicted      = make(map[enode.ID]*Peer) // disconnecting peers evicted for better ones
 		inboundCount = 0
 		trusted      = make(map[enode.ID]bool, len(srv.TrustedNodes))
 		taskdone     = make(chan task, maxActiveDialTasks)
@@ -712,8 +945,14 @@ running:
 				c.flags |= trustedConn
 			}
 			// TODO: track in-progress inbound node IDs (pre-Peer) to avoid dialing them.
+			err := srv.encHandshakeChecks(peers, inboundCount, c)
+			if err == DiscTooManyPeers && srv.evictionCandidate(peers, inboundCount, c) != nil {
+				// Room can be made for nodes known to perform better than a connected
+				// peer, which is evicted once the node completes the protocol handshake
+				err = nil
+			}
 			select {
-			case c.cont <- srv.encHandshakeChecks(peers, inboundCount, c):
+			case c.cont <- err:
 			case <-srv.quit:
 				break running
 			}
@@ -721,9 +960,19 @@ running:
 			// At this point the connection is past the protocol handshake.
 			// Its capabilities are known and the remote identity is verified.
 			err := srv.protoHandshakeChecks(peers, inboundCount, c)
+
+			var evict *Peer
+			if err == DiscTooManyPeers {
+				if evict = srv.evictionCandidate(peers, inboundCount, c); evict != nil {
+					err = nil
+				}
+			}
 			if err == nil {
 				// The handshakes are done and it passed all checks.
 				p := newPeer(c, srv.Protocols)
+				p.nodedb = srv.nodedb
+				p.ingress = newTrafficLimit(mclock.System{}, srv.ingress, newByteLimit(mclock.System{}, srv.MaxPeerIngress))
+				p.egress = newTrafficLimit(mclock.System{}, srv.egress, newByteLimit(mclock.System{}, srv.MaxPeerEgress))
 				// If message events are enabled, pass the peerFeed
 				// to the peer
 				if srv.EnableMsgEvents {
@@ -736,6 +985,14 @@ running:
 				if p.Inbound() {
 					inboundCount++
 				}
+				// Drop the peer making room for the new one only now it's added
+				if evict != nil {
+					evict.log.Debug("Evicting p2p peer for better node", "node", c.node.ID())
+					evict.Disconnect(DiscTooManyPeers)
+					delete(peers, evict.ID())
+					inboundCount--
+					evicted[evict.ID()] = evict
+				}
 			}
 			// The dialer logic relies on the assumption that
 			// dial tasks complete after the peer has been added or
@@ -748,6 +1005,12 @@ running:
 		case pd := <-srv.delpeer:
 			// A peer disconnected.
 			d := common.PrettyDuration(mclock.Now() - pd.created)
+			if evicted[pd.ID()] == pd.Peer {
+				// Evicted peers were already removed from the peer set
+				pd.log.Debug("Removed evicted p2p peer", "duration", d, "req", pd.requested, "err", pd.err)
+				delete(evicted, pd.ID())
+				break
+			}
 			pd.log.Debug("Removing p2p peer", "duration", d, "peers", len(peers)-1, "req", pd.requested, "err", pd.err)
 			delete(peers, pd.ID())
 			if pd.Inbound() {
@@ -759,12 +1022,21 @@ running:
 	srv.log.Trace("P2P networking is spinning down")
 
 	// Terminate discovery. If there is a running lookup it will terminate soon.
+	if srv.discmix != nil {
+		srv.discmix.Close()
+	}
 	if srv.ntab != nil {
 		srv.ntab.Close()
 	}
+	if srv.DiscV51 != nil {
+		srv.DiscV51.Close()
+	}
 	if srv.DiscV5 != nil {
 		srv.DiscV5.Close()
 	}
+	if srv.dnsdisc != nil {
+		srv.dnsdisc.Close()
+	}
 	// Disconnect all peers.
 	for _, p := range peers {
 		p.Disconnect(DiscQuitting)
@@ -772,10 +1044,14 @@ running:
 	// Wait for peers to shut down. Pending connections and tasks are
 	// not handled here and will terminate soon-ish because srv.quit
 	// is closed.
-	for len(peers) > 0 {
+	for len(peers)+len(evicted) > 0 {
 		p := <-srv.delpeer
 		p.log.Trace("<-delpeer (spindown)", "remainingTasks", len(runningTasks))
-		delete(peers, p.ID())
+		if evicted[p.ID()] == p.Peer {
+			delete(evicted, p.ID())
+		} else {
+			delete(peers, p.ID())
+		}
 	}
 }
 
@@ -795,20 +1071,60 @@ func (srv *Server) encHandshakeChecks(peers map[enode.ID]*Peer, inboundCount int
 		return DiscTooManyPeers
 	case !c.is(trustedConn) && c.is(inboundConn) && inboundCount >= srv.maxInboundConns():
 		return DiscTooManyPeers
+	case !c.is(trustedConn|staticDialedConn) && !srv.netlimit.allow(peers, nil, c.node.IP()):
+		return DiscTooManyPeers
 	case peers[c.node.ID()] != nil:
 		return DiscAlreadyConnected
 	case c.node.ID() == srv.localnode.ID():
 		return DiscSelf
+	case srv.nodedb != nil && srv.nodedb.CheckBan(c.node.ID(), c.node.IP()) != nil:
+		return DiscUselessPeer
 	default:
 		return nil
 	}
 }
 
+// evictionCandidate selects the connected inbound peer with the lowest measured
+// quality, if the node behind the given inbound connection is known to perform
+// better and disconnecting the peer makes room for it.
+func (srv *Server) evictionCandidate(peers map[enode.ID]*Peer, inboundCount int, c *conn) *Peer {
+	if srv.nodedb == nil || !c.is(inboundConn) || c.is(trustedConn) {
+		return nil
+	}
+	score := srv.nodedb.NodeQuality(c.node.ID()).Score()
+	if score <= 0 {
+		return nil
+	}
+	var (
+		worst      *Peer
+		worstScore float64
+	)
+	for _, p := range peers {
+		if !p.Inbound() || p.rw.is(trustedConn) || time.Duration(mclock.Now()-p.created) < evictionMinAge {
+			continue
+		}
+		if s := srv.nodedb.NodeQuality(p.ID()).Score(); worst == nil || s < worstScore {
+			worst, worstScore = p, s
+		}
+	}
+	if worst == nil || worstScore >= score {
+		return nil
+	}
+	// Ensure the connection passes all checks once the peer is gone
+	delete(peers, worst.ID())
+	err := srv.encHandshakeChecks(peers, inboundCount-1, c)
+	peers[worst.ID()] = worst
+	if err != nil {
+		return nil
+	}
+	return worst
+}
+
 func (srv *Server) maxInboundConns() int {
 	return srv.MaxPeers - srv.maxDialedConns()
 }
 func (srv *Server) maxDialedConns() int {
-	if srv.NoDiscovery || srv.NoDial {
+	if srv.NoDial || !srv.hasDialCandidates() {
 		return 0
 	}
 	r := srv.DialRatio
@@ -818,6 +1134,20 @@ func (srv *Server) maxDialedConns() int {
 	return srv.MaxPeers / r
 }
 
+// hasDialCandidates reports whether any source of dynamic dial candidates
+// is configured.
+func (srv *Server) hasDialCandidates() bool {
+	if !srv.NoDiscovery || srv.DiscoveryV51 || len(srv.DiscoveryDNS) > 0 || srv.DiscoveryFile != "" {
+		return true
+	}
+	for _, p := range srv.Protocols {
+		if p.DialCandidates != nil || (srv.DiscoveryV5 && p.DiscoveryTopic != "") {
+			return true
+		}
+	}
+	return false
+}
+
 // listenLoop runs in its own goroutine and accepts
 // inbound connections.
 func (srv *Server) listenLoop() {
@@ -897,6 +1227,11 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 	if !running {
 		return errServerStopped
 	}
+	// Reject connections from banned networks before spending any
+	// effort on them.
+	if tcp, ok := c.fd.RemoteAddr().(*net.TCPAddr); ok && srv.nodedb != nil && srv.nodedb.CheckBan(enode.ID{}, tcp.IP) != nil {
+		return errBanned
+	}
 	// If dialing, figure out the remote public key.
 	var dialPubkey *ecdsa.PublicKey
 	if dialDest != nil {
@@ -929,8 +1264,13 @@ func (srv *Server) setupConn(c *conn, flags connFlag, dialDest *enode.Node) erro
 		clog.Trace("Rejected peer before protocol handshake", "err", err)
 		return err
 	}
-	// Run the protocol handshake
-	phs, err := c.doProtoHandshake(srv.ourHandshake)
+	// Run the protocol handshake. Multiplexing is offered to all inbound
+	// connections, but only to dialed nodes which advertise it.
+	ourHandshake := srv.ourHandshake
+	if srv.Multiplex && (dialDest == nil || nodeMuxVersion(dialDest) >= muxVersion) {
+		ourHandshake = ourHandshake.withMux()
+	}
+	phs, err := c.doProtoHandshake(ourHandshake)
 	if err != nil {
 		clog.Trace("Failed proto handshake", "err", err)
 		return err
@@ -19,14 +19,20 @@ package p2p
 import (
 	"crypto/ecdsa"
 	"errors"
+	"io/ioutil"
 	"math/rand"
 	"net"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/common/mclock"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/log"
+	"github.com/ethereum/go-ethereum/p2p/discover"
+	"github.com/ethereum/go-ethereum/p2p/discv5"
 	"github.com/ethereum/go-ethereum/p2p/enode"
 	"github.com/ethereum/go-ethereum/p2p/enr"
 	"golang.org/x/crypto/sha3"
@@ -86,6 +92,148 @@ func startTestServer(t *testing.T, remoteKey *ecdsa.PublicKey, pf func(*Peer)) *
 	return server
 }
 
+// This test checks that discovery v5 and v5.1 can share the UDP socket, with and
+// without discovery v4.
+func TestServerDiscoveryV5Sharing(t *testing.T) {
+	for _, nodisc := range []bool{false, true} {
+		srv := &Server{Config: Config{
+			PrivateKey:   newkey(),
+			MaxPeers:     10,
+			ListenAddr:   "127.0.0.1:0",
+			NoDiscovery:  nodisc,
+			DiscoveryV5:  true,
+			DiscoveryV51: true,
+		}}
+		if err := srv.Start(); err != nil {
+			t.Fatalf("NoDiscovery %t: could not start server: %v", nodisc, err)
+		}
+		// Discovery v5.1 answers pings.
+		client51, db := newV51Client(t)
+		if err := client51.Ping(srv.DiscV51.Self()); err != nil {
+			t.Errorf("NoDiscovery %t: discovery v5.1 ping failed: %v", nodisc, err)
+		}
+		client51.Close()
+		db.Close()
+
+		// Discovery v5 adds a node running a lookup against it to its table.
+		client5 := newV5Client(t)
+		if err := client5.SetFallbackNodes([]*discv5.Node{srv.DiscV5.Self()}); err != nil {
+			t.Fatal(err)
+		}
+		client5.Lookup(client5.Self().ID)
+		if !waitForV5Node(srv.DiscV5, client5.Self().ID, 5*time.Second) {
+			t.Errorf("NoDiscovery %t: discovery v5 didn't learn about client", nodisc)
+		}
+		client5.Close()
+		srv.Stop()
+	}
+}
+
+func newV51Client(t *testing.T) (*discover.UDPv5, *enode.DB) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := newkey()
+	db, _ := enode.OpenDB("")
+	ln := enode.NewLocalNode(db, key)
+	ln.SetStaticIP(net.IP{127, 0, 0, 1})
+	ln.SetFallbackUDP(conn.LocalAddr().(*net.UDPAddr).Port)
+	client, err := discover.ListenV5(conn, ln, discover.Config{PrivateKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, db
+}
+
+func waitForV5Node(net *discv5.Network, id discv5.NodeID, timeout time.Duration) bool {
+	buf := make([]*discv5.Node, 16)
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		for _, n := range buf[:net.ReadRandomNodes(buf)] {
+			if n.ID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func newV5Client(t *testing.T) *discv5.Network {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IP{127, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := discv5.ListenUDP(newkey(), conn, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// Tests that the nodes listed in the discovery file are dialed.
+func TestServerDiscoveryFile(t *testing.T) {
+	remote := &Server{Config: Config{PrivateKey: newkey(), MaxPeers: 10, ListenAddr: "127.0.0.1:0", NoDiscovery: true}}
+	if err := remote.Start(); err != nil {
+		t.Fatalf("can't start remote server: %v", err)
+	}
+	defer remote.Stop()
+
+	dir, err := ioutil.TempDir("", "p2p-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "nodes.json")
+	if err := ioutil.WriteFile(file, []byte(`["`+remote.Self().String()+`"]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	srv := &Server{Config: Config{PrivateKey: newkey(), MaxPeers: 10, NoDiscovery: true, DiscoveryFile: file}}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("can't start server: %v", err)
+	}
+	defer srv.Stop()
+
+	events := make(chan *PeerEvent, 1)
+	sub := srv.SubscribeEvents(events)
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case ev := <-events:
+			if ev.Type == PeerEventTypeAdd && ev.Peer == remote.Self().ID() {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("node from discovery file not dialed")
+		}
+	}
+}
+
+// Tests that dial slots are only reserved if there is a source of dial candidates.
+func TestServerDialSlots(t *testing.T) {
+	tests := []struct {
+		config Config
+		want   int
+	}{
+		{Config{MaxPeers: 30}, 10},
+		{Config{MaxPeers: 30, NoDial: true}, 0},
+		{Config{MaxPeers: 30, NoDiscovery: true}, 0},
+		{Config{MaxPeers: 30, NoDiscovery: true, DiscoveryV5: true}, 0},
+		{Config{MaxPeers: 30, NoDiscovery: true, DiscoveryV51: true}, 10},
+		{Config{MaxPeers: 30, NoDiscovery: true, DiscoveryDNS: []string{"enrtree://"}}, 10},
+		{Config{MaxPeers: 30, NoDiscovery: true, DiscoveryFile: "nodes.json"}, 10},
+		{Config{MaxPeers: 30, NoDiscovery: true, Protocols: []Protocol{{DialCandidates: enode.IterNodes(nil)}}}, 10},
+		{Config{MaxPeers: 30, NoDiscovery: true, Protocols: []Protocol{{DiscoveryTopic: "foo"}}}, 0},
+		{Config{MaxPeers: 30, NoDiscovery: true, DiscoveryV5: true, Protocols: []Protocol{{DiscoveryTopic: "foo"}}}, 10},
+	}
+	for i, test := range tests {
+		srv := &Server{Config: test.config}
+		if n := srv.maxDialedConns(); n != test.want {
+			t.Errorf("test %d: dial slots mismatch: have %d, want %d", i, n, test.want)
+		}
+	}
+}
+
 func TestServerListen(t *testing.T) {
 	// start the test server
 	connected := make(chan *Peer)
@@ -414,6 +562,150 @@ func TestServerAtCap(t *testing.T) {
 	}
 }
 
+func TestServerEviction(t *testing.T) {
+	srv := &Server{
+		Config: Config{
+			PrivateKey: newkey(),
+			MaxPeers:   10,
+			NoDial:     true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id enode.ID) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&newkey().PublicKey, fd)
+		node := enode.SignNull(new(enr.Record), id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	// Fill up the peer set, with one of the peers known to misbehave
+	badID := randomID()
+	srv.nodedb.UpdateNodeQuality(badID, enode.Quality{Invalid: 5, Updated: time.Now()})
+
+	for i := 0; i < 10; i++ {
+		id := randomID()
+		if i == 0 {
+			id = badID
+		}
+		if err := srv.checkpoint(newconn(id), srv.addpeer); err != nil {
+			t.Fatalf("could not add conn %d: %v", i, err)
+		}
+	}
+	// Fresh peers should not be evicted
+	goodID := randomID()
+	srv.nodedb.UpdateNodeQuality(goodID, enode.Quality{Throughput: 10, Updated: time.Now()})
+
+	if err := srv.checkpoint(newconn(goodID), srv.posthandshake); err != DiscTooManyPeers {
+		t.Fatal("wrong error for fresh peers:", err)
+	}
+	srv.peerOp <- func(peers map[enode.ID]*Peer) {
+		for _, p := range peers {
+			p.created -= mclock.AbsTime(2 * evictionMinAge)
+		}
+	}
+	<-srv.peerOpDone
+
+	// Unknown nodes should not replace anyone, better ones the worst peer
+	if err := srv.checkpoint(newconn(randomID()), srv.posthandshake); err != DiscTooManyPeers {
+		t.Fatal("wrong error for unknown node:", err)
+	}
+	conn := newconn(goodID)
+	if err := srv.checkpoint(conn, srv.posthandshake); err != nil {
+		t.Fatal("unexpected error for better node:", err)
+	}
+	// The worst peer must only be evicted once the better one is added
+	connected := func(id enode.ID) bool {
+		for _, p := range srv.Peers() {
+			if p.ID() == id {
+				return true
+			}
+		}
+		return false
+	}
+	if !connected(badID) {
+		t.Fatal("misbehaving peer evicted before the protocol handshake")
+	}
+	if err := srv.checkpoint(conn, srv.addpeer); err != nil {
+		t.Fatal("unexpected error adding better node:", err)
+	}
+	if connected(badID) {
+		t.Fatal("misbehaving peer not evicted")
+	}
+	if !connected(goodID) || srv.PeerCount() != 10 {
+		t.Fatalf("better node not added: connected %v, peers %d", connected(goodID), srv.PeerCount())
+	}
+}
+
+func TestServerBan(t *testing.T) {
+	srv := &Server{
+		Config: Config{
+			PrivateKey: newkey(),
+			MaxPeers:   10,
+			NoDial:     true,
+		},
+	}
+	if err := srv.Start(); err != nil {
+		t.Fatalf("could not start: %v", err)
+	}
+	defer srv.Stop()
+
+	newconn := func(id enode.ID, ip net.IP) *conn {
+		fd, _ := net.Pipe()
+		tx := newTestTransport(&newkey().PublicKey, fd)
+		var r enr.Record
+		r.Set(enr.IP(ip))
+		node := enode.SignNull(&r, id)
+		return &conn{fd: fd, transport: tx, flags: inboundConn, node: node, cont: make(chan error)}
+	}
+	var (
+		bannedID        = randomID()
+		bannedIP        = net.IP{10, 1, 2, 3}
+		otherIP         = net.IP{10, 2, 2, 3}
+		_, bannedNet, _ = net.ParseCIDR("10.1.0.0/16")
+	)
+	if err := srv.checkpoint(newconn(bannedID, otherIP), srv.addpeer); err != nil {
+		t.Fatalf("could not add conn: %v", err)
+	}
+
+	// Banning the node disconnects it.
+	if err := srv.Ban(enode.Ban{ID: bannedID, Reason: "test"}); err != nil {
+		t.Fatalf("ban failed: %v", err)
+	}
+	for start := time.Now(); srv.PeerCount() > 0; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 2*time.Second {
+			t.Fatal("banned peer not disconnected")
+		}
+	}
+	if err := srv.checkpoint(newconn(bannedID, otherIP), srv.posthandshake); err != DiscUselessPeer {
+		t.Fatal("wrong error for banned node:", err)
+	}
+
+	// Network bans apply to all nodes in the network.
+	if err := srv.Ban(enode.Ban{Net: bannedNet}); err != nil {
+		t.Fatalf("ban failed: %v", err)
+	}
+	if err := srv.checkpoint(newconn(randomID(), bannedIP), srv.posthandshake); err != DiscUselessPeer {
+		t.Fatal("wrong error for node in banned network:", err)
+	}
+	if err := srv.checkpoint(newconn(randomID(), otherIP), srv.posthandshake); err != nil {
+		t.Fatal("unexpected error for node outside of banned network:", err)
+	}
+	if bans, _ := srv.Bans(); len(bans) != 2 {
+		t.Fatalf("wrong number of bans: got %d, want 2", len(bans))
+	}
+
+	// Removing the ban lets the node connect again.
+	if ok, err := srv.Unban(enode.Ban{ID: bannedID}); !ok || err != nil {
+		t.Fatalf("unban failed: %t, %v", ok, err)
+	}
+	if err := srv.checkpoint(newconn(bannedID, otherIP), srv.posthandshake); err != nil {
+		t.Fatal("unexpected error for unbanned node:", err)
+	}
+}
+
 func TestServerPeerLimits(t *testing.T) {
 	srvkey := newkey()
 	clientkey := newkey()